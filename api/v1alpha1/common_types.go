@@ -39,3 +39,8 @@ type IPSubnetv4Str string
 // +kubebuilder:validation:Pattern="^(([0-9a-fA-F]{1,4}:){7,7}[0-9a-fA-F]{1,4}|([0-9a-fA-F]{1,4}:){1,7}:|([0-9a-fA-F]{1,4}:){1,6}:[0-9a-fA-F]{1,4}|([0-9a-fA-F]{1,4}:){1,5}(:[0-9a-fA-F]{1,4}){1,2}|([0-9a-fA-F]{1,4}:){1,4}(:[0-9a-fA-F]{1,4}){1,3}|([0-9a-fA-F]{1,4}:){1,3}(:[0-9a-fA-F]{1,4}){1,4}|([0-9a-fA-F]{1,4}:){1,2}(:[0-9a-fA-F]{1,4}){1,5}|[0-9a-fA-F]{1,4}:((:[0-9a-fA-F]{1,4}){1,6})|:((:[0-9a-fA-F]{1,4}){1,7}|:))/([0-9]|[0-9][0-9]|1[0-1][0-9]|12[0-8])$"
 // IPSubnetv6 is used for validation of an IP subnet.
 type IPSubnetv6Str string
+
+// IPExclusionStr is a single IP address ("192.168.0.10"), an inclusive range
+// of IP addresses ("192.168.0.10-192.168.0.20") or a subnet in CIDR notation
+// ("192.168.0.16/28") that must not be allocated.
+type IPExclusionStr string
@@ -0,0 +1,79 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// GlobalIPPoolKind is the kind used by the IPClaims and the CAPI
+	// IPAddressClaims to reference a GlobalIPPool.
+	GlobalIPPoolKind = "GlobalIPPool"
+)
+
+// GlobalIPPoolSpec defines the desired state of GlobalIPPool.
+type GlobalIPPoolSpec struct {
+	IPPoolSpec `json:",inline"`
+
+	// NamespaceSelector restricts the namespaces whose claims can consume the
+	// GlobalIPPool. The claims of all namespaces can consume it if unset.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:path=globalippools,scope=Cluster,categories=cluster-api,shortName=gipp;globalippool;m3gipp;m3globalippool;m3globalippools
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description="GlobalIPPool is reconciled and can serve claims"
+// +kubebuilder:printcolumn:name="Total",type="string",JSONPath=".status.capacity.total",description="Number of addresses in the pool"
+// +kubebuilder:printcolumn:name="Free",type="string",JSONPath=".status.capacity.free",description="Number of free addresses in the pool"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of GlobalIPPool"
+// GlobalIPPool is the Schema for the cluster-scoped globalippools API. It
+// behaves as an IPPool whose addresses can be claimed from any namespace.
+type GlobalIPPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GlobalIPPoolSpec `json:"spec,omitempty"`
+	Status IPPoolStatus     `json:"status,omitempty"`
+}
+
+// GetConditions returns the list of conditions of the GlobalIPPool.
+func (m *GlobalIPPool) GetConditions() []metav1.Condition {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the conditions of the GlobalIPPool.
+func (m *GlobalIPPool) SetConditions(conditions []metav1.Condition) {
+	m.Status.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// GlobalIPPoolList contains a list of GlobalIPPool.
+type GlobalIPPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GlobalIPPool `json:"items"`
+}
+
+func init() {
+	objectTypes = append(objectTypes, &GlobalIPPool{}, &GlobalIPPoolList{})
+}
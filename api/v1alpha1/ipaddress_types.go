@@ -46,6 +46,17 @@ type IPAddressSpec struct {
 	// Address contains the IP address
 	Address IPAddressStr `json:"address"`
 
+	// PoolEntry is the name of the pool entry the address was allocated
+	// from, when the entry is named.
+	// +optional
+	PoolEntry string `json:"poolEntry,omitempty"`
+
+	// Subnet contains the subnet allocated to an IPClaim with a prefix
+	// length, in CIDR notation. Address is then the network address of the
+	// subnet and Prefix its prefix length.
+	// +optional
+	Subnet *IPSubnetStr `json:"subnet,omitempty"`
+
 	// DNSServers is the list of dns servers
 	DNSServers []IPAddressStr `json:"dnsServers,omitempty"`
 }
@@ -25,6 +25,55 @@ const (
 	// IPClaimFinalizer allows IPClaimReconciler to clean up resources
 	// associated with IPClaim before removing it from the apiserver.
 	IPClaimFinalizer = "ipclaim.ipam.metal3.io"
+
+	// IPClaimSecondaryFinalizer allows the IPPool referenced as secondary
+	// pool of a dual-stack IPClaim to release its address before the IPClaim
+	// is removed from the apiserver.
+	IPClaimSecondaryFinalizer = "ipclaim.ipam.metal3.io/secondary"
+
+	// RequestedAddressAnnotation requests a specific IP address for an
+	// IPClaim, or a CAPI IPAddressClaim.
+	//
+	// Deprecated: for an IPClaim, use Spec.RequestedAddress instead. The
+	// annotation is only used when Spec.RequestedAddress is unset.
+	RequestedAddressAnnotation = "ipAddress"
+)
+
+// IPClaim condition types.
+const (
+	// IPClaimReadyCondition reports whether the IPClaim holds its addresses.
+	IPClaimReadyCondition = "Ready"
+)
+
+// IPClaim condition reasons. The Ready condition of the CAPI IPAddressClaims
+// uses the same reasons.
+const (
+	// IPClaimAddressAllocatedReason is used when the addresses of the claim
+	// are allocated.
+	IPClaimAddressAllocatedReason = "AddressAllocated"
+
+	// IPClaimAllocationFailedReason is used when the allocation failed for
+	// another reason than the ones below, for example an invalid request.
+	IPClaimAllocationFailedReason = "AllocationFailed"
+
+	// IPClaimPoolExhaustedReason is used when no address is left in the pool.
+	IPClaimPoolExhaustedReason = "PoolExhausted"
+
+	// IPClaimRequestedIPUnavailableReason is used when the requested address
+	// is already in use.
+	IPClaimRequestedIPUnavailableReason = "RequestedIPUnavailable"
+
+	// IPClaimPreAllocationOutOfBoundsReason is used when the address
+	// pre-allocated to the claim is not within the bounds of the pool.
+	IPClaimPreAllocationOutOfBoundsReason = "PreAllocationOutOfBounds"
+
+	// IPClaimPoolNotFoundReason is used when the pool referenced by the claim
+	// does not exist.
+	IPClaimPoolNotFoundReason = "PoolNotFound"
+
+	// IPClaimQuotaExceededReason is used when the allocation would exceed an
+	// allocation quota of the pool.
+	IPClaimQuotaExceededReason = "QuotaExceeded"
 )
 
 // IPClaimSpec defines the desired state of IPClaim.
@@ -32,6 +81,44 @@ type IPClaimSpec struct {
 
 	// Pool is the IPPool this was generated from.
 	Pool corev1.ObjectReference `json:"pool"`
+
+	// SecondaryPool is the IPPool of the other IP family for a dual-stack
+	// IPClaim. The IPClaim gets an address from both pools, or none.
+	// +optional
+	SecondaryPool *corev1.ObjectReference `json:"secondaryPool,omitempty"`
+
+	// PrefixLength requests a subnet of this prefix length instead of a
+	// single address. The subnet is an aligned block carved out of the
+	// Subnet of one of the pool entries.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=128
+	// +optional
+	PrefixLength int `json:"prefixLength,omitempty"`
+
+	// RequestedAddress is the IP address to allocate from Pool. It must be
+	// within the bounds of the pool and not pre-allocated to another claim.
+	// It cannot be set together with PrefixLength.
+	// +optional
+	RequestedAddress *IPAddressStr `json:"requestedAddress,omitempty"`
+
+	// PoolEntrySelector selects, by their labels, the pool entries of the
+	// IPPool the address is allocated from. All the entries are considered
+	// when unset.
+	// +optional
+	PoolEntrySelector *metav1.LabelSelector `json:"poolEntrySelector,omitempty"`
+
+	// ReclaimPolicy overrides the ReclaimPolicy of the IPPool for this
+	// IPClaim.
+	// +kubebuilder:validation:Enum=delete;retain
+	// +optional
+	ReclaimPolicy ReclaimPolicy `json:"reclaimPolicy,omitempty"`
+
+	// Priority orders the IPClaims waiting for an address from the same
+	// IPPool. The IPClaims of higher priority are served first, and the
+	// IPClaims of the same priority in the order of their creation.
+	// Defaults to 0.
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
 }
 
 // IPClaimStatus defines the observed state of IPClaim.
@@ -40,8 +127,20 @@ type IPClaimStatus struct {
 	// Address is the IPAddress that was generated for this claim.
 	Address *corev1.ObjectReference `json:"address,omitempty"`
 
+	// SecondaryAddress is the IPAddress that was generated from the
+	// secondary pool of a dual-stack IPClaim.
+	SecondaryAddress *corev1.ObjectReference `json:"secondaryAddress,omitempty"`
+
 	// ErrorMessage contains the error message
 	ErrorMessage *string `json:"errorMessage,omitempty"`
+
+	// Conditions defines the current state of the IPClaim. The known
+	// condition type is Ready.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -49,6 +148,8 @@ type IPClaimStatus struct {
 // +kubebuilder:storageversion
 // +kubebuilder:subresource:status
 // +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description="IPClaim holds its addresses"
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason",description="Reason of the Ready condition"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of Metal3IPClaim"
 // IPClaim is the Schema for the ipclaims API.
 type IPClaim struct {
@@ -59,6 +160,16 @@ type IPClaim struct {
 	Status IPClaimStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the list of conditions of the IPClaim.
+func (m *IPClaim) GetConditions() []metav1.Condition {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the conditions of the IPClaim.
+func (m *IPClaim) SetConditions(conditions []metav1.Condition) {
+	m.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // IPClaimList contains a list of IPClaim.
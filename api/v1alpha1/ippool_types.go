@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -24,6 +25,10 @@ const (
 	// IPPoolFinalizer allows IPPoolReconciler to clean up resources
 	// associated with IPPool before removing it from the apiserver.
 	IPPoolFinalizer = "ippool.ipam.metal3.io"
+
+	// IPPoolKind is the claim kind of the IPAddress holding the subnet a
+	// child IPPool obtained from its parent IPPool.
+	IPPoolKind = "IPPool"
 )
 
 // AllocationStrategy defines the strategy for IP address allocation from a pool.
@@ -34,12 +39,123 @@ const (
 	AllocationStrategySequential AllocationStrategy = "sequential"
 	// AllocationStrategyRandom allocates IPs randomly from available pool addresses.
 	AllocationStrategyRandom AllocationStrategy = "random"
+	// AllocationStrategyLeastRecentlyReleased allocates the first IP never
+	// used, or else the IP released the longest time ago.
+	AllocationStrategyLeastRecentlyReleased AllocationStrategy = "leastRecentlyReleased"
+)
+
+// ReclaimPolicy defines what happens to the address of a deleted claim.
+type ReclaimPolicy string
+
+const (
+	// ReclaimPolicyDelete releases the address of a deleted claim (default).
+	ReclaimPolicyDelete ReclaimPolicy = "delete"
+	// ReclaimPolicyRetain keeps the address of a deleted claim reserved for
+	// the next claim of the same name.
+	ReclaimPolicyRetain ReclaimPolicy = "retain"
+)
+
+// QuotaScope defines how an allocation quota groups the claims.
+type QuotaScope string
+
+const (
+	// QuotaScopeNamespace limits the allocations of the claims of each
+	// namespace.
+	QuotaScopeNamespace QuotaScope = "Namespace"
+	// QuotaScopeCluster limits the allocations of the claims of each cluster,
+	// identified by the cluster.x-k8s.io/cluster-name label of the claims.
+	QuotaScopeCluster QuotaScope = "Cluster"
+	// QuotaScopeSelector limits the allocations of all the claims matching a
+	// label selector.
+	QuotaScopeSelector QuotaScope = "Selector"
+)
+
+// AllocationStorage defines where the allocations of an IPPool are recorded.
+type AllocationStorage string
+
+const (
+	// AllocationStorageStatus records the allocations in the status of the
+	// IPPool, in addition to the IPAddress objects (default).
+	AllocationStorageStatus AllocationStorage = "status"
+	// AllocationStorageIPAddresses records the allocations in the IPAddress
+	// objects only, the status of the IPPool keeps the aggregates.
+	AllocationStorageIPAddresses AllocationStorage = "ipAddresses"
+)
+
+// PoolSizeUnknown is reported in the capacity fields of the IPPool status
+// when the size of a pool entry cannot be computed, because the entry is
+// unbounded or too large.
+const PoolSizeUnknown = "unknown"
+
+// DefaultNearlyExhaustedThreshold is the utilization percentage above which
+// an IPPool is reported as nearly exhausted when the spec does not set one.
+const DefaultNearlyExhaustedThreshold = 90
+
+// IPPool condition types.
+const (
+	// IPPoolReadyCondition reports whether the IPPool was successfully
+	// reconciled and can serve claims.
+	IPPoolReadyCondition = "Ready"
+
+	// IPPoolExhaustedCondition reports whether the IPPool has no free
+	// addresses left.
+	IPPoolExhaustedCondition = "Exhausted"
+
+	// IPPoolNearlyExhaustedCondition reports whether the utilization of the
+	// IPPool reached Spec.NearlyExhaustedThreshold.
+	IPPoolNearlyExhaustedCondition = "NearlyExhausted"
+
+	// IPPoolDegradedCondition reports whether the IPPool failed to reconcile,
+	// for example because the referenced Cluster does not exist.
+	IPPoolDegradedCondition = "Degraded"
+)
+
+// IPPool condition reasons.
+const (
+	// IPPoolReconciledReason is used when the IPPool was reconciled without error.
+	IPPoolReconciledReason = "Reconciled"
+
+	// IPPoolReconcileFailedReason is used when the allocations of the IPPool
+	// could not be updated.
+	IPPoolReconcileFailedReason = "ReconcileFailed"
+
+	// IPPoolClusterNotFoundReason is used when the Cluster referenced by
+	// Spec.ClusterName does not exist.
+	IPPoolClusterNotFoundReason = "ClusterNotFound"
+
+	// IPPoolAddressesAvailableReason is used when the IPPool has free addresses.
+	IPPoolAddressesAvailableReason = "AddressesAvailable"
+
+	// IPPoolExhaustedReason is used when the IPPool has no free addresses.
+	IPPoolExhaustedReason = "PoolExhausted"
+
+	// IPPoolCapacityUnknownReason is used when the size of the IPPool cannot
+	// be computed, because one of its entries is unbounded or too large.
+	IPPoolCapacityUnknownReason = "CapacityUnknown"
+
+	// IPPoolUtilizationAboveThresholdReason is used when the utilization of
+	// the IPPool reached Spec.NearlyExhaustedThreshold.
+	IPPoolUtilizationAboveThresholdReason = "UtilizationAboveThreshold"
+
+	// IPPoolUtilizationBelowThresholdReason is used when the utilization of
+	// the IPPool is below Spec.NearlyExhaustedThreshold.
+	IPPoolUtilizationBelowThresholdReason = "UtilizationBelowThreshold"
 )
 
 // MetaDataIPAddress contains the info to render th ip address. It is IP-version
 // agnostic.
 type Pool struct {
 
+	// Name identifies the pool entry in the status of the IPPool and in the
+	// IPAddresses allocated from it. It is unique within the IPPool.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Labels of the pool entry, that the claims select with their pool
+	// entry selector.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
 	// Start is the first ip address that can be rendered
 	Start *IPAddressStr `json:"start,omitempty"`
 
@@ -61,6 +177,51 @@ type Pool struct {
 
 	// DNSServers is the list of dns servers
 	DNSServers []IPAddressStr `json:"dnsServers,omitempty"`
+
+	// Exclusions is the list of addresses, ranges and subnets of this pool
+	// that are never allocated, in addition to the exclusions of the IPPool.
+	// +optional
+	Exclusions []IPExclusionStr `json:"exclusions,omitempty"`
+
+	// ExcludeReservedAddresses overrides the ExcludeReservedAddresses setting
+	// of the IPPool for this pool.
+	// +optional
+	ExcludeReservedAddresses *bool `json:"excludeReservedAddresses,omitempty"`
+}
+
+// ParentPoolReference references the IPPool a child IPPool obtains its
+// range from.
+type ParentPoolReference struct {
+	// Name is the name of the parent IPPool, in the namespace of the child
+	// IPPool.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// PrefixLength is the prefix length of the subnet the child IPPool
+	// obtains from the subnets of the parent IPPool.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=128
+	PrefixLength int `json:"prefixLength"`
+}
+
+// AllocationQuota limits the number of addresses of the IPPool allocated to a
+// group of claims.
+type AllocationQuota struct {
+	// +kubebuilder:validation:Enum=Namespace;Cluster;Selector
+	// Scope defines how the claims are grouped. "Namespace" limits the claims
+	// of each namespace, "Cluster" the claims of each cluster, by their
+	// cluster.x-k8s.io/cluster-name label, and "Selector" all the claims
+	// matching Selector.
+	Scope QuotaScope `json:"scope"`
+
+	// Selector selects the claims, by their labels, for the Selector scope.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// +kubebuilder:validation:Minimum=0
+	// MaxAllocations is the maximum number of addresses allocated to each
+	// group of claims.
+	MaxAllocations int `json:"maxAllocations"`
 }
 
 // IPPoolSpec defines the desired state of IPPool.
@@ -72,19 +233,82 @@ type IPPoolSpec struct {
 	// Pools contains the list of IP addresses pools
 	Pools []Pool `json:"pools,omitempty"`
 
+	// ParentPool makes this IPPool a child of another IPPool. The child
+	// IPPool obtains a subnet from the parent IPPool and allocates its
+	// addresses from it, instead of from Pools.
+	// +optional
+	ParentPool *ParentPoolReference `json:"parentPool,omitempty"`
+
 	// +kubebuilder:default=sequential
-	// +kubebuilder:validation:Enum=sequential;random
+	// +kubebuilder:validation:Enum=sequential;random;leastRecentlyReleased
 	// AllocationStrategy defines how IP addresses are allocated from the pools.
 	// "sequential" (default) allocates the first available IP.
 	// "random" allocates a random available IP.
-	// In both strategies, multiple pools are consumed in declaration order: a
+	// "leastRecentlyReleased" allocates the first IP that was never used, or
+	// else the IP released the longest time ago, so that a released IP is not
+	// reused right away.
+	// In all strategies, multiple pools are consumed in declaration order: a
 	// pool is fully exhausted before the next one is used, and the strategy only
 	// changes how an address is selected within a single pool.
 	AllocationStrategy AllocationStrategy `json:"allocationStrategy,omitempty"`
 
+	// +kubebuilder:default=status
+	// +kubebuilder:validation:Enum=status;ipAddresses
+	// AllocationStorage defines where the allocations are recorded.
+	// "status" (default) records the map of the allocations in the status of
+	// the IPPool. "ipAddresses" records them in the IPAddress objects only,
+	// and the status of the IPPool keeps the capacity and conditions. Use it
+	// for pools holding tens of thousands of allocations, whose map would
+	// exceed the size limit of an object.
+	// +optional
+	AllocationStorage AllocationStorage `json:"allocationStorage,omitempty"`
+
+	// QuarantineDuration is the time during which an address released by a
+	// claim is not allocated to another claim, whatever the
+	// AllocationStrategy, so that the stale references to it, for example in
+	// DNS or ARP caches, age out. The pre-allocated and requested addresses
+	// are not quarantined. Addresses are not quarantined by default.
+	// +optional
+	QuarantineDuration *metav1.Duration `json:"quarantineDuration,omitempty"`
+
+	// +kubebuilder:default=delete
+	// +kubebuilder:validation:Enum=delete;retain
+	// ReclaimPolicy defines what happens to the address of a deleted claim.
+	// "delete" (default) releases the address. "retain" keeps the IPAddress
+	// reserved for the claim name, and binds it again to the next claim of
+	// the same name, until the ReservationTTL expires or the IPAddress is
+	// deleted. It can be overridden per claim.
+	// +optional
+	ReclaimPolicy ReclaimPolicy `json:"reclaimPolicy,omitempty"`
+
+	// ReservationTTL is the time during which the address of a deleted claim
+	// is reserved with the retain ReclaimPolicy. The address is reserved
+	// until its IPAddress is deleted when unset.
+	// +optional
+	ReservationTTL *metav1.Duration `json:"reservationTTL,omitempty"`
+
+	// Quotas limit the number of addresses allocated to groups of claims, so
+	// that a single namespace or cluster cannot drain the pool. A claim over
+	// any of the quotas fails with the QuotaExceeded reason.
+	// +optional
+	Quotas []AllocationQuota `json:"quotas,omitempty"`
+
 	// PreAllocations contains the preallocated IP addresses
 	PreAllocations map[string]IPAddressStr `json:"preAllocations,omitempty"`
 
+	// Exclusions is the list of addresses, ranges and subnets that are never
+	// allocated from any of the pools.
+	// +optional
+	Exclusions []IPExclusionStr `json:"exclusions,omitempty"`
+
+	// ExcludeReservedAddresses prevents the allocation of the addresses of the
+	// network that are reserved: the network and broadcast addresses in IPv4,
+	// the subnet-router anycast address in IPv6, and the gateway and dns
+	// servers of the pools. Following RFC 3021, all the addresses of IPv4 /31
+	// and /32 networks, and of IPv6 /127 and /128 networks, are allocatable.
+	// +optional
+	ExcludeReservedAddresses bool `json:"excludeReservedAddresses,omitempty"`
+
 	// +kubebuilder:validation:Maximum=128
 	// Prefix is the mask of the network as integer (max 128)
 	Prefix int `json:"prefix,omitempty"`
@@ -98,16 +322,158 @@ type IPPoolSpec struct {
 	// +kubebuilder:validation:MinLength=1
 	// namePrefix is the prefix used to generate the IPAddress object names
 	NamePrefix string `json:"namePrefix"`
+
+	// +kubebuilder:default=90
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// NearlyExhaustedThreshold is the utilization percentage of the pool at
+	// which the NearlyExhausted condition becomes true.
+	// +optional
+	NearlyExhaustedThreshold int `json:"nearlyExhaustedThreshold,omitempty"`
 }
 
+// MaxStatusEntries bounds the number of entries of ReleasedAddresses,
+// QuarantinedAddresses, Reservations and Retries in the IPPool status, so that
+// the IPPool stays within the size limit of an object whatever the size of its
+// pools.
+const MaxStatusEntries = 1024
+
 // IPPoolStatus defines the observed state of IPPool.
 type IPPoolStatus struct {
 	// LastUpdated identifies when this status was last observed.
 	// +optional
 	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
 
-	// Allocations contains the map of objects and IP addresses they have
+	// Allocations contains the map of objects and IP addresses they have. It
+	// is empty when the AllocationStorage is ipAddresses.
 	Allocations map[string]IPAddressStr `json:"indexes,omitempty"`
+
+	// ParentSubnet is the subnet a child IPPool obtained from its parent
+	// IPPool.
+	// +optional
+	ParentSubnet *IPSubnetStr `json:"parentSubnet,omitempty"`
+
+	// ReleasedAddresses records when the free addresses were last released,
+	// with the leastRecentlyReleased AllocationStrategy. Only the most recent
+	// releases are recorded, the older ones are forgotten.
+	// +optional
+	// +kubebuilder:validation:MaxProperties=1024
+	ReleasedAddresses map[IPAddressStr]metav1.Time `json:"releasedAddresses,omitempty"`
+
+	// QuarantinedAddresses maps the released addresses in quarantine to the
+	// time their quarantine ends, see Spec.QuarantineDuration. When too many
+	// addresses are in quarantine, the quarantines ending first end early.
+	// +optional
+	// +kubebuilder:validation:MaxProperties=1024
+	QuarantinedAddresses map[IPAddressStr]metav1.Time `json:"quarantinedAddresses,omitempty"`
+
+	// Reservations lists the addresses reserved for deleted claims with the
+	// retain ReclaimPolicy. The reservations are recorded on the IPAddresses,
+	// only the reservations expiring first are listed.
+	// +optional
+	// +kubebuilder:validation:MaxItems=1024
+	Reservations []AddressReservation `json:"reservations,omitempty"`
+
+	// Retries lists the claims whose allocation failed, that are allocated
+	// again once the IPPool changed or released addresses. Only the oldest
+	// failures are listed, the other failed claims are allocated again on
+	// every reconciliation.
+	// +optional
+	// +kubebuilder:validation:MaxItems=1024
+	Retries []ClaimRetry `json:"retries,omitempty"`
+
+	// Capacity reports the capacity and utilization of the whole IPPool.
+	// +optional
+	Capacity *IPPoolCapacity `json:"capacity,omitempty"`
+
+	// Pools reports the capacity and utilization of each entry of
+	// Spec.Pools, in declaration order.
+	// +optional
+	Pools []PoolStatus `json:"pools,omitempty"`
+
+	// Conditions defines the current state of the IPPool. The known
+	// condition types are Ready, Exhausted, NearlyExhausted and Degraded.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MaxItems=32
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// IPPoolCapacity reports the capacity and utilization of an IPPool or of one
+// of its pool entries.
+type IPPoolCapacity struct {
+	// Total is the number of addresses that can be allocated, or "unknown"
+	// when the size cannot be computed.
+	Total string `json:"total"`
+
+	// Allocated is the number of addresses bound to a claim.
+	Allocated int `json:"allocated"`
+
+	// PreAllocated is the number of addresses reserved in
+	// Spec.PreAllocations that are not bound to a claim yet.
+	PreAllocated int `json:"preAllocated"`
+
+	// Quarantined is the number of released addresses that cannot be
+	// allocated until their quarantine ends.
+	// +optional
+	Quarantined int `json:"quarantined,omitempty"`
+
+	// Free is the number of addresses still available for allocation, or
+	// "unknown" when the size cannot be computed.
+	Free string `json:"free"`
+
+	// FreeRanges is a short summary of the free address ranges. Only the
+	// first ranges are listed.
+	// +optional
+	FreeRanges []string `json:"freeRanges,omitempty"`
+}
+
+// AddressReservation is an address reserved for the name of a deleted claim.
+type AddressReservation struct {
+	// Claim is the deleted claim the address is reserved for.
+	Claim corev1.ObjectReference `json:"claim"`
+
+	// Address is the reserved address.
+	Address IPAddressStr `json:"address"`
+
+	// ExpiresAt is the time the reservation ends. The reservation lasts
+	// until the IPAddress is deleted when unset.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// ClaimRetry tracks the attempts to allocate an address to a failed claim.
+type ClaimRetry struct {
+	// Claim is the failed claim.
+	Claim corev1.ObjectReference `json:"claim"`
+
+	// Attempts is the number of failed attempts.
+	Attempts int32 `json:"attempts"`
+
+	// LastAttempt is the time of the last failed attempt.
+	LastAttempt metav1.Time `json:"lastAttempt"`
+
+	// PoolGeneration is the generation of the IPPool at the last attempt.
+	PoolGeneration int64 `json:"poolGeneration"`
+
+	// RetryAt is the time of the next attempt. It is set once the IPPool
+	// changed or released addresses after the last attempt, with a backoff
+	// growing with the attempts.
+	// +optional
+	RetryAt *metav1.Time `json:"retryAt,omitempty"`
+}
+
+// PoolStatus reports the capacity and utilization of a single pool entry.
+type PoolStatus struct {
+	// Index is the position of the entry in Spec.Pools.
+	Index int `json:"index"`
+
+	// Name is the name of the entry in Spec.Pools.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	IPPoolCapacity `json:",inline"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -116,6 +482,9 @@ type IPPoolStatus struct {
 // +kubebuilder:subresource:status
 // +kubebuilder:object:root=true
 // +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this template belongs"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description="IPPool is reconciled and can serve claims"
+// +kubebuilder:printcolumn:name="Total",type="string",JSONPath=".status.capacity.total",description="Number of addresses in the pool"
+// +kubebuilder:printcolumn:name="Free",type="string",JSONPath=".status.capacity.free",description="Number of free addresses in the pool"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of Metal3IPPool"
 // IPPool is the Schema for the ippools API.
 type IPPool struct {
@@ -126,6 +495,16 @@ type IPPool struct {
 	Status IPPoolStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the list of conditions of the IPPool.
+func (m *IPPool) GetConditions() []metav1.Condition {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the conditions of the IPPool.
+func (m *IPPool) SetConditions(conditions []metav1.Condition) {
+	m.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // IPPoolList contains a list of IPPool.
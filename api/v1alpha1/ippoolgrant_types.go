@@ -0,0 +1,63 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IPPoolGrantSpec defines the namespaces whose IPClaims can consume an IPPool.
+type IPPoolGrantSpec struct {
+
+	// +kubebuilder:validation:MinLength=1
+	// Pool is the name of the IPPool, in the namespace of the grant, that the
+	// IPClaims of the namespaces can consume.
+	Pool string `json:"pool"`
+
+	// +kubebuilder:validation:MinItems=1
+	// Namespaces is the list of namespaces whose IPClaims can consume the
+	// IPPool.
+	Namespaces []string `json:"namespaces"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:path=ippoolgrants,scope=Namespaced,categories=cluster-api,shortName=ippg;ippoolgrant;m3ippg;m3ippoolgrant;m3ippoolgrants
+// +kubebuilder:storageversion
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Pool",type="string",JSONPath=".spec.pool",description="IPPool consumable from other namespaces"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of IPPoolGrant"
+// IPPoolGrant allows the IPClaims of other namespaces to consume an IPPool of
+// its namespace.
+type IPPoolGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec IPPoolGrantSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// IPPoolGrantList contains a list of IPPoolGrant.
+type IPPoolGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []IPPoolGrant `json:"items"`
+}
+
+func init() {
+	objectTypes = append(objectTypes, &IPPoolGrant{}, &IPPoolGrantList{})
+}
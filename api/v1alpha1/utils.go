@@ -22,6 +22,8 @@ import (
 	"math"
 	"math/big"
 	"net"
+	"slices"
+	"strings"
 )
 
 const (
@@ -34,11 +36,40 @@ const (
 )
 
 // GetIPAddress renders the IP address, taking the index, offset and step into
-// account, it is IP version agnostic.
+// account, it is IP version agnostic. Excluded addresses are not part of the
+// index space, so the index-th address that is not excluded is returned.
 func GetIPAddress(entry Pool, index int) (IPAddressStr, error) {
+	poolIndex, err := NewPoolIndex(entry)
+	if err != nil {
+		return "", err
+	}
+	return poolIndex.IPAddress(index)
+}
+
+// PoolIndex renders the addresses of a pool entry by index, as GetIPAddress
+// does, parsing the exclusions of the entry once for all the lookups.
+type PoolIndex struct {
+	entry Pool
+	// exclusions are the exclusions of the entry, sorted and merged.
+	exclusions [][2]*big.Int
+}
+
+// NewPoolIndex returns the PoolIndex of the given pool entry.
+func NewPoolIndex(entry Pool) (*PoolIndex, error) {
 	if entry.Start == nil && entry.Subnet == nil {
-		return "", errors.New("either Start or Subnet is required for ipAddress")
+		return nil, errors.New("either Start or Subnet is required for ipAddress")
+	}
+	exclusions, err := exclusionRanges(entry.Exclusions)
+	if err != nil {
+		return nil, err
 	}
+	return &PoolIndex{entry: entry, exclusions: exclusions}, nil
+}
+
+// IPAddress returns the index-th address of the pool entry that is not
+// excluded.
+func (p *PoolIndex) IPAddress(index int) (IPAddressStr, error) {
+	entry := p.entry
 	var ip net.IP
 	var err error
 	var ipNet *net.IPNet
@@ -50,7 +81,14 @@ func GetIPAddress(entry Pool, index int) (IPAddressStr, error) {
 		if entry.End != nil {
 			endIP = net.ParseIP(string(*entry.End))
 		}
-		ip, err = addOffsetToIP(net.ParseIP(string(*entry.Start)), endIP, offset)
+		startIP := net.ParseIP(string(*entry.Start))
+		if len(p.exclusions) != 0 && startIP != nil {
+			offset, err = skipExclusions(ipToInt(startIP), offset, p.exclusions)
+			if err != nil {
+				return "", err
+			}
+		}
+		ip, err = addOffsetToIP(startIP, endIP, offset)
 		if err != nil {
 			return "", err
 		}
@@ -72,7 +110,22 @@ func GetIPAddress(entry Pool, index int) (IPAddressStr, error) {
 		if err != nil {
 			return "", err
 		}
-		offset++
+		// The index space starts after the network address, unless all the
+		// addresses of the subnet are allocatable.
+		skipNetwork := !allAddressesAllocatable(entry, ipNet)
+		if len(p.exclusions) != 0 {
+			first := ipToInt(ipNet.IP)
+			if skipNetwork {
+				first.Add(first, big.NewInt(1))
+			}
+			offset, err = skipExclusions(first, offset, p.exclusions)
+			if err != nil {
+				return "", err
+			}
+		}
+		if skipNetwork {
+			offset++
+		}
 		ip, err = addOffsetToIP(ip, nil, offset)
 		if err != nil {
 			return "", err
@@ -113,6 +166,11 @@ func ValidatePool(entry Pool) error {
 			return fmt.Errorf("invalid Subnet %q: %w", *entry.Subnet, err)
 		}
 	}
+	for _, exclusion := range entry.Exclusions {
+		if _, _, err := ParseExclusion(exclusion); err != nil {
+			return err
+		}
+	}
 	if startIP != nil && endIP != nil {
 		if new(big.Int).SetBytes(startIP.To16()).Cmp(new(big.Int).SetBytes(endIP.To16())) > 0 {
 			return fmt.Errorf("end IP %s is before start IP %s", endIP, startIP)
@@ -123,9 +181,10 @@ func ValidatePool(entry Pool) error {
 
 // GetPoolSize returns the number of indexable IP addresses in the given pool
 // entry, matching the index space accepted by GetIPAddress: GetIPAddress(entry, i)
-// is valid for i in [0, size) and returns an error for i >= size. Unlike
-// ValidatePool, it requires the pool to be bounded (Start with End/Subnet, or
-// Subnet only) so that a finite size can be computed.
+// is valid for i in [0, size) and returns an error for i >= size. Excluded
+// addresses are not counted. Unlike ValidatePool, it requires the pool to be
+// bounded (Start with End/Subnet, or Subnet only) so that a finite size can be
+// computed.
 func GetPoolSize(entry Pool) (int, error) {
 	if err := ValidatePool(entry); err != nil {
 		return 0, err
@@ -156,10 +215,11 @@ func GetPoolSize(entry Pool) (int, error) {
 			return 0, err
 		}
 		// GetIPAddress with Subnet-only maps index 0 to network+1, so the
-		// network address itself is excluded from the index space.
+		// network address itself is excluded from the index space, unless all
+		// the addresses of the subnet are allocatable.
 		startIP = ipNet.IP
 		endIP = lastIPInSubnet(ipNet)
-		inclusiveStart = false
+		inclusiveStart = allAddressesAllocatable(entry, ipNet)
 	}
 
 	s := new(big.Int).SetBytes(startIP.To16())
@@ -170,6 +230,15 @@ func GetPoolSize(entry Pool) (int, error) {
 	}
 	if inclusiveStart {
 		diff.Add(diff, big.NewInt(1))
+	} else {
+		s.Add(s, big.NewInt(1))
+	}
+	if len(entry.Exclusions) != 0 {
+		ranges, err := exclusionRanges(entry.Exclusions)
+		if err != nil {
+			return 0, err
+		}
+		diff.Sub(diff, countExcluded(ranges, s, e))
 	}
 	if !diff.IsInt64() || diff.Int64() > math.MaxInt {
 		return 0, errors.New("pool size exceeds int range")
@@ -177,6 +246,314 @@ func GetPoolSize(entry Pool) (int, error) {
 	return int(diff.Int64()), nil
 }
 
+// GetPoolRange returns the first and the last address of the index space of
+// the given pool entry, so that GetIPAddress(entry, 0) is the first address.
+// The last address is nil when the entry is unbounded (Start without End or
+// Subnet). Both addresses are returned in their 16-byte form.
+func GetPoolRange(entry Pool) (net.IP, net.IP, error) {
+	if err := ValidatePool(entry); err != nil {
+		return nil, nil, err
+	}
+	first, err := GetIPAddress(entry, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	firstIP := net.ParseIP(string(first)).To16()
+
+	var lastIP net.IP
+	if entry.Subnet != nil {
+		_, ipNet, err := net.ParseCIDR(string(*entry.Subnet))
+		if err != nil {
+			return nil, nil, err
+		}
+		lastIP = lastIPInSubnet(ipNet).To16()
+	}
+	// As in GetIPAddress, End only bounds the range when Start is given.
+	if entry.Start != nil && entry.End != nil {
+		endIP := net.ParseIP(string(*entry.End)).To16()
+		if lastIP == nil || new(big.Int).SetBytes(endIP).Cmp(new(big.Int).SetBytes(lastIP)) < 0 {
+			lastIP = endIP
+		}
+	}
+	if lastIP != nil && new(big.Int).SetBytes(lastIP).Cmp(new(big.Int).SetBytes(firstIP)) < 0 {
+		return nil, nil, fmt.Errorf("end IP %s is before start IP %s", lastIP, firstIP)
+	}
+	return firstIP, lastIP, nil
+}
+
+// GetPools returns the pool entries of the IPPool spec with the exclusions of
+// the spec, and the reserved addresses when ExcludeReservedAddresses is set,
+// merged into the exclusions of each entry. The spec is not modified.
+func GetPools(spec IPPoolSpec) []Pool {
+	pools := make([]Pool, 0, len(spec.Pools))
+	for _, pool := range spec.Pools {
+		if pool.ExcludeReservedAddresses == nil {
+			excludeReserved := spec.ExcludeReservedAddresses
+			pool.ExcludeReservedAddresses = &excludeReserved
+		}
+		exclusions := append(slices.Clone(pool.Exclusions), spec.Exclusions...)
+		pool.Exclusions = append(exclusions, reservedAddresses(pool, spec)...)
+		pools = append(pools, pool)
+	}
+	return pools
+}
+
+// GetChildPools returns the pool entries of a child IPPool: the subnet it
+// obtained from its parent IPPool. The prefix length of the subnet is the
+// longest of the obtained one and the one requested in the spec, so that the
+// child IPPool never allocates out of the range it holds while it is resized.
+// It returns no entry until the subnet is obtained.
+func GetChildPools(spec IPPoolSpec, parentSubnet *IPSubnetStr) []Pool {
+	if spec.ParentPool == nil || parentSubnet == nil {
+		return nil
+	}
+	_, ipNet, err := net.ParseCIDR(string(*parentSubnet))
+	if err != nil {
+		return nil
+	}
+	ones, bits := ipNet.Mask.Size()
+	if spec.ParentPool.PrefixLength > ones && spec.ParentPool.PrefixLength <= bits {
+		ones = spec.ParentPool.PrefixLength
+	}
+	subnet := IPSubnetStr(fmt.Sprintf("%s/%d", ipNet.IP, ones))
+	return []Pool{{Subnet: &subnet}}
+}
+
+// GetRequestedAddress returns the IP address requested by the IPClaim, from
+// its spec or from the deprecated RequestedAddressAnnotation, or an empty
+// string if no address is requested.
+func GetRequestedAddress(claim *IPClaim) IPAddressStr {
+	if claim.Spec.RequestedAddress != nil {
+		return *claim.Spec.RequestedAddress
+	}
+	return IPAddressStr(claim.Annotations[RequestedAddressAnnotation])
+}
+
+// StoresAllocationsInStatus returns true if the allocations of the IPPool
+// spec are recorded in the status of the IPPool. This is the case when the
+// AllocationStorage is unset, for IPPools created before it existed.
+func StoresAllocationsInStatus(spec IPPoolSpec) bool {
+	return spec.AllocationStorage != AllocationStorageIPAddresses
+}
+
+// reservedAddresses returns the addresses of the pool entry that must not be
+// allocated when ExcludeReservedAddresses is set: the gateway, the dns servers,
+// the network address (the subnet-router anycast address in IPv6) and the IPv4
+// broadcast address. The network and broadcast addresses are only known when
+// the entry has a Subnet, or a Start and a prefix.
+func reservedAddresses(pool Pool, spec IPPoolSpec) []IPExclusionStr {
+	if pool.ExcludeReservedAddresses == nil || !*pool.ExcludeReservedAddresses {
+		return nil
+	}
+	reserved := []IPExclusionStr{}
+
+	gateway := pool.Gateway
+	if gateway == nil {
+		gateway = spec.Gateway
+	}
+	dnsServers := pool.DNSServers
+	if len(dnsServers) == 0 {
+		dnsServers = spec.DNSServers
+	}
+	if gateway != nil {
+		dnsServers = append([]IPAddressStr{*gateway}, dnsServers...)
+	}
+	for _, address := range dnsServers {
+		// Invalid addresses are rejected by the webhook, ignore them here so
+		// that they do not make the whole pool unusable.
+		if net.ParseIP(string(address)) != nil {
+			reserved = append(reserved, IPExclusionStr(address))
+		}
+	}
+
+	ipNet := poolNetwork(pool, spec)
+	if ipNet == nil || allAddressesAllocatable(pool, ipNet) {
+		return reserved
+	}
+	reserved = append(reserved, IPExclusionStr(ipNet.IP.String()))
+	if ipNet.IP.To4() != nil {
+		reserved = append(reserved, IPExclusionStr(lastIPInSubnet(ipNet).String()))
+	}
+	return reserved
+}
+
+// poolNetwork returns the network of the pool entry, from its Subnet or from
+// its Start and prefix. It returns nil if the network is not known.
+func poolNetwork(pool Pool, spec IPPoolSpec) *net.IPNet {
+	if pool.Subnet != nil {
+		_, ipNet, err := net.ParseCIDR(string(*pool.Subnet))
+		if err != nil {
+			return nil
+		}
+		return ipNet
+	}
+	if pool.Start == nil {
+		return nil
+	}
+	prefix := pool.Prefix
+	if prefix == 0 {
+		prefix = spec.Prefix
+	}
+	ip := net.ParseIP(string(*pool.Start))
+	bits := 8 * net.IPv6len
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bits = 8 * net.IPv4len
+	}
+	if ip == nil || prefix <= 0 || prefix > bits {
+		return nil
+	}
+	mask := net.CIDRMask(prefix, bits)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+// allAddressesAllocatable returns true if the reserved addresses are excluded
+// from the pool entry and the network is an IPv4 /31 or /32, or an IPv6 /127
+// or /128. Following RFC 3021, such networks have no network or broadcast
+// address.
+func allAddressesAllocatable(pool Pool, ipNet *net.IPNet) bool {
+	if pool.ExcludeReservedAddresses == nil || !*pool.ExcludeReservedAddresses {
+		return false
+	}
+	ones, bits := ipNet.Mask.Size()
+	return bits-ones <= 1
+}
+
+// ParseExclusion parses an exclusion and returns the first and the last
+// address it contains, in their 16-byte form.
+func ParseExclusion(exclusion IPExclusionStr) (net.IP, net.IP, error) {
+	value := string(exclusion)
+	switch {
+	case strings.Contains(value, "/"):
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid exclusion %q: %w", exclusion, err)
+		}
+		return ipNet.IP.To16(), lastIPInSubnet(ipNet).To16(), nil
+	case strings.Contains(value, "-"):
+		first, last, _ := strings.Cut(value, "-")
+		firstIP := net.ParseIP(strings.TrimSpace(first))
+		lastIP := net.ParseIP(strings.TrimSpace(last))
+		if firstIP == nil || lastIP == nil {
+			return nil, nil, fmt.Errorf("invalid exclusion %q: invalid IP address in range", exclusion)
+		}
+		if (firstIP.To4() == nil) != (lastIP.To4() == nil) {
+			return nil, nil, fmt.Errorf("invalid exclusion %q: mixed IP versions in range", exclusion)
+		}
+		if ipToInt(firstIP).Cmp(ipToInt(lastIP)) > 0 {
+			return nil, nil, fmt.Errorf("invalid exclusion %q: end of range is before its start", exclusion)
+		}
+		return firstIP.To16(), lastIP.To16(), nil
+	default:
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, nil, fmt.Errorf("invalid exclusion %q: not an IP address, range or subnet", exclusion)
+		}
+		return ip.To16(), ip.To16(), nil
+	}
+}
+
+// IsAddressExcluded returns true if the address is contained in one of the
+// exclusions. Invalid exclusions are ignored.
+func IsAddressExcluded(address IPAddressStr, exclusions []IPExclusionStr) bool {
+	ip := net.ParseIP(string(address))
+	if ip == nil {
+		return false
+	}
+	value := ipToInt(ip)
+	for _, exclusion := range exclusions {
+		first, last, err := ParseExclusion(exclusion)
+		if err != nil {
+			continue
+		}
+		if value.Cmp(ipToInt(first)) >= 0 && value.Cmp(ipToInt(last)) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// exclusionRanges parses the exclusions and returns them as sorted, merged
+// and non-overlapping ranges of integers.
+func exclusionRanges(exclusions []IPExclusionStr) ([][2]*big.Int, error) {
+	ranges := make([][2]*big.Int, 0, len(exclusions))
+	for _, exclusion := range exclusions {
+		first, last, err := ParseExclusion(exclusion)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, [2]*big.Int{ipToInt(first), ipToInt(last)})
+	}
+	slices.SortFunc(ranges, func(a, b [2]*big.Int) int { return a[0].Cmp(b[0]) })
+
+	merged := make([][2]*big.Int, 0, len(ranges))
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			if r[0].Cmp(new(big.Int).Add(merged[n-1][1], big.NewInt(1))) <= 0 {
+				if r[1].Cmp(merged[n-1][1]) > 0 {
+					merged[n-1][1] = r[1]
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged, nil
+}
+
+// skipExclusions returns the offset from first of the index-th address that
+// is not excluded by the given exclusion ranges, see exclusionRanges.
+func skipExclusions(first *big.Int, index int, ranges [][2]*big.Int) (int, error) {
+	candidate := new(big.Int).Add(first, big.NewInt(int64(index)))
+	for _, r := range ranges {
+		if r[1].Cmp(first) < 0 {
+			continue
+		}
+		low := r[0]
+		if low.Cmp(first) < 0 {
+			low = first
+		}
+		if low.Cmp(candidate) > 0 {
+			break
+		}
+		// The candidate is at or after the start of this exclusion, shift it
+		// by the size of the exclusion.
+		candidate.Add(candidate, new(big.Int).Sub(r[1], low))
+		candidate.Add(candidate, big.NewInt(1))
+	}
+	offset := new(big.Int).Sub(candidate, first)
+	if !offset.IsInt64() || offset.Int64() > math.MaxInt {
+		return 0, errors.New("IP address out of bounds")
+	}
+	return int(offset.Int64()), nil
+}
+
+// countExcluded returns the number of excluded addresses between first and
+// last, both included.
+func countExcluded(ranges [][2]*big.Int, first, last *big.Int) *big.Int {
+	count := big.NewInt(0)
+	for _, r := range ranges {
+		low, high := r[0], r[1]
+		if low.Cmp(first) < 0 {
+			low = first
+		}
+		if high.Cmp(last) > 0 {
+			high = last
+		}
+		if low.Cmp(high) > 0 {
+			continue
+		}
+		count.Add(count, new(big.Int).Sub(high, low))
+		count.Add(count, big.NewInt(1))
+	}
+	return count
+}
+
+// ipToInt converts an IP address to an integer, using its 16-byte form.
+func ipToInt(ip net.IP) *big.Int {
+	return new(big.Int).SetBytes(ip.To16())
+}
+
 // lastIPInSubnet returns the highest address contained in the given CIDR.
 func lastIPInSubnet(n *net.IPNet) net.IP {
 	last := make(net.IP, len(n.IP))
@@ -115,6 +115,41 @@ var _ = Describe("IPPool manager", func() {
 			index:       250,
 			expectError: true,
 		}),
+		Entry("Start set, excluded addresses are skipped", testCaseGetIPAddress{
+			ipAddress: Pool{
+				Start: (*IPAddressStr)(ptr.To("192.168.0.10")),
+				End:   (*IPAddressStr)(ptr.To("192.168.0.20")),
+				Exclusions: []IPExclusionStr{
+					"192.168.0.10", "192.168.0.12-192.168.0.13", "192.168.0.14/31",
+				},
+			},
+			index:      1,
+			expectedIP: IPAddressStr("192.168.0.16"),
+		}),
+		Entry("Start set, exclusions push the address out of bound", testCaseGetIPAddress{
+			ipAddress: Pool{
+				Start:      (*IPAddressStr)(ptr.To("192.168.0.10")),
+				End:        (*IPAddressStr)(ptr.To("192.168.0.12")),
+				Exclusions: []IPExclusionStr{"192.168.0.11"},
+			},
+			index:       2,
+			expectError: true,
+		}),
+		Entry("Subnet set, exclusion including the network address", testCaseGetIPAddress{
+			ipAddress: Pool{
+				Subnet:     (*IPSubnetStr)(ptr.To("192.168.0.0/24")),
+				Exclusions: []IPExclusionStr{"192.168.0.0/28", "192.168.0.8-192.168.0.17"},
+			},
+			index:      0,
+			expectedIP: IPAddressStr("192.168.0.18"),
+		}),
+		Entry("Invalid exclusion", testCaseGetIPAddress{
+			ipAddress: Pool{
+				Subnet:     (*IPSubnetStr)(ptr.To("192.168.0.0/24")),
+				Exclusions: []IPExclusionStr{"not-an-ip"},
+			},
+			expectError: true,
+		}),
 	)
 
 	type testCaseAddOffsetToIP struct {
@@ -348,6 +383,110 @@ var _ = Describe("IPPool manager", func() {
 			},
 			expectError: true,
 		}),
+		Entry("Start and End set, overlapping exclusions are counted once", testCaseGetPoolSize{
+			pool: Pool{
+				Start: (*IPAddressStr)(ptr.To("192.168.0.10")),
+				End:   (*IPAddressStr)(ptr.To("192.168.0.20")),
+				Exclusions: []IPExclusionStr{
+					"192.168.0.0-192.168.0.11", "192.168.0.15-192.168.0.17", "192.168.0.16/30", "10.0.0.1",
+				},
+			},
+			expectedSize: 4,
+		}),
+		Entry("Subnet only, exclusion of the network address is not counted", testCaseGetPoolSize{
+			pool: Pool{
+				Subnet:     (*IPSubnetStr)(ptr.To("192.168.0.0/24")),
+				Exclusions: []IPExclusionStr{"192.168.0.0/28"},
+			},
+			expectedSize: 240,
+		}),
+		Entry("Invalid exclusion", testCaseGetPoolSize{
+			pool: Pool{
+				Subnet:     (*IPSubnetStr)(ptr.To("192.168.0.0/24")),
+				Exclusions: []IPExclusionStr{"192.168.0.20-192.168.0.10"},
+			},
+			expectError: true,
+		}),
+	)
+
+	type testCaseGetPoolRange struct {
+		pool          Pool
+		expectError   bool
+		expectedFirst string
+		expectedLast  string
+	}
+
+	DescribeTable("Test GetPoolRange",
+		func(tc testCaseGetPoolRange) {
+			first, last, err := GetPoolRange(tc.pool)
+			if tc.expectError {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first.Equal(net.ParseIP(tc.expectedFirst))).To(BeTrue())
+			if tc.expectedLast == "" {
+				Expect(last).To(BeNil())
+			} else {
+				Expect(last.Equal(net.ParseIP(tc.expectedLast))).To(BeTrue())
+			}
+		},
+		Entry("Empty Start and Subnet", testCaseGetPoolRange{
+			pool:        Pool{},
+			expectError: true,
+		}),
+		Entry("Start only is unbounded", testCaseGetPoolRange{
+			pool: Pool{
+				Start: (*IPAddressStr)(ptr.To("192.168.0.10")),
+			},
+			expectedFirst: "192.168.0.10",
+		}),
+		Entry("Start and End set", testCaseGetPoolRange{
+			pool: Pool{
+				Start: (*IPAddressStr)(ptr.To("192.168.0.10")),
+				End:   (*IPAddressStr)(ptr.To("192.168.0.20")),
+			},
+			expectedFirst: "192.168.0.10",
+			expectedLast:  "192.168.0.20",
+		}),
+		Entry("Start and Subnet set", testCaseGetPoolRange{
+			pool: Pool{
+				Start:  (*IPAddressStr)(ptr.To("192.168.0.10")),
+				Subnet: (*IPSubnetStr)(ptr.To("192.168.0.0/24")),
+			},
+			expectedFirst: "192.168.0.10",
+			expectedLast:  "192.168.0.255",
+		}),
+		Entry("End beyond Subnet is bounded by the subnet", testCaseGetPoolRange{
+			pool: Pool{
+				Start:  (*IPAddressStr)(ptr.To("192.168.0.10")),
+				End:    (*IPAddressStr)(ptr.To("192.168.1.20")),
+				Subnet: (*IPSubnetStr)(ptr.To("192.168.0.0/24")),
+			},
+			expectedFirst: "192.168.0.10",
+			expectedLast:  "192.168.0.255",
+		}),
+		Entry("Subnet only excludes network address", testCaseGetPoolRange{
+			pool: Pool{
+				Subnet: (*IPSubnetStr)(ptr.To("192.168.0.0/24")),
+			},
+			expectedFirst: "192.168.0.1",
+			expectedLast:  "192.168.0.255",
+		}),
+		Entry("Large IPv6 subnet", testCaseGetPoolRange{
+			pool: Pool{
+				Subnet: (*IPSubnetStr)(ptr.To("2001:db8::/32")),
+			},
+			expectedFirst: "2001:db8::1",
+			expectedLast:  "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff",
+		}),
+		Entry("Start outside Subnet", testCaseGetPoolRange{
+			pool: Pool{
+				Start:  (*IPAddressStr)(ptr.To("192.168.1.10")),
+				Subnet: (*IPSubnetStr)(ptr.To("192.168.0.0/24")),
+			},
+			expectError: true,
+		}),
 	)
 
 	type testCaseValidatePool struct {
@@ -431,6 +570,273 @@ var _ = Describe("IPPool manager", func() {
 				Subnet: (*IPSubnetStr)(ptr.To("2001:db8::/32")),
 			},
 		}),
+		Entry("Valid exclusions", testCaseValidatePool{
+			pool: Pool{
+				Subnet:     (*IPSubnetStr)(ptr.To("2001:db8::/64")),
+				Exclusions: []IPExclusionStr{"2001:db8::1", "2001:db8::10-2001:db8::20", "2001:db8::100/120"},
+			},
+		}),
+		Entry("Invalid exclusion", testCaseValidatePool{
+			pool: Pool{
+				Subnet:     (*IPSubnetStr)(ptr.To("2001:db8::/64")),
+				Exclusions: []IPExclusionStr{"2001:db8::1/129"},
+			},
+			expectError: true,
+		}),
+	)
+
+	It("GetIPAddress and GetPoolSize skip the same excluded addresses", func() {
+		pool := Pool{
+			Subnet:     (*IPSubnetStr)(ptr.To("192.168.0.0/27")),
+			Exclusions: []IPExclusionStr{"192.168.0.1", "192.168.0.4-192.168.0.9", "192.168.0.8/29", "192.168.0.31"},
+		}
+		size, err := GetPoolSize(pool)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(Equal(17))
+		rendered := map[IPAddressStr]bool{}
+		for index := range size {
+			address, err := GetIPAddress(pool, index)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(IsAddressExcluded(address, pool.Exclusions)).To(BeFalse())
+			rendered[address] = true
+		}
+		Expect(rendered).To(HaveLen(size))
+		_, err = GetIPAddress(pool, size)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("PoolIndex renders the addresses of GetIPAddress", func() {
+		pool := Pool{
+			Start:      (*IPAddressStr)(ptr.To("192.168.0.10")),
+			End:        (*IPAddressStr)(ptr.To("192.168.0.30")),
+			Exclusions: []IPExclusionStr{"192.168.0.12-192.168.0.15", "192.168.0.20"},
+		}
+		poolIndex, err := NewPoolIndex(pool)
+		Expect(err).NotTo(HaveOccurred())
+		for index := range 16 {
+			expected, err := GetIPAddress(pool, index)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(poolIndex.IPAddress(index)).To(Equal(expected))
+		}
+		_, err = poolIndex.IPAddress(16)
+		Expect(err).To(HaveOccurred())
+
+		pool.Exclusions = []IPExclusionStr{"192.168.0.1/33"}
+		_, err = NewPoolIndex(pool)
+		Expect(err).To(HaveOccurred())
+	})
+
+	type testCaseParseExclusion struct {
+		exclusion     IPExclusionStr
+		expectError   bool
+		expectedFirst string
+		expectedLast  string
+	}
+
+	DescribeTable("Test ParseExclusion",
+		func(tc testCaseParseExclusion) {
+			first, last, err := ParseExclusion(tc.exclusion)
+			if tc.expectError {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first.Equal(net.ParseIP(tc.expectedFirst))).To(BeTrue())
+			Expect(last.Equal(net.ParseIP(tc.expectedLast))).To(BeTrue())
+		},
+		Entry("Single address", testCaseParseExclusion{
+			exclusion:     "192.168.0.10",
+			expectedFirst: "192.168.0.10",
+			expectedLast:  "192.168.0.10",
+		}),
+		Entry("Range", testCaseParseExclusion{
+			exclusion:     "2001:db8::10-2001:db8::20",
+			expectedFirst: "2001:db8::10",
+			expectedLast:  "2001:db8::20",
+		}),
+		Entry("Subnet", testCaseParseExclusion{
+			exclusion:     "192.168.0.18/28",
+			expectedFirst: "192.168.0.16",
+			expectedLast:  "192.168.0.31",
+		}),
+		Entry("Invalid address", testCaseParseExclusion{
+			exclusion:   "192.168.0.300",
+			expectError: true,
+		}),
+		Entry("Invalid subnet", testCaseParseExclusion{
+			exclusion:   "192.168.0.0/33",
+			expectError: true,
+		}),
+		Entry("Reversed range", testCaseParseExclusion{
+			exclusion:   "192.168.0.20-192.168.0.10",
+			expectError: true,
+		}),
+		Entry("Range with mixed IP versions", testCaseParseExclusion{
+			exclusion:   "192.168.0.10-2001:db8::20",
+			expectError: true,
+		}),
+	)
+
+	It("GetPools merges the exclusions of the spec", func() {
+		spec := IPPoolSpec{
+			Pools: []Pool{
+				{
+					Subnet:     (*IPSubnetStr)(ptr.To("192.168.0.0/24")),
+					Exclusions: []IPExclusionStr{"192.168.0.1"},
+				},
+				{
+					Subnet: (*IPSubnetStr)(ptr.To("192.168.1.0/24")),
+				},
+			},
+			Exclusions: []IPExclusionStr{"192.168.0.0/23"},
+		}
+		pools := GetPools(spec)
+		Expect(pools).To(HaveLen(2))
+		Expect(pools[0].Exclusions).To(Equal([]IPExclusionStr{"192.168.0.1", "192.168.0.0/23"}))
+		Expect(pools[1].Exclusions).To(Equal([]IPExclusionStr{"192.168.0.0/23"}))
+		Expect(spec.Pools[0].Exclusions).To(Equal([]IPExclusionStr{"192.168.0.1"}))
+		Expect(spec.Pools[1].Exclusions).To(BeNil())
+	})
+
+	type testCaseReservedAddresses struct {
+		spec            IPPoolSpec
+		expectedSize    int
+		expectedFirst   IPAddressStr
+		expectedLast    IPAddressStr
+		expectedIndexes map[int]IPAddressStr
+	}
+
+	DescribeTable("Test ExcludeReservedAddresses",
+		func(tc testCaseReservedAddresses) {
+			pools := GetPools(tc.spec)
+			Expect(pools).To(HaveLen(1))
+			size, err := GetPoolSize(pools[0])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(size).To(Equal(tc.expectedSize))
+			first, err := GetIPAddress(pools[0], 0)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(first).To(Equal(tc.expectedFirst))
+			last, err := GetIPAddress(pools[0], size-1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(last).To(Equal(tc.expectedLast))
+			_, err = GetIPAddress(pools[0], size)
+			Expect(err).To(HaveOccurred())
+			for index, expected := range tc.expectedIndexes {
+				address, err := GetIPAddress(pools[0], index)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(address).To(Equal(expected))
+			}
+		},
+		Entry("Disabled keeps the broadcast address", testCaseReservedAddresses{
+			spec: IPPoolSpec{
+				Pools:   []Pool{{Subnet: (*IPSubnetStr)(ptr.To("192.168.0.0/29"))}},
+				Gateway: (*IPAddressStr)(ptr.To("192.168.0.1")),
+			},
+			expectedSize:  7,
+			expectedFirst: "192.168.0.1",
+			expectedLast:  "192.168.0.7",
+		}),
+		Entry("IPv4 subnet excludes the gateway, dns servers and broadcast", testCaseReservedAddresses{
+			spec: IPPoolSpec{
+				Pools:                    []Pool{{Subnet: (*IPSubnetStr)(ptr.To("192.168.0.0/29"))}},
+				Gateway:                  (*IPAddressStr)(ptr.To("192.168.0.1")),
+				DNSServers:               []IPAddressStr{"192.168.0.3"},
+				ExcludeReservedAddresses: true,
+			},
+			expectedSize:    4,
+			expectedFirst:   "192.168.0.2",
+			expectedLast:    "192.168.0.6",
+			expectedIndexes: map[int]IPAddressStr{1: "192.168.0.4"},
+		}),
+		Entry("IPv4 start with prefix excludes the network and broadcast", testCaseReservedAddresses{
+			spec: IPPoolSpec{
+				Pools: []Pool{
+					{
+						Start:  (*IPAddressStr)(ptr.To("192.168.0.0")),
+						End:    (*IPAddressStr)(ptr.To("192.168.0.7")),
+						Prefix: 29,
+					},
+				},
+				ExcludeReservedAddresses: true,
+			},
+			expectedSize:  6,
+			expectedFirst: "192.168.0.1",
+			expectedLast:  "192.168.0.6",
+		}),
+		Entry("Pool entry overrides the IPPool setting", testCaseReservedAddresses{
+			spec: IPPoolSpec{
+				Pools: []Pool{
+					{
+						Subnet:                   (*IPSubnetStr)(ptr.To("192.168.0.0/29")),
+						ExcludeReservedAddresses: ptr.To(true),
+					},
+				},
+			},
+			expectedSize:  6,
+			expectedFirst: "192.168.0.1",
+			expectedLast:  "192.168.0.6",
+		}),
+		Entry("IPv4 /31 follows RFC 3021", testCaseReservedAddresses{
+			spec: IPPoolSpec{
+				Pools:                    []Pool{{Subnet: (*IPSubnetStr)(ptr.To("192.168.0.0/31"))}},
+				ExcludeReservedAddresses: true,
+			},
+			expectedSize:  2,
+			expectedFirst: "192.168.0.0",
+			expectedLast:  "192.168.0.1",
+		}),
+		Entry("IPv4 /32 is a single address", testCaseReservedAddresses{
+			spec: IPPoolSpec{
+				Pools:                    []Pool{{Subnet: (*IPSubnetStr)(ptr.To("192.168.0.5/32"))}},
+				ExcludeReservedAddresses: true,
+			},
+			expectedSize:  1,
+			expectedFirst: "192.168.0.5",
+			expectedLast:  "192.168.0.5",
+		}),
+		Entry("IPv4 /31 still excludes the gateway", testCaseReservedAddresses{
+			spec: IPPoolSpec{
+				Pools:                    []Pool{{Subnet: (*IPSubnetStr)(ptr.To("192.168.0.0/31"))}},
+				Gateway:                  (*IPAddressStr)(ptr.To("192.168.0.0")),
+				ExcludeReservedAddresses: true,
+			},
+			expectedSize:  1,
+			expectedFirst: "192.168.0.1",
+			expectedLast:  "192.168.0.1",
+		}),
+		Entry("IPv6 subnet keeps the last address", testCaseReservedAddresses{
+			spec: IPPoolSpec{
+				Pools: []Pool{
+					{
+						Start:  (*IPAddressStr)(ptr.To("2001:db8::")),
+						End:    (*IPAddressStr)(ptr.To("2001:db8::7")),
+						Subnet: (*IPSubnetStr)(ptr.To("2001:db8::/125")),
+					},
+				},
+				ExcludeReservedAddresses: true,
+			},
+			expectedSize:  7,
+			expectedFirst: "2001:db8::1",
+			expectedLast:  "2001:db8::7",
+		}),
+		Entry("IPv6 /127 follows RFC 6164", testCaseReservedAddresses{
+			spec: IPPoolSpec{
+				Pools:                    []Pool{{Subnet: (*IPSubnetStr)(ptr.To("2001:db8::/127"))}},
+				ExcludeReservedAddresses: true,
+			},
+			expectedSize:  2,
+			expectedFirst: "2001:db8::",
+			expectedLast:  "2001:db8::1",
+		}),
+		Entry("IPv6 /128 is a single address", testCaseReservedAddresses{
+			spec: IPPoolSpec{
+				Pools:                    []Pool{{Subnet: (*IPSubnetStr)(ptr.To("2001:db8::5/128"))}},
+				ExcludeReservedAddresses: true,
+			},
+			expectedSize:  1,
+			expectedFirst: "2001:db8::5",
+			expectedLast:  "2001:db8::5",
+		}),
 	)
 
 })
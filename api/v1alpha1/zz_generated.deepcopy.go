@@ -21,10 +21,152 @@ limitations under the License.
 package v1alpha1
 
 import (
-	"k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AddressReservation) DeepCopyInto(out *AddressReservation) {
+	*out = *in
+	out.Claim = in.Claim
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AddressReservation.
+func (in *AddressReservation) DeepCopy() *AddressReservation {
+	if in == nil {
+		return nil
+	}
+	out := new(AddressReservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AllocationQuota) DeepCopyInto(out *AllocationQuota) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AllocationQuota.
+func (in *AllocationQuota) DeepCopy() *AllocationQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(AllocationQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimRetry) DeepCopyInto(out *ClaimRetry) {
+	*out = *in
+	out.Claim = in.Claim
+	in.LastAttempt.DeepCopyInto(&out.LastAttempt)
+	if in.RetryAt != nil {
+		in, out := &in.RetryAt, &out.RetryAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimRetry.
+func (in *ClaimRetry) DeepCopy() *ClaimRetry {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimRetry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GlobalIPPool) DeepCopyInto(out *GlobalIPPool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalIPPool.
+func (in *GlobalIPPool) DeepCopy() *GlobalIPPool {
+	if in == nil {
+		return nil
+	}
+	out := new(GlobalIPPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GlobalIPPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GlobalIPPoolList) DeepCopyInto(out *GlobalIPPoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GlobalIPPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalIPPoolList.
+func (in *GlobalIPPoolList) DeepCopy() *GlobalIPPoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(GlobalIPPoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GlobalIPPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GlobalIPPoolSpec) DeepCopyInto(out *GlobalIPPoolSpec) {
+	*out = *in
+	in.IPPoolSpec.DeepCopyInto(&out.IPPoolSpec)
+	if in.NamespaceSelector != nil {
+		in, out := &in.NamespaceSelector, &out.NamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GlobalIPPoolSpec.
+func (in *GlobalIPPoolSpec) DeepCopy() *GlobalIPPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GlobalIPPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IPAddress) DeepCopyInto(out *IPAddress) {
 	*out = *in
@@ -93,6 +235,11 @@ func (in *IPAddressSpec) DeepCopyInto(out *IPAddressSpec) {
 		*out = new(IPAddressStr)
 		**out = **in
 	}
+	if in.Subnet != nil {
+		in, out := &in.Subnet, &out.Subnet
+		*out = new(IPSubnetStr)
+		**out = **in
+	}
 	if in.DNSServers != nil {
 		in, out := &in.DNSServers, &out.DNSServers
 		*out = make([]IPAddressStr, len(*in))
@@ -115,7 +262,7 @@ func (in *IPClaim) DeepCopyInto(out *IPClaim) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -173,6 +320,21 @@ func (in *IPClaimList) DeepCopyObject() runtime.Object {
 func (in *IPClaimSpec) DeepCopyInto(out *IPClaimSpec) {
 	*out = *in
 	out.Pool = in.Pool
+	if in.SecondaryPool != nil {
+		in, out := &in.SecondaryPool, &out.SecondaryPool
+		*out = new(corev1.ObjectReference)
+		**out = **in
+	}
+	if in.RequestedAddress != nil {
+		in, out := &in.RequestedAddress, &out.RequestedAddress
+		*out = new(IPAddressStr)
+		**out = **in
+	}
+	if in.PoolEntrySelector != nil {
+		in, out := &in.PoolEntrySelector, &out.PoolEntrySelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPClaimSpec.
@@ -190,7 +352,12 @@ func (in *IPClaimStatus) DeepCopyInto(out *IPClaimStatus) {
 	*out = *in
 	if in.Address != nil {
 		in, out := &in.Address, &out.Address
-		*out = new(v1.ObjectReference)
+		*out = new(corev1.ObjectReference)
+		**out = **in
+	}
+	if in.SecondaryAddress != nil {
+		in, out := &in.SecondaryAddress, &out.SecondaryAddress
+		*out = new(corev1.ObjectReference)
 		**out = **in
 	}
 	if in.ErrorMessage != nil {
@@ -198,6 +365,13 @@ func (in *IPClaimStatus) DeepCopyInto(out *IPClaimStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPClaimStatus.
@@ -237,6 +411,104 @@ func (in *IPPool) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPPoolCapacity) DeepCopyInto(out *IPPoolCapacity) {
+	*out = *in
+	if in.FreeRanges != nil {
+		in, out := &in.FreeRanges, &out.FreeRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPPoolCapacity.
+func (in *IPPoolCapacity) DeepCopy() *IPPoolCapacity {
+	if in == nil {
+		return nil
+	}
+	out := new(IPPoolCapacity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPPoolGrant) DeepCopyInto(out *IPPoolGrant) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPPoolGrant.
+func (in *IPPoolGrant) DeepCopy() *IPPoolGrant {
+	if in == nil {
+		return nil
+	}
+	out := new(IPPoolGrant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IPPoolGrant) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPPoolGrantList) DeepCopyInto(out *IPPoolGrantList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]IPPoolGrant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPPoolGrantList.
+func (in *IPPoolGrantList) DeepCopy() *IPPoolGrantList {
+	if in == nil {
+		return nil
+	}
+	out := new(IPPoolGrantList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *IPPoolGrantList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPPoolGrantSpec) DeepCopyInto(out *IPPoolGrantSpec) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPPoolGrantSpec.
+func (in *IPPoolGrantSpec) DeepCopy() *IPPoolGrantSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IPPoolGrantSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IPPoolList) DeepCopyInto(out *IPPoolList) {
 	*out = *in
@@ -284,6 +556,28 @@ func (in *IPPoolSpec) DeepCopyInto(out *IPPoolSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ParentPool != nil {
+		in, out := &in.ParentPool, &out.ParentPool
+		*out = new(ParentPoolReference)
+		**out = **in
+	}
+	if in.QuarantineDuration != nil {
+		in, out := &in.QuarantineDuration, &out.QuarantineDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ReservationTTL != nil {
+		in, out := &in.ReservationTTL, &out.ReservationTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Quotas != nil {
+		in, out := &in.Quotas, &out.Quotas
+		*out = make([]AllocationQuota, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.PreAllocations != nil {
 		in, out := &in.PreAllocations, &out.PreAllocations
 		*out = make(map[string]IPAddressStr, len(*in))
@@ -291,6 +585,11 @@ func (in *IPPoolSpec) DeepCopyInto(out *IPPoolSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.Exclusions != nil {
+		in, out := &in.Exclusions, &out.Exclusions
+		*out = make([]IPExclusionStr, len(*in))
+		copy(*out, *in)
+	}
 	if in.Gateway != nil {
 		in, out := &in.Gateway, &out.Gateway
 		*out = new(IPAddressStr)
@@ -327,6 +626,58 @@ func (in *IPPoolStatus) DeepCopyInto(out *IPPoolStatus) {
 			(*out)[key] = val
 		}
 	}
+	if in.ParentSubnet != nil {
+		in, out := &in.ParentSubnet, &out.ParentSubnet
+		*out = new(IPSubnetStr)
+		**out = **in
+	}
+	if in.ReleasedAddresses != nil {
+		in, out := &in.ReleasedAddresses, &out.ReleasedAddresses
+		*out = make(map[IPAddressStr]v1.Time, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.QuarantinedAddresses != nil {
+		in, out := &in.QuarantinedAddresses, &out.QuarantinedAddresses
+		*out = make(map[IPAddressStr]v1.Time, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Reservations != nil {
+		in, out := &in.Reservations, &out.Reservations
+		*out = make([]AddressReservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Retries != nil {
+		in, out := &in.Retries, &out.Retries
+		*out = make([]ClaimRetry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = new(IPPoolCapacity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Pools != nil {
+		in, out := &in.Pools, &out.Pools
+		*out = make([]PoolStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPPoolStatus.
@@ -339,9 +690,31 @@ func (in *IPPoolStatus) DeepCopy() *IPPoolStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ParentPoolReference) DeepCopyInto(out *ParentPoolReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ParentPoolReference.
+func (in *ParentPoolReference) DeepCopy() *ParentPoolReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ParentPoolReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Pool) DeepCopyInto(out *Pool) {
 	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.Start != nil {
 		in, out := &in.Start, &out.Start
 		*out = new(IPAddressStr)
@@ -367,6 +740,16 @@ func (in *Pool) DeepCopyInto(out *Pool) {
 		*out = make([]IPAddressStr, len(*in))
 		copy(*out, *in)
 	}
+	if in.Exclusions != nil {
+		in, out := &in.Exclusions, &out.Exclusions
+		*out = make([]IPExclusionStr, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExcludeReservedAddresses != nil {
+		in, out := &in.ExcludeReservedAddresses, &out.ExcludeReservedAddresses
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Pool.
@@ -378,3 +761,19 @@ func (in *Pool) DeepCopy() *Pool {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PoolStatus) DeepCopyInto(out *PoolStatus) {
+	*out = *in
+	in.IPPoolCapacity.DeepCopyInto(&out.IPPoolCapacity)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PoolStatus.
+func (in *PoolStatus) DeepCopy() *PoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	"github.com/metal3-io/ip-address-manager/ipam"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+const (
+	globalIPPoolControllerName = "GlobalIPPool-controller"
+)
+
+// GlobalIPPoolReconciler reconciles a GlobalIPPool object.
+type GlobalIPPoolReconciler struct {
+	Client           client.Client
+	ManagerFactory   ipam.ManagerFactoryInterface
+	Log              logr.Logger
+	WatchFilterValue string
+}
+
+// +kubebuilder:rbac:groups=ipam.metal3.io,resources=globalippools,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=ipam.metal3.io,resources=globalippools/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// Reconcile handles GlobalIPPool events.
+func (r *GlobalIPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, rerr error) {
+	metadataLog := r.Log.WithName(globalIPPoolControllerName).WithValues("metal3-globalippool", req.Name)
+
+	// Fetch the GlobalIPPool instance.
+	ipamv1GlobalIPPool := &ipamv1.GlobalIPPool{}
+
+	var err error
+	var helper *patch.Helper
+
+	if err = r.Client.Get(ctx, req.NamespacedName, ipamv1GlobalIPPool); err != nil {
+		if apierrors.IsNotFound(err) {
+			// The claims referencing the missing pool report it.
+			return ctrl.Result{}, ipam.SetClaimsPoolNotFound(ctx, r.Client, req.NamespacedName, true)
+		}
+		return ctrl.Result{}, err
+	}
+
+	// Deep copy before reconcile
+	original := ipamv1GlobalIPPool.DeepCopy()
+
+	helper, err = patch.NewHelper(ipamv1GlobalIPPool, r.Client)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to init patch helper: %w", err)
+	}
+	// Always patch ipamv1GlobalIPPool exiting this function so we can persist
+	// any GlobalIPPool changes.
+	defer func() {
+		if !reflect.DeepEqual(original, ipamv1GlobalIPPool) {
+			err = helper.Patch(ctx, ipamv1GlobalIPPool)
+			if err != nil {
+				metadataLog.Info("failed to Patch ipamv1GlobalIPPool", "error", err)
+				rerr = err
+			}
+		}
+	}()
+
+	// Return early if the GlobalIPPool is paused. It is reconciled again
+	// once it is unpaused.
+	if HasPaused(ipamv1GlobalIPPool) {
+		metadataLog.Info("reconciliation is paused for this object")
+		return ctrl.Result{}, nil
+	}
+
+	// Create a helper for managing the GlobalIPPool object.
+	globalIPPoolMgr, err := r.ManagerFactory.NewGlobalIPPoolManager(ipamv1GlobalIPPool, metadataLog)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to create helper for managing the global IP pool: %w", err)
+	}
+
+	// Handle deleted GlobalIPPool
+	if !ipamv1GlobalIPPool.ObjectMeta.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(ctx, globalIPPoolMgr)
+	}
+
+	// Handle non-deleted GlobalIPPool
+	result, err := r.reconcileNormal(ctx, globalIPPoolMgr)
+	if err == nil && result.IsZero() {
+		// Reconcile again when the first quarantine or reservation ends.
+		result.RequeueAfter = ipam.RequeueAfter(ipamv1GlobalIPPool.Status)
+	}
+	return result, err
+}
+
+func (r *GlobalIPPoolReconciler) reconcileNormal(ctx context.Context,
+	globalIPPoolMgr ipam.IPPoolManagerInterface,
+) (ctrl.Result, error) {
+	// If the GlobalIPPool doesn't have finalizer, add it.
+	globalIPPoolMgr.SetFinalizer()
+
+	_, err := globalIPPoolMgr.UpdateAddresses(ctx)
+	if err != nil {
+		return checkReconcileError(err, "Failed to create the missing data")
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *GlobalIPPoolReconciler) reconcileDelete(ctx context.Context,
+	globalIPPoolMgr ipam.IPPoolManagerInterface,
+) (ctrl.Result, error) {
+	allocationsNb, err := globalIPPoolMgr.UpdateAddresses(ctx)
+	if err != nil {
+		return checkReconcileError(err, "Failed to delete the old addresses")
+	}
+
+	if allocationsNb == 0 {
+		// GlobalIPPool is marked for deletion and ready to be deleted,
+		// so remove the finalizer.
+		globalIPPoolMgr.UnsetFinalizer()
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager will add watches for this controller.
+func (r *GlobalIPPoolReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("GlobalIPPoolReconciler").
+		For(&ipamv1.GlobalIPPool{}).
+		WithOptions(options).
+		Watches(
+			&ipamv1.IPClaim{},
+			handler.EnqueueRequestsFromMapFunc(r.IPClaimToGlobalIPPool),
+		).
+		Watches(
+			&capipamv1.IPAddressClaim{},
+			handler.EnqueueRequestsFromMapFunc(r.IPAddressClaimToGlobalIPPool),
+		).
+		Watches(
+			&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.NamespaceToGlobalIPPools),
+		).
+		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(mgr.GetScheme(), ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
+		Complete(r)
+}
+
+// IPClaimToGlobalIPPool will return a reconcile request for a GlobalIPPool if
+// the event is for an IPClaim that references a GlobalIPPool.
+func (r *GlobalIPPoolReconciler) IPClaimToGlobalIPPool(_ context.Context, obj client.Object) []ctrl.Request {
+	requests := []ctrl.Request{}
+	if m3ipc, ok := obj.(*ipamv1.IPClaim); ok {
+		pools := []corev1.ObjectReference{m3ipc.Spec.Pool}
+		if m3ipc.Spec.SecondaryPool != nil {
+			pools = append(pools, *m3ipc.Spec.SecondaryPool)
+		}
+		for _, pool := range pools {
+			if pool.Name != "" && pool.Kind == ipamv1.GlobalIPPoolKind {
+				requests = append(requests, ctrl.Request{
+					NamespacedName: types.NamespacedName{
+						Name: pool.Name,
+					},
+				})
+			}
+		}
+	}
+	return requests
+}
+
+// IPAddressClaimToGlobalIPPool will return a reconcile request for a
+// GlobalIPPool if the event is for an IPAddressClaim that references a
+// GlobalIPPool.
+func (r *GlobalIPPoolReconciler) IPAddressClaimToGlobalIPPool(_ context.Context, obj client.Object) []ctrl.Request {
+	if ipac, ok := obj.(*capipamv1.IPAddressClaim); ok {
+		if ipac.Spec.PoolRef.Name != "" && ipac.Spec.PoolRef.Kind == ipamv1.GlobalIPPoolKind &&
+			ipac.Spec.PoolRef.APIGroup == ipam.APIGroup {
+			return []ctrl.Request{
+				{
+					NamespacedName: types.NamespacedName{
+						Name: ipac.Spec.PoolRef.Name,
+					},
+				},
+			}
+		}
+	}
+	return []ctrl.Request{}
+}
+
+// NamespaceToGlobalIPPools will return a reconcile request for all the
+// GlobalIPPools with a namespace selector when the labels of a namespace
+// change, so that its claims are served.
+func (r *GlobalIPPoolReconciler) NamespaceToGlobalIPPools(ctx context.Context, _ client.Object) []ctrl.Request {
+	globalIPPools := ipamv1.GlobalIPPoolList{}
+	if err := r.Client.List(ctx, &globalIPPools); err != nil {
+		r.Log.Error(err, "failed to list GlobalIPPools")
+		return []ctrl.Request{}
+	}
+
+	requests := []ctrl.Request{}
+	for _, globalIPPool := range globalIPPools.Items {
+		if globalIPPool.Spec.NamespaceSelector == nil {
+			continue
+		}
+		requests = append(requests, ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Name: globalIPPool.Name,
+			},
+		})
+	}
+	return requests
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	"github.com/metal3-io/ip-address-manager/ipam"
+	ipam_mocks "github.com/metal3-io/ip-address-manager/ipam/mocks"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/mock/gomock"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("GlobalIPPool controller", func() {
+	globalObjectMeta := metav1.ObjectMeta{
+		Name: "abc",
+	}
+
+	type testCaseReconcile struct {
+		m3gipp          *ipamv1.GlobalIPPool
+		expectManager   bool
+		managerError    bool
+		reconcileError  bool
+		expectError     bool
+		reconcileNormal bool
+	}
+
+	DescribeTable("Test Reconcile",
+		func(tc testCaseReconcile) {
+			gomockCtrl := gomock.NewController(GinkgoT())
+			f := ipam_mocks.NewMockManagerFactoryInterface(gomockCtrl)
+			m := ipam_mocks.NewMockIPPoolManagerInterface(gomockCtrl)
+
+			objects := []client.Object{}
+			if tc.m3gipp != nil {
+				objects = append(objects, tc.m3gipp)
+			}
+			c := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).WithStatusSubresource(&ipamv1.GlobalIPPool{}).
+				WithIndex(&ipamv1.IPClaim{}, ipam.IPClaimPoolField, ipam.IPClaimPools).Build()
+
+			if tc.managerError {
+				f.EXPECT().NewGlobalIPPoolManager(gomock.Any(), gomock.Any()).Return(nil, errors.New(""))
+			} else if tc.expectManager {
+				f.EXPECT().NewGlobalIPPoolManager(gomock.Any(), gomock.Any()).Return(m, nil)
+			}
+
+			if tc.expectManager && tc.reconcileNormal {
+				m.EXPECT().SetFinalizer()
+			}
+			if tc.expectManager {
+				if tc.reconcileError {
+					m.EXPECT().UpdateAddresses(context.Background()).Return(0, errors.New(""))
+				} else {
+					m.EXPECT().UpdateAddresses(context.Background()).Return(0, nil)
+				}
+				if !tc.reconcileNormal && !tc.reconcileError {
+					m.EXPECT().UnsetFinalizer()
+				}
+			}
+
+			globalIPPoolReconcile := &GlobalIPPoolReconciler{
+				Client:         c,
+				ManagerFactory: f,
+				Log:            logr.Discard(),
+			}
+
+			req := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name: "abc",
+				},
+			}
+
+			result, err := globalIPPoolReconcile.Reconcile(context.Background(), req)
+
+			if tc.expectError {
+				Expect(err).To(HaveOccurred())
+			} else {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(result.RequeueAfter).To(BeZero())
+			gomockCtrl.Finish()
+		},
+		Entry("GlobalIPPool not found", testCaseReconcile{}),
+		Entry("GlobalIPPool paused", testCaseReconcile{
+			m3gipp: &ipamv1.GlobalIPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "abc",
+					Annotations: map[string]string{
+						clusterv1.PausedAnnotation: "true",
+					},
+				},
+			},
+		}),
+		Entry("Error in manager", testCaseReconcile{
+			m3gipp: &ipamv1.GlobalIPPool{
+				ObjectMeta: globalObjectMeta,
+			},
+			managerError: true,
+			expectError:  true,
+		}),
+		Entry("Reconcile normal error", testCaseReconcile{
+			m3gipp: &ipamv1.GlobalIPPool{
+				ObjectMeta: globalObjectMeta,
+			},
+			expectManager:   true,
+			reconcileNormal: true,
+			reconcileError:  true,
+			expectError:     true,
+		}),
+		Entry("Reconcile normal no error", testCaseReconcile{
+			m3gipp: &ipamv1.GlobalIPPool{
+				ObjectMeta: globalObjectMeta,
+			},
+			expectManager:   true,
+			reconcileNormal: true,
+		}),
+		Entry("Reconcile delete", testCaseReconcile{
+			m3gipp: &ipamv1.GlobalIPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "abc",
+					DeletionTimestamp: &timestampNow,
+					Finalizers:        []string{ipamv1.IPPoolFinalizer},
+				},
+			},
+			expectManager: true,
+		}),
+	)
+
+	DescribeTable("IPClaim To GlobalIPPool tests",
+		func(pool corev1.ObjectReference, expectRequest bool) {
+			r := GlobalIPPoolReconciler{}
+			obj := client.Object(&ipamv1.IPClaim{
+				ObjectMeta: testObjectMeta,
+				Spec:       ipamv1.IPClaimSpec{Pool: pool},
+			})
+			reqs := r.IPClaimToGlobalIPPool(context.Background(), obj)
+
+			if expectRequest {
+				Expect(reqs).To(ConsistOf(reconcile.Request{NamespacedName: types.NamespacedName{Name: pool.Name}}))
+			} else {
+				Expect(reqs).To(BeEmpty())
+			}
+		},
+		Entry("No pool in Spec", corev1.ObjectReference{}, false),
+		Entry("IPPool in Spec", corev1.ObjectReference{Name: "abc", Namespace: "myns"}, false),
+		Entry("GlobalIPPool in Spec", corev1.ObjectReference{Name: "abc", Kind: ipamv1.GlobalIPPoolKind}, true),
+	)
+
+	DescribeTable("IPAddressClaim To GlobalIPPool tests",
+		func(poolRef capipamv1.IPPoolReference, expectRequest bool) {
+			r := GlobalIPPoolReconciler{}
+			obj := client.Object(&capipamv1.IPAddressClaim{
+				ObjectMeta: testObjectMeta,
+				Spec:       capipamv1.IPAddressClaimSpec{PoolRef: poolRef},
+			})
+			reqs := r.IPAddressClaimToGlobalIPPool(context.Background(), obj)
+
+			if expectRequest {
+				Expect(reqs).To(ConsistOf(reconcile.Request{NamespacedName: types.NamespacedName{Name: poolRef.Name}}))
+			} else {
+				Expect(reqs).To(BeEmpty())
+			}
+		},
+		Entry("No pool in Spec", capipamv1.IPPoolReference{}, false),
+		Entry("IPPool in Spec", capipamv1.IPPoolReference{Name: "abc", Kind: "IPPool", APIGroup: "ipam.metal3.io"}, false),
+		Entry("GlobalIPPool of another group in Spec", capipamv1.IPPoolReference{Name: "abc", Kind: ipamv1.GlobalIPPoolKind, APIGroup: "example.com"}, false),
+		Entry("GlobalIPPool in Spec", capipamv1.IPPoolReference{Name: "abc", Kind: ipamv1.GlobalIPPoolKind, APIGroup: "ipam.metal3.io"}, true),
+	)
+
+	It("Maps a Namespace to the GlobalIPPools with a namespace selector", func() {
+		objects := []client.Object{
+			&ipamv1.GlobalIPPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "all"},
+			},
+			&ipamv1.GlobalIPPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "selected"},
+				Spec: ipamv1.GlobalIPPoolSpec{
+					NamespaceSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{"tenant": "true"},
+					},
+				},
+			},
+		}
+		c := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+		r := GlobalIPPoolReconciler{Client: c, Log: logr.Discard()}
+
+		reqs := r.NamespaceToGlobalIPPools(context.Background(), &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "myns"},
+		})
+		Expect(reqs).To(ConsistOf(reconcile.Request{NamespacedName: types.NamespacedName{Name: "selected"}}))
+	})
+})
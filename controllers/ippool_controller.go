@@ -21,11 +21,11 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"time"
 
 	"github.com/go-logr/logr"
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	"github.com/metal3-io/ip-address-manager/ipam"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -42,7 +42,6 @@ import (
 
 const (
 	ipPoolControllerName = "IPPool-controller"
-	requeueAfter         = time.Second * 30
 )
 
 // IPPoolReconciler reconciles a IPPool object.
@@ -59,6 +58,7 @@ type IPPoolReconciler struct {
 // +kubebuilder:rbac:groups=ipam.metal3.io,resources=ipclaims/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=ipam.metal3.io,resources=ipaddresses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=ipam.metal3.io,resources=ipaddresses/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=ipam.metal3.io,resources=ippoolgrants,verbs=get;list;watch
 // +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddresses,verbs=get;list;watch;create;update;patch;delete
@@ -79,7 +79,8 @@ func (r *IPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ c
 
 	if err = r.Client.Get(ctx, req.NamespacedName, ipamv1IPPool); err != nil {
 		if apierrors.IsNotFound(err) {
-			return ctrl.Result{}, nil
+			// The claims referencing the missing pool report it.
+			return ctrl.Result{}, ipam.SetClaimsPoolNotFound(ctx, r.Client, req.NamespacedName, false)
 		}
 		return ctrl.Result{}, err
 	}
@@ -119,7 +120,12 @@ func (r *IPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ c
 		err = r.Client.Get(ctx, key, cluster)
 		if ipamv1IPPool.ObjectMeta.DeletionTimestamp.IsZero() {
 			if err != nil {
-				metadataLog.Info("Error fetching cluster. It might not exist yet, Requeuing")
+				// The IPPool is reconciled again once the Cluster is created.
+				metadataLog.Info("Error fetching cluster. It might not exist yet")
+				ipam.SetIPPoolHealthConditions(ipamv1IPPool, metav1.ConditionFalse,
+					ipamv1.IPPoolClusterNotFoundReason,
+					fmt.Sprintf("Cluster %s not found", *ipamv1IPPool.Spec.ClusterName),
+				)
 				return ctrl.Result{}, nil
 			}
 		}
@@ -139,10 +145,11 @@ func (r *IPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ c
 			return ctrl.Result{}, err
 		}
 
-		// Return early if the Metadata or Cluster is paused.
+		// Return early if the Metadata or Cluster is paused. The IPPool is
+		// reconciled again once it, or its Cluster, is unpaused.
 		if IsPaused(cluster, ipamv1IPPool) {
 			metadataLog.Info("reconciliation is paused for this object")
-			return ctrl.Result{Requeue: true, RequeueAfter: requeueAfter}, nil
+			return ctrl.Result{}, nil
 		}
 	}
 
@@ -152,7 +159,12 @@ func (r *IPPoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ c
 	}
 
 	// Handle non-deleted machines
-	return r.reconcileNormal(ctx, ipPoolMgr)
+	result, err := r.reconcileNormal(ctx, ipPoolMgr)
+	if err == nil && result.IsZero() {
+		// Reconcile again when the first quarantine or reservation ends.
+		result.RequeueAfter = ipam.RequeueAfter(ipamv1IPPool.Status)
+	}
+	return result, err
 }
 
 func (r *IPPoolReconciler) reconcileNormal(ctx context.Context,
@@ -186,8 +198,11 @@ func (r *IPPoolReconciler) reconcileDelete(ctx context.Context,
 	return ctrl.Result{}, nil
 }
 
-// SetupWithManager will add watches for this controller.
-func (r *IPPoolReconciler) SetupWithManagerForIPClaim(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
+// SetupWithManager will add watches for this controller. A single controller
+// serves both the IPClaims and the IPAddressClaims, so that the
+// reconciliations of an IPPool are serialized and no address is allocated to
+// two claims of different kinds.
+func (r *IPPoolReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		Named("IPPoolReconciler").
 		For(&ipamv1.IPPool{}).
@@ -196,20 +211,30 @@ func (r *IPPoolReconciler) SetupWithManagerForIPClaim(ctx context.Context, mgr c
 			&ipamv1.IPClaim{},
 			handler.EnqueueRequestsFromMapFunc(r.IPClaimToIPPool),
 		).
-		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(mgr.GetScheme(), ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
-		Complete(r)
-}
-
-// SetupWithManager will add watches for this controller.
-func (r *IPPoolReconciler) SetupWithManagerForIPAddressClaim(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		Named("IPPoolReconcilerForCAPI").
-		For(&ipamv1.IPPool{}).
-		WithOptions(options).
 		Watches(
 			&capipamv1.IPAddressClaim{},
 			handler.EnqueueRequestsFromMapFunc(r.IPAddressClaimToIPPool),
 		).
+		Watches(
+			&ipamv1.IPPoolGrant{},
+			handler.EnqueueRequestsFromMapFunc(r.IPPoolGrantToIPPool),
+		).
+		Watches(
+			&ipamv1.IPPool{},
+			handler.EnqueueRequestsFromMapFunc(r.IPPoolToParentIPPool),
+		).
+		Watches(
+			&ipamv1.IPAddress{},
+			handler.EnqueueRequestsFromMapFunc(r.IPAddressToIPPool),
+		).
+		Watches(
+			&capipamv1.IPAddress{},
+			handler.EnqueueRequestsFromMapFunc(r.IPAddressToIPPool),
+		).
+		Watches(
+			&clusterv1.Cluster{},
+			handler.EnqueueRequestsFromMapFunc(r.ClusterToIPPools),
+		).
 		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(mgr.GetScheme(), ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
 		Complete(r)
 }
@@ -218,17 +243,44 @@ func (r *IPPoolReconciler) SetupWithManagerForIPAddressClaim(ctx context.Context
 // Metal3DataTemplate if the event is for a
 // IPClaim and that IPClaim references a Metal3DataTemplate.
 func (r *IPPoolReconciler) IPClaimToIPPool(_ context.Context, obj client.Object) []ctrl.Request {
+	requests := []ctrl.Request{}
 	if m3ipc, ok := obj.(*ipamv1.IPClaim); ok {
-		if m3ipc.Spec.Pool.Name != "" {
-			namespace := m3ipc.Spec.Pool.Namespace
+		pools := []corev1.ObjectReference{m3ipc.Spec.Pool}
+		// Both pools of a dual-stack IPClaim are reconciled.
+		if m3ipc.Spec.SecondaryPool != nil {
+			pools = append(pools, *m3ipc.Spec.SecondaryPool)
+		}
+		for _, pool := range pools {
+			// GlobalIPPools are reconciled by the GlobalIPPoolReconciler.
+			if pool.Name == "" || pool.Kind == ipamv1.GlobalIPPoolKind {
+				continue
+			}
+			namespace := pool.Namespace
 			if namespace == "" {
 				namespace = m3ipc.Namespace
 			}
+			requests = append(requests, ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      pool.Name,
+					Namespace: namespace,
+				},
+			})
+		}
+	}
+	return requests
+}
+
+// IPPoolGrantToIPPool will return a reconcile request for the IPPool
+// referenced by an IPPoolGrant, so that the IPClaims of the granted
+// namespaces are served, or released when the grant is removed.
+func (r *IPPoolReconciler) IPPoolGrantToIPPool(_ context.Context, obj client.Object) []ctrl.Request {
+	if grant, ok := obj.(*ipamv1.IPPoolGrant); ok {
+		if grant.Spec.Pool != "" {
 			return []ctrl.Request{
 				{
 					NamespacedName: types.NamespacedName{
-						Name:      m3ipc.Spec.Pool.Name,
-						Namespace: namespace,
+						Name:      grant.Spec.Pool,
+						Namespace: grant.Namespace,
 					},
 				},
 			}
@@ -237,9 +289,107 @@ func (r *IPPoolReconciler) IPClaimToIPPool(_ context.Context, obj client.Object)
 	return []ctrl.Request{}
 }
 
+// IPPoolToParentIPPool will return a reconcile request for the parent IPPool
+// of a child IPPool, so that its subnet is allocated, resized or released.
+func (r *IPPoolReconciler) IPPoolToParentIPPool(_ context.Context, obj client.Object) []ctrl.Request {
+	if ipPool, ok := obj.(*ipamv1.IPPool); ok {
+		if ipPool.Spec.ParentPool != nil && ipPool.Spec.ParentPool.Name != "" {
+			return []ctrl.Request{
+				{
+					NamespacedName: types.NamespacedName{
+						Name:      ipPool.Spec.ParentPool.Name,
+						Namespace: ipPool.Namespace,
+					},
+				},
+			}
+		}
+	}
+	return []ctrl.Request{}
+}
+
+// IPAddressToIPPool will return a reconcile request for the child IPPool
+// whose subnet is held by an IPAddress, so that it gets its new range, and
+// for the IPPool of an IPAddress reserved for a deleted claim, so that it is
+// released once deleted.
+func (r *IPPoolReconciler) IPAddressToIPPool(_ context.Context, obj client.Object) []ctrl.Request {
+	requests := []ctrl.Request{}
+	switch ipAddress := obj.(type) {
+	case *ipamv1.IPAddress:
+		if ipAddress.Spec.Claim.Kind == ipamv1.IPPoolKind && ipAddress.Spec.Claim.Name != "" {
+			requests = append(requests, ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      ipAddress.Spec.Claim.Name,
+					Namespace: ipAddress.Namespace,
+				},
+			})
+		}
+		pool := ipAddress.Spec.Pool
+		// GlobalIPPools are reconciled by the GlobalIPPoolReconciler.
+		if isReserved(ipAddress) && pool.Name != "" && pool.Kind != ipamv1.GlobalIPPoolKind {
+			namespace := pool.Namespace
+			if namespace == "" {
+				namespace = ipAddress.Namespace
+			}
+			requests = append(requests, ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      pool.Name,
+					Namespace: namespace,
+				},
+			})
+		}
+	case *capipamv1.IPAddress:
+		pool := ipAddress.Spec.PoolRef
+		if isReserved(ipAddress) && pool.Name != "" && pool.Kind != ipamv1.GlobalIPPoolKind {
+			requests = append(requests, ctrl.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      pool.Name,
+					Namespace: ipAddress.Namespace,
+				},
+			})
+		}
+	}
+	return requests
+}
+
+// isReserved returns true if the IPAddress is reserved for a deleted claim.
+func isReserved(ipAddress client.Object) bool {
+	_, ok := ipAddress.GetAnnotations()[ipam.ReservedUntilAnnotation]
+	return ok
+}
+
+// ClusterToIPPools will return a reconcile request for the IPPools of a
+// Cluster, so that they are reconciled as soon as the Cluster is created,
+// paused or unpaused.
+func (r *IPPoolReconciler) ClusterToIPPools(ctx context.Context, obj client.Object) []ctrl.Request {
+	requests := []ctrl.Request{}
+	cluster, ok := obj.(*clusterv1.Cluster)
+	if !ok {
+		return requests
+	}
+	// The IPPools of a Cluster are labelled with its name when reconciled.
+	ipPools := &ipamv1.IPPoolList{}
+	if err := r.Client.List(ctx, ipPools, client.InNamespace(cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: cluster.Name}); err != nil {
+		r.Log.Error(err, "failed to list IPPools", "cluster", cluster.Name)
+		return requests
+	}
+	for _, ipPool := range ipPools.Items {
+		if ipPool.Spec.ClusterName == nil || *ipPool.Spec.ClusterName != cluster.Name {
+			continue
+		}
+		requests = append(requests, ctrl.Request{
+			NamespacedName: types.NamespacedName{
+				Name:      ipPool.Name,
+				Namespace: ipPool.Namespace,
+			},
+		})
+	}
+	return requests
+}
+
 func (r *IPPoolReconciler) IPAddressClaimToIPPool(_ context.Context, obj client.Object) []ctrl.Request {
 	if ipac, ok := obj.(*capipamv1.IPAddressClaim); ok {
-		if ipac.Spec.PoolRef.Name != "" {
+		if ipac.Spec.PoolRef.Name != "" && ipac.Spec.PoolRef.Kind != ipamv1.GlobalIPPoolKind {
 			namespace := ipac.Namespace
 			return []ctrl.Request{
 				{
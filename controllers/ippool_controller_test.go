@@ -19,6 +19,9 @@ package controllers
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
@@ -28,14 +31,19 @@ import (
 	. "github.com/onsi/gomega"
 	"go.uber.org/mock/gomock"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
 	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
@@ -50,7 +58,6 @@ var _ = Describe("IPPool controller", func() {
 
 	type testCaseReconcile struct {
 		expectError          bool
-		expectRequeue        bool
 		expectManager        bool
 		m3ipp                *ipamv1.IPPool
 		cluster              *clusterv1.Cluster
@@ -59,6 +66,7 @@ var _ = Describe("IPPool controller", func() {
 		reconcileNormalError bool
 		reconcileDeleteError bool
 		setOwnerRefError     bool
+		expectClusterMissing bool
 	}
 
 	DescribeTable("Test Reconcile",
@@ -74,7 +82,8 @@ var _ = Describe("IPPool controller", func() {
 			if tc.cluster != nil {
 				objects = append(objects, tc.cluster)
 			}
-			c := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build()
+			c := fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).WithStatusSubresource(&ipamv1.IPPool{}).
+				WithIndex(&ipamv1.IPClaim{}, ipam.IPClaimPoolField, ipam.IPClaimPools).Build()
 
 			if tc.managerError {
 				f.EXPECT().NewIPPoolManager(gomock.Any(), gomock.Any()).Return(nil, errors.New(""))
@@ -129,10 +138,17 @@ var _ = Describe("IPPool controller", func() {
 			} else {
 				Expect(err).NotTo(HaveOccurred())
 			}
-			if tc.expectRequeue {
-				Expect(result).To(Equal(ctrl.Result{RequeueAfter: requeueAfter, Requeue: true}))
-			} else {
-				Expect(result).ToNot(Equal(ctrl.Result{RequeueAfter: requeueAfter}))
+			Expect(result.RequeueAfter).To(BeZero())
+			if tc.expectClusterMissing {
+				ipPool := &ipamv1.IPPool{}
+				Expect(c.Get(context.Background(), req.NamespacedName, ipPool)).To(Succeed())
+				for _, conditionType := range []string{ipamv1.IPPoolReadyCondition, ipamv1.IPPoolDegradedCondition} {
+					condition := meta.FindStatusCondition(ipPool.Status.Conditions, conditionType)
+					Expect(condition).NotTo(BeNil())
+					Expect(condition.Reason).To(Equal(ipamv1.IPPoolClusterNotFoundReason))
+				}
+				Expect(meta.IsStatusConditionFalse(ipPool.Status.Conditions, ipamv1.IPPoolReadyCondition)).To(BeTrue())
+				Expect(meta.IsStatusConditionTrue(ipPool.Status.Conditions, ipamv1.IPPoolDegradedCondition)).To(BeTrue())
 			}
 			gomockCtrl.Finish()
 		},
@@ -142,6 +158,7 @@ var _ = Describe("IPPool controller", func() {
 				ObjectMeta: testObjectMeta,
 				Spec:       ipamv1.IPPoolSpec{ClusterName: ptr.To("abc")},
 			},
+			expectClusterMissing: true,
 		}),
 		Entry("Deletion, Cluster not found", testCaseReconcile{
 			m3ipp: &ipamv1.IPPool{
@@ -184,7 +201,6 @@ var _ = Describe("IPPool controller", func() {
 					Paused: ptr.To(true),
 				},
 			},
-			expectRequeue: true,
 			expectManager: true,
 		}),
 		Entry("Error in manager", testCaseReconcile{
@@ -231,9 +247,8 @@ var _ = Describe("IPPool controller", func() {
 	)
 
 	type reconcileNormalTestCase struct {
-		ExpectError   bool
-		ExpectRequeue bool
-		UpdateError   bool
+		ExpectError bool
+		UpdateError bool
 	}
 
 	DescribeTable("ReconcileNormal tests",
@@ -244,7 +259,7 @@ var _ = Describe("IPPool controller", func() {
 
 			ipPoolReconcile := &IPPoolReconciler{
 				Client:         c,
-				ManagerFactory: ipam.NewManagerFactory(c),
+				ManagerFactory: ipam.NewManagerFactory(c, record.NewFakeRecorder(10)),
 				Log:            logr.Discard(),
 			}
 			m := ipam_mocks.NewMockIPPoolManagerInterface(gomockCtrl)
@@ -265,28 +280,21 @@ var _ = Describe("IPPool controller", func() {
 			} else {
 				Expect(err).NotTo(HaveOccurred(), "Expected no error but got one: %v", err)
 			}
-			if tc.ExpectRequeue {
-				Expect(result).To(Equal(ctrl.Result{RequeueAfter: requeueAfter, Requeue: true}))
-			} else {
-				Expect(result).ToNot(Equal(ctrl.Result{RequeueAfter: requeueAfter}))
-			}
+			Expect(result).To(Equal(ctrl.Result{}))
 		},
 		Entry("No error", reconcileNormalTestCase{
-			ExpectError:   false,
-			ExpectRequeue: false,
+			ExpectError: false,
 		}),
 		Entry("Update error", reconcileNormalTestCase{
-			UpdateError:   true,
-			ExpectError:   true,
-			ExpectRequeue: false,
+			UpdateError: true,
+			ExpectError: true,
 		}),
 	)
 
 	type reconcileDeleteTestCase struct {
-		ExpectError   bool
-		ExpectRequeue bool
-		DeleteReady   bool
-		DeleteError   bool
+		ExpectError bool
+		DeleteReady bool
+		DeleteError bool
 	}
 
 	DescribeTable("ReconcileDelete tests",
@@ -296,7 +304,7 @@ var _ = Describe("IPPool controller", func() {
 			c := fake.NewClientBuilder().WithScheme(setupScheme()).Build()
 			ipPoolReconcile := &IPPoolReconciler{
 				Client:         c,
-				ManagerFactory: ipam.NewManagerFactory(c),
+				ManagerFactory: ipam.NewManagerFactory(c, record.NewFakeRecorder(10)),
 				Log:            logr.Discard(),
 			}
 			m := ipam_mocks.NewMockIPPoolManagerInterface(gomockCtrl)
@@ -318,25 +326,18 @@ var _ = Describe("IPPool controller", func() {
 			} else {
 				Expect(err).NotTo(HaveOccurred())
 			}
-			if tc.ExpectRequeue {
-				Expect(result).To(Equal(ctrl.Result{RequeueAfter: requeueAfter, Requeue: true}))
-			} else {
-				Expect(result).ToNot(Equal(ctrl.Result{RequeueAfter: requeueAfter}))
-			}
+			Expect(result).To(Equal(ctrl.Result{}))
 		},
 		Entry("No error", reconcileDeleteTestCase{
-			ExpectError:   false,
-			ExpectRequeue: false,
+			ExpectError: false,
 		}),
 		Entry("Delete error", reconcileDeleteTestCase{
-			DeleteError:   true,
-			ExpectError:   true,
-			ExpectRequeue: false,
+			DeleteError: true,
+			ExpectError: true,
 		}),
 		Entry("Delete ready", reconcileDeleteTestCase{
-			ExpectError:   false,
-			ExpectRequeue: false,
-			DeleteReady:   true,
+			ExpectError: false,
+			DeleteReady: true,
 		}),
 	)
 
@@ -406,8 +407,232 @@ var _ = Describe("IPPool controller", func() {
 				ExpectRequest: true,
 			},
 		),
+		Entry("GlobalIPPool in Spec",
+			TestCaseM3IPCToM3IPP{
+				IPClaim: &ipamv1.IPClaim{
+					ObjectMeta: testObjectMeta,
+					Spec: ipamv1.IPClaimSpec{
+						Pool: corev1.ObjectReference{
+							Name: "abc",
+							Kind: ipamv1.GlobalIPPoolKind,
+						},
+					},
+				},
+				ExpectRequest: false,
+			},
+		),
+	)
+
+	It("Requests both IPPools of a dual-stack IPClaim", func() {
+		r := IPPoolReconciler{}
+		ipClaim := &ipamv1.IPClaim{
+			ObjectMeta: testObjectMeta,
+			Spec: ipamv1.IPClaimSpec{
+				Pool: corev1.ObjectReference{
+					Name: "abc",
+				},
+				SecondaryPool: &corev1.ObjectReference{
+					Name:      "def",
+					Namespace: "otherns",
+				},
+			},
+		}
+		reqs := r.IPClaimToIPPool(context.Background(), ipClaim)
+		Expect(reqs).To(ConsistOf(
+			ctrl.Request{NamespacedName: types.NamespacedName{Name: "abc", Namespace: ipClaim.Namespace}},
+			ctrl.Request{NamespacedName: types.NamespacedName{Name: "def", Namespace: "otherns"}},
+		))
+	})
+
+	type TestCaseM3IPPGToM3IPP struct {
+		IPPoolGrant   *ipamv1.IPPoolGrant
+		ExpectRequest bool
+	}
+
+	DescribeTable("IPPoolGrant To IPPool tests",
+		func(tc TestCaseM3IPPGToM3IPP) {
+			r := IPPoolReconciler{}
+			obj := client.Object(tc.IPPoolGrant)
+			reqs := r.IPPoolGrantToIPPool(context.Background(), obj)
+
+			if tc.ExpectRequest {
+				Expect(reqs).To(HaveLen(1), "Expected 1 request, found %d", len(reqs))
+
+				req := reqs[0]
+				Expect(req.NamespacedName.Name).To(Equal(tc.IPPoolGrant.Spec.Pool))
+				Expect(req.NamespacedName.Namespace).To(Equal(tc.IPPoolGrant.Namespace))
+			} else {
+				Expect(reqs).To(BeEmpty(), "Expected 0 request, found %d", len(reqs))
+			}
+		},
+		Entry("No IPPool in Spec",
+			TestCaseM3IPPGToM3IPP{
+				IPPoolGrant: &ipamv1.IPPoolGrant{
+					ObjectMeta: testObjectMeta,
+				},
+				ExpectRequest: false,
+			},
+		),
+		Entry("IPPool in Spec",
+			TestCaseM3IPPGToM3IPP{
+				IPPoolGrant: &ipamv1.IPPoolGrant{
+					ObjectMeta: testObjectMeta,
+					Spec: ipamv1.IPPoolGrantSpec{
+						Pool:       "abc",
+						Namespaces: []string{"tenant"},
+					},
+				},
+				ExpectRequest: true,
+			},
+		),
+	)
+
+	It("Requests the parent IPPool of a child IPPool", func() {
+		r := IPPoolReconciler{}
+		childPool := &ipamv1.IPPool{
+			ObjectMeta: testObjectMeta,
+			Spec: ipamv1.IPPoolSpec{
+				ParentPool: &ipamv1.ParentPoolReference{
+					Name:         "parent",
+					PrefixLength: 26,
+				},
+			},
+		}
+		reqs := r.IPPoolToParentIPPool(context.Background(), childPool)
+		Expect(reqs).To(ConsistOf(
+			ctrl.Request{NamespacedName: types.NamespacedName{Name: "parent", Namespace: childPool.Namespace}},
+		))
+
+		reqs = r.IPPoolToParentIPPool(context.Background(), &ipamv1.IPPool{ObjectMeta: testObjectMeta})
+		Expect(reqs).To(BeEmpty())
+	})
+
+	type testCaseIPAddressToIPPool struct {
+		ipAddress        client.Object
+		expectedRequests []ctrl.Request
+	}
+
+	reservedObjectMeta := *testObjectMeta.DeepCopy()
+	reservedObjectMeta.Annotations = map[string]string{ipam.ReservedUntilAnnotation: ""}
+
+	DescribeTable("Requests the IPPools of an IPAddress",
+		func(tc testCaseIPAddressToIPPool) {
+			r := IPPoolReconciler{}
+			reqs := r.IPAddressToIPPool(context.Background(), tc.ipAddress)
+			Expect(reqs).To(ConsistOf(tc.expectedRequests))
+		},
+		Entry("Child IPPool", testCaseIPAddressToIPPool{
+			ipAddress: &ipamv1.IPAddress{
+				ObjectMeta: testObjectMeta,
+				Spec: ipamv1.IPAddressSpec{
+					Pool: corev1.ObjectReference{Name: "parent"},
+					Claim: corev1.ObjectReference{
+						Kind: ipamv1.IPPoolKind,
+						Name: "child",
+					},
+				},
+			},
+			expectedRequests: []ctrl.Request{
+				{NamespacedName: types.NamespacedName{Name: "child", Namespace: "myns"}},
+			},
+		}),
+		Entry("IPClaim", testCaseIPAddressToIPPool{
+			ipAddress: &ipamv1.IPAddress{
+				ObjectMeta: testObjectMeta,
+				Spec: ipamv1.IPAddressSpec{
+					Pool:  corev1.ObjectReference{Name: "abc"},
+					Claim: corev1.ObjectReference{Name: "claim"},
+				},
+			},
+			expectedRequests: []ctrl.Request{},
+		}),
+		Entry("Reserved IPAddress", testCaseIPAddressToIPPool{
+			ipAddress: &ipamv1.IPAddress{
+				ObjectMeta: reservedObjectMeta,
+				Spec: ipamv1.IPAddressSpec{
+					Pool:  corev1.ObjectReference{Name: "abc", Namespace: "pools"},
+					Claim: corev1.ObjectReference{Name: "claim"},
+				},
+			},
+			expectedRequests: []ctrl.Request{
+				{NamespacedName: types.NamespacedName{Name: "abc", Namespace: "pools"}},
+			},
+		}),
+		Entry("Reserved IPAddress of a GlobalIPPool", testCaseIPAddressToIPPool{
+			ipAddress: &ipamv1.IPAddress{
+				ObjectMeta: reservedObjectMeta,
+				Spec: ipamv1.IPAddressSpec{
+					Pool:  corev1.ObjectReference{Name: "abc", Kind: ipamv1.GlobalIPPoolKind},
+					Claim: corev1.ObjectReference{Name: "claim"},
+				},
+			},
+			expectedRequests: []ctrl.Request{},
+		}),
+		Entry("Reserved CAPI IPAddress", testCaseIPAddressToIPPool{
+			ipAddress: &capipamv1.IPAddress{
+				ObjectMeta: reservedObjectMeta,
+				Spec: capipamv1.IPAddressSpec{
+					PoolRef: capipamv1.IPPoolReference{Name: "abc", Kind: "IPPool"},
+				},
+			},
+			expectedRequests: []ctrl.Request{
+				{NamespacedName: types.NamespacedName{Name: "abc", Namespace: "myns"}},
+			},
+		}),
+		Entry("CAPI IPAddress", testCaseIPAddressToIPPool{
+			ipAddress: &capipamv1.IPAddress{
+				ObjectMeta: testObjectMeta,
+				Spec: capipamv1.IPAddressSpec{
+					PoolRef: capipamv1.IPPoolReference{Name: "abc", Kind: "IPPool"},
+				},
+			},
+			expectedRequests: []ctrl.Request{},
+		}),
 	)
 
+	It("Requests the IPPools of a Cluster", func() {
+		objects := []client.Object{
+			&ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "abc",
+					Namespace: "myns",
+					Labels:    map[string]string{clusterv1.ClusterNameLabel: "cluster"},
+				},
+				Spec: ipamv1.IPPoolSpec{ClusterName: ptr.To("cluster")},
+			},
+			&ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "other-cluster",
+					Namespace: "myns",
+					Labels:    map[string]string{clusterv1.ClusterNameLabel: "other"},
+				},
+				Spec: ipamv1.IPPoolSpec{ClusterName: ptr.To("other")},
+			},
+			&ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "no-cluster", Namespace: "myns"},
+			},
+			&ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "other-namespace",
+					Namespace: "otherns",
+					Labels:    map[string]string{clusterv1.ClusterNameLabel: "cluster"},
+				},
+				Spec: ipamv1.IPPoolSpec{ClusterName: ptr.To("cluster")},
+			},
+		}
+		r := IPPoolReconciler{
+			Client: fake.NewClientBuilder().WithScheme(setupScheme()).WithObjects(objects...).Build(),
+			Log:    logr.Discard(),
+		}
+		cluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "myns"},
+		}
+		reqs := r.ClusterToIPPools(context.Background(), cluster)
+		Expect(reqs).To(ConsistOf(
+			ctrl.Request{NamespacedName: types.NamespacedName{Name: "abc", Namespace: "myns"}},
+		))
+	})
+
 	type TestCaseK8SIPACToM3IPP struct {
 		IPAddressClaim *capipamv1.IPAddressClaim
 		ExpectRequest  bool
@@ -465,5 +690,112 @@ var _ = Describe("IPPool controller", func() {
 				ExpectRequest: true,
 			},
 		),
+		Entry("GlobalIPPool in Spec",
+			TestCaseK8SIPACToM3IPP{
+				IPAddressClaim: &capipamv1.IPAddressClaim{
+					ObjectMeta: testObjectMeta,
+					Spec: capipamv1.IPAddressClaimSpec{
+						PoolRef: capipamv1.IPPoolReference{
+							Name:     "abc",
+							Kind:     ipamv1.GlobalIPPoolKind,
+							APIGroup: "ipam.metal3.io",
+						},
+					},
+				},
+				ExpectRequest: false,
+			},
+		),
 	)
 })
+
+var _ = Describe("IPPool controller with both claim kinds", func() {
+	It("Never allocates an address to an IPClaim and an IPAddressClaim at once", func() {
+		const nbClaims = 25
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+			Scheme:  scheme.Scheme,
+			Metrics: metricsserver.Options{BindAddress: "0"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mgr.GetFieldIndexer().IndexField(ctx, &ipamv1.IPClaim{}, ipam.IPClaimPoolField, ipam.IPClaimPools)).To(Succeed())
+		recorder := record.NewFakeRecorder(10 * nbClaims)
+		Expect((&IPPoolReconciler{
+			Client:         mgr.GetClient(),
+			ManagerFactory: ipam.NewManagerFactory(mgr.GetClient(), recorder),
+			Log:            logr.Discard(),
+		}).SetupWithManager(ctx, mgr, controller.Options{
+			MaxConcurrentReconciles: 4,
+			SkipNameValidation:      ptr.To(true),
+		})).To(Succeed())
+		go func() {
+			defer GinkgoRecover()
+			Expect(mgr.Start(ctx)).To(Succeed())
+		}()
+
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "ippool-race-"}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+		ipPool := &ipamv1.IPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool", Namespace: namespace.Name},
+			Spec: ipamv1.IPPoolSpec{
+				NamePrefix: "pool",
+				Pools: []ipamv1.Pool{
+					{
+						Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.10.1")),
+						End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.10.200")),
+					},
+				},
+				Prefix: 24,
+			},
+		}
+		Expect(k8sClient.Create(ctx, ipPool)).To(Succeed())
+
+		// Both claim kinds are created at once, so that the reconciliations
+		// triggered by each kind interleave.
+		var wg sync.WaitGroup
+		for i := range nbClaims {
+			wg.Go(func() {
+				defer GinkgoRecover()
+				Expect(k8sClient.Create(ctx, &ipamv1.IPClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("m3-%d", i), Namespace: namespace.Name},
+					Spec: ipamv1.IPClaimSpec{
+						Pool: corev1.ObjectReference{Name: ipPool.Name},
+					},
+				})).To(Succeed())
+			})
+			wg.Go(func() {
+				defer GinkgoRecover()
+				Expect(k8sClient.Create(ctx, &capipamv1.IPAddressClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("capi-%d", i), Namespace: namespace.Name},
+					Spec: capipamv1.IPAddressClaimSpec{
+						PoolRef: capipamv1.IPPoolReference{
+							Name:     ipPool.Name,
+							Kind:     "IPPool",
+							APIGroup: ipam.APIGroup,
+						},
+					},
+				})).To(Succeed())
+			})
+		}
+		wg.Wait()
+
+		addresses := map[string]string{}
+		Eventually(func(g Gomega) {
+			addresses = map[string]string{}
+			m3Addresses := ipamv1.IPAddressList{}
+			g.Expect(k8sClient.List(ctx, &m3Addresses, client.InNamespace(namespace.Name))).To(Succeed())
+			capiAddresses := capipamv1.IPAddressList{}
+			g.Expect(k8sClient.List(ctx, &capiAddresses, client.InNamespace(namespace.Name))).To(Succeed())
+			for _, address := range m3Addresses.Items {
+				g.Expect(addresses).NotTo(HaveKey(string(address.Spec.Address)), "allocated twice")
+				addresses[string(address.Spec.Address)] = address.Name
+			}
+			for _, address := range capiAddresses.Items {
+				g.Expect(addresses).NotTo(HaveKey(address.Spec.Address), "allocated twice")
+				addresses[address.Spec.Address] = address.Name
+			}
+			g.Expect(addresses).To(HaveLen(2 * nbClaims))
+		}, 60*time.Second, 500*time.Millisecond).Should(Succeed())
+	})
+})
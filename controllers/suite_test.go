@@ -1,6 +1,4 @@
 /*
-/*
-
 Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
 You may obtain a copy of the License at
@@ -75,6 +73,7 @@ func setupScheme() *runtime.Scheme {
 
 	return s
 }
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Controller Suite")
@@ -86,7 +85,12 @@ var _ = BeforeSuite(func() {
 	go func() {
 		By("bootstrapping test environment")
 		testEnv = &envtest.Environment{
-			CRDDirectoryPaths: []string{filepath.Join("..", "config", "crd", "bases")},
+			CRDDirectoryPaths: []string{
+				filepath.Join("..", "config", "crd", "bases"),
+				// The CRDs of the cluster-api objects the controllers
+				// watch, copied from the cluster-api module in go.mod.
+				filepath.Join("testdata", "crd"),
+			},
 		}
 
 		var err error
@@ -0,0 +1,141 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"errors"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func (webhook *GlobalIPPool) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr, &ipamv1.GlobalIPPool{}).
+		WithDefaulter(webhook, admission.DefaulterRemoveUnknownOrOmitableFields).
+		WithValidator(webhook).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-ipam-metal3-io-v1alpha1-globalippool,mutating=false,failurePolicy=fail,groups=ipam.metal3.io,resources=globalippools,versions=v1alpha1,name=validation.globalippool.ipam.metal3.io,matchPolicy=Equivalent,sideEffects=None,admissionReviewVersions=v1;v1beta1
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-ipam-metal3-io-v1alpha1-globalippool,mutating=true,failurePolicy=fail,groups=ipam.metal3.io,resources=globalippools,versions=v1alpha1,name=default.globalippool.ipam.metal3.io,matchPolicy=Equivalent,sideEffects=None,admissionReviewVersions=v1;v1beta1
+
+// GlobalIPPool implements a validation and defaulting webhook for
+// GlobalIPPool. The spec of the GlobalIPPool is validated as the spec of an
+// IPPool.
+type GlobalIPPool struct {
+	IPPool
+}
+
+var _ admission.Defaulter[*ipamv1.GlobalIPPool] = &GlobalIPPool{}
+var _ admission.Validator[*ipamv1.GlobalIPPool] = &GlobalIPPool{}
+
+func (webhook *GlobalIPPool) Default(ctx context.Context, globalIPPool *ipamv1.GlobalIPPool) error {
+	ipPool := ipPoolView(globalIPPool)
+	if err := webhook.IPPool.Default(ctx, ipPool); err != nil {
+		return err
+	}
+	globalIPPool.Spec.IPPoolSpec = ipPool.Spec
+	return nil
+}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (webhook *GlobalIPPool) ValidateCreate(_ context.Context, globalIPPool *ipamv1.GlobalIPPool) (admission.Warnings, error) {
+	if globalIPPool == nil {
+		return nil, apierrors.NewBadRequest("expected a GlobalIPPool but got nil")
+	}
+
+	allErrs := webhook.validateGlobalSpec(globalIPPool)
+	allErrs = append(allErrs, webhook.validateCreate(ipPoolView(globalIPPool))...)
+
+	if len(allErrs) == 0 {
+		return nil, nil
+	}
+	return nil, apierrors.NewInvalid(ipamv1.GroupVersion.WithKind(ipamv1.GlobalIPPoolKind).GroupKind(), globalIPPool.Name, allErrs)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (webhook *GlobalIPPool) ValidateUpdate(ctx context.Context, oldGlobalIPPool, newGlobalIPPool *ipamv1.GlobalIPPool) (admission.Warnings, error) {
+	if oldGlobalIPPool == nil {
+		return nil, apierrors.NewInternalError(errors.New("unable to convert existing object"))
+	}
+
+	if newGlobalIPPool == nil {
+		return nil, apierrors.NewBadRequest("expected a GlobalIPPool but got nil")
+	}
+
+	allErrs := webhook.validateGlobalSpec(newGlobalIPPool)
+	allErrs = append(allErrs, webhook.validateUpdate(ctx, ipPoolView(oldGlobalIPPool), ipPoolView(newGlobalIPPool))...)
+
+	if len(allErrs) == 0 {
+		return nil, nil
+	}
+	return nil, apierrors.NewInvalid(ipamv1.GroupVersion.WithKind(ipamv1.GlobalIPPoolKind).GroupKind(), newGlobalIPPool.Name, allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (webhook *GlobalIPPool) ValidateDelete(_ context.Context, _ *ipamv1.GlobalIPPool) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateGlobalSpec validates the fields that differ between a GlobalIPPool
+// and an IPPool.
+func (webhook *GlobalIPPool) validateGlobalSpec(globalIPPool *ipamv1.GlobalIPPool) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if globalIPPool.Spec.ClusterName != nil {
+		allErrs = append(allErrs,
+			field.Forbidden(
+				field.NewPath("spec", "clusterName"),
+				"a GlobalIPPool cannot belong to a cluster",
+			),
+		)
+	}
+
+	if globalIPPool.Spec.ParentPool != nil {
+		allErrs = append(allErrs,
+			field.Forbidden(
+				field.NewPath("spec", "parentPool"),
+				"a GlobalIPPool cannot be a child IPPool",
+			),
+		)
+	}
+
+	if globalIPPool.Spec.NamespaceSelector != nil {
+		allErrs = append(allErrs, metav1validation.ValidateLabelSelector(
+			globalIPPool.Spec.NamespaceSelector,
+			metav1validation.LabelSelectorValidationOptions{},
+			field.NewPath("spec", "namespaceSelector"),
+		)...)
+	}
+
+	return allErrs
+}
+
+// ipPoolView returns an IPPool with the metadata, spec and status of the
+// GlobalIPPool, to share the validation of the IPPools.
+func ipPoolView(globalIPPool *ipamv1.GlobalIPPool) *ipamv1.IPPool {
+	return &ipamv1.IPPool{
+		ObjectMeta: globalIPPool.ObjectMeta,
+		Spec:       globalIPPool.Spec.IPPoolSpec,
+		Status:     globalIPPool.Status,
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"testing"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestGlobalIPPoolDefault(t *testing.T) {
+	g := NewWithT(t)
+	webhook := &GlobalIPPool{}
+
+	c := &ipamv1.GlobalIPPool{}
+	g.Expect(webhook.Default(ctx, c)).To(Succeed())
+
+	g.Expect(c.Spec.AllocationStrategy).To(Equal(ipamv1.AllocationStrategySequential))
+}
+
+func TestGlobalIPPoolValidation(t *testing.T) {
+	startAddr := ipamv1.IPAddressStr("192.168.0.10")
+	endAddr := ipamv1.IPAddressStr("192.168.0.20")
+
+	tests := []struct {
+		name      string
+		expectErr bool
+		spec      ipamv1.GlobalIPPoolSpec
+	}{
+		{
+			name:      "should succeed when values are correct",
+			expectErr: false,
+			spec: ipamv1.GlobalIPPoolSpec{
+				IPPoolSpec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{Start: &startAddr, End: &endAddr},
+					},
+				},
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{"tenant": "true"},
+				},
+			},
+		},
+		{
+			name:      "should fail when start > end",
+			expectErr: true,
+			spec: ipamv1.GlobalIPPoolSpec{
+				IPPoolSpec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{Start: &endAddr, End: &startAddr},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail when clusterName is set",
+			expectErr: true,
+			spec: ipamv1.GlobalIPPoolSpec{
+				IPPoolSpec: ipamv1.IPPoolSpec{
+					ClusterName: ptr.To("abc"),
+				},
+			},
+		},
+		{
+			name:      "should fail when parentPool is set",
+			expectErr: true,
+			spec: ipamv1.GlobalIPPoolSpec{
+				IPPoolSpec: ipamv1.IPPoolSpec{
+					ParentPool: &ipamv1.ParentPoolReference{Name: "abc", PrefixLength: 26},
+				},
+			},
+		},
+		{
+			name:      "should fail when namespaceSelector is invalid",
+			expectErr: true,
+			spec: ipamv1.GlobalIPPoolSpec{
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{
+						{Key: "tenant", Operator: "Unknown"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webhook := &GlobalIPPool{}
+			c := &ipamv1.GlobalIPPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "abc"},
+				Spec:       tt.spec,
+			}
+
+			_, err := webhook.ValidateCreate(ctx, c)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			_, err = webhook.ValidateUpdate(ctx, c.DeepCopy(), c)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			_, err = webhook.ValidateDelete(ctx, c)
+			g.Expect(err).NotTo(HaveOccurred())
+		})
+	}
+}
+
+func TestGlobalIPPoolUpdateValidation(t *testing.T) {
+	g := NewWithT(t)
+	webhook := &GlobalIPPool{}
+
+	oldPool := &ipamv1.GlobalIPPool{
+		Spec: ipamv1.GlobalIPPoolSpec{
+			IPPoolSpec: ipamv1.IPPoolSpec{NamePrefix: "abcd"},
+		},
+	}
+	newPool := &ipamv1.GlobalIPPool{
+		Spec: ipamv1.GlobalIPPoolSpec{
+			IPPoolSpec: ipamv1.IPPoolSpec{NamePrefix: "abcde"},
+		},
+	}
+
+	_, err := webhook.ValidateUpdate(ctx, oldPool, newPool)
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = webhook.ValidateUpdate(ctx, nil, newPool)
+	g.Expect(err).To(HaveOccurred())
+}
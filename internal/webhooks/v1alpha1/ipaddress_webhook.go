@@ -92,17 +92,7 @@ func (webhook *IPAddress) ValidateCreate(_ context.Context, ipAddress *ipamv1.IP
 	}
 
 	// Validate requested IP address if present in annotations (for CAPI claims)
-	if requestedIP, ok := ipAddress.ObjectMeta.Annotations["ipAddress"]; ok && requestedIP != "" {
-		if validateIPAddress(ipamv1.IPAddressStr(requestedIP)) != nil {
-			allErrs = append(allErrs,
-				field.Invalid(
-					field.NewPath("metadata", "annotations", "ipAddress"),
-					requestedIP,
-					"is not a valid IP address",
-				),
-			)
-		}
-	}
+	allErrs = append(allErrs, validateRequestedAddressAnnotation(ipAddress.Annotations)...)
 	if len(allErrs) == 0 {
 		return nil, nil
 	}
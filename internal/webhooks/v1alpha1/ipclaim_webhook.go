@@ -18,11 +18,18 @@ package webhooks
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"net/netip"
+	"reflect"
+	"slices"
 
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -37,7 +44,12 @@ func (webhook *IPClaim) SetupWebhookWithManager(mgr ctrl.Manager) error {
 // +kubebuilder:webhook:verbs=create;update,path=/mutate-ipam-metal3-io-v1alpha1-ipclaim,mutating=true,failurePolicy=fail,groups=ipam.metal3.io,resources=ipclaims,versions=v1alpha1,name=default.ipclaim.ipam.metal3.io,matchPolicy=Equivalent,sideEffects=None,admissionReviewVersions=v1;v1beta1
 
 // IPClaim implements a validation and defaulting webhook for IPClaim.
-type IPClaim struct{}
+type IPClaim struct {
+	// Client gets the IPPool of the IPClaims requesting an address, to
+	// validate the address against the IPPool. Without it, the requested
+	// addresses are only validated as IP addresses.
+	Client client.Reader
+}
 
 var _ admission.Defaulter[*ipamv1.IPClaim] = &IPClaim{}
 var _ admission.Validator[*ipamv1.IPClaim] = &IPClaim{}
@@ -47,7 +59,7 @@ func (webhook *IPClaim) Default(_ context.Context, _ *ipamv1.IPClaim) error {
 }
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
-func (webhook *IPClaim) ValidateCreate(_ context.Context, ipClaim *ipamv1.IPClaim) (admission.Warnings, error) {
+func (webhook *IPClaim) ValidateCreate(ctx context.Context, ipClaim *ipamv1.IPClaim) (admission.Warnings, error) {
 	if ipClaim == nil {
 		return nil, apierrors.NewBadRequest("expected an IPClaim but got nil")
 	}
@@ -63,18 +75,10 @@ func (webhook *IPClaim) ValidateCreate(_ context.Context, ipClaim *ipamv1.IPClai
 		)
 	}
 
-	// Validate requested IP address if present in annotations
-	if requestedIP, ok := ipClaim.ObjectMeta.Annotations["ipAddress"]; ok && requestedIP != "" {
-		if err := validateIPAddress(ipamv1.IPAddressStr(requestedIP)); err != nil {
-			allErrs = append(allErrs,
-				field.Invalid(
-					field.NewPath("metadata", "annotations", "ipAddress"),
-					requestedIP,
-					"is not a valid IP address",
-				),
-			)
-		}
-	}
+	allErrs = append(allErrs, webhook.validateSecondaryPool(ipClaim)...)
+	allErrs = append(allErrs, webhook.validatePrefixLength(ipClaim)...)
+	allErrs = append(allErrs, webhook.validatePoolEntrySelector(ipClaim)...)
+	allErrs = append(allErrs, webhook.validateRequestedAddress(ctx, ipClaim, true)...)
 
 	if len(allErrs) == 0 {
 		return nil, nil
@@ -83,7 +87,7 @@ func (webhook *IPClaim) ValidateCreate(_ context.Context, ipClaim *ipamv1.IPClai
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
-func (webhook *IPClaim) ValidateUpdate(_ context.Context, oldIPClaim, newIPClaim *ipamv1.IPClaim) (admission.Warnings, error) {
+func (webhook *IPClaim) ValidateUpdate(ctx context.Context, oldIPClaim, newIPClaim *ipamv1.IPClaim) (admission.Warnings, error) {
 	allErrs := field.ErrorList{}
 	if oldIPClaim == nil {
 		return nil, apierrors.NewInternalError(errors.New("unable to convert existing object"))
@@ -119,23 +123,254 @@ func (webhook *IPClaim) ValidateUpdate(_ context.Context, oldIPClaim, newIPClaim
 		)
 	}
 
-	// Validate requested IP address if present in annotations
-	if requestedIP, ok := newIPClaim.ObjectMeta.Annotations["ipAddress"]; ok && requestedIP != "" {
+	if !reflect.DeepEqual(newIPClaim.Spec.SecondaryPool, oldIPClaim.Spec.SecondaryPool) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "secondaryPool"),
+				newIPClaim.Spec.SecondaryPool,
+				"cannot be modified",
+			),
+		)
+	}
+
+	if newIPClaim.Spec.PrefixLength != oldIPClaim.Spec.PrefixLength {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "prefixLength"),
+				newIPClaim.Spec.PrefixLength,
+				"cannot be modified",
+			),
+		)
+	}
+
+	allErrs = append(allErrs, webhook.validatePoolEntrySelector(newIPClaim)...)
+	// The requested address is only validated against the IPPool when it
+	// changes, the IPPool may have changed since the address was allocated.
+	requestedAddressChanged := ipamv1.GetRequestedAddress(newIPClaim) != ipamv1.GetRequestedAddress(oldIPClaim)
+	allErrs = append(allErrs, webhook.validateRequestedAddress(ctx, newIPClaim, requestedAddressChanged)...)
+
+	if len(allErrs) == 0 {
+		return nil, nil
+	}
+	return nil, apierrors.NewInvalid(ipamv1.GroupVersion.WithKind("IPClaim").GroupKind(), newIPClaim.Name, allErrs)
+}
+
+// validatePoolEntrySelector validates the selector of the pool entries the
+// IPClaim gets its address from.
+func (webhook *IPClaim) validatePoolEntrySelector(ipClaim *ipamv1.IPClaim) field.ErrorList {
+	if ipClaim.Spec.PoolEntrySelector == nil {
+		return field.ErrorList{}
+	}
+	return metav1validation.ValidateLabelSelector(ipClaim.Spec.PoolEntrySelector,
+		metav1validation.LabelSelectorValidationOptions{}, field.NewPath("spec", "poolEntrySelector"))
+}
+
+// validateSecondaryPool validates the secondary pool of a dual-stack IPClaim,
+// that must be another pool than the primary pool.
+func (webhook *IPClaim) validateSecondaryPool(ipClaim *ipamv1.IPClaim) field.ErrorList {
+	allErrs := field.ErrorList{}
+	secondaryPool := ipClaim.Spec.SecondaryPool
+	if secondaryPool == nil {
+		return allErrs
+	}
+
+	if secondaryPool.Name == "" {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "secondaryPool", "name"),
+				secondaryPool.Name,
+				"cannot be empty",
+			),
+		)
+	}
+
+	poolNamespace := ipClaim.Spec.Pool.Namespace
+	if poolNamespace == "" {
+		poolNamespace = ipClaim.Namespace
+	}
+	secondaryPoolNamespace := secondaryPool.Namespace
+	if secondaryPoolNamespace == "" {
+		secondaryPoolNamespace = ipClaim.Namespace
+	}
+	if secondaryPool.Name == ipClaim.Spec.Pool.Name && secondaryPool.Kind == ipClaim.Spec.Pool.Kind &&
+		(secondaryPool.Kind == ipamv1.GlobalIPPoolKind || secondaryPoolNamespace == poolNamespace) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "secondaryPool"),
+				secondaryPool,
+				"cannot be the same pool as spec.pool",
+			),
+		)
+	}
+
+	return allErrs
+}
+
+// validatePrefixLength validates the prefix length of a subnet IPClaim, that
+// cannot be combined with a requested address nor with a secondary pool.
+func (webhook *IPClaim) validatePrefixLength(ipClaim *ipamv1.IPClaim) field.ErrorList {
+	allErrs := field.ErrorList{}
+	prefixLength := ipClaim.Spec.PrefixLength
+	if prefixLength == 0 {
+		return allErrs
+	}
+
+	if prefixLength < 0 || prefixLength > 128 {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "prefixLength"),
+				prefixLength,
+				"must be between 1 and 128",
+			),
+		)
+	}
+	if ipClaim.Spec.SecondaryPool != nil {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "secondaryPool"),
+				ipClaim.Spec.SecondaryPool,
+				"cannot be set together with spec.prefixLength",
+			),
+		)
+	}
+	if ipClaim.Spec.RequestedAddress != nil {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "requestedAddress"),
+				*ipClaim.Spec.RequestedAddress,
+				"cannot be set together with spec.prefixLength",
+			),
+		)
+	}
+	if requestedIP := ipClaim.Annotations[ipamv1.RequestedAddressAnnotation]; requestedIP != "" {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("metadata", "annotations", ipamv1.RequestedAddressAnnotation),
+				requestedIP,
+				"cannot be set together with spec.prefixLength",
+			),
+		)
+	}
+
+	return allErrs
+}
+
+// validateRequestedAddress validates the address requested by the IPClaim,
+// in its spec or in the deprecated annotation. With checkPool, it also
+// validates the address against the IPPool of the IPClaim.
+func (webhook *IPClaim) validateRequestedAddress(ctx context.Context, ipClaim *ipamv1.IPClaim, checkPool bool) field.ErrorList {
+	allErrs := validateRequestedAddressAnnotation(ipClaim.Annotations)
+	path := field.NewPath("metadata", "annotations", ipamv1.RequestedAddressAnnotation)
+	if requested := ipClaim.Spec.RequestedAddress; requested != nil {
+		annotation := ipClaim.Annotations[ipamv1.RequestedAddressAnnotation]
+		path = field.NewPath("spec", "requestedAddress")
+		if err := validateIPAddress(*requested); err != nil {
+			allErrs = append(allErrs, field.Invalid(path, *requested, "is not a valid IP address"))
+		} else if annotation != "" && !ipEqual(*requested, ipamv1.IPAddressStr(annotation)) {
+			allErrs = append(allErrs,
+				field.Invalid(
+					field.NewPath("metadata", "annotations", ipamv1.RequestedAddressAnnotation),
+					annotation,
+					"must match spec.requestedAddress",
+				),
+			)
+		}
+	}
+
+	requested := ipamv1.GetRequestedAddress(ipClaim)
+	if len(allErrs) != 0 || requested == "" || !checkPool || webhook.Client == nil {
+		return allErrs
+	}
+	return webhook.validateRequestedAddressInPool(ctx, ipClaim, requested, path)
+}
+
+// validateRequestedAddressInPool validates that the requested address is
+// within the bounds of the IPPool of the IPClaim, and not pre-allocated to
+// another claim. The IPPool may not exist yet, the address is then validated
+// on allocation.
+func (webhook *IPClaim) validateRequestedAddressInPool(ctx context.Context, ipClaim *ipamv1.IPClaim,
+	requested ipamv1.IPAddressStr, path *field.Path,
+) field.ErrorList {
+	allErrs := field.ErrorList{}
+	poolRef := ipClaim.Spec.Pool
+	var spec ipamv1.IPPoolSpec
+	var status ipamv1.IPPoolStatus
+	allocationKey := ipClaim.Name
+	if poolRef.Kind == ipamv1.GlobalIPPoolKind {
+		globalPool := &ipamv1.GlobalIPPool{}
+		if err := webhook.Client.Get(ctx, client.ObjectKey{Name: poolRef.Name}, globalPool); err != nil {
+			return ignoreNotFound(err, path)
+		}
+		spec, status = globalPool.Spec.IPPoolSpec, globalPool.Status
+		allocationKey = ipClaim.Namespace + "/" + ipClaim.Name
+	} else {
+		namespace := poolRef.Namespace
+		if namespace == "" {
+			namespace = ipClaim.Namespace
+		}
+		ipPool := &ipamv1.IPPool{}
+		if err := webhook.Client.Get(ctx, client.ObjectKey{Name: poolRef.Name, Namespace: namespace}, ipPool); err != nil {
+			return ignoreNotFound(err, path)
+		}
+		spec, status = ipPool.Spec, ipPool.Status
+		if namespace != ipClaim.Namespace {
+			allocationKey = ipClaim.Namespace + "/" + ipClaim.Name
+		}
+	}
+
+	// A child IPPool has no bounds until it obtains its subnet.
+	pools := spec.Pools
+	if spec.ParentPool != nil {
+		pools = ipamv1.GetChildPools(spec, status.ParentSubnet)
+	}
+	ip, _ := netip.ParseAddr(string(requested))
+	if (spec.ParentPool == nil || status.ParentSubnet != nil) &&
+		!slices.ContainsFunc(pools, func(pool ipamv1.Pool) bool { return isAddressInPool(pool, ip) }) {
+		allErrs = append(allErrs,
+			field.Invalid(path, requested, fmt.Sprintf("is not within the bounds of the pool %s", poolRef.Name)))
+	}
+
+	for _, claimKey := range slices.Sorted(maps.Keys(spec.PreAllocations)) {
+		if claimKey != allocationKey && ipEqual(spec.PreAllocations[claimKey], requested) {
+			allErrs = append(allErrs,
+				field.Invalid(path, requested, fmt.Sprintf("is pre-allocated to %s in the pool %s", claimKey, poolRef.Name)))
+		}
+	}
+	return allErrs
+}
+
+// validateRequestedAddressAnnotation validates the address requested with the
+// deprecated ipAddress annotation.
+func validateRequestedAddressAnnotation(annotations map[string]string) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if requestedIP := annotations[ipamv1.RequestedAddressAnnotation]; requestedIP != "" {
 		if err := validateIPAddress(ipamv1.IPAddressStr(requestedIP)); err != nil {
 			allErrs = append(allErrs,
 				field.Invalid(
-					field.NewPath("metadata", "annotations", "ipAddress"),
+					field.NewPath("metadata", "annotations", ipamv1.RequestedAddressAnnotation),
 					requestedIP,
 					"is not a valid IP address",
 				),
 			)
 		}
 	}
+	return allErrs
+}
 
-	if len(allErrs) == 0 {
-		return nil, nil
+// ipEqual returns true if both strings are the same valid IP address.
+func ipEqual(a, b ipamv1.IPAddressStr) bool {
+	ipA, errA := netip.ParseAddr(string(a))
+	ipB, errB := netip.ParseAddr(string(b))
+	return errA == nil && errB == nil && ipA.Unmap() == ipB.Unmap()
+}
+
+// ignoreNotFound returns no error if the object was not found, and an
+// internal error otherwise.
+func ignoreNotFound(err error, path *field.Path) field.ErrorList {
+	if apierrors.IsNotFound(err) {
+		return field.ErrorList{}
 	}
-	return nil, apierrors.NewInvalid(ipamv1.GroupVersion.WithKind("IPClaim").GroupKind(), newIPClaim.Name, allErrs)
+	return field.ErrorList{field.InternalError(path, err)}
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
@@ -22,6 +22,9 @@ import (
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestIPClaimDefault(t *testing.T) {
@@ -381,3 +384,394 @@ func TestIPClaimUpdateValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestIPClaimSecondaryPoolValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		expectErr     bool
+		pool          corev1.ObjectReference
+		secondaryPool *corev1.ObjectReference
+	}{
+		{
+			name:          "should succeed with another secondary pool",
+			expectErr:     false,
+			pool:          corev1.ObjectReference{Name: "pool-v4"},
+			secondaryPool: &corev1.ObjectReference{Name: "pool-v6"},
+		},
+		{
+			name:          "should succeed with a secondary pool of the same name in another namespace",
+			expectErr:     false,
+			pool:          corev1.ObjectReference{Name: "pool"},
+			secondaryPool: &corev1.ObjectReference{Name: "pool", Namespace: "bar"},
+		},
+		{
+			name:          "should succeed with a GlobalIPPool as secondary pool",
+			expectErr:     false,
+			pool:          corev1.ObjectReference{Name: "pool"},
+			secondaryPool: &corev1.ObjectReference{Name: "pool", Kind: ipamv1.GlobalIPPoolKind},
+		},
+		{
+			name:          "should fail without secondary pool name",
+			expectErr:     true,
+			pool:          corev1.ObjectReference{Name: "pool-v4"},
+			secondaryPool: &corev1.ObjectReference{Namespace: "foo"},
+		},
+		{
+			name:          "should fail with the same pool",
+			expectErr:     true,
+			pool:          corev1.ObjectReference{Name: "pool"},
+			secondaryPool: &corev1.ObjectReference{Name: "pool", Namespace: "foo"},
+		},
+		{
+			name:          "should fail with the same GlobalIPPool",
+			expectErr:     true,
+			pool:          corev1.ObjectReference{Name: "pool", Kind: ipamv1.GlobalIPPoolKind},
+			secondaryPool: &corev1.ObjectReference{Name: "pool", Kind: ipamv1.GlobalIPPoolKind},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webhook := &IPClaim{}
+
+			obj := &ipamv1.IPClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "test-claim",
+				},
+				Spec: ipamv1.IPClaimSpec{
+					Pool:          tt.pool,
+					SecondaryPool: tt.secondaryPool,
+				},
+			}
+
+			_, err := webhook.ValidateCreate(ctx, obj)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestIPClaimSecondaryPoolUpdateValidation(t *testing.T) {
+	g := NewWithT(t)
+	webhook := &IPClaim{}
+
+	oldObj := &ipamv1.IPClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "foo",
+			Name:      "test-claim",
+		},
+		Spec: ipamv1.IPClaimSpec{
+			Pool:          corev1.ObjectReference{Name: "pool-v4"},
+			SecondaryPool: &corev1.ObjectReference{Name: "pool-v6"},
+		},
+	}
+
+	newObj := oldObj.DeepCopy()
+	_, err := webhook.ValidateUpdate(ctx, oldObj, newObj)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	newObj.Spec.SecondaryPool.Name = "other-v6"
+	_, err = webhook.ValidateUpdate(ctx, oldObj, newObj)
+	g.Expect(err).To(HaveOccurred())
+
+	newObj.Spec.SecondaryPool = nil
+	_, err = webhook.ValidateUpdate(ctx, oldObj, newObj)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestIPClaimPrefixLengthValidation(t *testing.T) {
+	tests := []struct {
+		name          string
+		expectErr     bool
+		prefixLength  int
+		secondaryPool *corev1.ObjectReference
+		annotations   map[string]string
+	}{
+		{
+			name:         "should succeed with a prefix length",
+			expectErr:    false,
+			prefixLength: 28,
+		},
+		{
+			name:         "should fail with a prefix length above 128",
+			expectErr:    true,
+			prefixLength: 129,
+		},
+		{
+			name:          "should fail with a secondary pool",
+			expectErr:     true,
+			prefixLength:  28,
+			secondaryPool: &corev1.ObjectReference{Name: "pool-v6"},
+		},
+		{
+			name:         "should fail with a requested address",
+			expectErr:    true,
+			prefixLength: 28,
+			annotations:  map[string]string{"ipAddress": "192.168.0.16"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webhook := &IPClaim{}
+
+			obj := &ipamv1.IPClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "foo",
+					Name:        "test-claim",
+					Annotations: tt.annotations,
+				},
+				Spec: ipamv1.IPClaimSpec{
+					Pool:          corev1.ObjectReference{Name: "pool"},
+					SecondaryPool: tt.secondaryPool,
+					PrefixLength:  tt.prefixLength,
+				},
+			}
+
+			_, err := webhook.ValidateCreate(ctx, obj)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestIPClaimPrefixLengthUpdateValidation(t *testing.T) {
+	g := NewWithT(t)
+	webhook := &IPClaim{}
+
+	oldObj := &ipamv1.IPClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "foo",
+			Name:      "test-claim",
+		},
+		Spec: ipamv1.IPClaimSpec{
+			Pool:         corev1.ObjectReference{Name: "pool"},
+			PrefixLength: 28,
+		},
+	}
+
+	newObj := oldObj.DeepCopy()
+	_, err := webhook.ValidateUpdate(ctx, oldObj, newObj)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	newObj.Spec.PrefixLength = 27
+	_, err = webhook.ValidateUpdate(ctx, oldObj, newObj)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestIPClaimPoolEntrySelectorValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		expectErr bool
+		selector  *metav1.LabelSelector
+	}{
+		{
+			name:      "should succeed with a label selector",
+			expectErr: false,
+			selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"rack": "rack-3"}},
+		},
+		{
+			name:      "should succeed with a label selector expression",
+			expectErr: false,
+			selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "rack", Operator: metav1.LabelSelectorOpIn, Values: []string{"rack-3", "rack-4"}},
+			}},
+		},
+		{
+			name:      "should fail with an invalid label value",
+			expectErr: true,
+			selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"rack": "rack 3"}},
+		},
+		{
+			name:      "should fail with an expression without values",
+			expectErr: true,
+			selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "rack", Operator: metav1.LabelSelectorOpIn},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			webhook := &IPClaim{}
+
+			obj := &ipamv1.IPClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+					Name:      "test-claim",
+				},
+				Spec: ipamv1.IPClaimSpec{
+					Pool:              corev1.ObjectReference{Name: "pool"},
+					PoolEntrySelector: tt.selector,
+				},
+			}
+
+			_, err := webhook.ValidateCreate(ctx, obj)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+			_, err = webhook.ValidateUpdate(ctx, obj, obj)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestIPClaimRequestedAddressValidation(t *testing.T) {
+	start := ipamv1.IPAddressStr("192.168.0.10")
+	end := ipamv1.IPAddressStr("192.168.0.20")
+	ipPool := &ipamv1.IPPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "pool", Namespace: "foo"},
+		Spec: ipamv1.IPPoolSpec{
+			Pools: []ipamv1.Pool{{Start: &start, End: &end}},
+			PreAllocations: map[string]ipamv1.IPAddressStr{
+				"test-claim":  "192.168.0.11",
+				"other-claim": "192.168.0.12",
+			},
+		},
+	}
+	globalIPPool := &ipamv1.GlobalIPPool{
+		ObjectMeta: metav1.ObjectMeta{Name: "global"},
+		Spec: ipamv1.GlobalIPPoolSpec{
+			IPPoolSpec: ipamv1.IPPoolSpec{
+				Pools: []ipamv1.Pool{{Start: &start, End: &end}},
+				PreAllocations: map[string]ipamv1.IPAddressStr{
+					"foo/test-claim": "192.168.0.11",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		expectErr   bool
+		pool        corev1.ObjectReference
+		requested   *ipamv1.IPAddressStr
+		annotations map[string]string
+	}{
+		{
+			name:      "should succeed with an address within the pool",
+			expectErr: false,
+			pool:      corev1.ObjectReference{Name: "pool"},
+			requested: ptr.To(ipamv1.IPAddressStr("192.168.0.15")),
+		},
+		{
+			name:      "should succeed with the address pre-allocated to the claim",
+			expectErr: false,
+			pool:      corev1.ObjectReference{Name: "pool"},
+			requested: ptr.To(ipamv1.IPAddressStr("192.168.0.11")),
+		},
+		{
+			name:      "should succeed when the pool does not exist",
+			expectErr: false,
+			pool:      corev1.ObjectReference{Name: "missing"},
+			requested: ptr.To(ipamv1.IPAddressStr("10.0.0.1")),
+		},
+		{
+			name:      "should fail with an invalid address",
+			expectErr: true,
+			pool:      corev1.ObjectReference{Name: "pool"},
+			requested: ptr.To(ipamv1.IPAddressStr("192.168.0")),
+		},
+		{
+			name:      "should fail with an address out of the bounds of the pool",
+			expectErr: true,
+			pool:      corev1.ObjectReference{Name: "pool"},
+			requested: ptr.To(ipamv1.IPAddressStr("192.168.0.21")),
+		},
+		{
+			name:      "should fail with an address pre-allocated to another claim",
+			expectErr: true,
+			pool:      corev1.ObjectReference{Name: "pool"},
+			requested: ptr.To(ipamv1.IPAddressStr("192.168.0.12")),
+		},
+		{
+			name:        "should fail with an annotation address out of the bounds of the pool",
+			expectErr:   true,
+			pool:        corev1.ObjectReference{Name: "pool"},
+			annotations: map[string]string{ipamv1.RequestedAddressAnnotation: "192.168.0.21"},
+		},
+		{
+			name:        "should succeed with the same address in the annotation",
+			expectErr:   false,
+			pool:        corev1.ObjectReference{Name: "pool"},
+			requested:   ptr.To(ipamv1.IPAddressStr("192.168.0.15")),
+			annotations: map[string]string{ipamv1.RequestedAddressAnnotation: "192.168.0.15"},
+		},
+		{
+			name:        "should fail with another address in the annotation",
+			expectErr:   true,
+			pool:        corev1.ObjectReference{Name: "pool"},
+			requested:   ptr.To(ipamv1.IPAddressStr("192.168.0.15")),
+			annotations: map[string]string{ipamv1.RequestedAddressAnnotation: "192.168.0.16"},
+		},
+		{
+			name:      "should succeed with the address pre-allocated to the claim in a GlobalIPPool",
+			expectErr: false,
+			pool:      corev1.ObjectReference{Name: "global", Kind: ipamv1.GlobalIPPoolKind},
+			requested: ptr.To(ipamv1.IPAddressStr("192.168.0.11")),
+		},
+		{
+			name:      "should fail with an address out of the bounds of a GlobalIPPool",
+			expectErr: true,
+			pool:      corev1.ObjectReference{Name: "global", Kind: ipamv1.GlobalIPPoolKind},
+			requested: ptr.To(ipamv1.IPAddressStr("192.168.1.15")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+			webhook := &IPClaim{
+				Client: fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(ipPool, globalIPPool).Build(),
+			}
+
+			obj := &ipamv1.IPClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "foo",
+					Name:        "test-claim",
+					Annotations: tt.annotations,
+				},
+				Spec: ipamv1.IPClaimSpec{
+					Pool:             tt.pool,
+					RequestedAddress: tt.requested,
+				},
+			}
+
+			_, err := webhook.ValidateCreate(ctx, obj)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+
+			// The address is only validated against the pool when it changes.
+			oldObj := obj.DeepCopy()
+			oldObj.Spec.RequestedAddress = nil
+			oldObj.Annotations = nil
+			_, err = webhook.ValidateUpdate(ctx, oldObj, obj)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
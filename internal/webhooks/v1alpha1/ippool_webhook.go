@@ -19,14 +19,20 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net"
 	"net/netip"
 	"reflect"
+	"slices"
 
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -52,7 +58,12 @@ func (webhook *IPPool) SetupWebhookWithManager(mgr ctrl.Manager) error {
 // +kubebuilder:webhook:verbs=create;update,path=/mutate-ipam-metal3-io-v1alpha1-ippool,mutating=true,failurePolicy=fail,groups=ipam.metal3.io,resources=ippools,versions=v1alpha1,name=default.ippool.ipam.metal3.io,matchPolicy=Equivalent,sideEffects=None,admissionReviewVersions=v1;v1beta1
 
 // IPPool implements a validation and defaulting webhook for IPPool.
-type IPPool struct{}
+type IPPool struct {
+	// Client lists the IPAddresses of the IPPools that do not record their
+	// allocations in their status. Without it, the addresses in use of those
+	// IPPools are not validated.
+	Client client.Reader
+}
 
 var _ admission.Defaulter[*ipamv1.IPPool] = &IPPool{}
 var _ admission.Validator[*ipamv1.IPPool] = &IPPool{}
@@ -61,6 +72,9 @@ func (webhook *IPPool) Default(_ context.Context, ipPool *ipamv1.IPPool) error {
 	if ipPool.Spec.AllocationStrategy == "" {
 		ipPool.Spec.AllocationStrategy = ipamv1.AllocationStrategySequential
 	}
+	if ipPool.Spec.AllocationStorage == "" {
+		ipPool.Spec.AllocationStorage = ipamv1.AllocationStorageStatus
+	}
 	return nil
 }
 
@@ -70,9 +84,26 @@ func (webhook *IPPool) ValidateCreate(_ context.Context, ipPool *ipamv1.IPPool)
 		return nil, apierrors.NewBadRequest("expected an IPPool but got nil")
 	}
 
+	allErrs := webhook.validateCreate(ipPool)
+	if len(allErrs) == 0 {
+		return nil, nil
+	}
+	return nil, apierrors.NewInvalid(ipamv1.GroupVersion.WithKind("IPPool").GroupKind(), ipPool.Name, allErrs)
+}
+
+// validateCreate validates a new IPPool, or the IPPool view of a new
+// GlobalIPPool.
+func (webhook *IPPool) validateCreate(ipPool *ipamv1.IPPool) field.ErrorList {
 	allErrs := webhook.validatePoolRanges(ipPool)
+	allErrs = append(allErrs, webhook.validateParentPool(ipPool)...)
+
+	// The range of a child IPPool is only known once it obtained its subnet
+	// from its parent IPPool.
+	if ipPool.Spec.ParentPool != nil {
+		return allErrs
+	}
 
-	allocationOutOfBonds, _ := webhook.checkPoolBounds(ipPool, ipPool)
+	allocationOutOfBonds, _ := webhook.checkPoolBounds(nil, ipPool)
 	for _, address := range allocationOutOfBonds {
 		allErrs = append(allErrs,
 			field.Invalid(
@@ -83,15 +114,22 @@ func (webhook *IPPool) ValidateCreate(_ context.Context, ipPool *ipamv1.IPPool)
 		)
 	}
 
-	if len(allErrs) == 0 {
-		return nil, nil
+	allocationExcluded, _ := webhook.checkExclusions(nil, ipPool)
+	for _, address := range allocationExcluded {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "preAllocations"),
+				address,
+				"is excluded from the pools given",
+			),
+		)
 	}
-	return nil, apierrors.NewInvalid(ipamv1.GroupVersion.WithKind("IPPool").GroupKind(), ipPool.Name, allErrs)
+
+	return allErrs
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
-func (webhook *IPPool) ValidateUpdate(_ context.Context, oldIPPool, newIPPool *ipamv1.IPPool) (admission.Warnings, error) {
-	allErrs := field.ErrorList{}
+func (webhook *IPPool) ValidateUpdate(ctx context.Context, oldIPPool, newIPPool *ipamv1.IPPool) (admission.Warnings, error) {
 	if oldIPPool == nil {
 		return nil, apierrors.NewInternalError(errors.New("unable to convert existing object"))
 	}
@@ -100,6 +138,18 @@ func (webhook *IPPool) ValidateUpdate(_ context.Context, oldIPPool, newIPPool *i
 		return nil, apierrors.NewBadRequest("expected an IPPool but got nil")
 	}
 
+	allErrs := webhook.validateUpdate(ctx, oldIPPool, newIPPool)
+	if len(allErrs) == 0 {
+		return nil, nil
+	}
+	return nil, apierrors.NewInvalid(ipamv1.GroupVersion.WithKind("IPPool").GroupKind(), newIPPool.Name, allErrs)
+}
+
+// validateUpdate validates the update of an IPPool, or of the IPPool view of
+// a GlobalIPPool.
+func (webhook *IPPool) validateUpdate(ctx context.Context, oldIPPool, newIPPool *ipamv1.IPPool) field.ErrorList {
+	allErrs := field.ErrorList{}
+
 	if !reflect.DeepEqual(newIPPool.Spec.NamePrefix, oldIPPool.Spec.NamePrefix) {
 		allErrs = append(allErrs,
 			field.Invalid(
@@ -131,10 +181,42 @@ func (webhook *IPPool) ValidateUpdate(_ context.Context, oldIPPool, newIPPool *i
 		)
 	}
 
+	oldParentPool := oldIPPool.Spec.ParentPool
+	newParentPool := newIPPool.Spec.ParentPool
+	if (oldParentPool == nil) != (newParentPool == nil) ||
+		(oldParentPool != nil && oldParentPool.Name != newParentPool.Name) {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "parentPool"),
+				newParentPool,
+				"cannot be modified, except its prefixLength",
+			),
+		)
+	}
+
 	// Validate the new pool ranges
 	allErrs = append(allErrs, webhook.validatePoolRanges(newIPPool)...)
+	allErrs = append(allErrs, webhook.validateParentPool(newIPPool)...)
+
+	// The range of a child IPPool is the subnet it obtained from its parent
+	// IPPool, resized to the requested prefix length.
+	poolsPath := field.NewPath("spec", "pools")
+	if newParentPool != nil {
+		if oldIPPool.Status.ParentSubnet == nil {
+			return allErrs
+		}
+		childPool := newIPPool.DeepCopy()
+		childPool.Spec.Pools = ipamv1.GetChildPools(newIPPool.Spec, oldIPPool.Status.ParentSubnet)
+		newIPPool = childPool
+		poolsPath = field.NewPath("spec", "parentPool", "prefixLength")
+	}
+
+	inUse, err := webhook.inUseAddresses(ctx, oldIPPool)
+	if err != nil {
+		return append(allErrs, field.InternalError(poolsPath, err))
+	}
 
-	allocationOutOfBounds, inUseOutOfBounds := webhook.checkPoolBounds(oldIPPool, newIPPool)
+	allocationOutOfBounds, inUseOutOfBounds := webhook.checkPoolBounds(inUse, newIPPool)
 	if len(allocationOutOfBounds) != 0 {
 		for _, address := range allocationOutOfBounds {
 			allErrs = append(allErrs,
@@ -150,7 +232,7 @@ func (webhook *IPPool) ValidateUpdate(_ context.Context, oldIPPool, newIPPool *i
 		for _, address := range inUseOutOfBounds {
 			allErrs = append(allErrs,
 				field.Invalid(
-					field.NewPath("spec", "pools"),
+					poolsPath,
 					address,
 					"is in use but out of bounds of the pools given",
 				),
@@ -158,13 +240,124 @@ func (webhook *IPPool) ValidateUpdate(_ context.Context, oldIPPool, newIPPool *i
 		}
 	}
 
-	if len(allErrs) == 0 {
-		return nil, nil
+	allocationExcluded, inUseExcluded := webhook.checkExclusions(inUse, newIPPool)
+	for _, address := range allocationExcluded {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "preAllocations"),
+				address,
+				"is excluded from the pools given",
+			),
+		)
 	}
-	return nil, apierrors.NewInvalid(ipamv1.GroupVersion.WithKind("IPPool").GroupKind(), newIPPool.Name, allErrs)
+	for _, address := range inUseExcluded {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "exclusions"),
+				address,
+				"is in use but excluded from the pools given",
+			),
+		)
+	}
+
+	return allErrs
+}
+
+// validateParentPool validates that a child IPPool has no pool entries of
+// its own and is not its own parent.
+func (webhook *IPPool) validateParentPool(ipPool *ipamv1.IPPool) field.ErrorList {
+	allErrs := field.ErrorList{}
+	if ipPool.Spec.ParentPool == nil {
+		return allErrs
+	}
+
+	if len(ipPool.Spec.Pools) != 0 {
+		allErrs = append(allErrs,
+			field.Forbidden(
+				field.NewPath("spec", "pools"),
+				"cannot be set with parentPool",
+			),
+		)
+	}
+	if ipPool.Spec.ParentPool.Name == ipPool.Name {
+		allErrs = append(allErrs,
+			field.Invalid(
+				field.NewPath("spec", "parentPool", "name"),
+				ipPool.Spec.ParentPool.Name,
+				"cannot reference the IPPool itself",
+			),
+		)
+	}
+	return allErrs
+}
+
+// IPAddressPoolField is the field index of the IPAddresses on the name of
+// their pool. It must be registered on the cache of the Client of the IPPool
+// webhook.
+const IPAddressPoolField = "spec.pool.name"
+
+// IPAddressPool returns the name of the pool of an IPAddress, indexed as
+// IPAddressPoolField.
+func IPAddressPool(obj client.Object) []string {
+	ipAddress, ok := obj.(*ipamv1.IPAddress)
+	if !ok || ipAddress.Spec.Pool.Name == "" {
+		return nil
+	}
+	return []string{ipAddress.Spec.Pool.Name}
+}
+
+// inUseAddresses returns the addresses allocated by the IPPool. They are
+// listed from the IPAddress objects referencing the IPPool when it does not
+// record its allocations in its status. The IPPool view of a GlobalIPPool has
+// no namespace. The IPAddresses of the IPClaims of other namespaces are in
+// the namespace of their IPClaim, and those namespaces are not recorded
+// without the status, so the IPAddresses of the pool name are listed in all
+// namespaces.
+func (webhook *IPPool) inUseAddresses(ctx context.Context, ipPool *ipamv1.IPPool) ([]ipamv1.IPAddressStr, error) {
+	if ipamv1.StoresAllocationsInStatus(ipPool.Spec) || webhook.Client == nil {
+		return slices.Collect(maps.Values(ipPool.Status.Allocations)), nil
+	}
+	global := ipPool.Namespace == ""
+	inUse := []ipamv1.IPAddressStr{}
+
+	addressObjects := ipamv1.IPAddressList{}
+	if err := webhook.Client.List(ctx, &addressObjects, client.MatchingFields{IPAddressPoolField: ipPool.Name}); err != nil {
+		return nil, err
+	}
+	for _, addressObject := range addressObjects.Items {
+		ref := addressObject.Spec.Pool
+		if ref.Name != ipPool.Name || (ref.Kind == ipamv1.GlobalIPPoolKind) != global {
+			continue
+		}
+		// An IPAddress references the IPPool of its namespace when the
+		// namespace of the reference is unset.
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = addressObject.Namespace
+		}
+		if !global && namespace != ipPool.Namespace {
+			continue
+		}
+		inUse = append(inUse, addressObject.Spec.Address)
+	}
+
+	capiAddressObjects := capipamv1.IPAddressList{}
+	if err := webhook.Client.List(ctx, &capiAddressObjects, client.InNamespace(ipPool.Namespace)); err != nil {
+		return nil, err
+	}
+	for _, addressObject := range capiAddressObjects.Items {
+		ref := addressObject.Spec.PoolRef
+		if ref.Name != ipPool.Name || (ref.Kind == ipamv1.GlobalIPPoolKind) != global {
+			continue
+		}
+		inUse = append(inUse, ipamv1.IPAddressStr(addressObject.Spec.Address))
+	}
+	return inUse, nil
 }
 
-func (webhook *IPPool) checkPoolBounds(oldPool, newPool *ipamv1.IPPool) ([]ipamv1.IPAddressStr, []ipamv1.IPAddressStr) {
+// checkPoolBounds returns the pre-allocated addresses of the new pool and the
+// addresses in use that are out of the bounds of the new pool.
+func (webhook *IPPool) checkPoolBounds(inUse []ipamv1.IPAddressStr, newPool *ipamv1.IPPool) ([]ipamv1.IPAddressStr, []ipamv1.IPAddressStr) {
 	allocationOutOfBounds := []ipamv1.IPAddressStr{}
 	inUseOutOfBounds := []ipamv1.IPAddressStr{}
 	for _, address := range newPool.Spec.PreAllocations {
@@ -174,7 +367,7 @@ func (webhook *IPPool) checkPoolBounds(oldPool, newPool *ipamv1.IPPool) ([]ipamv
 			allocationOutOfBounds = append(allocationOutOfBounds, address)
 		}
 	}
-	for _, address := range oldPool.Status.Allocations {
+	for _, address := range inUse {
 		inBounds := webhook.isAddressInBounds(newPool, address)
 
 		if !inBounds {
@@ -191,47 +384,98 @@ func (webhook *IPPool) isAddressInBounds(newPool *ipamv1.IPPool, address ipamv1.
 	}
 
 	for _, pool := range newPool.Spec.Pools {
-		if pool.Start == nil && pool.End == nil && pool.Subnet == nil {
-			continue
+		if isAddressInPool(pool, ip) {
+			return true
 		}
+	}
 
-		if pool.Start != nil {
-			startIP, err := netip.ParseAddr(string(*pool.Start))
-			if err != nil {
-				// skip this invalid pool, as the validation error should be caught somewhere else
-				continue
-			}
-			if startIP.Compare(ip) > 0 {
-				continue
-			}
+	return false
+}
+
+// checkExclusions returns the pre-allocated addresses of the new pool and the
+// addresses in use that are excluded from the new pool.
+func (webhook *IPPool) checkExclusions(inUse []ipamv1.IPAddressStr, newPool *ipamv1.IPPool) ([]ipamv1.IPAddressStr, []ipamv1.IPAddressStr) {
+	allocationExcluded := []ipamv1.IPAddressStr{}
+	inUseExcluded := []ipamv1.IPAddressStr{}
+	for _, address := range newPool.Spec.PreAllocations {
+		if webhook.isAddressExcluded(newPool, address) {
+			allocationExcluded = append(allocationExcluded, address)
 		}
+	}
+	for _, address := range inUse {
+		if webhook.isAddressExcluded(newPool, address) {
+			inUseExcluded = append(inUseExcluded, address)
+		}
+	}
+	return allocationExcluded, inUseExcluded
+}
 
-		if pool.End != nil {
-			endIP, err := netip.ParseAddr(string(*pool.End))
-			if err != nil {
-				// skip this invalid pool, as the validation error should be caught somewhere else
-				continue
-			}
-			if endIP.Compare(ip) < 0 {
-				continue
-			}
+// isAddressExcluded returns true if the address is excluded at the IPPool
+// level, or if it is excluded or reserved in all the pool entries that
+// contain it.
+func (webhook *IPPool) isAddressExcluded(newPool *ipamv1.IPPool, address ipamv1.IPAddressStr) bool {
+	ip, err := netip.ParseAddr(string(address))
+	if err != nil {
+		return false
+	}
+	if ipamv1.IsAddressExcluded(address, newPool.Spec.Exclusions) {
+		return true
+	}
+
+	excluded := false
+	for _, pool := range ipamv1.GetPools(newPool.Spec) {
+		if !isAddressInPool(pool, ip) {
+			continue
+		}
+		if !ipamv1.IsAddressExcluded(address, pool.Exclusions) {
+			return false
 		}
+		excluded = true
+	}
+	return excluded
+}
 
-		if pool.Subnet != nil {
-			_, subnet, err := net.ParseCIDR(string(*pool.Subnet))
-			if err != nil {
-				// skip this invalid pool, as the validation error should be caught somewhere else
-				continue
-			}
-			if !subnet.Contains(net.ParseIP(ip.String())) {
-				continue
-			}
+// isAddressInPool returns true if the address is within the bounds of the
+// pool entry.
+func isAddressInPool(pool ipamv1.Pool, ip netip.Addr) bool {
+	if pool.Start == nil && pool.End == nil && pool.Subnet == nil {
+		return false
+	}
+
+	if pool.Start != nil {
+		startIP, err := netip.ParseAddr(string(*pool.Start))
+		if err != nil {
+			// skip this invalid pool, as the validation error should be caught somewhere else
+			return false
+		}
+		if startIP.Compare(ip) > 0 {
+			return false
 		}
+	}
 
-		return true
+	if pool.End != nil {
+		endIP, err := netip.ParseAddr(string(*pool.End))
+		if err != nil {
+			// skip this invalid pool, as the validation error should be caught somewhere else
+			return false
+		}
+		if endIP.Compare(ip) < 0 {
+			return false
+		}
 	}
 
-	return false
+	if pool.Subnet != nil {
+		_, subnet, err := net.ParseCIDR(string(*pool.Subnet))
+		if err != nil {
+			// skip this invalid pool, as the validation error should be caught somewhere else
+			return false
+		}
+		if !subnet.Contains(net.ParseIP(ip.String())) {
+			return false
+		}
+	}
+
+	return true
 }
 
 // validatePoolRanges validates that start <= end for each pool and validates
@@ -265,6 +509,20 @@ func (webhook *IPPool) validatePoolRanges(pool *ipamv1.IPPool) field.ErrorList {
 		}
 	}
 
+	// Validate spec-level exclusions, the exclusions of the pool entries are
+	// validated with the entries.
+	for i, exclusion := range pool.Spec.Exclusions {
+		if _, _, err := ipamv1.ParseExclusion(exclusion); err != nil {
+			allErrs = append(allErrs,
+				field.Invalid(
+					field.NewPath("spec", "exclusions").Index(i),
+					exclusion,
+					err.Error(),
+				),
+			)
+		}
+	}
+
 	// Validate preAllocations IPs.
 	ipToClaimName := make(map[netip.Addr]string, len(pool.Spec.PreAllocations))
 	for name, ipAddr := range pool.Spec.PreAllocations {
@@ -296,10 +554,45 @@ func (webhook *IPPool) validatePoolRanges(pool *ipamv1.IPPool) field.ErrorList {
 		}
 	}
 
+	switch pool.Spec.AllocationStrategy {
+	case "", ipamv1.AllocationStrategySequential, ipamv1.AllocationStrategyRandom,
+		ipamv1.AllocationStrategyLeastRecentlyReleased:
+	default:
+		allErrs = append(allErrs,
+			field.NotSupported(field.NewPath("spec", "allocationStrategy"), pool.Spec.AllocationStrategy,
+				[]ipamv1.AllocationStrategy{
+					ipamv1.AllocationStrategySequential,
+					ipamv1.AllocationStrategyRandom,
+					ipamv1.AllocationStrategyLeastRecentlyReleased,
+				}))
+	}
+	if pool.Spec.QuarantineDuration != nil && pool.Spec.QuarantineDuration.Duration < 0 {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "quarantineDuration"), pool.Spec.QuarantineDuration.String(),
+				"must not be negative"))
+	}
+	if pool.Spec.ReservationTTL != nil && pool.Spec.ReservationTTL.Duration < 0 {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "reservationTTL"), pool.Spec.ReservationTTL.String(),
+				"must not be negative"))
+	}
+	allErrs = append(allErrs, webhook.validateQuotas(pool)...)
+
 	// Validate each pool entry
 	randomStrategy := pool.Spec.AllocationStrategy == ipamv1.AllocationStrategyRandom
+	entryNames := make(map[string]bool, len(pool.Spec.Pools))
 	for i, p := range pool.Spec.Pools {
 		poolPath := field.NewPath("spec", "pools").Index(i)
+		if p.Name != "" {
+			for _, msg := range validation.IsDNS1123Label(p.Name) {
+				allErrs = append(allErrs, field.Invalid(poolPath.Child("name"), p.Name, msg))
+			}
+			if entryNames[p.Name] {
+				allErrs = append(allErrs, field.Duplicate(poolPath.Child("name"), p.Name))
+			}
+			entryNames[p.Name] = true
+		}
+		allErrs = append(allErrs, metav1validation.ValidateLabels(p.Labels, poolPath.Child("labels"))...)
 		errCountBefore := len(allErrs)
 		if p.Start == nil && p.End == nil && p.Subnet == nil {
 			allErrs = append(allErrs,
@@ -340,6 +633,38 @@ func (webhook *IPPool) validatePoolRanges(pool *ipamv1.IPPool) field.ErrorList {
 	return allErrs
 }
 
+// validateQuotas validates the allocation quotas of the IPPool. A selector is
+// required by the Selector scope only.
+func (webhook *IPPool) validateQuotas(pool *ipamv1.IPPool) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for i, quota := range pool.Spec.Quotas {
+		quotaPath := field.NewPath("spec", "quotas").Index(i)
+		switch quota.Scope {
+		case ipamv1.QuotaScopeNamespace, ipamv1.QuotaScopeCluster:
+			if quota.Selector != nil {
+				allErrs = append(allErrs, field.Forbidden(quotaPath.Child("selector"),
+					fmt.Sprintf("can only be set with the %s scope", ipamv1.QuotaScopeSelector)))
+			}
+		case ipamv1.QuotaScopeSelector:
+			if quota.Selector == nil {
+				allErrs = append(allErrs, field.Required(quotaPath.Child("selector"),
+					fmt.Sprintf("is required with the %s scope", ipamv1.QuotaScopeSelector)))
+			} else {
+				allErrs = append(allErrs, metav1validation.ValidateLabelSelector(quota.Selector,
+					metav1validation.LabelSelectorValidationOptions{}, quotaPath.Child("selector"))...)
+			}
+		default:
+			allErrs = append(allErrs, field.NotSupported(quotaPath.Child("scope"), quota.Scope,
+				[]ipamv1.QuotaScope{ipamv1.QuotaScopeNamespace, ipamv1.QuotaScopeCluster, ipamv1.QuotaScopeSelector}))
+		}
+		if quota.MaxAllocations < 0 {
+			allErrs = append(allErrs, field.Invalid(quotaPath.Child("maxAllocations"), quota.MaxAllocations,
+				"must not be negative"))
+		}
+	}
+	return allErrs
+}
+
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
 func (webhook *IPPool) ValidateDelete(_ context.Context, _ *ipamv1.IPPool) (admission.Warnings, error) {
 	return nil, nil
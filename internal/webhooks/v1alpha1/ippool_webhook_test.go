@@ -17,11 +17,17 @@ package webhooks
 
 import (
 	"testing"
+	"time"
 
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 var ctx = ctrl.SetupSignalHandler()
@@ -39,6 +45,7 @@ func TestIPPoolDefault(t *testing.T) {
 	g.Expect(webhook.Default(ctx, c)).To(Succeed())
 
 	g.Expect(c.Spec.AllocationStrategy).To(Equal(ipamv1.AllocationStrategySequential))
+	g.Expect(c.Spec.AllocationStorage).To(Equal(ipamv1.AllocationStorageStatus))
 	g.Expect(c.Status).To(Equal(ipamv1.IPPoolStatus{}))
 }
 
@@ -64,6 +71,8 @@ func TestIPPoolValidation(t *testing.T) {
 	invalidStartAddr := ipamv1.IPAddressStr("192.168.0.149")
 	endAddr := ipamv1.IPAddressStr("192.168.0.20")
 	invalidEndAddr := ipamv1.IPAddressStr("192.168.0.140")
+	lowStartAddr := ipamv1.IPAddressStr("192.168.0.1")
+	lowEndAddr := ipamv1.IPAddressStr("192.168.0.9")
 
 	malformedIP := ipamv1.IPAddressStr("not-an-ip")
 	invalidIPFormat := ipamv1.IPAddressStr("256.256.256.256")
@@ -343,6 +352,177 @@ func TestIPPoolValidation(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "should succeed with valid exclusions",
+			expectErr: false,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{
+							Subnet:     &validSubnet,
+							Exclusions: []ipamv1.IPExclusionStr{"192.168.0.1", "192.168.0.240/28"},
+						},
+					},
+					Exclusions: []ipamv1.IPExclusionStr{"192.168.0.10-192.168.0.20"},
+					PreAllocations: map[string]ipamv1.IPAddressStr{
+						"claim1": "192.168.0.5",
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with malformed spec exclusion",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{Subnet: &validSubnet},
+					},
+					Exclusions: []ipamv1.IPExclusionStr{"192.168.0.20-192.168.0.10"},
+				},
+			},
+		},
+		{
+			name:      "should fail with malformed pool exclusion",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{
+							Subnet:     &validSubnet,
+							Exclusions: []ipamv1.IPExclusionStr{"not-an-ip"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail when preAllocation is in a spec exclusion",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{Subnet: &validSubnet},
+					},
+					Exclusions: []ipamv1.IPExclusionStr{"192.168.0.0/28"},
+					PreAllocations: map[string]ipamv1.IPAddressStr{
+						"claim1": "192.168.0.5",
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail when preAllocation is in the exclusions of its pool",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{
+							Subnet:     &validSubnet,
+							Exclusions: []ipamv1.IPExclusionStr{"192.168.0.5"},
+						},
+					},
+					PreAllocations: map[string]ipamv1.IPAddressStr{
+						"claim1": "192.168.0.5",
+					},
+				},
+			},
+		},
+		{
+			name:      "should succeed when preAllocation is excluded from one pool but not from another",
+			expectErr: false,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{
+							Subnet:     &validSubnet,
+							Exclusions: []ipamv1.IPExclusionStr{"192.168.0.5"},
+						},
+						{Start: &startAddr, End: &endAddr},
+						{
+							Start: &lowStartAddr,
+							End:   &lowEndAddr,
+						},
+					},
+					PreAllocations: map[string]ipamv1.IPAddressStr{
+						"claim1": "192.168.0.5",
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail when preAllocation is the reserved gateway",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{Subnet: &validSubnet},
+					},
+					Gateway:                  &validGateway,
+					ExcludeReservedAddresses: true,
+					PreAllocations: map[string]ipamv1.IPAddressStr{
+						"claim1": validGateway,
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail when preAllocation is the reserved broadcast address",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{Subnet: &validSubnet},
+					},
+					ExcludeReservedAddresses: true,
+					PreAllocations: map[string]ipamv1.IPAddressStr{
+						"claim1": "192.168.0.255",
+					},
+				},
+			},
+		},
+		{
+			name:      "should succeed when preAllocation is the gateway and reserved addresses are not excluded",
+			expectErr: false,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{Subnet: &validSubnet},
+					},
+					Gateway: &validGateway,
+					PreAllocations: map[string]ipamv1.IPAddressStr{
+						"claim1": validGateway,
+					},
+				},
+			},
+		},
 		{
 			name:      "should succeed with random strategy when pool is bounded by start and end",
 			expectErr: false,
@@ -434,6 +614,140 @@ func TestIPPoolValidation(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "should succeed with leastRecentlyReleased strategy when pool is unbounded (start only)",
+			expectErr: false,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					AllocationStrategy: ipamv1.AllocationStrategyLeastRecentlyReleased,
+					Pools: []ipamv1.Pool{
+						{Start: &startAddr},
+					},
+				},
+			},
+		},
+		{
+			name:      "should succeed with a quarantine duration",
+			expectErr: false,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					QuarantineDuration: &metav1.Duration{Duration: 30 * time.Minute},
+					Pools: []ipamv1.Pool{
+						{Start: &startAddr, End: &endAddr},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with a negative quarantine duration",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					QuarantineDuration: &metav1.Duration{Duration: -time.Minute},
+					Pools: []ipamv1.Pool{
+						{Start: &startAddr, End: &endAddr},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with a negative reservation TTL",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					ReclaimPolicy:  ipamv1.ReclaimPolicyRetain,
+					ReservationTTL: &metav1.Duration{Duration: -time.Hour},
+					Pools: []ipamv1.Pool{
+						{Start: &startAddr, End: &endAddr},
+					},
+				},
+			},
+		},
+		{
+			name:      "should succeed with named and labelled pool entries",
+			expectErr: false,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{Name: "rack-1", Labels: map[string]string{"rack": "rack-1"}, Start: &startAddr, End: &startAddr},
+						{Name: "rack-2", Labels: map[string]string{"rack": "rack-2"}, Start: &endAddr, End: &endAddr},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with duplicate pool entry names",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{Name: "rack-1", Start: &startAddr, End: &startAddr},
+						{Name: "rack-1", Start: &endAddr, End: &endAddr},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with an invalid pool entry name",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{Name: "Rack_1", Start: &startAddr, End: &endAddr},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with invalid pool entry labels",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{Labels: map[string]string{"rack": "rack 1"}, Start: &startAddr, End: &endAddr},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with an unknown strategy",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					AllocationStrategy: "mostRecentlyReleased",
+					Pools: []ipamv1.Pool{
+						{Start: &startAddr, End: &endAddr},
+					},
+				},
+			},
+		},
 		{
 			name:      "should succeed with sequential strategy when pool is unbounded (start only)",
 			expectErr: false,
@@ -485,6 +799,135 @@ func TestIPPoolValidation(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "should succeed for a child IPPool with preAllocations",
+			expectErr: false,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					ParentPool: &ipamv1.ParentPoolReference{Name: "parent", PrefixLength: 26},
+					PreAllocations: map[string]ipamv1.IPAddressStr{
+						"claim1": "192.168.0.10",
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail when a child IPPool has pools",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					ParentPool: &ipamv1.ParentPoolReference{Name: "parent", PrefixLength: 26},
+					Pools: []ipamv1.Pool{
+						{Subnet: &largeV6Subnet},
+					},
+				},
+			},
+		},
+		{
+			name:      "should succeed with allocation quotas",
+			expectErr: false,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Quotas: []ipamv1.AllocationQuota{
+						{Scope: ipamv1.QuotaScopeNamespace, MaxAllocations: 10},
+						{Scope: ipamv1.QuotaScopeCluster, MaxAllocations: 5},
+						{
+							Scope:    ipamv1.QuotaScopeSelector,
+							Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with a Selector quota without selector",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Quotas: []ipamv1.AllocationQuota{
+						{Scope: ipamv1.QuotaScopeSelector, MaxAllocations: 10},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with a selector on a Namespace quota",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Quotas: []ipamv1.AllocationQuota{
+						{
+							Scope:          ipamv1.QuotaScopeNamespace,
+							Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+							MaxAllocations: 10,
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with an invalid quota selector",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Quotas: []ipamv1.AllocationQuota{
+						{
+							Scope: ipamv1.QuotaScopeSelector,
+							Selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+								{Key: "app", Operator: "Unknown"},
+							}},
+							MaxAllocations: 10,
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with an unknown quota scope",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Quotas: []ipamv1.AllocationQuota{
+						{Scope: "Owner", MaxAllocations: 10},
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail with a negative quota",
+			expectErr: true,
+			c: &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "foo",
+				},
+				Spec: ipamv1.IPPoolSpec{
+					Quotas: []ipamv1.AllocationQuota{
+						{Scope: ipamv1.QuotaScopeNamespace, MaxAllocations: -1},
+					},
+				},
+			},
+		},
 		{
 			name:      "should succeed when IPv6 preAllocations are genuinely different",
 			expectErr: false,
@@ -711,6 +1154,53 @@ func TestIPPoolUpdateValidation(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "should fail when ip in use is excluded",
+			expectErr: true,
+			newPoolSpec: &ipamv1.IPPoolSpec{
+				NamePrefix: "abcd",
+				Pools: []ipamv1.Pool{
+					{Start: &startAddr, End: &endAddr},
+				},
+				Exclusions: []ipamv1.IPExclusionStr{"192.168.0.4-192.168.0.6"},
+			},
+			oldPoolSpec: &ipamv1.IPPoolSpec{
+				NamePrefix: "abcd",
+				Pools: []ipamv1.Pool{
+					{Start: &startAddr, End: &endAddr},
+				},
+			},
+			oldPoolStatus: ipamv1.IPPoolStatus{
+				Allocations: map[string]ipamv1.IPAddressStr{
+					"inuse": ipamv1.IPAddressStr("192.168.0.5"),
+				},
+			},
+		},
+		{
+			name:      "should succeed when exclusions do not contain ips in use",
+			expectErr: false,
+			newPoolSpec: &ipamv1.IPPoolSpec{
+				NamePrefix: "abcd",
+				Pools: []ipamv1.Pool{
+					{
+						Start:      &startAddr,
+						End:        &endAddr,
+						Exclusions: []ipamv1.IPExclusionStr{"192.168.0.6/31"},
+					},
+				},
+			},
+			oldPoolSpec: &ipamv1.IPPoolSpec{
+				NamePrefix: "abcd",
+				Pools: []ipamv1.Pool{
+					{Start: &startAddr, End: &endAddr},
+				},
+			},
+			oldPoolStatus: ipamv1.IPPoolStatus{
+				Allocations: map[string]ipamv1.IPAddressStr{
+					"inuse": ipamv1.IPAddressStr("192.168.0.5"),
+				},
+			},
+		},
 		{
 			name:      "should succeed when update has unique preAllocation IPs",
 			expectErr: false,
@@ -771,6 +1261,76 @@ func TestIPPoolUpdateValidation(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:      "should succeed when a child IPPool shrinks around its addresses",
+			expectErr: false,
+			newPoolSpec: &ipamv1.IPPoolSpec{
+				ParentPool: &ipamv1.ParentPoolReference{Name: "parent", PrefixLength: 26},
+			},
+			oldPoolSpec: &ipamv1.IPPoolSpec{
+				ParentPool: &ipamv1.ParentPoolReference{Name: "parent", PrefixLength: 25},
+			},
+			oldPoolStatus: ipamv1.IPPoolStatus{
+				ParentSubnet: &subnet,
+				Allocations: map[string]ipamv1.IPAddressStr{
+					"inUse": ipamv1.IPAddressStr("192.168.0.10"),
+				},
+			},
+		},
+		{
+			name:      "should fail when a child IPPool shrinks past its addresses",
+			expectErr: true,
+			newPoolSpec: &ipamv1.IPPoolSpec{
+				ParentPool: &ipamv1.ParentPoolReference{Name: "parent", PrefixLength: 26},
+			},
+			oldPoolSpec: &ipamv1.IPPoolSpec{
+				ParentPool: &ipamv1.ParentPoolReference{Name: "parent", PrefixLength: 25},
+			},
+			oldPoolStatus: ipamv1.IPPoolStatus{
+				ParentSubnet: &subnet,
+				Allocations: map[string]ipamv1.IPAddressStr{
+					"inUse": ipamv1.IPAddressStr("192.168.0.100"),
+				},
+			},
+		},
+		{
+			name:      "should succeed when a child IPPool grows",
+			expectErr: false,
+			newPoolSpec: &ipamv1.IPPoolSpec{
+				ParentPool: &ipamv1.ParentPoolReference{Name: "parent", PrefixLength: 24},
+			},
+			oldPoolSpec: &ipamv1.IPPoolSpec{
+				ParentPool: &ipamv1.ParentPoolReference{Name: "parent", PrefixLength: 25},
+			},
+			oldPoolStatus: ipamv1.IPPoolStatus{
+				ParentSubnet: &subnet,
+				Allocations: map[string]ipamv1.IPAddressStr{
+					"inUse": ipamv1.IPAddressStr("192.168.0.100"),
+				},
+			},
+		},
+		{
+			name:      "should fail when the parent IPPool is modified",
+			expectErr: true,
+			newPoolSpec: &ipamv1.IPPoolSpec{
+				ParentPool: &ipamv1.ParentPoolReference{Name: "other", PrefixLength: 25},
+			},
+			oldPoolSpec: &ipamv1.IPPoolSpec{
+				ParentPool: &ipamv1.ParentPoolReference{Name: "parent", PrefixLength: 25},
+			},
+		},
+		{
+			name:      "should fail when the parent IPPool is removed",
+			expectErr: true,
+			newPoolSpec: &ipamv1.IPPoolSpec{
+				Pools: []ipamv1.Pool{
+					{Subnet: &subnet},
+				},
+			},
+			oldPoolSpec: &ipamv1.IPPoolSpec{
+				ParentPool: &ipamv1.ParentPoolReference{Name: "parent", PrefixLength: 25},
+			},
+		},
 		{
 			name:      "should succeed when IPv6 preAllocations are genuinely different",
 			expectErr: false,
@@ -828,3 +1388,120 @@ func TestIPPoolUpdateValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestIPPoolUpdateValidationWithIPAddresses(t *testing.T) {
+	subnet := ipamv1.IPSubnetStr("192.168.0.0/24")
+	smallSubnet := ipamv1.IPSubnetStr("192.168.0.0/26")
+	exclusion := ipamv1.IPExclusionStr("192.168.0.100")
+
+	newAddress := func(name, namespace string, pool corev1.ObjectReference, address ipamv1.IPAddressStr) *ipamv1.IPAddress {
+		return &ipamv1.IPAddress{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: ipamv1.IPAddressSpec{
+				Pool:    pool,
+				Address: address,
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		expectErr   bool
+		newPoolSpec ipamv1.IPPoolSpec
+		objects     []client.Object
+	}{
+		{
+			name:        "should fail when an IPAddress is out of bounds",
+			expectErr:   true,
+			newPoolSpec: ipamv1.IPPoolSpec{Pools: []ipamv1.Pool{{Subnet: &smallSubnet}}},
+			objects: []client.Object{
+				newAddress("abc-1", "foo", corev1.ObjectReference{Name: "abc"}, "192.168.0.100"),
+			},
+		},
+		{
+			name:        "should fail when an IPAddress of a granted namespace is out of bounds",
+			expectErr:   true,
+			newPoolSpec: ipamv1.IPPoolSpec{Pools: []ipamv1.Pool{{Subnet: &smallSubnet}}},
+			objects: []client.Object{
+				newAddress("abc-1", "tenant", corev1.ObjectReference{Name: "abc", Namespace: "foo"}, "192.168.0.100"),
+			},
+		},
+		{
+			name:        "should fail when a CAPI IPAddress is out of bounds",
+			expectErr:   true,
+			newPoolSpec: ipamv1.IPPoolSpec{Pools: []ipamv1.Pool{{Subnet: &smallSubnet}}},
+			objects: []client.Object{
+				&capipamv1.IPAddress{
+					ObjectMeta: metav1.ObjectMeta{Name: "abc-1", Namespace: "foo"},
+					Spec: capipamv1.IPAddressSpec{
+						PoolRef: capipamv1.IPPoolReference{Name: "abc", Kind: "IPPool", APIGroup: ipamv1.GroupVersion.Group},
+						Address: "192.168.0.100",
+					},
+				},
+			},
+		},
+		{
+			name:      "should fail when an IPAddress is excluded",
+			expectErr: true,
+			newPoolSpec: ipamv1.IPPoolSpec{
+				Pools:      []ipamv1.Pool{{Subnet: &subnet}},
+				Exclusions: []ipamv1.IPExclusionStr{exclusion},
+			},
+			objects: []client.Object{
+				newAddress("abc-1", "foo", corev1.ObjectReference{Name: "abc"}, "192.168.0.100"),
+			},
+		},
+		{
+			name:        "should succeed when the IPAddresses out of bounds belong to other pools",
+			expectErr:   false,
+			newPoolSpec: ipamv1.IPPoolSpec{Pools: []ipamv1.Pool{{Subnet: &smallSubnet}}},
+			objects: []client.Object{
+				newAddress("other-1", "foo", corev1.ObjectReference{Name: "other"}, "192.168.0.100"),
+				newAddress("abc-1", "bar", corev1.ObjectReference{Name: "abc"}, "192.168.0.101"),
+				newAddress("abc-2", "foo", corev1.ObjectReference{Name: "abc", Kind: ipamv1.GlobalIPPoolKind}, "192.168.0.102"),
+				newAddress("abc-3", "foo", corev1.ObjectReference{Name: "abc"}, "192.168.0.10"),
+			},
+		},
+		{
+			name:        "should succeed when the IPAddresses out of bounds belong to a same-named pool of another namespace",
+			expectErr:   false,
+			newPoolSpec: ipamv1.IPPoolSpec{Pools: []ipamv1.Pool{{Subnet: &smallSubnet}}},
+			objects: []client.Object{
+				newAddress("abc-1", "foo", corev1.ObjectReference{Name: "abc", Namespace: "bar"}, "192.168.0.100"),
+				newAddress("abc-2", "bar", corev1.ObjectReference{Name: "abc", Namespace: "bar"}, "192.168.0.101"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			scheme := runtime.NewScheme()
+			g.Expect(ipamv1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(capipamv1.AddToScheme(scheme)).To(Succeed())
+			webhook := &IPPool{
+				Client: fakeclient.NewClientBuilder().WithScheme(scheme).WithObjects(tt.objects...).
+					WithIndex(&ipamv1.IPAddress{}, IPAddressPoolField, IPAddressPool).Build(),
+			}
+
+			oldPool := &ipamv1.IPPool{
+				ObjectMeta: metav1.ObjectMeta{Name: "abc", Namespace: "foo"},
+				Spec: ipamv1.IPPoolSpec{
+					NamePrefix:        "abc",
+					AllocationStorage: ipamv1.AllocationStorageIPAddresses,
+					Pools:             []ipamv1.Pool{{Subnet: &subnet}},
+				},
+			}
+			newPool := oldPool.DeepCopy()
+			newPool.Spec.Pools = tt.newPoolSpec.Pools
+			newPool.Spec.Exclusions = tt.newPoolSpec.Exclusions
+
+			_, err := webhook.ValidateUpdate(ctx, oldPool, newPool)
+			if tt.expectErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrorReason is the reason of an AllocationError.
+type ErrorReason string
+
+const (
+	// ErrorReasonExhausted is used when no address, or no subnet, is left in
+	// the pool.
+	ErrorReasonExhausted ErrorReason = "Exhausted"
+	// ErrorReasonOutOfBounds is used when the pre-allocated address, or
+	// subnet, of the claim is not within the bounds of the pool.
+	ErrorReasonOutOfBounds ErrorReason = "OutOfBounds"
+	// ErrorReasonConflictingRequest is used when the address requested by the
+	// claim is held by another claim.
+	ErrorReasonConflictingRequest ErrorReason = "ConflictingRequest"
+	// ErrorReasonPreAllocationConflict is used when the address requested by
+	// the claim conflicts with its pre-allocated address.
+	ErrorReasonPreAllocationConflict ErrorReason = "PreAllocationConflict"
+	// ErrorReasonNameCollision is used when the IPAddress of the allocated
+	// address already exists for another pool.
+	ErrorReasonNameCollision ErrorReason = "NameCollision"
+	// ErrorReasonPoolNotFound is used when the pool of the claim does not
+	// exist.
+	ErrorReasonPoolNotFound ErrorReason = "PoolNotFound"
+	// ErrorReasonQuotaExceeded is used when the allocation would exceed an
+	// allocation quota of the pool.
+	ErrorReasonQuotaExceeded ErrorReason = "QuotaExceeded"
+	// ErrorReasonIPFamilyConflict is used when both pools of a dual-stack
+	// IPClaim allocate an address of the same IP family.
+	ErrorReasonIPFamilyConflict ErrorReason = "IPFamilyConflict"
+	// ErrorReasonInvalidRequestedIP is used when the address requested by the
+	// claim is not a valid IP address.
+	ErrorReasonInvalidRequestedIP ErrorReason = "InvalidRequestedIP"
+	// ErrorReasonInvalidPoolEntrySelector is used when the pool entry selector
+	// of the claim cannot be parsed.
+	ErrorReasonInvalidPoolEntrySelector ErrorReason = "InvalidPoolEntrySelector"
+	// ErrorReasonNoMatchingPoolEntry is used when no pool entry matches the
+	// pool entry selector of the claim.
+	ErrorReasonNoMatchingPoolEntry ErrorReason = "NoMatchingPoolEntry"
+	// ErrorReasonInvalidPrefixLength is used when no pool entry has a subnet
+	// that can hold the prefix length requested by the claim.
+	ErrorReasonInvalidPrefixLength ErrorReason = "InvalidPrefixLength"
+	// ErrorReasonInvalidPrefix is used when the prefix of the pool is not a
+	// valid prefix length.
+	ErrorReasonInvalidPrefix ErrorReason = "InvalidPrefix"
+	// ErrorReasonAddressCreationFailed is used when the IPAddress of the claim
+	// cannot be created.
+	ErrorReasonAddressCreationFailed ErrorReason = "AddressCreationFailed"
+)
+
+// errorReasonMapping holds the reason of the Ready condition of the claim,
+// the reason of the event and the reason label of the allocation failures
+// metric reporting an ErrorReason. The reasons that are not counted have no
+// metric.
+type errorReasonMapping struct {
+	condition string
+	event     string
+	metric    AllocationFailureReason
+}
+
+// errorReasons maps each ErrorReason to the way it is reported.
+var errorReasons = map[ErrorReason]errorReasonMapping{
+	ErrorReasonExhausted: {
+		ipamv1.IPClaimPoolExhaustedReason, PoolExhaustedReason, AllocationFailureExhausted,
+	},
+	ErrorReasonOutOfBounds: {
+		ipamv1.IPClaimPreAllocationOutOfBoundsReason, AddressOutOfBoundsReason, AllocationFailurePreAllocationOutOfBounds,
+	},
+	ErrorReasonConflictingRequest: {
+		ipamv1.IPClaimRequestedIPUnavailableReason, RequestedIPUnavailableReason, AllocationFailureRequestedIPUnavailable,
+	},
+	ErrorReasonPreAllocationConflict: {
+		ipamv1.IPClaimAllocationFailedReason, AllocationConflictReason, AllocationFailureConflict,
+	},
+	ErrorReasonNameCollision: {
+		ipamv1.IPClaimAllocationFailedReason, AddressNameCollisionReason, AllocationFailureNameCollision,
+	},
+	// The series of a missing pool would never be deleted.
+	ErrorReasonPoolNotFound: {
+		ipamv1.IPClaimPoolNotFoundReason, PoolNotFoundReason, "",
+	},
+	ErrorReasonQuotaExceeded: {
+		ipamv1.IPClaimQuotaExceededReason, QuotaExceededReason, AllocationFailureQuotaExceeded,
+	},
+	ErrorReasonIPFamilyConflict: {
+		ipamv1.IPClaimAllocationFailedReason, IPFamilyConflictReason, AllocationFailureIPFamilyConflict,
+	},
+	ErrorReasonInvalidRequestedIP: {
+		ipamv1.IPClaimAllocationFailedReason, InvalidRequestReason, AllocationFailureInvalidRequestedIP,
+	},
+	ErrorReasonInvalidPoolEntrySelector: {
+		ipamv1.IPClaimAllocationFailedReason, InvalidRequestReason, AllocationFailureInvalidPoolEntrySelector,
+	},
+	ErrorReasonNoMatchingPoolEntry: {
+		ipamv1.IPClaimAllocationFailedReason, InvalidRequestReason, AllocationFailureNoMatchingPoolEntry,
+	},
+	ErrorReasonInvalidPrefixLength: {
+		ipamv1.IPClaimAllocationFailedReason, InvalidRequestReason, AllocationFailureInvalidPrefixLength,
+	},
+	ErrorReasonInvalidPrefix: {
+		ipamv1.IPClaimAllocationFailedReason, InvalidRequestReason, AllocationFailureInvalidPrefix,
+	},
+	ErrorReasonAddressCreationFailed: {
+		ipamv1.IPClaimAllocationFailedReason, AddressCreationFailedReason, AllocationFailureAddressCreationFailed,
+	},
+}
+
+// AllocationError is the failure of the allocation of an address to a claim.
+// Its message is reported to the claim.
+type AllocationError struct {
+	Reason  ErrorReason
+	Message string
+	err     error
+}
+
+// Error returns the message of the AllocationError.
+func (e *AllocationError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the error that caused the AllocationError, if any.
+func (e *AllocationError) Unwrap() error {
+	return e.err
+}
+
+// isClaimError returns true if the error is the failure to serve a claim,
+// which is reported to the claim only. The allocation errors caused by a
+// failing API call are reported to the pool too.
+func isClaimError(err error) bool {
+	var allocationError *AllocationError
+	return errors.As(err, &allocationError) && allocationError.err == nil
+}
+
+// newAllocationError returns an AllocationError with a formatted message.
+func newAllocationError(reason ErrorReason, messageFmt string, args ...any) *AllocationError {
+	return &AllocationError{Reason: reason, Message: fmt.Sprintf(messageFmt, args...)}
+}
+
+// The allocation errors with a fixed message.
+var (
+	errPoolExhausted = newAllocationError(ErrorReasonExhausted,
+		"Exhausted IP Pools")
+	errPreAllocationOutOfBounds = newAllocationError(ErrorReasonOutOfBounds,
+		"Pre-allocated IP out of bounds")
+	errPreAllocatedSubnetOutOfBounds = newAllocationError(ErrorReasonOutOfBounds,
+		"Pre-allocated subnet out of bounds")
+	errNoPoolEntryMatches = newAllocationError(ErrorReasonNoMatchingPoolEntry,
+		"No pool entry matches the pool entry selector")
+	errInvalidPrefix = newAllocationError(ErrorReasonInvalidPrefix,
+		"Invalid prefix value, it must be between 0 and 128")
+	errSameIPFamily = newAllocationError(ErrorReasonIPFamilyConflict,
+		"Pools of a dual-stack IPClaim are of the same IP family")
+)
+
+// setClaimError reports the AllocationError in the Ready condition of the
+// claim. The error message of an IPClaim is also set, except when its pool
+// was not found, since the claim is then served once the pool is created.
+func setClaimError(claim client.Object, err *AllocationError) {
+	reason := errorReasons[err.Reason].condition
+	switch claim := claim.(type) {
+	case *ipamv1.IPClaim:
+		if err.Reason != ErrorReasonPoolNotFound {
+			setClaimFailed(claim, reason, err.Message)
+			return
+		}
+		conditions.Set(claim, metav1.Condition{
+			Type:    ipamv1.IPClaimReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: err.Message,
+		})
+	case *capipamv1.IPAddressClaim:
+		setCapiClaimCondition(claim, metav1.ConditionFalse, reason, err.Message)
+	}
+}
+
+// failAllocation reports the AllocationError of a claim in its Ready
+// condition, in a Warning event and in the allocation failures metric, and
+// returns it. A child IPPool has no condition reporting its allocation.
+func (m *IPPoolManager) failAllocation(claim client.Object, claimKind string, err *AllocationError) error {
+	setClaimError(claim, err)
+	mapping := errorReasons[err.Reason]
+	if mapping.metric != "" {
+		m.recordAllocationFailure(claimKind, mapping.metric)
+	}
+	m.recordWarning(claim, claimKind, mapping.event, "%s", err.Message)
+	return err
+}
+
+// preAllocationConflict returns the error of a claim requesting another
+// address than its pre-allocated address.
+func preAllocationConflict(preAllocated, requested ipamv1.IPAddressStr) *AllocationError {
+	return newAllocationError(ErrorReasonPreAllocationConflict,
+		"Pre-allocated address %s conflicts with requested address %s", preAllocated, requested)
+}
+
+// conflictingRequest returns the error of a claim requesting an address that
+// is in use, naming the holder of the address.
+func (m *IPPoolManager) conflictingRequest(requested ipamv1.IPAddressStr,
+	addresses map[ipamv1.IPAddressStr]string,
+) *AllocationError {
+	for address, key := range addresses {
+		if !m.ipEqual(address, requested) {
+			continue
+		}
+		if key == "" {
+			for claimName, preAllocated := range m.IPPool.Spec.PreAllocations {
+				if m.ipEqual(preAllocated, requested) {
+					return newAllocationError(ErrorReasonConflictingRequest,
+						"Requested IP %s is pre-allocated to claim %s", requested, claimName)
+				}
+			}
+			break
+		}
+		if name, ok := strings.CutPrefix(key, childPoolKeyPrefix); ok {
+			return newAllocationError(ErrorReasonConflictingRequest,
+				"Requested IP %s is held by child IPPool %s", requested, name)
+		}
+		if _, ok := m.reservations[key]; ok {
+			return newAllocationError(ErrorReasonConflictingRequest,
+				"Requested IP %s is reserved for deleted claim %s", requested, key)
+		}
+		return newAllocationError(ErrorReasonConflictingRequest,
+			"Requested IP %s is held by claim %s", requested, key)
+	}
+	return newAllocationError(ErrorReasonConflictingRequest, "Requested IP %s not available", requested)
+}
+
+// createAddressObject creates the IPAddress, or the CAPI IPAddress, of a
+// claim. It returns an AllocationError if the IPAddress cannot be created, or
+// if it already exists for another pool. An IPAddress that already exists for
+// the IPPool is missing from the cache, and its creation is retried.
+func (m *IPPoolManager) createAddressObject(ctx context.Context, addressObject client.Object) error {
+	err := createObject(ctx, m.client, addressObject)
+	if err == nil {
+		return nil
+	}
+	var reconcileError ReconcileError
+	if !errors.As(err, &reconcileError) {
+		return &AllocationError{
+			Reason:  ErrorReasonAddressCreationFailed,
+			Message: fmt.Sprintf("Failed to create IPAddress %s: %v", addressObject.GetName(), err),
+			err:     err,
+		}
+	}
+
+	var pool string
+	switch addressObject.(type) {
+	case *ipamv1.IPAddress:
+		existing := &ipamv1.IPAddress{}
+		if m.client.Get(ctx, client.ObjectKeyFromObject(addressObject), existing) == nil &&
+			!m.isPoolReference(existing.Spec.Pool, existing.Namespace) {
+			pool = existing.Spec.Pool.Name
+		}
+	case *capipamv1.IPAddress:
+		existing := &capipamv1.IPAddress{}
+		if m.client.Get(ctx, client.ObjectKeyFromObject(addressObject), existing) == nil &&
+			!m.isCAPIPoolReference(existing.Spec.PoolRef) {
+			pool = existing.Spec.PoolRef.Name
+		}
+	}
+	if pool != "" {
+		return newAllocationError(ErrorReasonNameCollision,
+			"IPAddress %s already exists for pool %s", addressObject.GetName(), pool)
+	}
+	return err
+}
+
+// noSubnetFits returns the error of a claim requesting a prefix length that no
+// pool entry can hold.
+func noSubnetFits(prefixLength int) *AllocationError {
+	return newAllocationError(ErrorReasonInvalidPrefixLength,
+		"No pool entry has a subnet that can hold a /%d subnet", prefixLength)
+}
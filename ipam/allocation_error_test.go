@@ -0,0 +1,130 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("IPPool allocation errors", func() {
+	It("Maps every reason to a condition, an event and a metric", func() {
+		// The failures to find a pool are not counted.
+		Expect(errorReasons[ErrorReasonPoolNotFound].metric).To(BeEmpty())
+		for _, reason := range []ErrorReason{
+			ErrorReasonExhausted, ErrorReasonOutOfBounds, ErrorReasonConflictingRequest,
+			ErrorReasonPreAllocationConflict, ErrorReasonNameCollision,
+			ErrorReasonQuotaExceeded, ErrorReasonIPFamilyConflict, ErrorReasonInvalidRequestedIP,
+			ErrorReasonInvalidPoolEntrySelector, ErrorReasonNoMatchingPoolEntry,
+			ErrorReasonInvalidPrefixLength, ErrorReasonInvalidPrefix, ErrorReasonAddressCreationFailed,
+		} {
+			mapping, ok := errorReasons[reason]
+			Expect(ok).To(BeTrue(), string(reason))
+			Expect(mapping.condition).NotTo(BeEmpty())
+			Expect(mapping.event).NotTo(BeEmpty())
+			Expect(mapping.metric).NotTo(BeEmpty())
+		}
+	})
+
+	type testCaseAllocationError struct {
+		objects           []client.Object
+		claim             string
+		expectedMessage   string
+		expectedReason    ErrorReason
+		expectedCondition string
+		expectedEvent     string
+	}
+
+	DescribeTable("Test allocation errors",
+		func(tc testCaseAllocationError) {
+			c := newTestClient(tc.objects...)
+			ipPool := newTestIPPool(newTestPool("192.168.1.10", "192.168.1.12"))
+			ipPool.Spec.PreAllocations = map[string]ipamv1.IPAddressStr{
+				"prealloc": "192.168.1.12",
+			}
+			ipPoolMgr, err := NewIPPoolManager(c, ipPool, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			recorder := record.NewFakeRecorder(10)
+			ipPoolMgr.recorder = recorder
+
+			_, err = ipPoolMgr.UpdateAddresses(context.TODO())
+			Expect(err).To(MatchError(tc.expectedMessage))
+			var allocationError *AllocationError
+			Expect(errors.As(err, &allocationError)).To(BeTrue())
+			Expect(allocationError.Reason).To(Equal(tc.expectedReason))
+
+			claim := getTestIPClaim(c, tc.claim)
+			Expect(conditions.GetReason(claim, ipamv1.IPClaimReadyCondition)).To(Equal(tc.expectedCondition))
+			Expect(claim.Status.ErrorMessage).To(Equal(ptr.To(tc.expectedMessage)))
+			if tc.expectedEvent != "" {
+				var events []string
+				for len(recorder.Events) > 0 {
+					events = append(events, <-recorder.Events)
+				}
+				Expect(events).To(ContainElement(HavePrefix(tc.expectedEvent)))
+			}
+		},
+		Entry("Requested IP allocated to another claim", testCaseAllocationError{
+			objects: []client.Object{
+				newTestRequestingIPClaim("first", "192.168.1.10"),
+				newTestRequestingIPClaim("second", "192.168.1.10"),
+			},
+			claim:             "second",
+			expectedMessage:   "Requested IP 192.168.1.10 is held by claim first",
+			expectedReason:    ErrorReasonConflictingRequest,
+			expectedCondition: ipamv1.IPClaimRequestedIPUnavailableReason,
+		}),
+		Entry("Requested IP pre-allocated to another claim", testCaseAllocationError{
+			objects: []client.Object{
+				newTestRequestingIPClaim("first", "192.168.1.10"),
+				newTestRequestingIPClaim("second", "192.168.1.12"),
+			},
+			claim:             "second",
+			expectedMessage:   "Requested IP 192.168.1.12 is pre-allocated to claim prealloc",
+			expectedReason:    ErrorReasonConflictingRequest,
+			expectedCondition: ipamv1.IPClaimRequestedIPUnavailableReason,
+		}),
+		Entry("IPAddress existing for another pool", testCaseAllocationError{
+			objects: []client.Object{
+				&ipamv1.IPAddress{
+					ObjectMeta: metav1.ObjectMeta{Name: "abcpref-192-168-1-10", Namespace: "myns"},
+					Spec: ipamv1.IPAddressSpec{
+						Address: "192.168.1.10",
+						Pool:    corev1.ObjectReference{Name: "other", Namespace: "myns"},
+					},
+				},
+				newTestIPClaim("first"),
+			},
+			claim:             "first",
+			expectedMessage:   "IPAddress abcpref-192-168-1-10 already exists for pool other",
+			expectedReason:    ErrorReasonNameCollision,
+			expectedCondition: ipamv1.IPClaimAllocationFailedReason,
+			expectedEvent:     corev1.EventTypeWarning + " " + AddressNameCollisionReason,
+		}),
+	)
+})
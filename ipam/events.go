@@ -0,0 +1,99 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reasons of the events emitted on the claims and the IPPool.
+const (
+	// AddressAllocatedReason is used when an address is bound to a claim.
+	AddressAllocatedReason = "AddressAllocated"
+	// AddressReleasedReason is used when the address of a claim is released.
+	AddressReleasedReason = "AddressReleased"
+	// AddressRetainedReason is used when the address of a deleted claim is
+	// reserved for the claim name.
+	AddressRetainedReason = "AddressRetained"
+	// AddressReboundReason is used when the address reserved for the name of
+	// a claim is bound to the claim again.
+	AddressReboundReason = "AddressRebound"
+	// PoolExhaustedReason is used when no address is left for a claim.
+	PoolExhaustedReason = "PoolExhausted"
+	// QuotaExceededReason is used when a claim is over an allocation quota of
+	// the pool.
+	QuotaExceededReason = "QuotaExceeded"
+	// AllocationConflictReason is used when the requested address of a claim
+	// conflicts with its pre-allocated address.
+	AllocationConflictReason = "AllocationConflict"
+	// RequestedIPUnavailableReason is used when the requested address of a
+	// claim is held by another claim.
+	RequestedIPUnavailableReason = "RequestedIPUnavailable"
+	// AddressOutOfBoundsReason is used when the pre-allocated address of a
+	// claim is not within the bounds of the pool.
+	AddressOutOfBoundsReason = "AddressOutOfBounds"
+	// InvalidRequestReason is used when the request of a claim, or the pool,
+	// is invalid.
+	InvalidRequestReason = "InvalidRequest"
+	// AddressNameCollisionReason is used when the IPAddress of a claim
+	// already exists for another pool.
+	AddressNameCollisionReason = "AddressNameCollision"
+	// PoolNotFoundReason is used when the pool of a claim does not exist.
+	PoolNotFoundReason = "PoolNotFound"
+	// AddressCreationFailedReason is used when the IPAddress of a claim
+	// cannot be created.
+	AddressCreationFailedReason = "AddressCreationFailed"
+	// AddressDeletionFailedReason is used when the IPAddress of a claim
+	// cannot be deleted.
+	AddressDeletionFailedReason = "AddressDeletionFailed"
+	// IPFamilyConflictReason is used when both pools of a dual-stack IPClaim
+	// allocate an address of the same IP family.
+	IPFamilyConflictReason = "IPFamilyConflict"
+	// DualStackRollbackReason is used when the address of a dual-stack IPClaim
+	// is released because its other pool failed to allocate an address.
+	DualStackRollbackReason = "DualStackRollback"
+	// ChildPoolResizedReason is used when the subnet of a child IPPool is
+	// resized to its requested prefix length.
+	ChildPoolResizedReason = "ChildPoolResized"
+	// ChildPoolResizeFailedReason is used when the subnet of a child IPPool
+	// cannot be resized to its requested prefix length.
+	ChildPoolResizeFailedReason = "ChildPoolResizeFailed"
+)
+
+// recordEvent emits the same event on the claim and on the IPPool. It is a
+// no-op when the manager has no event recorder.
+func (m *IPPoolManager) recordEvent(claim client.Object, claimKind, eventType, reason, messageFmt string, args ...any) {
+	if m.recorder == nil {
+		return
+	}
+	message := fmt.Sprintf(messageFmt, args...)
+	m.recorder.Event(claim, eventType, reason, message)
+	m.recorder.Event(m.IPPool, eventType, reason, fmt.Sprintf("%s %s: %s", claimKind, claim.GetName(), message))
+}
+
+// recordWarning emits a Warning event on the claim and on the IPPool.
+func (m *IPPoolManager) recordWarning(claim client.Object, claimKind, reason, messageFmt string, args ...any) {
+	m.recordEvent(claim, claimKind, corev1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// recordNormal emits a Normal event on the claim and on the IPPool.
+func (m *IPPoolManager) recordNormal(claim client.Object, claimKind, reason, messageFmt string, args ...any) {
+	m.recordEvent(claim, claimKind, corev1.EventTypeNormal, reason, messageFmt, args...)
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("IPPool events", func() {
+	type testCaseEvents struct {
+		claimName      string
+		requested      string
+		addresses      map[ipamv1.IPAddressStr]string
+		expectError    bool
+		expectedEvents []string
+	}
+
+	runEventsTest := func(tc testCaseEvents, capi bool) {
+		ipPool := &ipamv1.IPPool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "abc",
+				Namespace: "myns",
+			},
+			Spec: ipamv1.IPPoolSpec{
+				NamePrefix: "abcpref",
+				Pools: []ipamv1.Pool{
+					{
+						Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+						End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.11")),
+					},
+				},
+				PreAllocations: map[string]ipamv1.IPAddressStr{
+					"prealloc": "192.168.0.11",
+				},
+			},
+			Status: ipamv1.IPPoolStatus{
+				Allocations: map[string]ipamv1.IPAddressStr{},
+			},
+		}
+		objectMeta := metav1.ObjectMeta{
+			Name:      tc.claimName,
+			Namespace: "myns",
+		}
+		if tc.requested != "" {
+			objectMeta.Annotations = map[string]string{IPAddressAnnotation: tc.requested}
+		}
+
+		fakeClient := fakeclient.NewClientBuilder().WithScheme(setupScheme()).Build()
+		ipPoolMgr, err := NewIPPoolManager(fakeClient, ipPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		recorder := record.NewFakeRecorder(10)
+		ipPoolMgr.recorder = recorder
+
+		if capi {
+			_, err = ipPoolMgr.capiCreateAddress(context.TODO(), &capipamv1.IPAddressClaim{ObjectMeta: objectMeta}, tc.addresses)
+		} else {
+			_, err = ipPoolMgr.createAddress(context.TODO(), &ipamv1.IPClaim{ObjectMeta: objectMeta}, tc.addresses)
+		}
+		if tc.expectError {
+			Expect(err).To(HaveOccurred())
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		// Each event is emitted on the claim and on the IPPool.
+		Expect(recorder.Events).To(HaveLen(2 * len(tc.expectedEvents)))
+		for _, expectedEvent := range tc.expectedEvents {
+			Expect(<-recorder.Events).To(HavePrefix(expectedEvent))
+			Expect(<-recorder.Events).To(HavePrefix(expectedEvent))
+		}
+	}
+
+	DescribeTable("Test events for IPClaim",
+		func(tc testCaseEvents) {
+			runEventsTest(tc, false)
+		},
+		Entry("Address allocated", testCaseEvents{
+			claimName:      "abc",
+			addresses:      map[ipamv1.IPAddressStr]string{},
+			expectedEvents: []string{corev1.EventTypeNormal + " " + AddressAllocatedReason},
+		}),
+		Entry("Pool exhausted", testCaseEvents{
+			claimName: "abc",
+			addresses: map[ipamv1.IPAddressStr]string{
+				"192.168.0.10": "bcd",
+				"192.168.0.11": "",
+			},
+			expectError:    true,
+			expectedEvents: []string{corev1.EventTypeWarning + " " + PoolExhaustedReason},
+		}),
+		Entry("Conflicting pre-allocation and requested IP", testCaseEvents{
+			claimName:      "prealloc",
+			requested:      "192.168.0.10",
+			addresses:      map[ipamv1.IPAddressStr]string{},
+			expectError:    true,
+			expectedEvents: []string{corev1.EventTypeWarning + " " + AllocationConflictReason},
+		}),
+	)
+
+	DescribeTable("Test events for IPAddressClaim",
+		func(tc testCaseEvents) {
+			runEventsTest(tc, true)
+		},
+		Entry("Address allocated", testCaseEvents{
+			claimName:      "abc",
+			addresses:      map[ipamv1.IPAddressStr]string{},
+			expectedEvents: []string{corev1.EventTypeNormal + " " + AddressAllocatedReason},
+		}),
+		Entry("Pool exhausted", testCaseEvents{
+			claimName: "abc",
+			addresses: map[ipamv1.IPAddressStr]string{
+				"192.168.0.10": "bcd",
+				"192.168.0.11": "",
+			},
+			expectError:    true,
+			expectedEvents: []string{corev1.EventTypeWarning + " " + PoolExhaustedReason},
+		}),
+		Entry("Conflicting pre-allocation and requested IP", testCaseEvents{
+			claimName:      "prealloc",
+			requested:      "192.168.0.10",
+			addresses:      map[ipamv1.IPAddressStr]string{},
+			expectError:    true,
+			expectedEvents: []string{corev1.EventTypeWarning + " " + AllocationConflictReason},
+		}),
+	)
+
+	It("Does not emit events without a recorder", func() {
+		ipPoolMgr, err := NewIPPoolManager(nil, &ipamv1.IPPool{}, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		ipPoolMgr.recordNormal(&ipamv1.IPClaim{}, claimKindIPClaim, AddressAllocatedReason, "Allocated address %s", "192.168.0.10")
+	})
+})
@@ -0,0 +1,168 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// GlobalIPPoolManager is responsible for performing GlobalIPPool
+// reconciliation. It manages an IPPool view of the GlobalIPPool, so that the
+// allocation logic is shared with the IPPools, and copies the results back.
+type GlobalIPPoolManager struct {
+	*IPPoolManager
+	GlobalIPPool *ipamv1.GlobalIPPool
+}
+
+// NewGlobalIPPoolManager returns a new helper for managing a globalIPPool
+// object.
+func NewGlobalIPPoolManager(client client.Client, globalIPPool *ipamv1.GlobalIPPool, ipPoolLog logr.Logger) (*GlobalIPPoolManager, error) {
+	selector := labels.Everything()
+	if globalIPPool.Spec.NamespaceSelector != nil {
+		var err error
+		selector, err = metav1.LabelSelectorAsSelector(globalIPPool.Spec.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ipPool := &ipamv1.IPPool{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       ipamv1.GlobalIPPoolKind,
+			APIVersion: ipamv1.GroupVersion.String(),
+		},
+		ObjectMeta: *globalIPPool.ObjectMeta.DeepCopy(),
+		Spec:       *globalIPPool.Spec.IPPoolSpec.DeepCopy(),
+		Status:     *globalIPPool.Status.DeepCopy(),
+	}
+	ipPoolMgr, err := NewIPPoolManager(client, ipPool, ipPoolLog)
+	if err != nil {
+		return nil, err
+	}
+	ipPoolMgr.global = true
+	ipPoolMgr.namespaceSelector = selector
+
+	return &GlobalIPPoolManager{
+		IPPoolManager: ipPoolMgr,
+		GlobalIPPool:  globalIPPool,
+	}, nil
+}
+
+// SetFinalizer sets finalizer.
+func (m *GlobalIPPoolManager) SetFinalizer() {
+	m.IPPoolManager.SetFinalizer()
+	m.GlobalIPPool.Finalizers = m.IPPool.Finalizers
+}
+
+// UnsetFinalizer unsets finalizer.
+func (m *GlobalIPPoolManager) UnsetFinalizer() {
+	m.IPPoolManager.UnsetFinalizer()
+	m.GlobalIPPool.Finalizers = m.IPPool.Finalizers
+}
+
+// SetClusterOwnerRef does nothing, GlobalIPPools do not belong to a cluster.
+func (m *GlobalIPPoolManager) SetClusterOwnerRef(_ *clusterv1.Cluster) error {
+	return nil
+}
+
+// UpdateAddresses manages the claims of the GlobalIPPool and updates its
+// status.
+func (m *GlobalIPPoolManager) UpdateAddresses(ctx context.Context) (int, error) {
+	count, err := m.IPPoolManager.UpdateAddresses(ctx)
+	m.GlobalIPPool.Status = *m.IPPool.Status.DeepCopy()
+	return count, err
+}
+
+// matchesNamespaceSelector returns true if the labels of the namespace match
+// the namespace selector of the GlobalIPPool.
+func (m *IPPoolManager) matchesNamespaceSelector(ctx context.Context, namespace string) (bool, error) {
+	if m.namespaceSelector == nil || m.namespaceSelector.Empty() {
+		return true, nil
+	}
+	namespaceObject := &corev1.Namespace{}
+	err := m.client.Get(ctx, client.ObjectKey{Name: namespace}, namespaceObject)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return m.namespaceSelector.Matches(labels.Set(namespaceObject.Labels)), nil
+}
+
+// isPoolReference returns true if the reference, from an object of the
+// namespace, points to the IPPool. IPPools are referenced by name and
+// namespace, the namespace of the object when unset, and GlobalIPPools by
+// name and kind.
+func (m *IPPoolManager) isPoolReference(ref corev1.ObjectReference, namespace string) bool {
+	if ref.Name == "" || ref.Name != m.IPPool.Name {
+		return false
+	}
+	if m.global {
+		return ref.Kind == ipamv1.GlobalIPPoolKind
+	}
+	if ref.Kind == ipamv1.GlobalIPPoolKind {
+		return false
+	}
+	if ref.Namespace != "" {
+		namespace = ref.Namespace
+	}
+	return namespace == m.IPPool.Namespace
+}
+
+// isCAPIPoolReference returns true if the reference of a CAPI object points to
+// the IPPool.
+func (m *IPPoolManager) isCAPIPoolReference(ref capipamv1.IPPoolReference) bool {
+	if ref.Name == "" || ref.Name != m.IPPool.Name {
+		return false
+	}
+	if m.global {
+		return ref.Kind == ipamv1.GlobalIPPoolKind && ref.APIGroup == APIGroup
+	}
+	return ref.Kind != ipamv1.GlobalIPPoolKind
+}
+
+// poolReference returns the reference to the IPPool set in the IPAddresses.
+func (m *IPPoolManager) poolReference() corev1.ObjectReference {
+	if m.global {
+		return corev1.ObjectReference{
+			APIVersion: ipamv1.GroupVersion.String(),
+			Kind:       ipamv1.GlobalIPPoolKind,
+			Name:       m.IPPool.Name,
+		}
+	}
+	return corev1.ObjectReference{
+		Name:      m.IPPool.Name,
+		Namespace: m.IPPool.Namespace,
+	}
+}
+
+// canOwnAddress returns true if the IPPool can be an owner of the address of
+// the claim. Owners must be cluster-scoped or in the namespace of the address.
+func (m *IPPoolManager) canOwnAddress(claim client.Object) bool {
+	return m.global || !m.isCrossNamespace(claim)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("GlobalIPPool manager", func() {
+	newGlobalIPPool := func(selector *metav1.LabelSelector) *ipamv1.GlobalIPPool {
+		return &ipamv1.GlobalIPPool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "abc",
+			},
+			Spec: ipamv1.GlobalIPPoolSpec{
+				IPPoolSpec: ipamv1.IPPoolSpec{
+					NamePrefix: "abcpref",
+					Pools: []ipamv1.Pool{
+						{
+							Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.1.10")),
+							End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.1.20")),
+						},
+					},
+					PreAllocations: map[string]ipamv1.IPAddressStr{
+						"tenant/prealloc": "192.168.1.20",
+					},
+				},
+				NamespaceSelector: selector,
+			},
+		}
+	}
+
+	newGlobalIPClaim := func(name, namespace string, pool corev1.ObjectReference) *ipamv1.IPClaim {
+		claim := newTestIPClaim(name)
+		claim.Namespace = namespace
+		claim.Spec.Pool = pool
+		return claim
+	}
+
+	globalRef := corev1.ObjectReference{Name: "abc", Kind: ipamv1.GlobalIPPoolKind}
+
+	It("Serves the IPClaims and IPAddressClaims of all namespaces", func() {
+		capiClaim := newTestIPAddressClaim("capi")
+		capiClaim.Namespace = "other"
+		capiClaim.Spec.PoolRef.Kind = ipamv1.GlobalIPPoolKind
+		objects := []client.Object{
+			newGlobalIPClaim("claim", "tenant", globalRef),
+			newGlobalIPClaim("claim", "other", globalRef),
+			newGlobalIPClaim("prealloc", "tenant", globalRef),
+			// An IPClaim of an IPPool with the same name.
+			newGlobalIPClaim("ippool", "tenant", corev1.ObjectReference{Name: "abc"}),
+			capiClaim,
+		}
+		c := newTestClient(objects...)
+		globalIPPool := newGlobalIPPool(nil)
+		globalIPPoolMgr, err := NewGlobalIPPoolManager(c, globalIPPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		globalIPPoolMgr.SetFinalizer()
+		Expect(globalIPPool.Finalizers).To(ConsistOf(ipamv1.IPPoolFinalizer))
+
+		nbAllocations, err := globalIPPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nbAllocations).To(Equal(4))
+		Expect(globalIPPool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{
+			"other/capi":      "192.168.1.10",
+			"other/claim":     "192.168.1.11",
+			"tenant/claim":    "192.168.1.12",
+			"tenant/prealloc": "192.168.1.20",
+		}))
+
+		// The IPAddress is created in the namespace of the IPClaim, owned by
+		// the IPClaim and the GlobalIPPool.
+		address := &ipamv1.IPAddress{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: "abcpref-192-168-1-12", Namespace: "tenant"}, address)).To(Succeed())
+		Expect(address.Spec.Pool).To(Equal(corev1.ObjectReference{
+			APIVersion: ipamv1.GroupVersion.String(),
+			Kind:       ipamv1.GlobalIPPoolKind,
+			Name:       "abc",
+		}))
+		Expect(address.OwnerReferences).To(HaveLen(2))
+		Expect(address.OwnerReferences[0].Kind).To(Equal(ipamv1.GlobalIPPoolKind))
+
+		capiAddress := &capipamv1.IPAddress{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: "abcpref-192-168-1-10", Namespace: "other"}, capiAddress)).To(Succeed())
+
+		claim := &ipamv1.IPClaim{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: "ippool", Namespace: "tenant"}, claim)).To(Succeed())
+		Expect(claim.Status.Address).To(BeNil())
+
+		// The allocations are found again on the next reconciliation.
+		globalIPPool.Status.Allocations = nil
+		globalIPPoolMgr, err = NewGlobalIPPoolManager(c, globalIPPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		nbAllocations, err = globalIPPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nbAllocations).To(Equal(4))
+		Expect(globalIPPool.Status.Allocations).To(HaveKeyWithValue("other/capi", ipamv1.IPAddressStr("192.168.1.10")))
+	})
+
+	It("Serves the IPClaims of the selected namespaces only", func() {
+		objects := []client.Object{
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:   "tenant",
+					Labels: map[string]string{"tenant": "true"},
+				},
+			},
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "other",
+				},
+			},
+			newGlobalIPClaim("claim", "tenant", globalRef),
+			newGlobalIPClaim("claim", "other", globalRef),
+		}
+		c := newTestClient(objects...)
+		globalIPPool := newGlobalIPPool(&metav1.LabelSelector{
+			MatchLabels: map[string]string{"tenant": "true"},
+		})
+		globalIPPoolMgr, err := NewGlobalIPPoolManager(c, globalIPPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		nbAllocations, err := globalIPPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nbAllocations).To(Equal(2))
+		Expect(globalIPPool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{
+			"tenant/claim": "192.168.1.10",
+		}))
+
+		claim := &ipamv1.IPClaim{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: "claim", Namespace: "other"}, claim)).To(Succeed())
+		Expect(claim.Status.Address).To(BeNil())
+	})
+
+	It("Fails with an invalid namespace selector", func() {
+		globalIPPool := newGlobalIPPool(&metav1.LabelSelector{
+			MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: "tenant", Operator: "Unknown"},
+			},
+		})
+		_, err := NewGlobalIPPoolManager(nil, globalIPPool, logr.Discard())
+		Expect(err).To(HaveOccurred())
+	})
+})
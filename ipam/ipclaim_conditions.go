@@ -0,0 +1,186 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"fmt"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The IPClaims and the CAPI IPAddressClaims report the result of their
+// allocation in a Ready condition, with the same reasons. An IPClaim also
+// keeps the message of a failure in its ErrorMessage. The failures are
+// reported through the AllocationError reasons, see allocation_error.go.
+
+// setClaimFailed records the failure of the allocation of an IPClaim.
+func setClaimFailed(claim *ipamv1.IPClaim, reason, message string) {
+	claim.Status.ErrorMessage = ptr.To(message)
+	conditions.Set(claim, metav1.Condition{
+		Type:    ipamv1.IPClaimReadyCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+}
+
+// setClaimReady marks the IPClaim ready once it holds all its addresses, the
+// addresses of both pools for a dual-stack IPClaim.
+func setClaimReady(claim *ipamv1.IPClaim) {
+	if claim.Status.Address == nil || (claim.Spec.SecondaryPool != nil && claim.Status.SecondaryAddress == nil) {
+		return
+	}
+	conditions.Set(claim, metav1.Condition{
+		Type:   ipamv1.IPClaimReadyCondition,
+		Status: metav1.ConditionTrue,
+		Reason: ipamv1.IPClaimAddressAllocatedReason,
+	})
+}
+
+// setCapiClaimCondition sets the Ready condition of the CAPI IPAddressClaim.
+// It is the only condition of the IPAddressClaim.
+func setCapiClaimCondition(claim *capipamv1.IPAddressClaim, status metav1.ConditionStatus, reason, message string) {
+	claim.SetConditions([]metav1.Condition{
+		{
+			Type:               capipamv1.IPAddressClaimReadyCondition,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		},
+	})
+}
+
+// anyErrorInExistingClaim returns true if the allocation of the CAPI
+// IPAddressClaim failed. Only Conditions[0] is checked because
+// setCapiClaimCondition always sets a single condition.
+func anyErrorInExistingClaim(addressClaim capipamv1.IPAddressClaim) bool {
+	if len(addressClaim.Status.Conditions) == 0 {
+		return false
+	}
+	switch addressClaim.Status.Conditions[0].Reason {
+	case ipamv1.IPClaimAllocationFailedReason, ipamv1.IPClaimPoolExhaustedReason,
+		ipamv1.IPClaimRequestedIPUnavailableReason, ipamv1.IPClaimPreAllocationOutOfBoundsReason,
+		ipamv1.IPClaimQuotaExceededReason:
+		return true
+	}
+	return false
+}
+
+// IPClaimPoolField is the field index of the IPClaims on the names of their
+// pool and secondary pool. It must be registered on the cache of the client
+// given to SetClaimsPoolNotFound.
+const IPClaimPoolField = "spec.pool.name"
+
+// IPClaimPools returns the names of the pool and the secondary pool of an
+// IPClaim, indexed as IPClaimPoolField.
+func IPClaimPools(obj client.Object) []string {
+	claim, ok := obj.(*ipamv1.IPClaim)
+	if !ok {
+		return nil
+	}
+	pools := []string{claim.Spec.Pool.Name}
+	if claim.Spec.SecondaryPool != nil && claim.Spec.SecondaryPool.Name != claim.Spec.Pool.Name {
+		pools = append(pools, claim.Spec.SecondaryPool.Name)
+	}
+	return pools
+}
+
+// SetClaimsPoolNotFound marks the claims waiting for an address from a missing
+// IPPool, or GlobalIPPool when global is true, as not ready with the
+// PoolNotFound reason. The claims are served once the pool is created. Only
+// the claims of the namespace of a missing IPPool are marked, the claims of
+// other namespaces can only claim from it once it grants them.
+func SetClaimsPoolNotFound(ctx context.Context, c client.Client, pool types.NamespacedName, global bool) error {
+	m := &IPPoolManager{
+		IPPool: &ipamv1.IPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: pool.Name, Namespace: pool.Namespace},
+		},
+		global: global,
+	}
+	// The failures are only reported on the claims. Events and metrics would
+	// be about the missing pool, and its metrics would never be deleted.
+	kind := ipamv1.IPPoolKind
+	if global {
+		kind = ipamv1.GlobalIPPoolKind
+	}
+	poolNotFound := newAllocationError(ErrorReasonPoolNotFound, "%s %s not found", kind, pool.Name)
+
+	opts := []client.ListOption{}
+	if !global {
+		opts = append(opts, client.InNamespace(pool.Namespace))
+	}
+
+	ipClaims := ipamv1.IPClaimList{}
+	if err := c.List(ctx, &ipClaims, append(opts, client.MatchingFields{IPClaimPoolField: pool.Name})...); err != nil {
+		return err
+	}
+	for i := range ipClaims.Items {
+		claim := &ipClaims.Items[i]
+		if !claim.DeletionTimestamp.IsZero() || claim.Status.ErrorMessage != nil {
+			continue
+		}
+		waiting := m.isPoolReference(claim.Spec.Pool, claim.Namespace) && claim.Status.Address == nil
+		if claim.Spec.SecondaryPool != nil && m.isPoolReference(*claim.Spec.SecondaryPool, claim.Namespace) &&
+			claim.Status.SecondaryAddress == nil {
+			waiting = true
+		}
+		if !waiting || conditions.GetReason(claim, ipamv1.IPClaimReadyCondition) == ipamv1.IPClaimPoolNotFoundReason {
+			continue
+		}
+		helper, err := patch.NewHelper(claim, c)
+		if err != nil {
+			return fmt.Errorf("failed to init patch helper: %w", err)
+		}
+		setClaimError(claim, poolNotFound)
+		if err := helper.Patch(ctx, claim); err != nil {
+			return err
+		}
+	}
+
+	capiClaims := capipamv1.IPAddressClaimList{}
+	if err := c.List(ctx, &capiClaims, opts...); err != nil {
+		return err
+	}
+	for i := range capiClaims.Items {
+		claim := &capiClaims.Items[i]
+		if !claim.DeletionTimestamp.IsZero() || claim.Status.AddressRef.Name != "" ||
+			anyErrorInExistingClaim(*claim) || !m.isCAPIPoolReference(claim.Spec.PoolRef) {
+			continue
+		}
+		if len(claim.Status.Conditions) > 0 && claim.Status.Conditions[0].Reason == ipamv1.IPClaimPoolNotFoundReason {
+			continue
+		}
+		helper, err := patch.NewHelper(claim, c)
+		if err != nil {
+			return fmt.Errorf("failed to init patch helper: %w", err)
+		}
+		setClaimError(claim, poolNotFound)
+		if err := helper.Patch(ctx, claim); err != nil {
+			return err
+		}
+	}
+	return nil
+}
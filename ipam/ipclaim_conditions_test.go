@@ -0,0 +1,157 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("IPClaim conditions", func() {
+	newPreAllocatingIPPool := func() *ipamv1.IPPool {
+		ipPool := newTestIPPool(newTestPool("192.168.1.10", "192.168.1.11"))
+		ipPool.Spec.PreAllocations = map[string]ipamv1.IPAddressStr{
+			"outside": "192.168.2.10",
+		}
+		return ipPool
+	}
+
+	type testCaseClaimCondition struct {
+		// claims are served after the IPClaim first, the condition of the
+		// last one is checked.
+		claims          []string
+		requested       string
+		expectedStatus  metav1.ConditionStatus
+		expectedReason  string
+		expectedMessage string
+	}
+
+	DescribeTable("Reports the result of the allocation in the Ready condition",
+		func(tc testCaseClaimCondition) {
+			objects := []client.Object{newTestIPClaim("first")}
+			for _, name := range tc.claims {
+				objects = append(objects, newTestIPClaim(name))
+			}
+			name := tc.claims[len(tc.claims)-1]
+			if tc.requested != "" {
+				objects[len(objects)-1].SetAnnotations(map[string]string{IPAddressAnnotation: tc.requested})
+			}
+			c := newTestClient(objects...)
+			_, _ = updateTestAddresses(c, newPreAllocatingIPPool())
+
+			claim := getTestIPClaim(c, name)
+			condition := conditions.Get(claim, ipamv1.IPClaimReadyCondition)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(tc.expectedStatus))
+			Expect(condition.Reason).To(Equal(tc.expectedReason))
+			if tc.expectedStatus == metav1.ConditionFalse {
+				Expect(claim.Status.ErrorMessage).To(Equal(ptr.To(condition.Message)))
+			}
+			if tc.expectedMessage != "" {
+				Expect(condition.Message).To(Equal(tc.expectedMessage))
+			}
+		},
+		Entry("Allocated", testCaseClaimCondition{
+			claims:         []string{"second"},
+			expectedStatus: metav1.ConditionTrue,
+			expectedReason: ipamv1.IPClaimAddressAllocatedReason,
+		}),
+		Entry("Requested IP not available", testCaseClaimCondition{
+			claims:         []string{"second"},
+			requested:      "192.168.1.10",
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ipamv1.IPClaimRequestedIPUnavailableReason,
+		}),
+		Entry("Pre-allocated IP out of bounds", testCaseClaimCondition{
+			claims:         []string{"outside"},
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ipamv1.IPClaimPreAllocationOutOfBoundsReason,
+		}),
+		Entry("Invalid requested address", testCaseClaimCondition{
+			claims:         []string{"second"},
+			requested:      "abc",
+			expectedStatus: metav1.ConditionFalse,
+			expectedReason: ipamv1.IPClaimAllocationFailedReason,
+		}),
+		Entry("Pool exhausted", testCaseClaimCondition{
+			claims:          []string{"second", "third"},
+			expectedStatus:  metav1.ConditionFalse,
+			expectedReason:  ipamv1.IPClaimPoolExhaustedReason,
+			expectedMessage: "Exhausted IP Pools",
+		}),
+	)
+
+	It("Marks a dual-stack IPClaim ready once it holds both addresses", func() {
+		claim := newTestIPClaim("first")
+		claim.Spec.SecondaryPool = &corev1.ObjectReference{Name: "def", Namespace: "myns"}
+		claim.Status.Address = &corev1.ObjectReference{Name: "abcpref-192-168-1-10"}
+		setClaimReady(claim)
+		Expect(conditions.Has(claim, ipamv1.IPClaimReadyCondition)).To(BeFalse())
+
+		claim.Status.SecondaryAddress = &corev1.ObjectReference{Name: "defpref-fd00--10"}
+		setClaimReady(claim)
+		Expect(conditions.IsTrue(claim, ipamv1.IPClaimReadyCondition)).To(BeTrue())
+	})
+
+	It("Marks the claims of a missing pool until the pool is created", func() {
+		allocated := newTestIPClaim("allocated")
+		allocated.Status.Address = &corev1.ObjectReference{Name: "abcpref-192-168-1-10"}
+		otherPool := newTestIPClaim("other-pool")
+		otherPool.Spec.Pool.Name = "def"
+		secondary := newTestIPClaim("secondary")
+		secondary.Spec.Pool.Name = "def"
+		secondary.Spec.SecondaryPool = &corev1.ObjectReference{Name: "abc", Namespace: "myns"}
+		otherNamespace := newTestIPClaim("other-namespace")
+		otherNamespace.Namespace = "otherns"
+		capiClaim := newTestIPAddressClaim("capi-claim")
+		c := newTestClient(newTestIPClaim("first"), allocated, otherPool, secondary, otherNamespace, capiClaim)
+
+		pool := types.NamespacedName{Name: "abc", Namespace: "myns"}
+		Expect(SetClaimsPoolNotFound(context.TODO(), c, pool, false)).To(Succeed())
+		condition := conditions.Get(getTestIPClaim(c, "first"), ipamv1.IPClaimReadyCondition)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Reason).To(Equal(ipamv1.IPClaimPoolNotFoundReason))
+		Expect(condition.Message).To(Equal("IPPool abc not found"))
+		Expect(getTestIPClaim(c, "first").Status.ErrorMessage).To(BeNil())
+		Expect(conditions.Has(getTestIPClaim(c, "allocated"), ipamv1.IPClaimReadyCondition)).To(BeFalse())
+		Expect(conditions.Has(getTestIPClaim(c, "other-pool"), ipamv1.IPClaimReadyCondition)).To(BeFalse())
+		Expect(conditions.GetReason(getTestIPClaim(c, "secondary"), ipamv1.IPClaimReadyCondition)).To(Equal(ipamv1.IPClaimPoolNotFoundReason))
+		Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(otherNamespace), otherNamespace)).To(Succeed())
+		Expect(conditions.Has(otherNamespace, ipamv1.IPClaimReadyCondition)).To(BeFalse())
+		Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(capiClaim), capiClaim)).To(Succeed())
+		Expect(capiClaim.Status.Conditions).To(HaveLen(1))
+		Expect(capiClaim.Status.Conditions[0].Reason).To(Equal(ipamv1.IPClaimPoolNotFoundReason))
+
+		// The claims are served once the pool exists.
+		Expect(c.Delete(context.TODO(), allocated)).To(Succeed())
+		Expect(c.Delete(context.TODO(), secondary)).To(Succeed())
+		_, _ = updateTestAddresses(c, newPreAllocatingIPPool())
+		Expect(conditions.IsTrue(getTestIPClaim(c, "first"), ipamv1.IPClaimReadyCondition)).To(BeTrue())
+		Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(capiClaim), capiClaim)).To(Succeed())
+		Expect(capiClaim.Status.AddressRef.Name).NotTo(BeEmpty())
+		Expect(capiClaim.Status.Conditions[0].Reason).To(Equal(ipamv1.IPClaimAddressAllocatedReason))
+	})
+})
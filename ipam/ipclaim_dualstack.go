@@ -0,0 +1,177 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"net"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// A dual-stack IPClaim references a pool of each IP family. Each IPPool
+// manages its side of the IPClaim independently: the primary pool sets the
+// address and the IPClaimFinalizer, the secondary pool sets the secondary
+// address and the IPClaimSecondaryFinalizer. When one side fails, it sets the
+// error message of the IPClaim and the other side releases its address, so
+// that an address is never kept allocated for half of the IPClaim. Only the
+// failing side clears the error, once it can serve the IPClaim, so that the
+// IPClaim waits for the retries of the failing side meanwhile.
+
+// isSecondaryPool returns true if the IPPool is the secondary pool of the
+// IPClaim.
+func (m *IPPoolManager) isSecondaryPool(claim *ipamv1.IPClaim) bool {
+	return claim.Spec.SecondaryPool != nil &&
+		!m.isPoolReference(claim.Spec.Pool, claim.Namespace) &&
+		m.isPoolReference(*claim.Spec.SecondaryPool, claim.Namespace)
+}
+
+// isClaimPool returns true if the IPPool is the pool or the secondary pool of
+// the IPClaim.
+func (m *IPPoolManager) isClaimPool(claim *ipamv1.IPClaim) bool {
+	return m.isPoolReference(claim.Spec.Pool, claim.Namespace) || m.isSecondaryPool(claim)
+}
+
+// claimFinalizer returns the finalizer set by the IPPool on the IPClaim.
+func (m *IPPoolManager) claimFinalizer(claim *ipamv1.IPClaim) string {
+	if m.isSecondaryPool(claim) {
+		return ipamv1.IPClaimSecondaryFinalizer
+	}
+	return ipamv1.IPClaimFinalizer
+}
+
+// claimAddress returns the reference to the IPAddress allocated by the IPPool
+// to the IPClaim.
+func (m *IPPoolManager) claimAddress(claim *ipamv1.IPClaim) *corev1.ObjectReference {
+	if m.isSecondaryPool(claim) {
+		return claim.Status.SecondaryAddress
+	}
+	return claim.Status.Address
+}
+
+// setClaimAddress sets the reference to the IPAddress allocated by the IPPool
+// to the IPClaim.
+func (m *IPPoolManager) setClaimAddress(claim *ipamv1.IPClaim, address *corev1.ObjectReference) {
+	if m.isSecondaryPool(claim) {
+		claim.Status.SecondaryAddress = address
+		return
+	}
+	claim.Status.Address = address
+}
+
+// otherClaimAddress returns the reference to the IPAddress allocated by the
+// other pool of a dual-stack IPClaim.
+func (m *IPPoolManager) otherClaimAddress(claim *ipamv1.IPClaim) *corev1.ObjectReference {
+	if m.isSecondaryPool(claim) {
+		return claim.Status.Address
+	}
+	return claim.Status.SecondaryAddress
+}
+
+// hasOtherFinalizers returns true if the IPClaim has finalizers that are not
+// set by the IPPools, meaning that it is still in use.
+func hasOtherFinalizers(claim *ipamv1.IPClaim) bool {
+	for _, finalizer := range claim.Finalizers {
+		if finalizer != ipamv1.IPClaimFinalizer && finalizer != ipamv1.IPClaimSecondaryFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// mustRollback returns true if the other side of a dual-stack IPClaim failed
+// while the IPPool holds an address for it, that must then be released.
+func (m *IPPoolManager) mustRollback(claim *ipamv1.IPClaim) bool {
+	if claim.Spec.SecondaryPool == nil || claim.Status.ErrorMessage == nil || !claim.DeletionTimestamp.IsZero() {
+		return false
+	}
+	_, ok := m.allocations[m.allocationKey(claim)]
+	return ok
+}
+
+// otherPoolFailed returns true if the error of a dual-stack IPClaim was set by
+// its other pool. The failing pool holds its finalizer but no address, unless
+// it is missing. The error is the one of the IPPool when both failed.
+func (m *IPPoolManager) otherPoolFailed(ctx context.Context, claim *ipamv1.IPClaim) (bool, error) {
+	if claim.Spec.SecondaryPool == nil || claim.Status.ErrorMessage == nil {
+		return false, nil
+	}
+	finalizer, otherFinalizer := ipamv1.IPClaimFinalizer, ipamv1.IPClaimSecondaryFinalizer
+	otherRef := *claim.Spec.SecondaryPool
+	if m.isSecondaryPool(claim) {
+		finalizer, otherFinalizer = otherFinalizer, finalizer
+		otherRef = claim.Spec.Pool
+	}
+	if Contains(claim.Finalizers, finalizer) && m.claimAddress(claim) == nil {
+		return false, nil
+	}
+	if conditions.GetReason(claim, ipamv1.IPClaimReadyCondition) != ipamv1.IPClaimPoolNotFoundReason {
+		return Contains(claim.Finalizers, otherFinalizer) && m.otherClaimAddress(claim) == nil, nil
+	}
+
+	// The error reports a missing pool, that is the other pool if it is
+	// still missing.
+	var otherPool client.Object = &ipamv1.IPPool{}
+	key := client.ObjectKey{Name: otherRef.Name, Namespace: otherRef.Namespace}
+	if otherRef.Kind == ipamv1.GlobalIPPoolKind {
+		otherPool = &ipamv1.GlobalIPPool{}
+		key.Namespace = ""
+	} else if key.Namespace == "" {
+		key.Namespace = claim.Namespace
+	}
+	err := m.client.Get(ctx, key, otherPool)
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	return false, err
+}
+
+// checkDualStackFamily verifies that the address allocated to a dual-stack
+// IPClaim is not of the same IP family as the address allocated by its other
+// pool.
+func (m *IPPoolManager) checkDualStackFamily(ctx context.Context, claim *ipamv1.IPClaim, address ipamv1.IPAddressStr) error {
+	if claim.Spec.SecondaryPool == nil {
+		return nil
+	}
+	otherRef := m.otherClaimAddress(claim)
+	if otherRef == nil {
+		return nil
+	}
+	otherAddress := &ipamv1.IPAddress{}
+	err := m.client.Get(ctx, client.ObjectKey{Name: otherRef.Name, Namespace: otherRef.Namespace}, otherAddress)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if isIPv4(address) == isIPv4(otherAddress.Spec.Address) {
+		return m.failAllocation(claim, claimKindIPClaim, errSameIPFamily)
+	}
+	return nil
+}
+
+// isIPv4 returns true if the address is an IPv4 address.
+func isIPv4(address ipamv1.IPAddressStr) bool {
+	ip := net.ParseIP(string(address))
+	return ip != nil && ip.To4() != nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Dual-stack IPClaim", func() {
+	newFamilyPool := func(name, start, end string) *ipamv1.IPPool {
+		ipPool := newTestIPPool(newTestPool(start, end))
+		ipPool.Name = name
+		ipPool.Spec.NamePrefix = name
+		return ipPool
+	}
+
+	newDualStackIPClaim := func() *ipamv1.IPClaim {
+		claim := newTestIPClaim("claim")
+		claim.Spec.Pool = corev1.ObjectReference{Name: "v4"}
+		claim.Spec.SecondaryPool = &corev1.ObjectReference{Name: "v6"}
+		return claim
+	}
+
+	It("Gets an address from both pools", func() {
+		claim := newDualStackIPClaim()
+		c := newTestClient(claim)
+		v4Pool := newFamilyPool("v4", "192.168.1.10", "192.168.1.20")
+		v4PoolMgr, err := NewIPPoolManager(c, v4Pool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		v6Pool := newFamilyPool("v6", "2001:db8::10", "2001:db8::20")
+		v6PoolMgr, err := NewIPPoolManager(c, v6Pool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = v4PoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = v6PoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v4Pool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{"claim": "192.168.1.10"}))
+		Expect(v6Pool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{"claim": "2001:db8::10"}))
+
+		claim = getTestIPClaim(c, "claim")
+		Expect(claim.Status.Address).To(Equal(&corev1.ObjectReference{Name: "v4-192-168-1-10", Namespace: "myns"}))
+		Expect(claim.Status.SecondaryAddress).To(Equal(&corev1.ObjectReference{Name: "v6-2001-db8--10", Namespace: "myns"}))
+		Expect(claim.Status.ErrorMessage).To(BeNil())
+		Expect(claim.Finalizers).To(ConsistOf(ipamv1.IPClaimFinalizer, ipamv1.IPClaimSecondaryFinalizer))
+
+		// Both pools release their address when the IPClaim is deleted.
+		Expect(c.Delete(context.TODO(), claim)).To(Succeed())
+		_, err = v4PoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		claim = getTestIPClaim(c, "claim")
+		Expect(claim.Finalizers).To(Equal([]string{ipamv1.IPClaimSecondaryFinalizer}))
+		_, err = v6PoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(v4Pool.Status.Allocations).To(BeEmpty())
+		Expect(v6Pool.Status.Allocations).To(BeEmpty())
+		err = c.Get(context.TODO(), client.ObjectKey{Name: "claim", Namespace: "myns"}, &ipamv1.IPClaim{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	type testCaseDualStackFailure struct {
+		v6Pool          *ipamv1.IPPool
+		fixV6Pool       func(*ipamv1.IPPool)
+		expectedMessage string
+	}
+
+	DescribeTable("Releases the address of the other pool when a pool fails",
+		func(tc testCaseDualStackFailure) {
+			claim := newDualStackIPClaim()
+			c := newTestClient(claim)
+			v4Pool := newFamilyPool("v4", "192.168.1.10", "192.168.1.20")
+			v4Pool.Spec.AllocationStrategy = ipamv1.AllocationStrategyLeastRecentlyReleased
+			v4Pool.Spec.QuarantineDuration = &metav1.Duration{Duration: time.Hour}
+			v4PoolMgr, err := NewIPPoolManager(c, v4Pool, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			v6PoolMgr, err := NewIPPoolManager(c, tc.v6Pool, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = v4PoolMgr.UpdateAddresses(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4Pool.Status.Allocations).To(HaveKey("claim"))
+
+			_, err = v6PoolMgr.UpdateAddresses(context.TODO())
+			Expect(err).To(MatchError(tc.expectedMessage))
+			Expect(tc.v6Pool.Status.Allocations).To(BeEmpty())
+			claim = getTestIPClaim(c, "claim")
+			Expect(claim.Status.SecondaryAddress).To(BeNil())
+			Expect(claim.Status.ErrorMessage).To(Equal(ptr.To(tc.expectedMessage)))
+
+			_, err = v4PoolMgr.UpdateAddresses(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4Pool.Status.Allocations).To(BeEmpty())
+			// The address was not used, it is freed without being released.
+			Expect(v4Pool.Status.QuarantinedAddresses).To(BeEmpty())
+			Expect(v4Pool.Status.ReleasedAddresses).To(BeEmpty())
+			claim = getTestIPClaim(c, "claim")
+			Expect(claim.Status.Address).To(BeNil())
+			Expect(claim.Status.SecondaryAddress).To(BeNil())
+			Expect(claim.Status.ErrorMessage).To(Equal(ptr.To(tc.expectedMessage)))
+			Expect(claim.Finalizers).To(Equal([]string{ipamv1.IPClaimSecondaryFinalizer}))
+			err = c.Get(context.TODO(), client.ObjectKey{Name: "v4-192-168-1-10", Namespace: "myns"}, &ipamv1.IPAddress{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+			// The IPClaim waits for the failing pool, without being a failure
+			// of the other pool.
+			_, err = v4PoolMgr.UpdateAddresses(context.TODO())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(v4Pool.Status.Allocations).To(BeEmpty())
+			Expect(v4Pool.Status.Retries).To(BeEmpty())
+			claim = getTestIPClaim(c, "claim")
+			Expect(claim.Status.Address).To(BeNil())
+			Expect(claim.Status.ErrorMessage).To(Equal(ptr.To(tc.expectedMessage)))
+
+			// Once the failing pool serves the IPClaim, the other pool serves
+			// it again.
+			tc.v6Pool.Generation++
+			tc.fixV6Pool(tc.v6Pool)
+			Expect(updateTestAddresses(c, tc.v6Pool)).To(Equal(1))
+			claim = getTestIPClaim(c, "claim")
+			Expect(claim.Status.SecondaryAddress).NotTo(BeNil())
+			Expect(claim.Status.ErrorMessage).To(BeNil())
+			Expect(updateTestAddresses(c, v4Pool)).To(Equal(1))
+			claim = getTestIPClaim(c, "claim")
+			Expect(claim.Status.Address).NotTo(BeNil())
+			Expect(conditions.IsTrue(claim, ipamv1.IPClaimReadyCondition)).To(BeTrue())
+		},
+		Entry("Secondary pool exhausted", testCaseDualStackFailure{
+			v6Pool: func() *ipamv1.IPPool {
+				ipPool := newFamilyPool("v6", "2001:db8::10", "2001:db8::10")
+				ipPool.Spec.PreAllocations = map[string]ipamv1.IPAddressStr{"other": "2001:db8::10"}
+				return ipPool
+			}(),
+			fixV6Pool: func(ipPool *ipamv1.IPPool) {
+				ipPool.Spec.PreAllocations = nil
+			},
+			expectedMessage: "Exhausted IP Pools",
+		}),
+		Entry("Pools of the same IP family", testCaseDualStackFailure{
+			v6Pool: newFamilyPool("v6", "192.168.2.10", "192.168.2.20"),
+			fixV6Pool: func(ipPool *ipamv1.IPPool) {
+				ipPool.Spec.Pools = []ipamv1.Pool{newTestPool("2001:db8::10", "2001:db8::20")}
+			},
+			expectedMessage: "Pools of a dual-stack IPClaim are of the same IP family",
+		}),
+	)
+})
@@ -0,0 +1,277 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"math"
+	"math/big"
+	"net"
+	"slices"
+	"strconv"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+)
+
+// maxFreeRanges is the number of free ranges listed in the capacity status.
+const maxFreeRanges = 5
+
+// poolEntryUsage holds the bookkeeping of a single pool entry used to render
+// its capacity status.
+type poolEntryUsage struct {
+	first        *big.Int
+	last         *big.Int
+	bound        *big.Int
+	size         int
+	sizeKnown    bool
+	allocated    int
+	preAllocated int
+	quarantined  int
+	used         [][2]*big.Int
+	excluded     [][2]*big.Int
+}
+
+// updateCapacity recomputes the capacity and utilization fields of the IPPool
+// status from the addresses map built by getIndexes, where an empty claim name
+// marks a pre-allocation that is not bound to a claim yet. All the addresses
+// of an allocated subnet are counted as allocated.
+func (m *IPPoolManager) updateCapacity(addresses map[ipamv1.IPAddressStr]string) {
+	pools := ipamv1.GetPools(m.poolSpec())
+	entries := make([]*poolEntryUsage, len(pools))
+	for i, pool := range pools {
+		entry := &poolEntryUsage{}
+		if first, last, err := ipamv1.GetPoolRange(pool); err == nil {
+			entry.first = ipToInt(first)
+			entry.bound = lastIPOfFamily(first)
+			if last != nil {
+				entry.last = ipToInt(last)
+				entry.bound = entry.last
+			}
+		}
+		for _, exclusion := range pool.Exclusions {
+			if first, last, err := ipamv1.ParseExclusion(exclusion); err == nil {
+				entry.excluded = append(entry.excluded, [2]*big.Int{ipToInt(first), ipToInt(last)})
+			}
+		}
+		if size, err := ipamv1.GetPoolSize(pool); err == nil {
+			entry.size = size
+			entry.sizeKnown = true
+		}
+		entries[i] = entry
+	}
+
+	total := ipamv1.IPPoolCapacity{}
+	// The same address can appear several times with different textual
+	// representations (e.g. non-canonical IPv6), count it only once.
+	seen := make(map[string]bool, len(addresses))
+	for address, claimName := range addresses {
+		ip := net.ParseIP(string(address))
+		if ip == nil || seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+		used := [2]*big.Int{ipToInt(ip), ipToInt(ip)}
+		count := 1
+		if ipNet, ok := m.subnets[address]; ok && claimName != "" {
+			used, count = subnetUsage(ipNet)
+		}
+		if claimName == "" {
+			total.PreAllocated++
+		} else {
+			total.Allocated = saturatingAdd(total.Allocated, count)
+		}
+
+		for _, entry := range entries {
+			if !entry.contains(used[0]) {
+				continue
+			}
+			if claimName == "" {
+				entry.preAllocated++
+			} else {
+				entry.allocated = saturatingAdd(entry.allocated, count)
+			}
+			entry.used = append(entry.used, used)
+			break
+		}
+	}
+
+	for address := range m.IPPool.Status.QuarantinedAddresses {
+		ip := net.ParseIP(string(address))
+		if ip == nil || seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+		total.Quarantined++
+		for _, entry := range entries {
+			if entry.contains(ipToInt(ip)) {
+				entry.quarantined++
+				entry.used = append(entry.used, [2]*big.Int{ipToInt(ip), ipToInt(ip)})
+				break
+			}
+		}
+	}
+
+	poolStatuses := make([]ipamv1.PoolStatus, 0, len(entries))
+	totalSize, totalFree := 0, 0
+	sizeKnown := true
+	for i, entry := range entries {
+		capacity := entry.capacity()
+		poolStatuses = append(poolStatuses, ipamv1.PoolStatus{
+			Index:          i,
+			Name:           pools[i].Name,
+			IPPoolCapacity: capacity,
+		})
+		if !entry.sizeKnown {
+			sizeKnown = false
+		} else {
+			totalSize += entry.size
+			totalFree += entry.free()
+		}
+		for _, freeRange := range capacity.FreeRanges {
+			if len(total.FreeRanges) == maxFreeRanges {
+				break
+			}
+			total.FreeRanges = append(total.FreeRanges, freeRange)
+		}
+	}
+	total.Total = formatCount(totalSize, sizeKnown)
+	total.Free = formatCount(totalFree, sizeKnown)
+
+	m.IPPool.Status.Capacity = &total
+	m.IPPool.Status.Pools = poolStatuses
+}
+
+// contains returns true if the address is in the range of the pool entry.
+// Unbounded entries contain all the following addresses of the same family.
+func (e *poolEntryUsage) contains(value *big.Int) bool {
+	if e.first == nil {
+		return false
+	}
+	return value.Cmp(e.first) >= 0 && value.Cmp(e.bound) <= 0
+}
+
+// free returns the number of addresses of the entry that are neither
+// allocated, pre-allocated nor quarantined.
+func (e *poolEntryUsage) free() int {
+	return max(e.size-e.allocated-e.preAllocated-e.quarantined, 0)
+}
+
+// capacity renders the capacity status of the pool entry.
+func (e *poolEntryUsage) capacity() ipamv1.IPPoolCapacity {
+	return ipamv1.IPPoolCapacity{
+		Total:        formatCount(e.size, e.sizeKnown),
+		Allocated:    e.allocated,
+		PreAllocated: e.preAllocated,
+		Quarantined:  e.quarantined,
+		Free:         formatCount(e.free(), e.sizeKnown),
+		FreeRanges:   e.freeRanges(),
+	}
+}
+
+// freeRanges lists the first free ranges of the pool entry, skipping the used
+// and the excluded addresses. Unbounded entries have no free ranges listed.
+func (e *poolEntryUsage) freeRanges() []string {
+	if e.first == nil || e.last == nil {
+		return nil
+	}
+	one := big.NewInt(1)
+	blocked := slices.Clone(e.excluded)
+	blocked = append(blocked, e.used...)
+	end := new(big.Int).Add(e.last, one)
+	blocked = append(blocked, [2]*big.Int{end, end})
+	slices.SortFunc(blocked, func(a, b [2]*big.Int) int { return a[0].Cmp(b[0]) })
+
+	var ranges []string
+	next := new(big.Int).Set(e.first)
+	for _, block := range blocked {
+		if len(ranges) == maxFreeRanges || next.Cmp(end) > 0 {
+			break
+		}
+		if block[0].Cmp(next) > 0 {
+			last := new(big.Int).Sub(block[0], one)
+			if last.Cmp(e.last) > 0 {
+				last = e.last
+			}
+			ranges = append(ranges, formatRange(next, last))
+		}
+		if after := new(big.Int).Add(block[1], one); after.Cmp(next) > 0 {
+			next = after
+		}
+	}
+	return ranges
+}
+
+// subnetUsage returns the range of addresses of an allocated subnet and their
+// number, capped to the largest int.
+func subnetUsage(ipNet *net.IPNet) ([2]*big.Int, int) {
+	ones, bits := ipNet.Mask.Size()
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	first := ipToInt(ipNet.IP)
+	last := new(big.Int).Add(first, size)
+	last.Sub(last, big.NewInt(1))
+	count := math.MaxInt
+	if size.IsInt64() && size.Int64() <= math.MaxInt {
+		count = int(size.Int64())
+	}
+	return [2]*big.Int{first, last}, count
+}
+
+// saturatingAdd adds two non-negative counts, capped to the largest int.
+func saturatingAdd(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+// formatCount renders a count of the capacity status.
+func formatCount(count int, known bool) string {
+	if !known {
+		return ipamv1.PoolSizeUnknown
+	}
+	return strconv.Itoa(count)
+}
+
+// formatRange renders an address range as "first-last", or as a single
+// address if the range contains only one.
+func formatRange(first, last *big.Int) string {
+	if first.Cmp(last) == 0 {
+		return intToIP(first).String()
+	}
+	return intToIP(first).String() + "-" + intToIP(last).String()
+}
+
+// ipToInt converts an IP address to an integer, using its 16-byte form so
+// that IPv4 and IPv6 addresses share the same space.
+func ipToInt(ip net.IP) *big.Int {
+	return new(big.Int).SetBytes(ip.To16())
+}
+
+// lastIPOfFamily returns the highest address of the family of the given
+// address, as computed by ipToInt.
+func lastIPOfFamily(ip net.IP) *big.Int {
+	if ip.To4() != nil {
+		return ipToInt(net.IPv4bcast)
+	}
+	return new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+}
+
+// intToIP converts an integer computed by ipToInt back to an IP address.
+func intToIP(value *big.Int) net.IP {
+	ip := make(net.IP, net.IPv6len)
+	value.FillBytes(ip)
+	return ip
+}
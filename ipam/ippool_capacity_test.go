@@ -0,0 +1,319 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("IPPool capacity", func() {
+	type testCaseUpdateCapacity struct {
+		pools            []ipamv1.Pool
+		addresses        map[ipamv1.IPAddressStr]string
+		expectedCapacity ipamv1.IPPoolCapacity
+		expectedPools    []ipamv1.PoolStatus
+	}
+
+	DescribeTable("Test updateCapacity",
+		func(tc testCaseUpdateCapacity) {
+			ipPool := &ipamv1.IPPool{
+				Spec: ipamv1.IPPoolSpec{
+					Pools: tc.pools,
+				},
+			}
+			ipPoolMgr, err := NewIPPoolManager(nil, ipPool, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			ipPoolMgr.updateCapacity(tc.addresses)
+			Expect(ipPool.Status.Capacity).NotTo(BeNil())
+			Expect(*ipPool.Status.Capacity).To(Equal(tc.expectedCapacity))
+			Expect(ipPool.Status.Pools).To(Equal(tc.expectedPools))
+		},
+		Entry("No pools", testCaseUpdateCapacity{
+			addresses: map[ipamv1.IPAddressStr]string{},
+			expectedCapacity: ipamv1.IPPoolCapacity{
+				Total: "0",
+				Free:  "0",
+			},
+			expectedPools: []ipamv1.PoolStatus{},
+		}),
+		Entry("Empty pool", testCaseUpdateCapacity{
+			pools: []ipamv1.Pool{
+				{
+					Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+					End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.20")),
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{},
+			expectedCapacity: ipamv1.IPPoolCapacity{
+				Total:      "11",
+				Free:       "11",
+				FreeRanges: []string{"192.168.0.10-192.168.0.20"},
+			},
+			expectedPools: []ipamv1.PoolStatus{
+				{
+					Index: 0,
+					IPPoolCapacity: ipamv1.IPPoolCapacity{
+						Total:      "11",
+						Free:       "11",
+						FreeRanges: []string{"192.168.0.10-192.168.0.20"},
+					},
+				},
+			},
+		}),
+		Entry("Allocated and pre-allocated addresses in several pools", testCaseUpdateCapacity{
+			pools: []ipamv1.Pool{
+				{
+					Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+					End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.20")),
+				},
+				{
+					Subnet: (*ipamv1.IPSubnetStr)(ptr.To("192.168.1.0/29")),
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{
+				"192.168.0.10": "abc",
+				"192.168.0.12": "",
+				"192.168.0.20": "bcd",
+				"192.168.1.1":  "cde",
+				"10.0.0.1":     "def",
+			},
+			expectedCapacity: ipamv1.IPPoolCapacity{
+				Total:        "18",
+				Allocated:    4,
+				PreAllocated: 1,
+				Free:         "14",
+				FreeRanges: []string{
+					"192.168.0.11",
+					"192.168.0.13-192.168.0.19",
+					"192.168.1.2-192.168.1.7",
+				},
+			},
+			expectedPools: []ipamv1.PoolStatus{
+				{
+					Index: 0,
+					IPPoolCapacity: ipamv1.IPPoolCapacity{
+						Total:        "11",
+						Allocated:    2,
+						PreAllocated: 1,
+						Free:         "8",
+						FreeRanges: []string{
+							"192.168.0.11",
+							"192.168.0.13-192.168.0.19",
+						},
+					},
+				},
+				{
+					Index: 1,
+					IPPoolCapacity: ipamv1.IPPoolCapacity{
+						Total:      "7",
+						Allocated:  1,
+						Free:       "6",
+						FreeRanges: []string{"192.168.1.2-192.168.1.7"},
+					},
+				},
+			},
+		}),
+		Entry("Reserved addresses are neither counted nor free", testCaseUpdateCapacity{
+			pools: []ipamv1.Pool{
+				{
+					Subnet:                   (*ipamv1.IPSubnetStr)(ptr.To("192.168.0.0/29")),
+					Gateway:                  (*ipamv1.IPAddressStr)(ptr.To("192.168.0.1")),
+					ExcludeReservedAddresses: ptr.To(true),
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{},
+			expectedCapacity: ipamv1.IPPoolCapacity{
+				Total:      "5",
+				Free:       "5",
+				FreeRanges: []string{"192.168.0.2-192.168.0.6"},
+			},
+			expectedPools: []ipamv1.PoolStatus{
+				{
+					Index: 0,
+					IPPoolCapacity: ipamv1.IPPoolCapacity{
+						Total:      "5",
+						Free:       "5",
+						FreeRanges: []string{"192.168.0.2-192.168.0.6"},
+					},
+				},
+			},
+		}),
+		Entry("Excluded addresses are neither counted nor free", testCaseUpdateCapacity{
+			pools: []ipamv1.Pool{
+				{
+					Start:      (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+					End:        (*ipamv1.IPAddressStr)(ptr.To("192.168.0.20")),
+					Exclusions: []ipamv1.IPExclusionStr{"192.168.0.10-192.168.0.11", "192.168.0.15", "192.168.0.20/30"},
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{
+				"192.168.0.13": "abc",
+			},
+			expectedCapacity: ipamv1.IPPoolCapacity{
+				Total:      "7",
+				Allocated:  1,
+				Free:       "6",
+				FreeRanges: []string{"192.168.0.12", "192.168.0.14", "192.168.0.16-192.168.0.19"},
+			},
+			expectedPools: []ipamv1.PoolStatus{
+				{
+					Index: 0,
+					IPPoolCapacity: ipamv1.IPPoolCapacity{
+						Total:      "7",
+						Allocated:  1,
+						Free:       "6",
+						FreeRanges: []string{"192.168.0.12", "192.168.0.14", "192.168.0.16-192.168.0.19"},
+					},
+				},
+			},
+		}),
+		Entry("Non-canonical IPv6 addresses are counted once", testCaseUpdateCapacity{
+			pools: []ipamv1.Pool{
+				{
+					Start: (*ipamv1.IPAddressStr)(ptr.To("2001:db8::1")),
+					End:   (*ipamv1.IPAddressStr)(ptr.To("2001:db8::5")),
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{
+				"2001:db8::5":    "abc",
+				"2001:db8::0005": "abc",
+			},
+			expectedCapacity: ipamv1.IPPoolCapacity{
+				Total:      "5",
+				Allocated:  1,
+				Free:       "4",
+				FreeRanges: []string{"2001:db8::1-2001:db8::4"},
+			},
+			expectedPools: []ipamv1.PoolStatus{
+				{
+					Index: 0,
+					IPPoolCapacity: ipamv1.IPPoolCapacity{
+						Total:      "5",
+						Allocated:  1,
+						Free:       "4",
+						FreeRanges: []string{"2001:db8::1-2001:db8::4"},
+					},
+				},
+			},
+		}),
+		Entry("Unbounded and large pools report unknown", testCaseUpdateCapacity{
+			pools: []ipamv1.Pool{
+				{
+					Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+				},
+				{
+					Subnet: (*ipamv1.IPSubnetStr)(ptr.To("2001:db8::/32")),
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{
+				"192.168.0.11": "abc",
+				"2001:db8::1":  "bcd",
+			},
+			expectedCapacity: ipamv1.IPPoolCapacity{
+				Total:      ipamv1.PoolSizeUnknown,
+				Allocated:  2,
+				Free:       ipamv1.PoolSizeUnknown,
+				FreeRanges: []string{"2001:db8::2-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+			},
+			expectedPools: []ipamv1.PoolStatus{
+				{
+					Index: 0,
+					IPPoolCapacity: ipamv1.IPPoolCapacity{
+						Total:     ipamv1.PoolSizeUnknown,
+						Allocated: 1,
+						Free:      ipamv1.PoolSizeUnknown,
+					},
+				},
+				{
+					Index: 1,
+					IPPoolCapacity: ipamv1.IPPoolCapacity{
+						Total:      ipamv1.PoolSizeUnknown,
+						Allocated:  1,
+						Free:       ipamv1.PoolSizeUnknown,
+						FreeRanges: []string{"2001:db8::2-2001:db8:ffff:ffff:ffff:ffff:ffff:ffff"},
+					},
+				},
+			},
+		}),
+		Entry("Free ranges are truncated", testCaseUpdateCapacity{
+			pools: []ipamv1.Pool{
+				{
+					Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.1")),
+					End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.13")),
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{
+				"192.168.0.2":  "a",
+				"192.168.0.4":  "b",
+				"192.168.0.6":  "c",
+				"192.168.0.8":  "d",
+				"192.168.0.10": "e",
+				"192.168.0.12": "f",
+			},
+			expectedCapacity: ipamv1.IPPoolCapacity{
+				Total:     "13",
+				Allocated: 6,
+				Free:      "7",
+				FreeRanges: []string{
+					"192.168.0.1", "192.168.0.3", "192.168.0.5", "192.168.0.7", "192.168.0.9",
+				},
+			},
+			expectedPools: []ipamv1.PoolStatus{
+				{
+					Index: 0,
+					IPPoolCapacity: ipamv1.IPPoolCapacity{
+						Total:     "13",
+						Allocated: 6,
+						Free:      "7",
+						FreeRanges: []string{
+							"192.168.0.1", "192.168.0.3", "192.168.0.5", "192.168.0.7", "192.168.0.9",
+						},
+					},
+				},
+			},
+		}),
+	)
+
+	It("Updates the capacity and its metrics when an allocation fails", func() {
+		// The IPPool is in its own namespace for its metrics to be apart
+		// from the ones of the other tests.
+		var objects []client.Object
+		for _, name := range []string{"first", "second"} {
+			claim := newTestIPClaim(name)
+			claim.Namespace = "capacity"
+			claim.Spec.Pool.Namespace = "capacity"
+			objects = append(objects, claim)
+		}
+		c := newTestClient(objects...)
+		ipPool := newTestIPPool(newTestPool("192.168.0.1", "192.168.0.1"))
+		ipPool.Namespace = "capacity"
+
+		_, err := updateTestAddresses(c, ipPool)
+		Expect(err).To(MatchError(errPoolExhausted))
+		Expect(ipPool.Status.Capacity).NotTo(BeNil())
+		Expect(ipPool.Status.Capacity.Allocated).To(Equal(1))
+		Expect(ipPool.Status.Capacity.Free).To(Equal("0"))
+		Expect(testutil.ToFloat64(poolUsedAddresses.WithLabelValues("capacity", "abc"))).To(Equal(1.0))
+	})
+})
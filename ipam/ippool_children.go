@@ -0,0 +1,391 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// A child IPPool, with a ParentPool, obtains its range as a subnet allocated
+// by its parent IPPool, in the same namespace. The parent IPPool records the
+// subnet in its allocations under the childPoolKey of the child IPPool, with
+// an IPAddress in its namespace whose claim is the child IPPool. The subnet is
+// resized when the prefix length requested by the child IPPool changes, and
+// released once the child IPPool is deleted.
+
+const (
+	// childPoolKeyPrefix prefixes the allocation keys of the child IPPools.
+	// IPClaims cannot collide with them, ':' is not allowed in object names.
+	childPoolKeyPrefix = "ippool:"
+
+	// parentSubnetRequeueAfter is the time after which a child IPPool that
+	// did not obtain a subnet from its parent IPPool is reconciled again.
+	parentSubnetRequeueAfter = 30 * time.Second
+)
+
+// childPoolKey returns the allocation key of a child IPPool in its parent
+// IPPool.
+func childPoolKey(name string) string {
+	return childPoolKeyPrefix + name
+}
+
+// isChildPoolAddress returns true if the IPAddress holds the subnet of a
+// child IPPool.
+func isChildPoolAddress(address *ipamv1.IPAddress) bool {
+	return address.Spec.Claim.Kind == ipamv1.IPPoolKind
+}
+
+// poolSpec returns the spec of the IPPool. The pool entry of a child IPPool
+// is the subnet obtained from its parent IPPool.
+func (m *IPPoolManager) poolSpec() ipamv1.IPPoolSpec {
+	spec := m.IPPool.Spec
+	if spec.ParentPool != nil {
+		spec.Pools = ipamv1.GetChildPools(spec, m.IPPool.Status.ParentSubnet)
+	}
+	return spec
+}
+
+// updateParentSubnet records the subnet a child IPPool obtained from its
+// parent IPPool in its status. While the subnet is moved to a shorter prefix
+// length, the IPAddresses of both subnets exist and the largest one, which
+// contains the other, is used. It returns a transient error as long as the
+// child IPPool has no subnet, unless it is being deleted.
+func (m *IPPoolManager) updateParentSubnet(ctx context.Context) error {
+	parentPool := m.IPPool.Spec.ParentPool
+	if m.global || parentPool == nil {
+		return nil
+	}
+
+	addressObjects := ipamv1.IPAddressList{}
+	// without this ListOption, all namespaces would be including in the listing
+	opts := &client.ListOptions{
+		Namespace: m.IPPool.Namespace,
+	}
+	if err := m.client.List(ctx, &addressObjects, opts); err != nil {
+		return err
+	}
+
+	var parentSubnet *ipamv1.IPSubnetStr
+	prefix := 0
+	for _, addressObject := range addressObjects.Items {
+		if !isChildPoolAddress(&addressObject) || addressObject.Spec.Claim.Name != m.IPPool.Name ||
+			addressObject.Spec.Pool.Name != parentPool.Name || addressObject.Spec.Subnet == nil ||
+			!addressObject.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if parentSubnet == nil || addressObject.Spec.Prefix < prefix {
+			parentSubnet = addressObject.Spec.Subnet
+			prefix = addressObject.Spec.Prefix
+		}
+	}
+
+	if !reflect.DeepEqual(parentSubnet, m.IPPool.Status.ParentSubnet) {
+		m.IPPool.Status.ParentSubnet = parentSubnet
+		m.updateStatusTimestamp()
+	}
+	if parentSubnet == nil && m.IPPool.DeletionTimestamp.IsZero() {
+		return WithTransientError(fmt.Errorf("waiting for a subnet from parent IPPool %s", parentPool.Name),
+			parentSubnetRequeueAfter)
+	}
+	return nil
+}
+
+// updateChildPools allocates a subnet to the child IPPools of the IPPool,
+// resizes their subnet to their requested prefix length and releases the
+// subnet of the deleted ones. Failures to allocate or resize a subnet are
+// reported as events on the child IPPool.
+func (m *IPPoolManager) updateChildPools(ctx context.Context,
+	addresses map[ipamv1.IPAddressStr]string,
+) (map[ipamv1.IPAddressStr]string, error) {
+	if m.global {
+		return addresses, nil
+	}
+
+	childPools := ipamv1.IPPoolList{}
+	// without this ListOption, all namespaces would be including in the listing
+	opts := &client.ListOptions{
+		Namespace: m.IPPool.Namespace,
+	}
+	if err := m.client.List(ctx, &childPools, opts); err != nil {
+		return addresses, err
+	}
+
+	children := []ipamv1.IPPool{}
+	childNames := make(map[string]bool)
+	for _, childPool := range childPools.Items {
+		if childPool.Spec.ParentPool == nil || childPool.Spec.ParentPool.Name != m.IPPool.Name ||
+			childPool.Name == m.IPPool.Name {
+			continue
+		}
+		children = append(children, childPool)
+		childNames[childPool.Name] = true
+	}
+
+	// The subnet of a child IPPool is released once the child IPPool is gone,
+	// before the other subnets are allocated or resized.
+	for allocationKey, allocatedAddress := range m.allocations {
+		name, ok := strings.CutPrefix(allocationKey, childPoolKeyPrefix)
+		if !ok || childNames[name] {
+			continue
+		}
+		if err := m.deleteChildPoolAddress(ctx, allocatedAddress); err != nil {
+			return addresses, err
+		}
+		m.untrackCreatedAddress(allocatedAddress)
+		delete(addresses, allocatedAddress)
+		delete(m.subnets, allocatedAddress)
+		m.addressSpaces = nil
+		delete(m.allocations, allocationKey)
+		m.Log.Info("Child IPPool removed from IPPool allocations", "IPPool", name)
+		m.recordRelease(claimKindIPPool)
+		m.updateStatusTimestamp()
+	}
+
+	var err error
+	for _, childPool := range children {
+		allocatedAddress, ok := m.allocations[childPoolKey(childPool.Name)]
+		switch {
+		case ok && childPool.DeletionTimestamp.IsZero():
+			addresses, err = m.resizeChildPool(ctx, &childPool, allocatedAddress, addresses)
+		case !ok && childPool.DeletionTimestamp.IsZero() && m.IPPool.DeletionTimestamp.IsZero():
+			addresses, err = m.allocateChildPool(ctx, &childPool, addresses)
+		}
+		if err != nil {
+			return addresses, err
+		}
+	}
+	return addresses, nil
+}
+
+// allocateChildPool allocates a subnet of its requested prefix length to a
+// child IPPool.
+func (m *IPPoolManager) allocateChildPool(ctx context.Context, childPool *ipamv1.IPPool,
+	addresses map[ipamv1.IPAddressStr]string,
+) (map[ipamv1.IPAddressStr]string, error) {
+	prefixLength := childPool.Spec.ParentPool.PrefixLength
+	allocatedAddress, _, err := m.selectSubnet(prefixLength, "", "", labels.Everything(), addresses)
+	if err != nil {
+		if errors.Is(err, errNoSubnetFits) {
+			_ = m.failAllocation(childPool, claimKindIPPool, noSubnetFits(prefixLength))
+		} else {
+			_ = m.failAllocation(childPool, claimKindIPPool, newAllocationError(ErrorReasonExhausted,
+				"No /%d subnet left in IPPool %s", prefixLength, m.IPPool.Name))
+		}
+		return addresses, nil
+	}
+
+	addressObject := m.newChildPoolAddress(childPool, allocatedAddress, prefixLength)
+	if err := createObject(ctx, m.client, addressObject); err != nil {
+		return addresses, err
+	}
+
+	allocationKey := childPoolKey(childPool.Name)
+	m.allocations[allocationKey] = allocatedAddress
+	addresses[allocatedAddress] = allocationKey
+	m.addSubnet(allocatedAddress, *addressObject.Spec.Subnet)
+	m.trackCreatedAddress(allocatedAddress, allocationKey, addressObject.Spec.Subnet, nil)
+	m.updateStatusTimestamp()
+	m.recordAllocation(claimKindIPPool, childPool.CreationTimestamp)
+	m.recordNormal(childPool, claimKindIPPool, AddressAllocatedReason, "Allocated subnet %s", *addressObject.Spec.Subnet)
+	return addresses, nil
+}
+
+// resizeChildPool resizes the subnet of a child IPPool to its requested
+// prefix length. The subnet keeps its network address when it shrinks, and
+// moves to the network address of the larger subnet containing it when it
+// grows. A subnet cannot shrink past the addresses the child IPPool allocated
+// or pre-allocated, nor grow over the addresses in use in the IPPool.
+func (m *IPPoolManager) resizeChildPool(ctx context.Context, childPool *ipamv1.IPPool,
+	allocatedAddress ipamv1.IPAddressStr, addresses map[ipamv1.IPAddressStr]string,
+) (map[ipamv1.IPAddressStr]string, error) {
+	allocatedSubnet, ok := m.subnets[allocatedAddress]
+	if !ok {
+		return addresses, nil
+	}
+	ones, bits := allocatedSubnet.Mask.Size()
+	prefixLength := childPool.Spec.ParentPool.PrefixLength
+	if prefixLength == ones || prefixLength > bits {
+		return addresses, nil
+	}
+
+	resizedSubnet := &net.IPNet{
+		IP:   allocatedSubnet.IP.Mask(net.CIDRMask(prefixLength, bits)),
+		Mask: net.CIDRMask(prefixLength, bits),
+	}
+	if prefixLength > ones {
+		inUse, err := m.childPoolAddresses(ctx, childPool)
+		if err != nil {
+			return addresses, err
+		}
+		for _, address := range inUse {
+			if ip := net.ParseIP(string(address)); ip != nil && !resizedSubnet.Contains(ip) {
+				m.recordWarning(childPool, claimKindIPPool, ChildPoolResizeFailedReason,
+					"Cannot shrink subnet %s to /%d, address %s is in use", allocatedSubnet, prefixLength, address)
+				return addresses, nil
+			}
+		}
+	}
+
+	resizedAddress := ipamv1.IPAddressStr(resizedSubnet.IP.String())
+	if _, _, err := m.selectSubnet(prefixLength, resizedAddress, allocatedAddress, labels.Everything(), addresses); err != nil {
+		m.recordWarning(childPool, claimKindIPPool, ChildPoolResizeFailedReason,
+			"Cannot resize subnet %s to %s in IPPool %s", allocatedSubnet, resizedSubnet, m.IPPool.Name)
+		return addresses, nil
+	}
+
+	addressObject := m.newChildPoolAddress(childPool, resizedAddress, prefixLength)
+	if m.ipEqual(resizedAddress, allocatedAddress) {
+		// The IPAddress is updated in place, its address cannot be modified.
+		existing := &ipamv1.IPAddress{}
+		key := client.ObjectKey{
+			Name:      addressObject.Name,
+			Namespace: addressObject.Namespace,
+		}
+		if err := m.client.Get(ctx, key, existing); err != nil {
+			return addresses, err
+		}
+		existing.Spec.Prefix = addressObject.Spec.Prefix
+		existing.Spec.Subnet = addressObject.Spec.Subnet
+		if err := updateObject(ctx, m.client, existing); err != nil {
+			return addresses, err
+		}
+	} else {
+		// The IPAddress of the larger subnet is created before the other one
+		// is deleted, so that the child IPPool always holds its addresses.
+		if err := createObject(ctx, m.client, addressObject); err != nil {
+			return addresses, err
+		}
+		if err := m.deleteChildPoolAddress(ctx, allocatedAddress); err != nil {
+			return addresses, err
+		}
+		m.untrackCreatedAddress(allocatedAddress)
+		allocationKey := childPoolKey(childPool.Name)
+		delete(addresses, allocatedAddress)
+		delete(m.subnets, allocatedAddress)
+		m.addressSpaces = nil
+		m.allocations[allocationKey] = resizedAddress
+		addresses[resizedAddress] = allocationKey
+	}
+	m.addSubnet(resizedAddress, *addressObject.Spec.Subnet)
+	m.trackCreatedAddress(resizedAddress, childPoolKey(childPool.Name), addressObject.Spec.Subnet, nil)
+	m.updateStatusTimestamp()
+	m.recordNormal(childPool, claimKindIPPool, ChildPoolResizedReason,
+		"Resized subnet %s to %s", allocatedSubnet, *addressObject.Spec.Subnet)
+	return addresses, nil
+}
+
+// newChildPoolAddress returns the IPAddress holding the subnet of a child
+// IPPool. It is owned by the IPPool only, so that it is not garbage collected
+// before the child IPPool released its addresses.
+func (m *IPPoolManager) newChildPoolAddress(childPool *ipamv1.IPPool,
+	address ipamv1.IPAddressStr, prefixLength int,
+) *ipamv1.IPAddress {
+	subnet := ipamv1.IPSubnetStr(fmt.Sprintf("%s/%d", address, prefixLength))
+	return &ipamv1.IPAddress{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "IPAddress",
+			APIVersion: ipamv1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       m.formatAddressName(address),
+			Namespace:  m.IPPool.Namespace,
+			Finalizers: []string{ipamv1.IPAddressFinalizer},
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: m.IPPool.APIVersion,
+					Kind:       m.IPPool.Kind,
+					Name:       m.IPPool.Name,
+					UID:        m.IPPool.UID,
+				},
+			},
+		},
+		Spec: ipamv1.IPAddressSpec{
+			Address: address,
+			Pool:    m.poolReference(),
+			Claim: corev1.ObjectReference{
+				APIVersion: ipamv1.GroupVersion.String(),
+				Kind:       ipamv1.IPPoolKind,
+				Name:       childPool.Name,
+				Namespace:  childPool.Namespace,
+			},
+			Prefix: prefixLength,
+			Subnet: &subnet,
+		},
+	}
+}
+
+// childPoolAddresses returns the addresses a child IPPool allocated or
+// pre-allocated. They are listed from the IPAddress objects of the child
+// IPPool when it does not record its allocations in its status.
+func (m *IPPoolManager) childPoolAddresses(ctx context.Context, childPool *ipamv1.IPPool) ([]ipamv1.IPAddressStr, error) {
+	inUse := slices.Collect(maps.Values(childPool.Spec.PreAllocations))
+	if ipamv1.StoresAllocationsInStatus(childPool.Spec) {
+		return append(inUse, slices.Collect(maps.Values(childPool.Status.Allocations))...), nil
+	}
+	childPoolMgr, err := NewIPPoolManager(m.client, childPool.DeepCopy(), m.Log)
+	if err != nil {
+		return nil, err
+	}
+	childPoolMgr.created = m.created
+	addresses, err := childPoolMgr.getIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(inUse, slices.Collect(maps.Keys(addresses))...), nil
+}
+
+// deleteChildPoolAddress removes the finalizer of the IPAddress holding the
+// subnet of a child IPPool and deletes it.
+func (m *IPPoolManager) deleteChildPoolAddress(ctx context.Context, address ipamv1.IPAddressStr) error {
+	ipAddress := &ipamv1.IPAddress{}
+	key := client.ObjectKey{
+		Name:      m.formatAddressName(address),
+		Namespace: m.IPPool.Namespace,
+	}
+	if err := m.client.Get(ctx, key, ipAddress); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	ipAddress.Finalizers = Filter(ipAddress.Finalizers,
+		ipamv1.IPAddressFinalizer,
+	)
+	if err := updateObject(ctx, m.client, ipAddress); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err := deleteObject(ctx, m.client, ipAddress); err != nil {
+		return err
+	}
+	m.Log.Info("Deleted IPAddress", "IPAddress", ipAddress.Name)
+	return nil
+}
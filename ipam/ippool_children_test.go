@@ -0,0 +1,160 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Child IPPools", func() {
+	newParentPool := func() *ipamv1.IPPool {
+		ipPool := newTestIPPool(ipamv1.Pool{
+			Subnet: (*ipamv1.IPSubnetStr)(ptr.To("10.0.0.0/24")),
+		})
+		ipPool.Name = "parent"
+		ipPool.Spec.NamePrefix = "parent"
+		return ipPool
+	}
+
+	newChildPool := func(name string, prefixLength int) *ipamv1.IPPool {
+		ipPool := newTestIPPool()
+		ipPool.Name = name
+		ipPool.Spec.NamePrefix = name
+		ipPool.Spec.ParentPool = &ipamv1.ParentPoolReference{
+			Name:         "parent",
+			PrefixLength: prefixLength,
+		}
+		return ipPool
+	}
+
+	newChildIPClaim := func(name, pool string) *ipamv1.IPClaim {
+		claim := newTestIPClaim(name)
+		claim.Spec.Pool = corev1.ObjectReference{Name: pool}
+		return claim
+	}
+
+	setPrefixLength := func(c client.Client, name string, prefixLength int) {
+		childPool := &ipamv1.IPPool{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "myns"}, childPool)).To(Succeed())
+		childPool.Spec.ParentPool.PrefixLength = prefixLength
+		Expect(c.Update(context.TODO(), childPool)).To(Succeed())
+	}
+
+	It("Allocates, resizes and releases the subnet of a child IPPool", func() {
+		childPool := newChildPool("child", 26)
+		objects := []client.Object{childPool, newChildIPClaim("host", "child")}
+		c := newTestClient(objects...)
+		childPoolMgr, err := NewIPPoolManager(c, childPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		parentPool := newParentPool()
+		parentPoolMgr, err := NewIPPoolManager(c, parentPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		// The child IPPool waits for its subnet.
+		_, err = childPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).To(HaveOccurred())
+		Expect(childPool.Status.ParentSubnet).To(BeNil())
+		Expect(childPool.Status.Allocations).To(BeEmpty())
+
+		_, err = parentPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parentPool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{"ippool:child": "10.0.0.0"}))
+		address := getTestIPAddress(c, "parent-10-0-0-0")
+		Expect(address.Spec.Claim.Kind).To(Equal(ipamv1.IPPoolKind))
+		Expect(address.Spec.Claim.Name).To(Equal("child"))
+		Expect(address.Spec.Subnet).To(Equal((*ipamv1.IPSubnetStr)(ptr.To("10.0.0.0/26"))))
+
+		_, err = childPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(childPool.Status.ParentSubnet).To(Equal((*ipamv1.IPSubnetStr)(ptr.To("10.0.0.0/26"))))
+		Expect(childPool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{"host": "10.0.0.1"}))
+		Expect(childPool.Status.Capacity.Total).To(Equal("63"))
+
+		// The parent IPPool allocates its addresses out of the subnet.
+		Expect(c.Create(context.TODO(), newChildIPClaim("other", "parent"))).To(Succeed())
+		_, err = parentPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parentPool.Status.Allocations).To(HaveKeyWithValue("other", ipamv1.IPAddressStr("10.0.0.64")))
+
+		// The subnet cannot grow over the addresses in use.
+		setPrefixLength(c, "child", 25)
+		_, err = parentPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getTestIPAddress(c, "parent-10-0-0-0").Spec.Prefix).To(Equal(26))
+
+		// The subnet shrinks around the addresses of the child IPPool.
+		setPrefixLength(c, "child", 27)
+		_, err = parentPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		address = getTestIPAddress(c, "parent-10-0-0-0")
+		Expect(address.Spec.Prefix).To(Equal(27))
+		Expect(address.Spec.Subnet).To(Equal((*ipamv1.IPSubnetStr)(ptr.To("10.0.0.0/27"))))
+		Expect(parentPool.Status.Capacity.Allocated).To(Equal(33))
+
+		// The subnet is released once the child IPPool is gone.
+		Expect(c.Delete(context.TODO(), childPool)).To(Succeed())
+		_, err = parentPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parentPool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{"other": "10.0.0.64"}))
+		err = c.Get(context.TODO(), client.ObjectKey{Name: "parent-10-0-0-0", Namespace: "myns"}, &ipamv1.IPAddress{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Moves the subnet of a child IPPool that grows", func() {
+		objects := []client.Object{newChildPool("first", 26), newChildPool("second", 26)}
+		c := newTestClient(objects...)
+		parentPool := newParentPool()
+		parentPoolMgr, err := NewIPPoolManager(c, parentPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = parentPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parentPool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{
+			"ippool:first":  "10.0.0.0",
+			"ippool:second": "10.0.0.64",
+		}))
+
+		Expect(c.Delete(context.TODO(), objects[0])).To(Succeed())
+		setPrefixLength(c, "second", 25)
+		_, err = parentPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parentPool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{"ippool:second": "10.0.0.0"}))
+		address := getTestIPAddress(c, "parent-10-0-0-0")
+		Expect(address.Spec.Claim.Name).To(Equal("second"))
+		Expect(address.Spec.Subnet).To(Equal((*ipamv1.IPSubnetStr)(ptr.To("10.0.0.0/25"))))
+		err = c.Get(context.TODO(), client.ObjectKey{Name: "parent-10-0-0-64", Namespace: "myns"}, &ipamv1.IPAddress{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		// The child IPPool gets its new range.
+		secondPool := &ipamv1.IPPool{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: "second", Namespace: "myns"}, secondPool)).To(Succeed())
+		secondPoolMgr, err := NewIPPoolManager(c, secondPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = secondPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(secondPool.Status.ParentSubnet).To(Equal((*ipamv1.IPSubnetStr)(ptr.To("10.0.0.0/25"))))
+	})
+})
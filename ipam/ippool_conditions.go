@@ -0,0 +1,130 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+)
+
+// updateConditions sets the Ready, Degraded, Exhausted and NearlyExhausted
+// conditions of the IPPool from the result of UpdateAddresses and from the
+// capacity status. Transient errors leave Ready and Degraded untouched since
+// the pool is requeued and the error is expected to resolve itself. The
+// failures to serve a claim are reported to the claim and do not degrade the
+// pool.
+func (m *IPPoolManager) updateConditions(err error) {
+	var reconcileError ReconcileError
+	switch {
+	case err == nil, isClaimError(err):
+		SetIPPoolHealthConditions(m.IPPool, metav1.ConditionTrue, ipamv1.IPPoolReconciledReason, "")
+	case errors.As(err, &reconcileError) && reconcileError.IsTransient():
+	default:
+		SetIPPoolHealthConditions(m.IPPool, metav1.ConditionFalse, ipamv1.IPPoolReconcileFailedReason, err.Error())
+	}
+
+	if m.IPPool.Status.Capacity == nil {
+		return
+	}
+	total, totalErr := strconv.Atoi(m.IPPool.Status.Capacity.Total)
+	free, freeErr := strconv.Atoi(m.IPPool.Status.Capacity.Free)
+	if totalErr != nil || freeErr != nil {
+		message := "The size of the pool cannot be computed"
+		conditions.Set(m.IPPool, metav1.Condition{
+			Type:    ipamv1.IPPoolExhaustedCondition,
+			Status:  metav1.ConditionUnknown,
+			Reason:  ipamv1.IPPoolCapacityUnknownReason,
+			Message: message,
+		})
+		conditions.Set(m.IPPool, metav1.Condition{
+			Type:    ipamv1.IPPoolNearlyExhaustedCondition,
+			Status:  metav1.ConditionUnknown,
+			Reason:  ipamv1.IPPoolCapacityUnknownReason,
+			Message: message,
+		})
+		return
+	}
+
+	if free == 0 {
+		conditions.Set(m.IPPool, metav1.Condition{
+			Type:    ipamv1.IPPoolExhaustedCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  ipamv1.IPPoolExhaustedReason,
+			Message: fmt.Sprintf("All %d addresses of the pool are in use", total),
+		})
+	} else {
+		conditions.Set(m.IPPool, metav1.Condition{
+			Type:    ipamv1.IPPoolExhaustedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  ipamv1.IPPoolAddressesAvailableReason,
+			Message: fmt.Sprintf("%d of %d addresses are free", free, total),
+		})
+	}
+
+	threshold := m.IPPool.Spec.NearlyExhaustedThreshold
+	if threshold == 0 {
+		threshold = ipamv1.DefaultNearlyExhaustedThreshold
+	}
+	// An empty pool is fully utilized.
+	utilization := 100
+	if total > 0 {
+		utilization = (total - free) * 100 / total
+	}
+	message := fmt.Sprintf("Utilization is %d%%, threshold is %d%%", utilization, threshold)
+	if utilization >= threshold {
+		conditions.Set(m.IPPool, metav1.Condition{
+			Type:    ipamv1.IPPoolNearlyExhaustedCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  ipamv1.IPPoolUtilizationAboveThresholdReason,
+			Message: message,
+		})
+	} else {
+		conditions.Set(m.IPPool, metav1.Condition{
+			Type:    ipamv1.IPPoolNearlyExhaustedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  ipamv1.IPPoolUtilizationBelowThresholdReason,
+			Message: message,
+		})
+	}
+}
+
+// SetIPPoolHealthConditions sets the Ready condition of the IPPool to the
+// given status and the Degraded condition to its opposite, with the same
+// reason and message.
+func SetIPPoolHealthConditions(ipPool *ipamv1.IPPool, ready metav1.ConditionStatus, reason, message string) {
+	degraded := metav1.ConditionFalse
+	if ready != metav1.ConditionTrue {
+		degraded = metav1.ConditionTrue
+	}
+	conditions.Set(ipPool, metav1.Condition{
+		Type:    ipamv1.IPPoolReadyCondition,
+		Status:  ready,
+		Reason:  reason,
+		Message: message,
+	})
+	conditions.Set(ipPool, metav1.Condition{
+		Type:    ipamv1.IPPoolDegradedCondition,
+		Status:  degraded,
+		Reason:  reason,
+		Message: message,
+	})
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("IPPool conditions", func() {
+	type testCaseUpdateConditions struct {
+		capacity           *ipamv1.IPPoolCapacity
+		threshold          int
+		existingConditions []metav1.Condition
+		err                error
+		expectedStatus     map[string]metav1.ConditionStatus
+		expectedReasons    map[string]string
+	}
+
+	DescribeTable("Test updateConditions",
+		func(tc testCaseUpdateConditions) {
+			ipPool := &ipamv1.IPPool{
+				Spec: ipamv1.IPPoolSpec{
+					NearlyExhaustedThreshold: tc.threshold,
+				},
+				Status: ipamv1.IPPoolStatus{
+					Capacity:   tc.capacity,
+					Conditions: tc.existingConditions,
+				},
+			}
+			ipPoolMgr, err := NewIPPoolManager(nil, ipPool, logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+
+			ipPoolMgr.updateConditions(tc.err)
+			Expect(ipPool.Status.Conditions).To(HaveLen(len(tc.expectedStatus)))
+			for conditionType, status := range tc.expectedStatus {
+				condition := meta.FindStatusCondition(ipPool.Status.Conditions, conditionType)
+				Expect(condition).NotTo(BeNil())
+				Expect(condition.Status).To(Equal(status))
+				Expect(condition.Reason).To(Equal(tc.expectedReasons[conditionType]))
+			}
+		},
+		Entry("Reconciled, addresses available", testCaseUpdateConditions{
+			capacity: &ipamv1.IPPoolCapacity{Total: "10", Allocated: 5, Free: "5"},
+			expectedStatus: map[string]metav1.ConditionStatus{
+				ipamv1.IPPoolReadyCondition:           metav1.ConditionTrue,
+				ipamv1.IPPoolDegradedCondition:        metav1.ConditionFalse,
+				ipamv1.IPPoolExhaustedCondition:       metav1.ConditionFalse,
+				ipamv1.IPPoolNearlyExhaustedCondition: metav1.ConditionFalse,
+			},
+			expectedReasons: map[string]string{
+				ipamv1.IPPoolReadyCondition:           ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolDegradedCondition:        ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolExhaustedCondition:       ipamv1.IPPoolAddressesAvailableReason,
+				ipamv1.IPPoolNearlyExhaustedCondition: ipamv1.IPPoolUtilizationBelowThresholdReason,
+			},
+		}),
+		Entry("Default threshold reached", testCaseUpdateConditions{
+			capacity: &ipamv1.IPPoolCapacity{Total: "10", Allocated: 8, PreAllocated: 1, Free: "1"},
+			expectedStatus: map[string]metav1.ConditionStatus{
+				ipamv1.IPPoolReadyCondition:           metav1.ConditionTrue,
+				ipamv1.IPPoolDegradedCondition:        metav1.ConditionFalse,
+				ipamv1.IPPoolExhaustedCondition:       metav1.ConditionFalse,
+				ipamv1.IPPoolNearlyExhaustedCondition: metav1.ConditionTrue,
+			},
+			expectedReasons: map[string]string{
+				ipamv1.IPPoolReadyCondition:           ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolDegradedCondition:        ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolExhaustedCondition:       ipamv1.IPPoolAddressesAvailableReason,
+				ipamv1.IPPoolNearlyExhaustedCondition: ipamv1.IPPoolUtilizationAboveThresholdReason,
+			},
+		}),
+		Entry("Custom threshold reached", testCaseUpdateConditions{
+			capacity:  &ipamv1.IPPoolCapacity{Total: "10", Allocated: 5, Free: "5"},
+			threshold: 50,
+			expectedStatus: map[string]metav1.ConditionStatus{
+				ipamv1.IPPoolReadyCondition:           metav1.ConditionTrue,
+				ipamv1.IPPoolDegradedCondition:        metav1.ConditionFalse,
+				ipamv1.IPPoolExhaustedCondition:       metav1.ConditionFalse,
+				ipamv1.IPPoolNearlyExhaustedCondition: metav1.ConditionTrue,
+			},
+			expectedReasons: map[string]string{
+				ipamv1.IPPoolReadyCondition:           ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolDegradedCondition:        ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolExhaustedCondition:       ipamv1.IPPoolAddressesAvailableReason,
+				ipamv1.IPPoolNearlyExhaustedCondition: ipamv1.IPPoolUtilizationAboveThresholdReason,
+			},
+		}),
+		Entry("Exhausted", testCaseUpdateConditions{
+			capacity: &ipamv1.IPPoolCapacity{Total: "2", Allocated: 2, Free: "0"},
+			expectedStatus: map[string]metav1.ConditionStatus{
+				ipamv1.IPPoolReadyCondition:           metav1.ConditionTrue,
+				ipamv1.IPPoolDegradedCondition:        metav1.ConditionFalse,
+				ipamv1.IPPoolExhaustedCondition:       metav1.ConditionTrue,
+				ipamv1.IPPoolNearlyExhaustedCondition: metav1.ConditionTrue,
+			},
+			expectedReasons: map[string]string{
+				ipamv1.IPPoolReadyCondition:           ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolDegradedCondition:        ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolExhaustedCondition:       ipamv1.IPPoolExhaustedReason,
+				ipamv1.IPPoolNearlyExhaustedCondition: ipamv1.IPPoolUtilizationAboveThresholdReason,
+			},
+		}),
+		Entry("Empty pool is exhausted", testCaseUpdateConditions{
+			capacity: &ipamv1.IPPoolCapacity{Total: "0", Free: "0"},
+			expectedStatus: map[string]metav1.ConditionStatus{
+				ipamv1.IPPoolReadyCondition:           metav1.ConditionTrue,
+				ipamv1.IPPoolDegradedCondition:        metav1.ConditionFalse,
+				ipamv1.IPPoolExhaustedCondition:       metav1.ConditionTrue,
+				ipamv1.IPPoolNearlyExhaustedCondition: metav1.ConditionTrue,
+			},
+			expectedReasons: map[string]string{
+				ipamv1.IPPoolReadyCondition:           ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolDegradedCondition:        ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolExhaustedCondition:       ipamv1.IPPoolExhaustedReason,
+				ipamv1.IPPoolNearlyExhaustedCondition: ipamv1.IPPoolUtilizationAboveThresholdReason,
+			},
+		}),
+		Entry("Unknown capacity", testCaseUpdateConditions{
+			capacity: &ipamv1.IPPoolCapacity{Total: ipamv1.PoolSizeUnknown, Allocated: 2, Free: ipamv1.PoolSizeUnknown},
+			expectedStatus: map[string]metav1.ConditionStatus{
+				ipamv1.IPPoolReadyCondition:           metav1.ConditionTrue,
+				ipamv1.IPPoolDegradedCondition:        metav1.ConditionFalse,
+				ipamv1.IPPoolExhaustedCondition:       metav1.ConditionUnknown,
+				ipamv1.IPPoolNearlyExhaustedCondition: metav1.ConditionUnknown,
+			},
+			expectedReasons: map[string]string{
+				ipamv1.IPPoolReadyCondition:           ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolDegradedCondition:        ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolExhaustedCondition:       ipamv1.IPPoolCapacityUnknownReason,
+				ipamv1.IPPoolNearlyExhaustedCondition: ipamv1.IPPoolCapacityUnknownReason,
+			},
+		}),
+		Entry("Reconcile error", testCaseUpdateConditions{
+			err: errors.New("Failed"),
+			expectedStatus: map[string]metav1.ConditionStatus{
+				ipamv1.IPPoolReadyCondition:    metav1.ConditionFalse,
+				ipamv1.IPPoolDegradedCondition: metav1.ConditionTrue,
+			},
+			expectedReasons: map[string]string{
+				ipamv1.IPPoolReadyCondition:    ipamv1.IPPoolReconcileFailedReason,
+				ipamv1.IPPoolDegradedCondition: ipamv1.IPPoolReconcileFailedReason,
+			},
+		}),
+		Entry("Claim allocation error keeps the pool healthy", testCaseUpdateConditions{
+			capacity: &ipamv1.IPPoolCapacity{Total: "2", Allocated: 2, Free: "0"},
+			err:      errPoolExhausted,
+			expectedStatus: map[string]metav1.ConditionStatus{
+				ipamv1.IPPoolReadyCondition:           metav1.ConditionTrue,
+				ipamv1.IPPoolDegradedCondition:        metav1.ConditionFalse,
+				ipamv1.IPPoolExhaustedCondition:       metav1.ConditionTrue,
+				ipamv1.IPPoolNearlyExhaustedCondition: metav1.ConditionTrue,
+			},
+			expectedReasons: map[string]string{
+				ipamv1.IPPoolReadyCondition:           ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolDegradedCondition:        ipamv1.IPPoolReconciledReason,
+				ipamv1.IPPoolExhaustedCondition:       ipamv1.IPPoolExhaustedReason,
+				ipamv1.IPPoolNearlyExhaustedCondition: ipamv1.IPPoolUtilizationAboveThresholdReason,
+			},
+		}),
+		Entry("Failed API call degrades the pool", testCaseUpdateConditions{
+			err: &AllocationError{
+				Reason:  ErrorReasonAddressCreationFailed,
+				Message: "Failed to create IPAddress abc",
+				err:     errors.New("Failed"),
+			},
+			expectedStatus: map[string]metav1.ConditionStatus{
+				ipamv1.IPPoolReadyCondition:    metav1.ConditionFalse,
+				ipamv1.IPPoolDegradedCondition: metav1.ConditionTrue,
+			},
+			expectedReasons: map[string]string{
+				ipamv1.IPPoolReadyCondition:    ipamv1.IPPoolReconcileFailedReason,
+				ipamv1.IPPoolDegradedCondition: ipamv1.IPPoolReconcileFailedReason,
+			},
+		}),
+		Entry("Transient error keeps the health conditions", testCaseUpdateConditions{
+			err: WithTransientError(errors.New("Failed"), time.Second),
+			existingConditions: []metav1.Condition{
+				{
+					Type:   ipamv1.IPPoolReadyCondition,
+					Status: metav1.ConditionTrue,
+					Reason: ipamv1.IPPoolReconciledReason,
+				},
+			},
+			expectedStatus: map[string]metav1.ConditionStatus{
+				ipamv1.IPPoolReadyCondition: metav1.ConditionTrue,
+			},
+			expectedReasons: map[string]string{
+				ipamv1.IPPoolReadyCondition: ipamv1.IPPoolReconciledReason,
+			},
+		}),
+	)
+})
@@ -0,0 +1,160 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The reconciliations of an IPPool are serialized, but the cache may not have
+// observed the IPAddresses created by the previous one yet. The IPAddress of
+// an address allocated again to a claim of the same kind conflicts on
+// creation, but the IPAddresses of the two claim kinds do not. The addresses
+// allocated by the process are therefore tracked until the cache observed
+// their IPAddress, and are not allocated again meanwhile.
+
+// createdAddressTTL bounds the time an address is tracked, in case its
+// IPAddress was deleted before the cache observed it.
+const createdAddressTTL = time.Minute
+
+// createdAddress is an address allocated by the process.
+type createdAddress struct {
+	allocationKey string
+	subnet        *ipamv1.IPSubnetStr
+	// namespace and labels are the ones of the claim the address is
+	// allocated to, counted in the quotas unless the address is allocated
+	// to a child IPPool.
+	namespace string
+	labels    map[string]string
+	quota     bool
+	created   time.Time
+}
+
+// createdAddresses tracks the addresses allocated by the process, by IPPool,
+// until the cache observed their IPAddress. It is shared by the managers of a
+// ManagerFactory.
+type createdAddresses struct {
+	mu    sync.Mutex
+	pools map[corev1.ObjectReference]map[ipamv1.IPAddressStr]createdAddress
+}
+
+func newCreatedAddresses() *createdAddresses {
+	return &createdAddresses{
+		pools: make(map[corev1.ObjectReference]map[ipamv1.IPAddressStr]createdAddress),
+	}
+}
+
+// add tracks an address allocated by the IPPool.
+func (c *createdAddresses) add(pool corev1.ObjectReference, address ipamv1.IPAddressStr, created createdAddress) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pools[pool] == nil {
+		c.pools[pool] = make(map[ipamv1.IPAddressStr]createdAddress)
+	}
+	c.pools[pool][address] = created
+}
+
+// remove forgets an address of the IPPool, once released.
+func (c *createdAddresses) remove(pool corev1.ObjectReference, address ipamv1.IPAddressStr) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pools[pool], address)
+	if len(c.pools[pool]) == 0 {
+		delete(c.pools, pool)
+	}
+}
+
+// unobserved returns the addresses allocated by the IPPool that are missing
+// from the given addresses, and forgets the others.
+func (c *createdAddresses) unobserved(pool corev1.ObjectReference,
+	addresses map[ipamv1.IPAddressStr]string,
+) map[ipamv1.IPAddressStr]createdAddress {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	unobserved := make(map[ipamv1.IPAddressStr]createdAddress)
+	for address, created := range c.pools[pool] {
+		if _, ok := addresses[address]; ok || time.Since(created.created) > createdAddressTTL {
+			delete(c.pools[pool], address)
+			continue
+		}
+		unobserved[address] = created
+	}
+	if len(c.pools[pool]) == 0 {
+		delete(c.pools, pool)
+	}
+	return unobserved
+}
+
+// trackCreatedAddress tracks the address allocated to a claim, or to a child
+// IPPool when the claim is nil, until the cache observed its IPAddress.
+func (m *IPPoolManager) trackCreatedAddress(address ipamv1.IPAddressStr, allocationKey string,
+	subnet *ipamv1.IPSubnetStr, claim client.Object,
+) {
+	created := createdAddress{
+		allocationKey: allocationKey,
+		subnet:        subnet,
+		created:       time.Now(),
+	}
+	if claim != nil {
+		created.namespace = claim.GetNamespace()
+		created.labels = maps.Clone(claim.GetLabels())
+		created.quota = true
+	}
+	m.created.add(m.poolReference(), address, created)
+}
+
+// untrackCreatedAddress forgets the address once its IPAddress is deleted, so
+// that it is not seen as allocated until the cache observes the deletion.
+func (m *IPPoolManager) untrackCreatedAddress(address ipamv1.IPAddressStr) {
+	m.created.remove(m.poolReference(), address)
+}
+
+// addCreatedAddresses adds the addresses allocated by the process that the
+// cache has not observed yet to the addresses and the allocations of the
+// IPPool.
+func (m *IPPoolManager) addCreatedAddresses(addresses map[ipamv1.IPAddressStr]string,
+	allocations map[string]ipamv1.IPAddressStr,
+) {
+	for address, created := range m.created.unobserved(m.poolReference(), addresses) {
+		m.Log.Info("Address allocated but not observed yet", "address", address)
+		addresses[address] = created.allocationKey
+		if _, ok := allocations[created.allocationKey]; !ok {
+			allocations[created.allocationKey] = address
+		}
+		if created.subnet != nil {
+			m.addSubnet(address, *created.subnet)
+		}
+		if created.quota {
+			m.updateQuotaUsage(created.namespace, created.labels, 1)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+var _ = Describe("IPPool created addresses", func() {
+	pool := corev1.ObjectReference{Name: "abc", Namespace: "myns"}
+
+	// newUnobservingClient returns a client whose cache does not observe the
+	// IPAddresses of the IPClaims.
+	newUnobservingClient := func(objects ...client.Object) client.Client {
+		return fakeclient.NewClientBuilder().WithScheme(setupScheme()).
+			WithStatusSubresource(&ipamv1.IPClaim{}, &capipamv1.IPAddressClaim{}).WithObjects(objects...).
+			WithIndex(&ipamv1.IPClaim{}, IPClaimPoolField, IPClaimPools).
+			WithInterceptorFuncs(interceptor.Funcs{
+				List: func(ctx context.Context, cl client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					if _, ok := list.(*ipamv1.IPAddressList); ok {
+						return nil
+					}
+					return cl.List(ctx, list, opts...)
+				},
+			}).Build()
+	}
+
+	// updateFactoryAddresses reconciles the claims of the IPPool with a new
+	// manager of the factory, sharing the addresses allocated by the previous
+	// ones.
+	updateFactoryAddresses := func(managerFactory ManagerFactory, ipPool *ipamv1.IPPool) {
+		ipPoolMgr, err := managerFactory.NewIPPoolManager(ipPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		_, err = ipPoolMgr.UpdateAddresses(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	It("Does not allocate the addresses the cache has not observed yet", func() {
+		ipAddressClaim := newTestIPAddressClaim("second")
+		c := newUnobservingClient(newTestIPClaim("first"))
+		managerFactory := NewManagerFactory(c, record.NewFakeRecorder(32))
+
+		ipPool := newTestIPPool(newTestPool("192.168.1.10", "192.168.1.20"))
+		updateFactoryAddresses(managerFactory, ipPool)
+
+		Expect(c.Create(context.TODO(), ipAddressClaim)).To(Succeed())
+		updateFactoryAddresses(managerFactory, ipPool)
+		Expect(ipPool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{
+			"first":  "192.168.1.10",
+			"second": "192.168.1.11",
+		}))
+	})
+
+	It("Forgets the released addresses the cache has not observed yet", func() {
+		c := newUnobservingClient(newTestIPClaim("first"))
+		managerFactory := NewManagerFactory(c, record.NewFakeRecorder(32))
+		ipPool := newTestIPPool(newTestPool("192.168.1.10", "192.168.1.20"))
+		updateFactoryAddresses(managerFactory, ipPool)
+
+		// The IPClaim is deleted and created again before the cache observed
+		// its IPAddress.
+		Expect(c.Delete(context.TODO(), newTestIPClaim("first"))).To(Succeed())
+		updateFactoryAddresses(managerFactory, ipPool)
+		Expect(ipPool.Status.Allocations).To(BeEmpty())
+		Expect(managerFactory.created.pools).To(BeEmpty())
+
+		Expect(c.Create(context.TODO(), newTestIPClaim("first"))).To(Succeed())
+		updateFactoryAddresses(managerFactory, ipPool)
+		claim := getTestIPClaim(c, "first")
+		Expect(claim.Status.Address).NotTo(BeNil())
+		getTestIPAddress(c, claim.Status.Address.Name)
+	})
+
+	It("Forgets the addresses the cache observed", func() {
+		created := newCreatedAddresses()
+		created.add(pool, "192.168.1.10", createdAddress{allocationKey: "first", created: time.Now()})
+		created.add(pool, "192.168.1.11", createdAddress{allocationKey: "second", created: time.Now()})
+		created.add(pool, "192.168.1.12", createdAddress{
+			allocationKey: "third",
+			created:       time.Now().Add(-createdAddressTTL - time.Second),
+		})
+
+		unobserved := created.unobserved(pool, map[ipamv1.IPAddressStr]string{"192.168.1.10": "first"})
+		Expect(unobserved).To(HaveLen(1))
+		Expect(unobserved).To(HaveKey(ipamv1.IPAddressStr("192.168.1.11")))
+		Expect(created.pools[pool]).To(HaveLen(1))
+
+		unobserved = created.unobserved(pool, map[ipamv1.IPAddressStr]string{"192.168.1.11": "second"})
+		Expect(unobserved).To(BeEmpty())
+		Expect(created.pools).To(BeEmpty())
+
+		// The released addresses are forgotten.
+		created.add(pool, "192.168.1.10", createdAddress{allocationKey: "first", created: time.Now()})
+		created.remove(pool, "192.168.1.10")
+		Expect(created.pools).To(BeEmpty())
+	})
+})
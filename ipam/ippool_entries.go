@@ -0,0 +1,83 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"net/netip"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// PoolEntrySelectorAnnotation selects, with a label selector such as
+	// "rack=rack-3", the pool entries an IPAddressClaim gets its address
+	// from.
+	PoolEntrySelectorAnnotation = "ipam.metal3.io/pool-entry-selector"
+	// PoolEntryAnnotation records on a CAPI IPAddress the name of the pool
+	// entry its address was allocated from.
+	PoolEntryAnnotation = "ipam.metal3.io/pool-entry"
+)
+
+// poolEntrySelector returns the selector of the pool entries the claim gets
+// its address from. It selects all the entries when the claim has no pool
+// entry selector.
+func poolEntrySelector(claim client.Object) (labels.Selector, error) {
+	switch claim := claim.(type) {
+	case *ipamv1.IPClaim:
+		if claim.Spec.PoolEntrySelector != nil {
+			return metav1.LabelSelectorAsSelector(claim.Spec.PoolEntrySelector)
+		}
+	case *capipamv1.IPAddressClaim:
+		if selector, ok := claim.Annotations[PoolEntrySelectorAnnotation]; ok {
+			return labels.Parse(selector)
+		}
+	}
+	return labels.Everything(), nil
+}
+
+// poolEntryName returns the name of the first pool entry matching the
+// selector whose range holds the address, or an empty string if that entry is
+// not named.
+func (m *IPPoolManager) poolEntryName(selector labels.Selector, address ipamv1.IPAddressStr) string {
+	addr, err := netip.ParseAddr(string(address))
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	for _, pool := range m.getPools() {
+		if !selector.Matches(labels.Set(pool.Labels)) {
+			continue
+		}
+		first, last, err := ipamv1.GetPoolRange(pool)
+		if err != nil {
+			continue
+		}
+		firstAddr := ipToAddr(first)
+		if addr.BitLen() != firstAddr.BitLen() || addr.Less(firstAddr) {
+			continue
+		}
+		if last != nil && ipToAddr(last).Less(addr) {
+			continue
+		}
+		return pool.Name
+	}
+	return ""
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("IPPool entries", func() {
+	newRackIPPool := func() *ipamv1.IPPool {
+		rack1 := newTestPool("192.168.1.10", "192.168.1.19")
+		rack1.Name = "rack-1"
+		rack1.Labels = map[string]string{"rack": "rack-1"}
+		rack2 := newTestPool("192.168.2.10", "192.168.2.19")
+		rack2.Name = "rack-2"
+		rack2.Labels = map[string]string{"rack": "rack-2"}
+		return newTestIPPool(rack1, rack2)
+	}
+
+	newRackIPClaim := func(name, rack string) *ipamv1.IPClaim {
+		claim := newTestIPClaim(name)
+		if rack != "" {
+			claim.Spec.PoolEntrySelector = &metav1.LabelSelector{MatchLabels: map[string]string{"rack": rack}}
+		}
+		return claim
+	}
+
+	It("Allocates the addresses from the pool entries matching the claims", func() {
+		objects := []client.Object{
+			newRackIPClaim("any", ""),
+			newRackIPClaim("rack-2", "rack-2"),
+			newRackIPClaim("rack-3", "rack-3"),
+		}
+		c := newTestClient(objects...)
+		ipPool := newRackIPPool()
+		_, err := updateTestAddresses(c, ipPool)
+		Expect(err).To(MatchError(errNoPoolEntryMatches))
+
+		ipAddress := getTestIPAddress(c, "abcpref-192-168-1-10")
+		Expect(ipAddress.Spec.Claim.Name).To(Equal("any"))
+		Expect(ipAddress.Spec.PoolEntry).To(Equal("rack-1"))
+		ipAddress = getTestIPAddress(c, "abcpref-192-168-2-10")
+		Expect(ipAddress.Spec.Claim.Name).To(Equal("rack-2"))
+		Expect(ipAddress.Spec.PoolEntry).To(Equal("rack-2"))
+
+		claim := getTestIPClaim(c, "rack-3")
+		Expect(claim.Status.Address).To(BeNil())
+		Expect(claim.Status.ErrorMessage).To(Equal(ptr.To("No pool entry matches the pool entry selector")))
+
+		// The usage of the pool entries shows in the status.
+		Expect(c.Delete(context.TODO(), claim)).To(Succeed())
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(2))
+		Expect(ipPool.Status.Pools).To(HaveLen(2))
+		Expect(ipPool.Status.Pools[0].Name).To(Equal("rack-1"))
+		Expect(ipPool.Status.Pools[0].Allocated).To(Equal(1))
+		Expect(ipPool.Status.Pools[1].Name).To(Equal("rack-2"))
+		Expect(ipPool.Status.Pools[1].Allocated).To(Equal(1))
+	})
+
+	It("Records the pool entry of the CAPI IPAddress", func() {
+		capiClaim := newTestIPAddressClaim("capi-claim")
+		capiClaim.Annotations = map[string]string{PoolEntrySelectorAnnotation: "rack in (rack-2)"}
+		c := newTestClient(capiClaim)
+		_, err := updateTestAddresses(c, newRackIPPool())
+		Expect(err).NotTo(HaveOccurred())
+
+		ipAddress := &capipamv1.IPAddress{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: "abcpref-192-168-2-10", Namespace: "myns"}, ipAddress)).To(Succeed())
+		Expect(ipAddress.Annotations).To(HaveKeyWithValue(PoolEntryAnnotation, "rack-2"))
+	})
+
+	DescribeTable("Test poolEntryName",
+		func(claim client.Object, address ipamv1.IPAddressStr, expectedPoolEntry string) {
+			selector, err := poolEntrySelector(claim)
+			Expect(err).NotTo(HaveOccurred())
+			ipPoolMgr, err := NewIPPoolManager(nil, newRackIPPool(), logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipPoolMgr.poolEntryName(selector, address)).To(Equal(expectedPoolEntry))
+		},
+		Entry("Any pool entry", newRackIPClaim("any", ""), ipamv1.IPAddressStr("192.168.2.15"), "rack-2"),
+		Entry("Out of the pool entries", newRackIPClaim("any", ""), ipamv1.IPAddressStr("192.168.3.15"), ""),
+		Entry("Pool entry not selected", newRackIPClaim("rack-1", "rack-1"), ipamv1.IPAddressStr("192.168.2.15"), ""),
+		Entry("Pool entry selected by annotation", func() client.Object {
+			capiClaim := newTestIPAddressClaim("capi-claim")
+			capiClaim.Annotations = map[string]string{PoolEntrySelectorAnnotation: "rack in (rack-2)"}
+			return capiClaim
+		}(), ipamv1.IPAddressStr("192.168.2.15"), "rack-2"),
+	)
+
+	It("Fails with an invalid pool entry selector annotation", func() {
+		capiClaim := newTestIPAddressClaim("capi-claim")
+		capiClaim.Annotations = map[string]string{PoolEntrySelectorAnnotation: "rack in ("}
+		_, err := poolEntrySelector(capiClaim)
+		Expect(err).To(HaveOccurred())
+	})
+})
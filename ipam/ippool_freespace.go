@@ -0,0 +1,340 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"math/big"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"slices"
+	"sort"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The free addresses of each pool entry are tracked by an addressSpace, built
+// from the addresses in use the first time an address is selected from the
+// entry during a reconciliation. The addresses in use and the excluded ranges
+// are kept as sorted, disjoint and non-adjacent ranges, so that the next free
+// address from any position is found with a binary search instead of scanning
+// the entry address by address. The addressSpaces are dropped whenever an
+// address is released or the allocated subnets change, and rebuilt on the next
+// selection.
+//
+// With the leastRecentlyReleased AllocationStrategy, the released addresses
+// recorded in the status of the IPPool are marked as used in the addressSpace
+// and kept aside ordered by release time, so that the addresses never used are
+// selected first, then the addresses released the longest time ago.
+
+// addressRange is a range of addresses, both bounds included.
+type addressRange struct {
+	first netip.Addr
+	last  netip.Addr
+}
+
+// addressSpace tracks the free addresses of a pool entry.
+type addressSpace struct {
+	first netip.Addr
+	last  netip.Addr
+	// used holds the ranges of addresses in use or excluded, sorted,
+	// disjoint and non-adjacent, within first and last.
+	used []addressRange
+	// released holds the free addresses that were released, from the least
+	// recently released. They are marked as used.
+	released []netip.Addr
+}
+
+// newAddressSpace returns the addressSpace of the pool entry, with its
+// exclusions and the addresses in use marked as used. It returns nil if the
+// range of the pool entry cannot be computed.
+func newAddressSpace(pool ipamv1.Pool, addresses map[ipamv1.IPAddressStr]string) *addressSpace {
+	firstIP, lastIP, err := ipamv1.GetPoolRange(pool)
+	if err != nil {
+		return nil
+	}
+	space := &addressSpace{first: ipToAddr(firstIP)}
+	if lastIP != nil {
+		space.last = ipToAddr(lastIP)
+	} else {
+		space.last = ipToAddr(intToIP(lastIPOfFamily(firstIP)))
+	}
+	if !space.first.IsValid() || !space.last.IsValid() || space.last.Less(space.first) {
+		return nil
+	}
+
+	ranges := make([]addressRange, 0, len(pool.Exclusions)+len(addresses))
+	for _, exclusion := range pool.Exclusions {
+		first, last, err := ipamv1.ParseExclusion(exclusion)
+		if err != nil {
+			continue
+		}
+		ranges = append(ranges, addressRange{first: ipToAddr(first), last: ipToAddr(last)})
+	}
+	for address := range addresses {
+		if addr, err := netip.ParseAddr(string(address)); err == nil {
+			ranges = append(ranges, addressRange{first: addr.Unmap(), last: addr.Unmap()})
+		}
+	}
+	space.used = space.merge(ranges)
+	return space
+}
+
+// merge returns the ranges clipped to the addressSpace, sorted, and merged
+// when they overlap or are adjacent.
+func (s *addressSpace) merge(ranges []addressRange) []addressRange {
+	slices.SortFunc(ranges, func(a, b addressRange) int { return a.first.Compare(b.first) })
+	merged := make([]addressRange, 0, len(ranges))
+	for _, r := range ranges {
+		if r.last.Less(s.first) || s.last.Less(r.first) {
+			continue
+		}
+		if r.first.Less(s.first) {
+			r.first = s.first
+		}
+		if s.last.Less(r.last) {
+			r.last = s.last
+		}
+		if n := len(merged); n > 0 {
+			previous := &merged[n-1]
+			if r.first.Compare(previous.last) <= 0 || r.first == previous.last.Next() {
+				if previous.last.Less(r.last) {
+					previous.last = r.last
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// markUsed marks an address of the addressSpace as used.
+func (s *addressSpace) markUsed(addr netip.Addr) {
+	if addr.Less(s.first) || s.last.Less(addr) {
+		return
+	}
+	i := sort.Search(len(s.used), func(i int) bool { return s.used[i].last.Compare(addr) >= 0 })
+	if i < len(s.used) && s.used[i].first.Compare(addr) <= 0 {
+		return
+	}
+	joinPrevious := i > 0 && s.used[i-1].last.Next() == addr
+	joinNext := i < len(s.used) && addr.Next() == s.used[i].first
+	switch {
+	case joinPrevious && joinNext:
+		s.used[i-1].last = s.used[i].last
+		s.used = slices.Delete(s.used, i, i+1)
+	case joinPrevious:
+		s.used[i-1].last = addr
+	case joinNext:
+		s.used[i].first = addr
+	default:
+		s.used = slices.Insert(s.used, i, addressRange{first: addr, last: addr})
+	}
+}
+
+// nextFree returns the first free address of the addressSpace from the given
+// address, or false if there is none up to the end of the addressSpace.
+func (s *addressSpace) nextFree(from netip.Addr) (netip.Addr, bool) {
+	if from.Less(s.first) {
+		from = s.first
+	}
+	i := sort.Search(len(s.used), func(i int) bool { return s.used[i].last.Compare(from) >= 0 })
+	if i < len(s.used) && s.used[i].first.Compare(from) <= 0 {
+		// The ranges are not adjacent, so the address after a range is
+		// free if it is in the addressSpace.
+		from = s.used[i].last.Next()
+		if !from.IsValid() {
+			return netip.Addr{}, false
+		}
+	}
+	if s.last.Less(from) {
+		return netip.Addr{}, false
+	}
+	return from, true
+}
+
+// firstFree returns the lowest free address of the addressSpace.
+func (s *addressSpace) firstFree() (netip.Addr, bool) {
+	return s.nextFree(s.first)
+}
+
+// reserveReleased marks the free addresses of the addressSpace that were
+// released at the given times as used, and keeps them aside ordered from the
+// least recently released.
+func (s *addressSpace) reserveReleased(releasedAt map[ipamv1.IPAddressStr]metav1.Time) {
+	s.released = nil
+	for address := range releasedAt {
+		addr, err := netip.ParseAddr(string(address))
+		if err != nil {
+			continue
+		}
+		addr = addr.Unmap()
+		if free, ok := s.nextFree(addr); !ok || free != addr {
+			continue
+		}
+		s.released = append(s.released, addr)
+	}
+	slices.SortFunc(s.released, func(a, b netip.Addr) int {
+		timeA := releasedAt[ipamv1.IPAddressStr(a.String())]
+		timeB := releasedAt[ipamv1.IPAddressStr(b.String())]
+		if c := timeA.Compare(timeB.Time); c != 0 {
+			return c
+		}
+		return a.Compare(b)
+	})
+	for _, addr := range s.released {
+		s.markUsed(addr)
+	}
+}
+
+// leastRecentlyReleasedFree returns the lowest free address of the
+// addressSpace that was never released, or else the address released the
+// longest time ago.
+func (s *addressSpace) leastRecentlyReleasedFree() (netip.Addr, bool) {
+	if addr, ok := s.firstFree(); ok {
+		return addr, true
+	}
+	if len(s.released) == 0 {
+		return netip.Addr{}, false
+	}
+	addr := s.released[0]
+	s.released = s.released[1:]
+	return addr, true
+}
+
+// randomFree returns the first free address from a random position of the
+// addressSpace, wrapping around at its end.
+func (s *addressSpace) randomFree() (netip.Addr, bool) {
+	first := addrToInt(s.first)
+	size := new(big.Int).Sub(addrToInt(s.last), first)
+	size.Add(size, big.NewInt(1))
+	from := intToAddr(first.Add(first, randomOffset(size)), s.first.BitLen())
+	if addr, ok := s.nextFree(from); ok {
+		return addr, true
+	}
+	return s.firstFree()
+}
+
+// selectIPFromPool selects a free address in the pool entry at the given
+// index of the pools of the IPPool, following the allocation strategy of the
+// IPPool, and marks it as used.
+func (m *IPPoolManager) selectIPFromPool(index int, pool ipamv1.Pool, addresses map[ipamv1.IPAddressStr]string) (ipamv1.IPAddressStr, bool) {
+	if m.addressSpaces == nil {
+		m.addressSpaces = make(map[int]*addressSpace)
+	}
+	space, ok := m.addressSpaces[index]
+	if !ok {
+		space = newAddressSpace(pool, addresses)
+		if space != nil {
+			space.reserveQuarantined(m.IPPool.Status.QuarantinedAddresses)
+		}
+		if space != nil && m.IPPool.Spec.AllocationStrategy == ipamv1.AllocationStrategyLeastRecentlyReleased {
+			space.reserveReleased(m.IPPool.Status.ReleasedAddresses)
+		}
+		m.addressSpaces[index] = space
+	}
+	if space == nil {
+		return "", false
+	}
+
+	for {
+		var candidate netip.Addr
+		switch m.IPPool.Spec.AllocationStrategy {
+		case ipamv1.AllocationStrategyRandom:
+			candidate, ok = space.randomFree()
+		case ipamv1.AllocationStrategyLeastRecentlyReleased:
+			candidate, ok = space.leastRecentlyReleasedFree()
+		default:
+			candidate, ok = space.firstFree()
+		}
+		if !ok {
+			return "", false
+		}
+		space.markUsed(candidate)
+		// The pre-allocated and requested addresses allocated since the
+		// addressSpace was built are skipped.
+		address := ipamv1.IPAddressStr(candidate.String())
+		if _, ok := addresses[address]; !ok {
+			delete(m.IPPool.Status.ReleasedAddresses, address)
+			return address, true
+		}
+	}
+}
+
+// recordReleasedAddress records the time at which an address was released,
+// with the leastRecentlyReleased AllocationStrategy. The allocated subnets are
+// not recorded.
+func (m *IPPoolManager) recordReleasedAddress(address ipamv1.IPAddressStr) {
+	if m.IPPool.Spec.AllocationStrategy != ipamv1.AllocationStrategyLeastRecentlyReleased {
+		return
+	}
+	if _, ok := m.subnets[address]; ok {
+		return
+	}
+	if m.IPPool.Status.ReleasedAddresses == nil {
+		m.IPPool.Status.ReleasedAddresses = make(map[ipamv1.IPAddressStr]metav1.Time)
+	}
+	m.IPPool.Status.ReleasedAddresses[address] = metav1.Now()
+}
+
+// pruneReleasedAddresses drops the release times of the addresses in use, and
+// all release times if the IPPool does not use the leastRecentlyReleased
+// AllocationStrategy.
+func (m *IPPoolManager) pruneReleasedAddresses(addresses map[ipamv1.IPAddressStr]string) {
+	if m.IPPool.Spec.AllocationStrategy != ipamv1.AllocationStrategyLeastRecentlyReleased {
+		m.IPPool.Status.ReleasedAddresses = nil
+		return
+	}
+	for address := range m.IPPool.Status.ReleasedAddresses {
+		if _, ok := addresses[address]; ok {
+			delete(m.IPPool.Status.ReleasedAddresses, address)
+		}
+	}
+}
+
+// ipToAddr converts an IP address to a netip.Addr, with IPv4 addresses in
+// their 4-byte form.
+func ipToAddr(ip net.IP) netip.Addr {
+	addr, _ := netip.AddrFromSlice(ip)
+	return addr.Unmap()
+}
+
+// addrToInt converts an address to an integer.
+func addrToInt(addr netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(addr.AsSlice())
+}
+
+// intToAddr converts an integer computed by addrToInt back to an address of
+// the given bit length.
+func intToAddr(value *big.Int, bitLen int) netip.Addr {
+	addr, _ := netip.AddrFromSlice(value.FillBytes(make([]byte, bitLen/8)))
+	return addr
+}
+
+// randomOffset returns a random offset lower than size, which must be
+// positive and hold on 128 bits.
+func randomOffset(size *big.Int) *big.Int {
+	if size.IsUint64() {
+		return new(big.Int).SetUint64(rand.Uint64N(size.Uint64())) //nolint:gosec // cryptographic randomness not needed for IP allocation
+	}
+	offset := new(big.Int).SetUint64(rand.Uint64()) //nolint:gosec // cryptographic randomness not needed for IP allocation
+	offset.Lsh(offset, 64)
+	offset.Or(offset, new(big.Int).SetUint64(rand.Uint64())) //nolint:gosec // cryptographic randomness not needed for IP allocation
+	return offset.Mod(offset, size)
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"math/big"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+var _ = Describe("Address space", func() {
+	usedRanges := func(space *addressSpace) []string {
+		ranges := []string{}
+		for _, r := range space.used {
+			ranges = append(ranges, r.first.String()+"-"+r.last.String())
+		}
+		return ranges
+	}
+
+	It("Merges the exclusions and the addresses in use", func() {
+		space := newAddressSpace(newTestPool("192.168.0.10", "192.168.0.30", "192.168.0.1-192.168.0.12", "192.168.0.20/30"),
+			map[ipamv1.IPAddressStr]string{
+				"192.168.0.13": "a",
+				"192.168.0.16": "",
+				"192.168.0.24": "b",
+				"10.0.0.1":     "c",
+			})
+		Expect(space).NotTo(BeNil())
+		Expect(usedRanges(space)).To(Equal([]string{
+			"192.168.0.13-192.168.0.13",
+			"192.168.0.16-192.168.0.16",
+			"192.168.0.20-192.168.0.24",
+		}))
+
+		addr, ok := space.firstFree()
+		Expect(ok).To(BeTrue())
+		Expect(addr.String()).To(Equal("192.168.0.14"))
+		addr, ok = space.nextFree(netip.MustParseAddr("192.168.0.20"))
+		Expect(ok).To(BeTrue())
+		Expect(addr.String()).To(Equal("192.168.0.25"))
+	})
+
+	It("Merges the addresses marked as used with their neighbours", func() {
+		space := newAddressSpace(newTestPool("2001:db8::1", "2001:db8::8"), map[ipamv1.IPAddressStr]string{
+			"2001:db8::2": "a",
+			"2001:db8::4": "b",
+		})
+		space.markUsed(netip.MustParseAddr("2001:db8::3"))
+		Expect(usedRanges(space)).To(Equal([]string{"2001:db8::2-2001:db8::4"}))
+		space.markUsed(netip.MustParseAddr("2001:db8::1"))
+		space.markUsed(netip.MustParseAddr("2001:db8::8"))
+		space.markUsed(netip.MustParseAddr("2001:db8::6"))
+		Expect(usedRanges(space)).To(Equal([]string{
+			"2001:db8::1-2001:db8::4",
+			"2001:db8::6-2001:db8::6",
+			"2001:db8::8-2001:db8::8",
+		}))
+
+		_, ok := space.nextFree(netip.MustParseAddr("2001:db8::7"))
+		Expect(ok).To(BeTrue())
+		space.markUsed(netip.MustParseAddr("2001:db8::5"))
+		space.markUsed(netip.MustParseAddr("2001:db8::7"))
+		Expect(usedRanges(space)).To(Equal([]string{"2001:db8::1-2001:db8::8"}))
+		_, ok = space.firstFree()
+		Expect(ok).To(BeFalse())
+		_, ok = space.randomFree()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Wraps around to find a random free address", func() {
+		space := newAddressSpace(newTestPool("192.168.0.10", "192.168.0.14"), map[ipamv1.IPAddressStr]string{
+			"192.168.0.11": "a",
+			"192.168.0.12": "b",
+			"192.168.0.13": "c",
+			"192.168.0.14": "d",
+		})
+		for range 10 {
+			addr, ok := space.randomFree()
+			Expect(ok).To(BeTrue())
+			Expect(addr.String()).To(Equal("192.168.0.10"))
+		}
+	})
+
+	It("Skips the addresses allocated since the address space was built", func() {
+		ipPool := &ipamv1.IPPool{
+			Spec: ipamv1.IPPoolSpec{
+				Pools: []ipamv1.Pool{newTestPool("192.168.0.10", "192.168.0.20")},
+			},
+		}
+		ipPoolMgr, err := NewIPPoolManager(nil, ipPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		addresses := map[ipamv1.IPAddressStr]string{}
+
+		address, ok := ipPoolMgr.selectIPFromPool(0, ipPool.Spec.Pools[0], addresses)
+		Expect(ok).To(BeTrue())
+		Expect(address).To(Equal(ipamv1.IPAddressStr("192.168.0.10")))
+		addresses[address] = "a"
+		addresses["192.168.0.11"] = "b"
+		address, ok = ipPoolMgr.selectIPFromPool(0, ipPool.Spec.Pools[0], addresses)
+		Expect(ok).To(BeTrue())
+		Expect(address).To(Equal(ipamv1.IPAddressStr("192.168.0.12")))
+	})
+
+	It("Selects the addresses never used before the least recently released ones", func() {
+		space := newAddressSpace(newTestPool("192.168.0.10", "192.168.0.14"), map[ipamv1.IPAddressStr]string{
+			"192.168.0.11": "a",
+		})
+		now := time.Now()
+		space.reserveReleased(map[ipamv1.IPAddressStr]metav1.Time{
+			"192.168.0.10": metav1.NewTime(now),
+			"192.168.0.11": metav1.NewTime(now.Add(-3 * time.Hour)),
+			"192.168.0.12": metav1.NewTime(now.Add(-time.Hour)),
+			"192.168.0.13": metav1.NewTime(now.Add(-2 * time.Hour)),
+		})
+
+		selected := []string{}
+		for {
+			addr, ok := space.leastRecentlyReleasedFree()
+			if !ok {
+				break
+			}
+			space.markUsed(addr)
+			selected = append(selected, addr.String())
+		}
+		Expect(selected).To(Equal([]string{
+			"192.168.0.14",
+			"192.168.0.13",
+			"192.168.0.12",
+			"192.168.0.10",
+		}))
+	})
+
+	It("Records the release time of the addresses", func() {
+		ipPool := &ipamv1.IPPool{
+			Spec: ipamv1.IPPoolSpec{
+				AllocationStrategy: ipamv1.AllocationStrategyLeastRecentlyReleased,
+				Pools:              []ipamv1.Pool{newTestPool("192.168.0.10", "192.168.0.11")},
+			},
+		}
+		ipPoolMgr, err := NewIPPoolManager(nil, ipPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		addresses := map[ipamv1.IPAddressStr]string{"192.168.0.11": "a"}
+
+		ipPoolMgr.recordReleasedAddress("192.168.0.10")
+		Expect(ipPool.Status.ReleasedAddresses).To(HaveKey(ipamv1.IPAddressStr("192.168.0.10")))
+		ipPool.Status.ReleasedAddresses["192.168.0.11"] = metav1.Now()
+		ipPoolMgr.pruneReleasedAddresses(addresses)
+		Expect(ipPool.Status.ReleasedAddresses).NotTo(HaveKey(ipamv1.IPAddressStr("192.168.0.11")))
+
+		address, ok := ipPoolMgr.selectIPFromPool(0, ipPool.Spec.Pools[0], addresses)
+		Expect(ok).To(BeTrue())
+		Expect(address).To(Equal(ipamv1.IPAddressStr("192.168.0.10")))
+		Expect(ipPool.Status.ReleasedAddresses).To(BeEmpty())
+
+		ipPool.Spec.AllocationStrategy = ipamv1.AllocationStrategySequential
+		ipPoolMgr.recordReleasedAddress("192.168.0.10")
+		Expect(ipPool.Status.ReleasedAddresses).To(BeEmpty())
+	})
+
+	It("Selects random addresses in large IPv6 pools", func() {
+		space := newAddressSpace(ipamv1.Pool{Subnet: (*ipamv1.IPSubnetStr)(ptr.To("2001:db8::/32"))}, nil)
+		Expect(space).NotTo(BeNil())
+		addr, ok := space.randomFree()
+		Expect(ok).To(BeTrue())
+		Expect(netip.MustParsePrefix("2001:db8::/32").Contains(addr)).To(BeTrue())
+		Expect(randomOffset(new(big.Int).Lsh(big.NewInt(1), 100)).BitLen()).To(BeNumerically("<=", 100))
+	})
+})
+
+// benchmarkPool returns a /16 pool entry and the addresses in use of a nearly
+// full pool: all its addresses but the last one.
+func benchmarkPool(b *testing.B) (ipamv1.Pool, map[ipamv1.IPAddressStr]string) {
+	b.Helper()
+	pool := ipamv1.Pool{Subnet: (*ipamv1.IPSubnetStr)(ptr.To("10.0.0.0/16"))}
+	addresses := make(map[ipamv1.IPAddressStr]string)
+	for index := 0; ; index++ {
+		address, err := ipamv1.GetIPAddress(pool, index)
+		if err != nil {
+			b.Fatalf("failed to get address %d: %v", index, err)
+		}
+		if address == "10.0.255.255" {
+			break
+		}
+		addresses[address] = "claim"
+	}
+	return pool, addresses
+}
+
+// BenchmarkLinearScan measures the selection of the free address of a nearly
+// full /16 pool by scanning the pool entry, as done before the addressSpace.
+func BenchmarkLinearScan(b *testing.B) {
+	pool, addresses := benchmarkPool(b)
+	for b.Loop() {
+		for index := 0; ; index++ {
+			candidate, err := ipamv1.GetIPAddress(pool, index)
+			if err != nil {
+				b.Fatal("no free address found")
+			}
+			if _, ok := addresses[candidate]; !ok {
+				break
+			}
+		}
+	}
+}
+
+// BenchmarkAddressSpace measures the selection of the free address of a
+// nearly full /16 pool with an addressSpace, and the build of the
+// addressSpace once per reconciliation.
+func BenchmarkAddressSpace(b *testing.B) {
+	pool, addresses := benchmarkPool(b)
+	space := newAddressSpace(pool, addresses)
+
+	b.Run("Build", func(b *testing.B) {
+		for b.Loop() {
+			newAddressSpace(pool, addresses)
+		}
+	})
+	b.Run("FirstFree", func(b *testing.B) {
+		for b.Loop() {
+			if _, ok := space.firstFree(); !ok {
+				b.Fatal("no free address found")
+			}
+		}
+	})
+	b.Run("RandomFree", func(b *testing.B) {
+		for b.Loop() {
+			if _, ok := space.randomFree(); !ok {
+				b.Fatal("no free address found")
+			}
+		}
+	})
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// grantedNamespaces returns the namespaces whose IPClaims are allowed to
+// consume the IPPool by the IPPoolGrants of the namespace of the IPPool.
+func (m *IPPoolManager) grantedNamespaces(ctx context.Context) (map[string]bool, error) {
+	// GlobalIPPools are not granted, they use a namespace selector.
+	if m.global {
+		return map[string]bool{}, nil
+	}
+
+	grants := ipamv1.IPPoolGrantList{}
+	// without this ListOption, all namespaces would be including in the listing
+	opts := &client.ListOptions{
+		Namespace: m.IPPool.Namespace,
+	}
+	if err := m.client.List(ctx, &grants, opts); err != nil {
+		return nil, err
+	}
+
+	granted := make(map[string]bool)
+	for _, grant := range grants.Items {
+		if grant.Spec.Pool != m.IPPool.Name {
+			continue
+		}
+		for _, namespace := range grant.Spec.Namespaces {
+			if namespace != m.IPPool.Namespace {
+				granted[namespace] = true
+			}
+		}
+	}
+	return granted, nil
+}
+
+// claimNamespaces returns the namespaces in which the IPClaims and IPAddresses
+// of the IPPool live: the namespace of the IPPool, the granted namespaces, and
+// the namespaces that still hold allocations after their grant was revoked, so
+// that those allocations can be released. It also returns the granted
+// namespaces. The claims of a GlobalIPPool are in all the namespaces.
+func (m *IPPoolManager) claimNamespaces(ctx context.Context) ([]string, map[string]bool, error) {
+	if m.global {
+		return []string{metav1.NamespaceAll}, map[string]bool{}, nil
+	}
+
+	granted, err := m.grantedNamespaces(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	namespaces := []string{}
+	for namespace := range granted {
+		namespaces = append(namespaces, namespace)
+	}
+	for key := range m.allocations {
+		if namespace, _, ok := strings.Cut(key, "/"); ok && !granted[namespace] {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	slices.Sort(namespaces)
+	namespaces = slices.Compact(namespaces)
+	return append([]string{m.IPPool.Namespace}, namespaces...), granted, nil
+}
+
+// canClaim returns true if the claims of the namespace can get an address
+// from the IPPool: the namespace of the IPPool and the granted namespaces, or
+// the namespaces matching the namespace selector of a GlobalIPPool.
+func (m *IPPoolManager) canClaim(ctx context.Context, namespace string, granted map[string]bool) (bool, error) {
+	if m.global {
+		return m.matchesNamespaceSelector(ctx, namespace)
+	}
+	return namespace == m.IPPool.Namespace || granted[namespace], nil
+}
+
+// isCrossNamespace returns true if the claim is not in the namespace of the
+// IPPool.
+func (m *IPPoolManager) isCrossNamespace(claim client.Object) bool {
+	return claim.GetNamespace() != "" && claim.GetNamespace() != m.IPPool.Namespace
+}
+
+// claimNamespace returns the namespace of the claim, in which its IPAddress
+// is created.
+func (m *IPPoolManager) claimNamespace(claim client.Object) string {
+	if m.isCrossNamespace(claim) {
+		return claim.GetNamespace()
+	}
+	return m.IPPool.Namespace
+}
+
+// allocationKey returns the key of the claim in the allocations and the
+// pre-allocations of the IPPool. It is the name of the claim, prefixed with
+// its namespace if it is not in the namespace of the IPPool.
+func (m *IPPoolManager) allocationKey(claim client.Object) string {
+	if m.isCrossNamespace(claim) {
+		return crossNamespaceKey(claim.GetNamespace(), claim.GetName())
+	}
+	return claim.GetName()
+}
+
+// crossNamespaceKey returns the allocation key of a claim that is not in the
+// namespace of the IPPool.
+func crossNamespaceKey(namespace, name string) string {
+	return namespace + "/" + name
+}
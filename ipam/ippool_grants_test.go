@@ -0,0 +1,203 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("IPPool grants", func() {
+	newGrantedIPPool := func() *ipamv1.IPPool {
+		return newTestIPPool(newTestPool("192.168.1.10", "192.168.1.20"))
+	}
+
+	newTenantIPClaim := func(namespace string) *ipamv1.IPClaim {
+		claim := newTestIPClaim("claim")
+		claim.Namespace = namespace
+		return claim
+	}
+
+	grant := &ipamv1.IPPoolGrant{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tenant",
+			Namespace: "myns",
+		},
+		Spec: ipamv1.IPPoolGrantSpec{
+			Pool:       "abc",
+			Namespaces: []string{"tenant"},
+		},
+	}
+
+	It("Serves the IPClaims of the granted namespaces only", func() {
+		ownClaim := newTenantIPClaim("myns")
+		ownClaim.Spec.Pool.Namespace = ""
+		c := newTestClient(
+			grant.DeepCopy(),
+			newTenantIPClaim("tenant"),
+			newTenantIPClaim("other"),
+			ownClaim,
+		)
+		ipPool := newGrantedIPPool()
+
+		nbAllocations, err := updateTestAddresses(c, ipPool)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nbAllocations).To(Equal(2))
+		Expect(ipPool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{
+			"claim":        "192.168.1.10",
+			"tenant/claim": "192.168.1.11",
+		}))
+
+		// The IPAddress is created in the namespace of the IPClaim, and is
+		// only owned by the IPClaim.
+		claim := &ipamv1.IPClaim{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: "claim", Namespace: "tenant"}, claim)).To(Succeed())
+		Expect(claim.Status.Address).To(Equal(&corev1.ObjectReference{Name: "abcpref-192-168-1-11", Namespace: "tenant"}))
+		address := &ipamv1.IPAddress{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: "abcpref-192-168-1-11", Namespace: "tenant"}, address)).To(Succeed())
+		Expect(address.Spec.Pool).To(Equal(corev1.ObjectReference{Name: "abc", Namespace: "myns"}))
+		Expect(address.Spec.Claim).To(Equal(corev1.ObjectReference{Name: "claim", Namespace: "tenant"}))
+		Expect(address.OwnerReferences).To(HaveLen(1))
+		Expect(address.OwnerReferences[0].Name).To(Equal("claim"))
+
+		claim = &ipamv1.IPClaim{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: "claim", Namespace: "other"}, claim)).To(Succeed())
+		Expect(claim.Status.Address).To(BeNil())
+		Expect(claim.Finalizers).To(BeEmpty())
+
+		// The allocations are found again on the next reconciliation.
+		ipPool.Status.Allocations = nil
+		nbAllocations, err = updateTestAddresses(c, ipPool)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nbAllocations).To(Equal(2))
+		Expect(ipPool.Status.Allocations).To(HaveKeyWithValue("tenant/claim", ipamv1.IPAddressStr("192.168.1.11")))
+	})
+
+	type testCaseGrantRemoved struct {
+		allocationStorage   ipamv1.AllocationStorage
+		allocations         map[string]ipamv1.IPAddressStr
+		expectedAllocations map[string]ipamv1.IPAddressStr
+	}
+
+	DescribeTable("Releases the address of an IPClaim after the grant is removed",
+		func(tc testCaseGrantRemoved) {
+			claim := newTenantIPClaim("tenant")
+			claim.Finalizers = []string{ipamv1.IPClaimFinalizer}
+			claim.Status.Address = &corev1.ObjectReference{Name: "abcpref-192-168-1-10", Namespace: "tenant"}
+			address := &ipamv1.IPAddress{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "abcpref-192-168-1-10",
+					Namespace:  "tenant",
+					Finalizers: []string{ipamv1.IPAddressFinalizer},
+				},
+				Spec: ipamv1.IPAddressSpec{
+					Pool:    corev1.ObjectReference{Name: "abc", Namespace: "myns"},
+					Claim:   corev1.ObjectReference{Name: "claim", Namespace: "tenant"},
+					Address: "192.168.1.10",
+				},
+			}
+			c := newTestClient(claim, address)
+			ipPool := newGrantedIPPool()
+			ipPool.Spec.AllocationStorage = tc.allocationStorage
+			ipPool.Status.Allocations = tc.allocations
+
+			// Without a grant, the allocation is kept while the IPClaim
+			// exists: the namespace of the IPClaim is found from the
+			// allocations or from its IPAddress.
+			nbAllocations, err := updateTestAddresses(c, ipPool)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nbAllocations).To(Equal(1))
+			Expect(ipPool.Status.Allocations).To(Equal(tc.expectedAllocations))
+
+			Expect(c.Delete(context.TODO(), claim)).To(Succeed())
+			nbAllocations, err = updateTestAddresses(c, ipPool)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(nbAllocations).To(Equal(0))
+			Expect(ipPool.Status.Allocations).To(BeEmpty())
+
+			err = c.Get(context.TODO(), client.ObjectKey{Name: "abcpref-192-168-1-10", Namespace: "tenant"}, &ipamv1.IPAddress{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			err = c.Get(context.TODO(), client.ObjectKey{Name: "claim", Namespace: "tenant"}, &ipamv1.IPClaim{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		},
+		Entry("Allocations in the status", testCaseGrantRemoved{
+			allocations: map[string]ipamv1.IPAddressStr{
+				"tenant/claim": "192.168.1.10",
+			},
+			expectedAllocations: map[string]ipamv1.IPAddressStr{
+				"tenant/claim": "192.168.1.10",
+			},
+		}),
+		Entry("Allocations in the IPAddresses", testCaseGrantRemoved{
+			allocationStorage: ipamv1.AllocationStorageIPAddresses,
+		}),
+	)
+
+	type testCaseSameNamedPools struct {
+		claimNamespace string
+		poolNamespace  string
+		expectedPool   string
+	}
+
+	DescribeTable("Serves an IPClaim from the referenced IPPool of the same-named IPPools",
+		func(tc testCaseSameNamedPools) {
+			claim := newTenantIPClaim(tc.claimNamespace)
+			claim.Spec.Pool.Namespace = tc.poolNamespace
+			tenantGrant := grant.DeepCopy()
+			tenantGrant.Namespace = "tenant"
+			tenantGrant.Spec.Namespaces = []string{"myns"}
+			c := newTestClient(grant.DeepCopy(), tenantGrant, claim)
+
+			for _, namespace := range []string{"myns", "tenant"} {
+				ipPool := newGrantedIPPool()
+				ipPool.Namespace = namespace
+				nbAllocations, err := updateTestAddresses(c, ipPool)
+				Expect(err).NotTo(HaveOccurred())
+				if namespace == tc.expectedPool {
+					Expect(nbAllocations).To(Equal(1), namespace)
+				} else {
+					Expect(nbAllocations).To(Equal(0), namespace)
+				}
+			}
+		},
+		Entry("Claim of the namespace of the IPPool", testCaseSameNamedPools{
+			claimNamespace: "myns",
+			expectedPool:   "myns",
+		}),
+		Entry("Claim of the namespace of the IPPool, referencing the other IPPool", testCaseSameNamedPools{
+			claimNamespace: "myns",
+			poolNamespace:  "tenant",
+			expectedPool:   "tenant",
+		}),
+		Entry("Claim of the other namespace", testCaseSameNamedPools{
+			claimNamespace: "tenant",
+			expectedPool:   "tenant",
+		}),
+		Entry("Claim of the other namespace, referencing the IPPool", testCaseSameNamedPools{
+			claimNamespace: "tenant",
+			poolNamespace:  "myns",
+			expectedPool:   "myns",
+		}),
+	)
+})
@@ -20,16 +20,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"math/rand/v2"
 	"net"
-	"reflect"
+	"net/netip"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
 	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
@@ -44,7 +47,10 @@ var (
 const (
 	IPAddressClaimFinalizer = "ipam.metal3.io/ipaddressclaim"
 	IPAddressFinalizer      = "ipam.metal3.io/ipaddress"
-	IPAddressAnnotation     = "ipAddress"
+	// IPAddressAnnotation requests a specific IP address for a CAPI
+	// IPAddressClaim. It is a deprecated alias of the RequestedAddress of an
+	// IPClaim.
+	IPAddressAnnotation = ipamv1.RequestedAddressAnnotation
 )
 
 // IPPoolManagerInterface is an interface for a IPPoolManager.
@@ -57,18 +63,56 @@ type IPPoolManagerInterface interface {
 
 // IPPoolManager is responsible for performing machine reconciliation.
 type IPPoolManager struct {
-	client client.Client
-	IPPool *ipamv1.IPPool
-	Log    logr.Logger
+	client   client.Client
+	recorder record.EventRecorder
+	IPPool   *ipamv1.IPPool
+	Log      logr.Logger
+
+	// global is set when the IPPool is a view of a GlobalIPPool.
+	global bool
+	// namespaceSelector selects the namespaces allowed to claim from a
+	// GlobalIPPool.
+	namespaceSelector labels.Selector
+	// allocations holds the addresses allocated by the IPPool, by allocation
+	// key. It is the allocations map of the status when the IPPool records
+	// its allocations in its status.
+	allocations map[string]ipamv1.IPAddressStr
+	// subnets holds the subnets allocated to IPClaims with a prefix length,
+	// by network address.
+	subnets map[ipamv1.IPAddressStr]*net.IPNet
+	// reservations holds the addresses reserved for deleted claims, by
+	// allocation key.
+	reservations map[string]ipamv1.AddressReservation
+	// expiredReservations holds the IPAddresses whose reservation ended,
+	// found by getIndexes and released in the write phase.
+	expiredReservations []expiredReservation
+	// addressSpaces tracks the free addresses of the pool entries, by index
+	// in the pools returned by getPools.
+	addressSpaces map[int]*addressSpace
+	// quotaUsage holds the number of allocations of each group of claims, by
+	// index in the quotas of the IPPool.
+	quotaUsage []map[string]int
+	// retries holds the retries of the failed claims, loaded from the
+	// status, and failedClaims the failed claims examined.
+	retries      map[corev1.ObjectReference]*ipamv1.ClaimRetry
+	failedClaims map[corev1.ObjectReference]bool
+	// created tracks the addresses allocated by the process that the cache
+	// may not have observed yet.
+	created *createdAddresses
 }
 
 // NewIPPoolManager returns a new helper for managing a ipPool object.
 func NewIPPoolManager(client client.Client, ipPool *ipamv1.IPPool, ipPoolLog logr.Logger) (*IPPoolManager, error) {
-	return &IPPoolManager{
+	m := &IPPoolManager{
 		client: client,
 		IPPool: ipPool,
 		Log:    ipPoolLog,
-	}, nil
+	}
+	if ipPool != nil {
+		m.allocations = ipPool.Status.Allocations
+		m.loadRetries()
+	}
+	return m, nil
 }
 
 // SetFinalizer sets finalizer.
@@ -87,6 +131,7 @@ func (m *IPPoolManager) UnsetFinalizer() {
 	m.IPPool.Finalizers = Filter(m.IPPool.Finalizers,
 		ipamv1.IPPoolFinalizer,
 	)
+	m.deletePoolMetrics()
 }
 
 // ipEqual compares two IP addresses by their parsed values to handle non-canonical
@@ -138,12 +183,14 @@ func (m *IPPoolManager) getIndexes(ctx context.Context) (map[ipamv1.IPAddressStr
 	m.Log.Info("Fetching IPAddress objects")
 
 	// start from empty maps
-	if m.IPPool.Status.Allocations == nil {
-		m.IPPool.Status.Allocations = make(map[string]ipamv1.IPAddressStr)
-	}
 	updatedAllocations := make(map[string]ipamv1.IPAddressStr)
 
 	addresses := make(map[ipamv1.IPAddressStr]string)
+	m.subnets = make(map[ipamv1.IPAddressStr]*net.IPNet)
+	m.reservations = make(map[string]ipamv1.AddressReservation)
+	m.expiredReservations = nil
+	m.addressSpaces = nil
+	m.quotaUsage = nil
 
 	// After addresses map is populated, we consider that there are still addresses in use.
 	// However, when IPPool.Spec.PreAllocations is given, it can still hold addresses even
@@ -155,40 +202,70 @@ func (m *IPPoolManager) getIndexes(ctx context.Context) (map[ipamv1.IPAddressStr
 		}
 	}
 
-	// get list of IPAddress objects
-	addressObjects := ipamv1.IPAddressList{}
-	// without this ListOption, all namespaces would be including in the listing
-	opts := &client.ListOptions{
-		Namespace: m.IPPool.Namespace,
-	}
-
-	err := m.client.List(ctx, &addressObjects, opts)
+	namespaces, err := m.addressNamespaces(ctx)
 	if err != nil {
 		return addresses, err
 	}
 
-	// Iterate over the IPAddress objects to find all addresses and objects
-	for _, addressObject := range addressObjects.Items {
-		// If IPPool does not point to this object, discard
-		if addressObject.Spec.Pool.Name == "" {
-			continue
+	// get list of IPAddress objects, the IPAddresses of the IPClaims of other
+	// namespaces are in the namespace of their IPClaim.
+	for _, namespace := range namespaces {
+		addressObjects := ipamv1.IPAddressList{}
+		// without this ListOption, all namespaces would be including in the listing
+		opts := &client.ListOptions{
+			Namespace: namespace,
 		}
-		if addressObject.Spec.Pool.Name != m.IPPool.Name {
-			continue
+
+		err = m.client.List(ctx, &addressObjects, opts)
+		if err != nil {
+			return addresses, err
 		}
 
-		// Get the claim Name, if unset use empty string, to still record the
-		// index being used, to avoid conflicts
-		claimName := ""
-		if addressObject.Spec.Claim.Name != "" {
-			claimName = addressObject.Spec.Claim.Name
+		// Iterate over the IPAddress objects to find all addresses and objects
+		for _, addressObject := range addressObjects.Items {
+			// If IPPool does not point to this object, discard
+			if !m.isPoolReference(addressObject.Spec.Pool, addressObject.Namespace) {
+				continue
+			}
+
+			// Get the claim Name, if unset use empty string, to still record the
+			// index being used, to avoid conflicts
+			claimName := ""
+			if addressObject.Spec.Claim.Name != "" {
+				claimName = addressObject.Spec.Claim.Name
+			}
+			if isChildPoolAddress(&addressObject) {
+				claimName = childPoolKey(claimName)
+			}
+			if addressObject.Namespace != m.IPPool.Namespace {
+				claimName = crossNamespaceKey(addressObject.Namespace, claimName)
+			}
+			reserved := m.checkReservation(&addressObject, ipamv1.IPAddressFinalizer, claimName,
+				corev1.ObjectReference{
+					Kind:      claimKindIPClaim,
+					Name:      addressObject.Spec.Claim.Name,
+					Namespace: addressObject.Namespace,
+				}, addressObject.Spec.Address)
+			if !reserved {
+				continue
+			}
+			updatedAllocations[claimName] = addressObject.Spec.Address
+			addresses[addressObject.Spec.Address] = claimName
+			if addressObject.Spec.Subnet != nil {
+				m.addSubnet(addressObject.Spec.Address, *addressObject.Spec.Subnet)
+			}
+			if !isChildPoolAddress(&addressObject) {
+				m.updateQuotaUsage(addressObject.Namespace, addressObject.Labels, 1)
+			}
 		}
-		updatedAllocations[claimName] = addressObject.Spec.Address
-		addresses[addressObject.Spec.Address] = claimName
 	}
 
 	// get list of IPAddress objects for cluster.x-k8s.io addresses
 	capiAddressObjects := capipamv1.IPAddressList{}
+	// without this ListOption, all namespaces would be including in the listing
+	opts := &client.ListOptions{
+		Namespace: m.IPPool.Namespace,
+	}
 	err = m.client.List(ctx, &capiAddressObjects, opts)
 	if err != nil {
 		return addresses, err
@@ -197,10 +274,7 @@ func (m *IPPoolManager) getIndexes(ctx context.Context) (map[ipamv1.IPAddressStr
 	// Iterate over the IPAddress objects to find all addresses and objects
 	for _, addressObject := range capiAddressObjects.Items {
 		// If IPPool does not point to this object, discard
-		if addressObject.Spec.PoolRef.Name == "" {
-			continue
-		}
-		if addressObject.Spec.PoolRef.Name != m.IPPool.Name {
+		if !m.isCAPIPoolReference(addressObject.Spec.PoolRef) {
 			continue
 		}
 
@@ -210,14 +284,27 @@ func (m *IPPoolManager) getIndexes(ctx context.Context) (map[ipamv1.IPAddressStr
 		if addressObject.Spec.ClaimRef.Name != "" {
 			claimName = addressObject.Spec.ClaimRef.Name
 		}
+		if addressObject.Namespace != m.IPPool.Namespace {
+			claimName = crossNamespaceKey(addressObject.Namespace, claimName)
+		}
+		reserved := m.checkReservation(&addressObject, IPAddressFinalizer, claimName,
+			corev1.ObjectReference{
+				Kind:      claimKindIPAddressClaim,
+				Name:      addressObject.Spec.ClaimRef.Name,
+				Namespace: addressObject.Namespace,
+			}, ipamv1.IPAddressStr(addressObject.Spec.Address))
+		if !reserved {
+			continue
+		}
 		updatedAllocations[claimName] = ipamv1.IPAddressStr(addressObject.Spec.Address)
 		addresses[ipamv1.IPAddressStr(addressObject.Spec.Address)] = claimName
+		m.updateQuotaUsage(addressObject.Namespace, addressObject.Labels, 1)
 	}
 
-	if !reflect.DeepEqual(updatedAllocations, m.IPPool.Status.Allocations) {
-		m.IPPool.Status.Allocations = updatedAllocations
-		m.updateStatusTimestamp()
-	}
+	m.addCreatedAddresses(addresses, updatedAllocations)
+	m.setAllocations(updatedAllocations)
+	m.pruneReleasedAddresses(addresses)
+	m.pruneQuarantinedAddresses(addresses)
 
 	return addresses, nil
 }
@@ -227,6 +314,28 @@ func (m *IPPoolManager) updateStatusTimestamp() {
 	m.IPPool.Status.LastUpdated = &now
 }
 
+// RequeueAfter returns the time after which the IPPool must be reconciled
+// again for its first quarantine or reservation to end, or zero if none ends.
+func RequeueAfter(status ipamv1.IPPoolStatus) time.Duration {
+	requeueAfter := quarantineRequeueAfter(status)
+	for _, after := range []time.Duration{reservationRequeueAfter(status), retryRequeueAfter(status)} {
+		if after != 0 && (requeueAfter == 0 || after < requeueAfter) {
+			requeueAfter = after
+		}
+	}
+	return requeueAfter
+}
+
+// boundAddressTimes keeps the release times and the quarantines of the
+// IPPool status within ipamv1.MaxStatusEntries. The oldest releases are
+// forgotten, and the quarantines ending first end early.
+func (m *IPPoolManager) boundAddressTimes() {
+	dropEarliest(m.IPPool.Status.ReleasedAddresses, ipamv1.MaxStatusEntries)
+	if dropEarliest(m.IPPool.Status.QuarantinedAddresses, ipamv1.MaxStatusEntries) > 0 {
+		m.triggerRetries()
+	}
+}
+
 // UpdateAddresses manages the claims and creates or deletes IPAddress accordingly.
 // It returns the number of current allocations. Current allocation include
 // both capi and metal3 type ipaddress objects.
@@ -234,67 +343,180 @@ func (m *IPPoolManager) UpdateAddresses(ctx context.Context) (int, error) {
 	if m.IPPool.Status.LastUpdated == nil {
 		m.IPPool.Status.LastUpdated = m.IPPool.CreationTimestamp.DeepCopy()
 	}
-	_, err := m.m3UpdateAddresses(ctx)
+	err := m.updateParentSubnet(ctx)
 	if err != nil {
+		m.updateConditions(err)
 		return 0, err
 	}
-	count, err := m.capiUpdateAddresses(ctx)
-	if err != nil {
+	addresses, err := m.updateClaimAddresses(ctx)
+	m.boundAddressTimes()
+	if err != nil && !isClaimError(err) {
+		m.updateRetries(false)
+		// The capacity accounts for the addresses allocated before the
+		// failure, unless they could not be fetched.
+		if addresses != nil {
+			m.updateCapacity(addresses)
+		}
+		m.updateConditions(err)
+		m.recordCapacity()
 		return 0, err
 	}
-	return count, nil
+	// The failure to serve a claim is reported to the claim, the IPPool
+	// itself was reconciled.
+	m.updateReservations()
+	m.updateRetries(true)
+	m.updateCapacity(addresses)
+	m.updateConditions(err)
+	m.recordCapacity()
+	return len(addresses), err
 }
 
-// UpdateM3Addresses manages the ipclaims.ipam.metal3.io and creates or deletes IPAddress.ipam.metal3.io accordingly.
-// It returns the number of current allocations. Current allocation include
+// updateClaimAddresses releases the addresses of the deleted claims of both
+// kinds, then serves the pending claims of both kinds in priority order.
+// It returns the current allocations. Current allocation include
 // both capi and metal3 type ipaddress objects.
-func (m *IPPoolManager) m3UpdateAddresses(ctx context.Context) (int, error) {
+func (m *IPPoolManager) updateClaimAddresses(ctx context.Context) (map[ipamv1.IPAddressStr]string, error) {
 	addresses, err := m.getIndexes(ctx)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-
-	// get list of IPClaim objects
-	addressClaimObjects := ipamv1.IPClaimList{}
-	// without this ListOption, all namespaces would be including in the listing
-	opts := &client.ListOptions{
-		Namespace: m.IPPool.Namespace,
+	err = m.releaseReservations(ctx)
+	if err != nil {
+		return addresses, err
 	}
-
-	err = m.client.List(ctx, &addressClaimObjects, opts)
+	addresses, pending, err := m.m3UpdateAddresses(ctx, addresses)
 	if err != nil {
-		return 0, err
+		return addresses, err
+	}
+	addresses, capiPending, err := m.capiUpdateAddresses(ctx, addresses)
+	if err != nil {
+		return addresses, err
 	}
 
-	// Iterate over the IPClaim objects to find all addresses and objects
-	for _, addressClaim := range addressClaimObjects.Items {
-		// If IPPool does not point to this object, discard
-		if addressClaim.Spec.Pool.Name != m.IPPool.Name {
-			continue
+	// The pending claims, including the failed ones, are served once the
+	// addresses of the deleted claims are released, in priority order
+	// whatever their kind. The failure to serve one of them does not prevent
+	// serving the others, it is reported once all were served.
+	pending = append(pending, capiPending...)
+	slices.SortStableFunc(pending, compareClaims)
+	var claimErr error
+	for _, addressClaim := range pending {
+		addresses, err = m.servePendingClaim(ctx, addressClaim, addresses)
+		if err != nil {
+			if !isClaimError(err) {
+				return addresses, err
+			}
+			if claimErr == nil {
+				claimErr = err
+			}
 		}
+	}
+	addresses, err = m.updateChildPools(ctx, addresses)
+	if err != nil {
+		return addresses, err
+	}
+	return addresses, claimErr
+}
 
-		if addressClaim.Status.Address != nil && addressClaim.DeletionTimestamp.IsZero() {
-			continue
+// servePendingClaim creates the IPAddress of a pending claim, unless it failed
+// and must not be retried yet.
+func (m *IPPoolManager) servePendingClaim(ctx context.Context, addressClaim client.Object,
+	addresses map[ipamv1.IPAddressStr]string,
+) (map[ipamv1.IPAddressStr]string, error) {
+	switch addressClaim := addressClaim.(type) {
+	case *ipamv1.IPClaim:
+		// A dual-stack IPClaim failed by its other pool waits for that pool
+		// to serve it, and is not a failure of the IPPool.
+		otherFailed, err := m.otherPoolFailed(ctx, addressClaim)
+		if err != nil {
+			return addresses, err
 		}
-
-		if addressClaim.Status.ErrorMessage != nil && addressClaim.DeletionTimestamp.IsZero() {
-			continue
+		if otherFailed {
+			return addresses, nil
 		}
-		addresses, err = m.updateAddress(ctx, &addressClaim, addresses)
-		if err != nil {
-			return 0, err
+		if addressClaim.Status.ErrorMessage != nil && !m.mustRetry(addressClaim, claimKindIPClaim) {
+			return addresses, nil
+		}
+		return m.updateAddress(ctx, addressClaim, addresses)
+	case *capipamv1.IPAddressClaim:
+		if anyErrorInExistingClaim(*addressClaim) && !m.mustRetry(addressClaim, claimKindIPAddressClaim) {
+			return addresses, nil
 		}
+		return m.capiUpdateAddress(ctx, addressClaim, addresses)
 	}
-	return len(addresses), nil
+	return addresses, nil
 }
 
-// UpdateCAPIAddresses manages the ipaddressclaims.ipam.cluster.x-k8s.io and creates or deletes IPAddress.ipam.cluster.x-k8s.io accordingly.
-// It returns the number of current allocations.
-func (m *IPPoolManager) capiUpdateAddresses(ctx context.Context) (int, error) {
-	addresses, err := m.getIndexes(ctx)
+// UpdateM3Addresses manages the ipclaims.ipam.metal3.io and deletes IPAddress.ipam.metal3.io accordingly.
+// It returns the current allocations and the IPClaims waiting for an address.
+func (m *IPPoolManager) m3UpdateAddresses(ctx context.Context, addresses map[ipamv1.IPAddressStr]string,
+) (map[ipamv1.IPAddressStr]string, []client.Object, error) {
+	namespaces, granted, err := m.claimNamespaces(ctx)
 	if err != nil {
-		return 0, err
+		return addresses, nil, err
+	}
+
+	var pending []client.Object
+	for _, namespace := range namespaces {
+		// get list of IPClaim objects
+		addressClaimObjects := ipamv1.IPClaimList{}
+		// without this ListOption, all namespaces would be including in the listing
+		opts := &client.ListOptions{
+			Namespace: namespace,
+		}
+
+		err = m.client.List(ctx, &addressClaimObjects, opts)
+		if err != nil {
+			return addresses, nil, err
+		}
+
+		// Iterate over the IPClaim objects to find all addresses and objects
+		for _, addressClaim := range addressClaimObjects.Items {
+			// If IPPool does not point to this object, discard
+			if !m.isClaimPool(&addressClaim) {
+				continue
+			}
+
+			// The address of a dual-stack IPClaim is released when its other
+			// pool failed.
+			if m.mustRollback(&addressClaim) {
+				addresses, err = m.updateAddress(ctx, &addressClaim, addresses)
+				if err != nil {
+					return addresses, nil, err
+				}
+				continue
+			}
+
+			if m.claimAddress(&addressClaim) != nil && addressClaim.DeletionTimestamp.IsZero() {
+				continue
+			}
+
+			// IPClaims can only get an address while their namespace is
+			// allowed to claim from the IPPool. They can always release it.
+			if addressClaim.DeletionTimestamp.IsZero() {
+				allowed, err := m.canClaim(ctx, addressClaim.Namespace, granted)
+				if err != nil {
+					return addresses, nil, err
+				}
+				if allowed {
+					pending = append(pending, &addressClaim)
+				}
+				continue
+			}
+			addresses, err = m.updateAddress(ctx, &addressClaim, addresses)
+			if err != nil {
+				return addresses, nil, err
+			}
+		}
 	}
+	return addresses, pending, nil
+}
+
+// UpdateCAPIAddresses manages the ipaddressclaims.ipam.cluster.x-k8s.io and deletes IPAddress.ipam.cluster.x-k8s.io accordingly.
+// It returns the current allocations and the IPAddressClaims waiting for an
+// address.
+func (m *IPPoolManager) capiUpdateAddresses(ctx context.Context, addresses map[ipamv1.IPAddressStr]string,
+) (map[ipamv1.IPAddressStr]string, []client.Object, error) {
 	// get list of IPClaim objects
 	addressClaimObjects := capipamv1.IPAddressClaimList{}
 	// without this ListOption, all namespaces would be including in the listing
@@ -302,15 +524,16 @@ func (m *IPPoolManager) capiUpdateAddresses(ctx context.Context) (int, error) {
 		Namespace: m.IPPool.Namespace,
 	}
 
-	err = m.client.List(ctx, &addressClaimObjects, opts)
+	err := m.client.List(ctx, &addressClaimObjects, opts)
 	if err != nil {
-		return 0, err
+		return addresses, nil, err
 	}
 
 	// Iterate over the IPAddressClaim objects to find all addresses and objects
+	var pending []client.Object
 	for _, addressClaim := range addressClaimObjects.Items {
 		// If IPPool does not point to this object, discard
-		if addressClaim.Spec.PoolRef.Name != m.IPPool.Name {
+		if !m.isCAPIPoolReference(addressClaim.Spec.PoolRef) {
 			continue
 		}
 
@@ -318,15 +541,22 @@ func (m *IPPoolManager) capiUpdateAddresses(ctx context.Context) (int, error) {
 			continue
 		}
 
-		if anyErrorInExistingClaim(addressClaim) && addressClaim.DeletionTimestamp.IsZero() {
+		if addressClaim.DeletionTimestamp.IsZero() {
+			allowed, err := m.canClaim(ctx, addressClaim.Namespace, nil)
+			if err != nil {
+				return addresses, nil, err
+			}
+			if allowed {
+				pending = append(pending, &addressClaim)
+			}
 			continue
 		}
 		addresses, err = m.capiUpdateAddress(ctx, &addressClaim, addresses)
 		if err != nil {
-			return 0, err
+			return addresses, nil, err
 		}
 	}
-	return len(addresses), nil
+	return addresses, pending, nil
 }
 
 // UpdateAddress creates metal3 ipaddress or deletes it. Address can be deleted if it
@@ -354,18 +584,29 @@ func (m *IPPoolManager) updateAddress(ctx context.Context,
 		}
 	}()
 
+	// The error of the other pool of a dual-stack IPClaim is kept, and its
+	// address is released.
+	if m.mustRollback(addressClaim) {
+		m.recordNormal(addressClaim, claimKindIPClaim, DualStackRollbackReason,
+			"Releasing address, the other pool failed: %s", *addressClaim.Status.ErrorMessage)
+		return m.rollbackAddress(ctx, addressClaim, addresses)
+	}
+
 	addressClaim.Status.ErrorMessage = nil
 
 	if addressClaim.DeletionTimestamp.IsZero() {
 		addresses, err = m.createAddress(ctx, addressClaim, addresses)
 		if err != nil {
+			if addressClaim.Status.ErrorMessage != nil {
+				m.recordFailedAttempt(addressClaim, claimKindIPClaim, err)
+			}
 			return addresses, err
 		}
+		m.clearRetry(addressClaim, claimKindIPClaim)
 	} else {
 		// Check if this claim is in use. Does it have any other finalizers than our own?
 		// If it is no longer in use, proceed to delete the associated IPAddress
-		if len(addressClaim.Finalizers) > 1 ||
-			(len(addressClaim.Finalizers) == 1 && !Contains(addressClaim.Finalizers, ipamv1.IPClaimFinalizer)) {
+		if hasOtherFinalizers(addressClaim) {
 			m.Log.Info("IPClaim is still in use (has other finalizers). Cannot delete IPAddress.",
 				"IPClaim", addressClaim.Name, "Finalizers", addressClaim.Finalizers)
 			return addresses, nil
@@ -408,8 +649,12 @@ func (m *IPPoolManager) capiUpdateAddress(ctx context.Context,
 	if addressClaim.DeletionTimestamp.IsZero() {
 		addresses, err = m.capiCreateAddress(ctx, addressClaim, addresses)
 		if err != nil {
+			if anyErrorInExistingClaim(*addressClaim) {
+				m.recordFailedAttempt(addressClaim, claimKindIPAddressClaim, err)
+			}
 			return addresses, err
 		}
+		m.clearRetry(addressClaim, claimKindIPAddressClaim)
 	} else {
 		// Check if this claim is in use. Does it have any other finalizers than our own?
 		// If it is no longer in use, proceed to delete the associated IPAddress
@@ -429,101 +674,135 @@ func (m *IPPoolManager) capiUpdateAddress(ctx context.Context,
 	return addresses, nil
 }
 
+// selectAddress selects the address of a claim in the pool entries matching
+// the selector: its pre-allocated address, else the requested address if it
+// is free, else a free address following the allocation strategy of the
+// IPPool. It returns the address and the pool entry holding it, or the error
+// set on the claim.
+func (m *IPPoolManager) selectAddress(claim client.Object, claimKind string, requestedIP ipamv1.IPAddressStr,
+	selector labels.Selector, addresses map[ipamv1.IPAddressStr]string,
+) (ipamv1.IPAddressStr, ipamv1.Pool, error) {
+	// Get pre-allocated addresses
+	preAllocatedAddress, ipPreAllocated := m.IPPool.Spec.PreAllocations[m.allocationKey(claim)]
+
+	// Conflict-case, claim is preAllocated but has requested different IP
+	if requestedIP != "" && ipPreAllocated && !m.ipEqual(requestedIP, preAllocatedAddress) {
+		return "", ipamv1.Pool{}, m.failAllocation(claim, claimKind,
+			preAllocationConflict(preAllocatedAddress, requestedIP))
+	}
+
+	entryMatched := false
+	isRequestedIPAllocated := false
+	for i, pool := range m.getPools() {
+		if !selector.Matches(labels.Set(pool.Labels)) {
+			continue
+		}
+		entryMatched = true
+
+		switch {
+		case ipPreAllocated:
+			// The pre-allocated address is allocated even if it is in use,
+			// since the pre-allocations are recorded as addresses in use.
+			if address, ok := poolEntryAddress(pool, preAllocatedAddress); ok {
+				return address, pool, nil
+			}
+		case requestedIP != "":
+			if address, ok := poolEntryAddress(pool, requestedIP); ok {
+				isRequestedIPAllocated = true
+				if _, ok := addresses[address]; !ok {
+					return address, pool, nil
+				}
+			}
+		default:
+			if address, ok := m.selectIPFromPool(i, pool, addresses); ok {
+				return address, pool, nil
+			}
+		}
+	}
+	if !entryMatched && !selector.Empty() {
+		return "", ipamv1.Pool{}, m.failAllocation(claim, claimKind, errNoPoolEntryMatches)
+	}
+	// We did not get requestedIp as it did not match with any available IP
+	if requestedIP != "" && isRequestedIPAllocated {
+		return "", ipamv1.Pool{}, m.failAllocation(claim, claimKind,
+			m.conflictingRequest(requestedIP, addresses))
+	}
+	// We have a preallocated IP but we did not find it in the pools! It means it is
+	// misconfigured
+	if ipPreAllocated {
+		return "", ipamv1.Pool{}, m.failAllocation(claim, claimKind, errPreAllocationOutOfBounds)
+	}
+	return "", ipamv1.Pool{}, m.failAllocation(claim, claimKind, errPoolExhausted)
+}
+
+// poolEntryAddress returns the address in its canonical form if it is in the
+// index space of the pool entry, within its range and not excluded.
+func poolEntryAddress(pool ipamv1.Pool, address ipamv1.IPAddressStr) (ipamv1.IPAddressStr, bool) {
+	addr, err := netip.ParseAddr(string(address))
+	if err != nil {
+		return "", false
+	}
+	addr = addr.Unmap()
+	firstIP, lastIP, err := ipamv1.GetPoolRange(pool)
+	if err != nil {
+		return "", false
+	}
+	first := ipToAddr(firstIP)
+	if addr.BitLen() != first.BitLen() || addr.Less(first) {
+		return "", false
+	}
+	if lastIP != nil && ipToAddr(lastIP).Less(addr) {
+		return "", false
+	}
+	if ipamv1.IsAddressExcluded(address, pool.Exclusions) {
+		return "", false
+	}
+	return ipamv1.IPAddressStr(addr.String()), true
+}
+
 // allocateAddress gets an (metal3)IpAddress for a (metal3)IPClaim.
 // it takes into consideration the possible preallocations.
 // Returns an IP address, a prefix, a gateway and a list of DNS servers.
 func (m *IPPoolManager) allocateAddress(addressClaim *ipamv1.IPClaim,
 	addresses map[ipamv1.IPAddressStr]string,
 ) (ipamv1.IPAddressStr, int, *ipamv1.IPAddressStr, []ipamv1.IPAddressStr, error) {
-	var allocatedAddress ipamv1.IPAddressStr
-	var err error
-
-	// Get pre-allocated addresses
-	preAllocatedAddress, ipPreAllocated := m.IPPool.Spec.PreAllocations[addressClaim.Name]
-	// If the IP is pre-allocated, the default prefix and gateway are used
+	// The pool entry holding the address overrides the defaults of the IPPool.
 	prefix := m.IPPool.Spec.Prefix
 	gateway := m.IPPool.Spec.Gateway
 	dnsServers := m.IPPool.Spec.DNSServers
 
-	ipAllocated := false
-
-	requestedIP := ipamv1.IPAddressStr(addressClaim.ObjectMeta.Annotations[IPAddressAnnotation])
-	isRequestedIPAllocated := false
+	// The requested address applies to the primary pool of a dual-stack
+	// IPClaim.
+	requestedIP := ipamv1.IPAddressStr("")
+	if !m.isSecondaryPool(addressClaim) {
+		requestedIP = ipamv1.GetRequestedAddress(addressClaim)
+	}
 
 	if requestedIP != "" {
 		if net.ParseIP(string(requestedIP)) == nil {
-			msg := fmt.Sprintf("Invalid ipAddress annotation %q: not a valid IP address", requestedIP)
-			addressClaim.Status.ErrorMessage = ptr.To(msg)
-			return "", 0, nil, []ipamv1.IPAddressStr{}, errors.New(msg)
+			return "", 0, nil, []ipamv1.IPAddressStr{}, m.failAllocation(addressClaim, claimKindIPClaim,
+				newAllocationError(ErrorReasonInvalidRequestedIP, "Invalid requested address %q: not a valid IP address", requestedIP))
 		}
 	}
 
-	// Conflict-case, claim is preAllocated but has requested different IP
-	if requestedIP != "" && ipPreAllocated && !m.ipEqual(requestedIP, preAllocatedAddress) {
-		addressClaim.Status.ErrorMessage = ptr.To("PreAllocation and requested ip address are conflicting")
-		return "", 0, nil, []ipamv1.IPAddressStr{}, errors.New("PreAllocation and requested ip address are conflicting")
+	selector, err := poolEntrySelector(addressClaim)
+	if err != nil {
+		return "", 0, nil, []ipamv1.IPAddressStr{}, m.failAllocation(addressClaim, claimKindIPClaim,
+			newAllocationError(ErrorReasonInvalidPoolEntrySelector, "Invalid pool entry selector: %v", err))
 	}
 
-	for _, pool := range m.IPPool.Spec.Pools {
-		if ipAllocated {
-			break
-		}
-
-		// PreAllocations and requestedIP always use sequential scan
-		if ipPreAllocated || requestedIP != "" {
-			index := 0
-			for !ipAllocated {
-				allocatedAddress, err = ipamv1.GetIPAddress(pool, index)
-				if err != nil {
-					break
-				}
-				index++
-				if requestedIP != "" && !m.ipEqual(allocatedAddress, requestedIP) {
-					continue
-				}
-				if requestedIP != "" && m.ipEqual(allocatedAddress, requestedIP) {
-					isRequestedIPAllocated = true
-				}
-				if ipPreAllocated && !m.ipEqual(allocatedAddress, preAllocatedAddress) {
-					continue
-				}
-				if ipPreAllocated {
-					ipAllocated = true
-				}
-				if _, ok := addresses[allocatedAddress]; !ok && allocatedAddress != "" {
-					ipAllocated = true
-				}
-			}
-		} else {
-			allocatedAddress, ipAllocated = m.selectIPFromPool(pool, addresses)
-		}
-
-		if ipAllocated {
-			if pool.Prefix != 0 {
-				prefix = pool.Prefix
-			}
-			if pool.Gateway != nil {
-				gateway = pool.Gateway
-			}
-			if len(pool.DNSServers) != 0 {
-				dnsServers = pool.DNSServers
-			}
-		}
+	allocatedAddress, pool, err := m.selectAddress(addressClaim, claimKindIPClaim, requestedIP, selector, addresses)
+	if err != nil {
+		return "", 0, nil, []ipamv1.IPAddressStr{}, err
 	}
-	// We did not get requestedIp as it did not match with any available IP
-	if requestedIP != "" && isRequestedIPAllocated && !ipAllocated {
-		addressClaim.Status.ErrorMessage = ptr.To("Requested IP not available")
-		return "", 0, nil, []ipamv1.IPAddressStr{}, errors.New("requested IP not available")
+	if pool.Prefix != 0 {
+		prefix = pool.Prefix
 	}
-	// We have a preallocated IP but we did not find it in the pools! It means it is
-	// misconfigured
-	if !ipAllocated && ipPreAllocated {
-		addressClaim.Status.ErrorMessage = ptr.To("Pre-allocated IP out of bounds")
-		return "", 0, nil, []ipamv1.IPAddressStr{}, errors.New("pre-allocated IP out of bounds")
+	if pool.Gateway != nil {
+		gateway = pool.Gateway
 	}
-	if !ipAllocated {
-		addressClaim.Status.ErrorMessage = ptr.To("Exhausted IP Pools")
-		return "", 0, nil, []ipamv1.IPAddressStr{}, errors.New("exhausted IP pools")
+	if len(pool.DNSServers) != 0 {
+		dnsServers = pool.DNSServers
 	}
 	return allocatedAddress, prefix, gateway, dnsServers, nil
 }
@@ -534,143 +813,37 @@ func (m *IPPoolManager) allocateAddress(addressClaim *ipamv1.IPClaim,
 func (m *IPPoolManager) capiAllocateAddress(addressClaim *capipamv1.IPAddressClaim,
 	addresses map[ipamv1.IPAddressStr]string,
 ) (ipamv1.IPAddressStr, int32, *ipamv1.IPAddressStr, error) {
-	var allocatedAddress ipamv1.IPAddressStr
-	var err error
-
-	// Get pre-allocated addresses
-	preAllocatedAddress, ipPreAllocated := m.IPPool.Spec.PreAllocations[addressClaim.Name]
-	// If the IP is pre-allocated, the default prefix and gateway are used
+	// The pool entry holding the address overrides the defaults of the IPPool.
 	prefix := m.IPPool.Spec.Prefix
 	gateway := m.IPPool.Spec.Gateway
 
-	ipAllocated := false
-
 	requestedIP := ipamv1.IPAddressStr(addressClaim.ObjectMeta.Annotations[IPAddressAnnotation])
-	isRequestedIPAllocated := false
 
 	if requestedIP != "" {
 		if net.ParseIP(string(requestedIP)) == nil {
-			msg := fmt.Sprintf("Invalid ipAddress annotation %q: not a valid IP address", requestedIP)
-			conditions := make([]metav1.Condition, 0, 1)
-			conditions = append(conditions, metav1.Condition{
-				Type:               capipamv1.IPAddressClaimReadyCondition,
-				Status:             metav1.ConditionFalse,
-				LastTransitionTime: metav1.Now(),
-				Reason:             capipamv1.IPAddressClaimReadyAllocationFailedReason,
-				Message:            msg,
-			})
-			addressClaim.SetConditions(conditions)
-			return "", 0, nil, errors.New(msg)
+			return "", 0, nil, m.failAllocation(addressClaim, claimKindIPAddressClaim,
+				newAllocationError(ErrorReasonInvalidRequestedIP, "Invalid ipAddress annotation %q: not a valid IP address", requestedIP))
 		}
 	}
 
-	// Conflict-case, claim is preAllocated but has requested different IP
-	if requestedIP != "" && ipPreAllocated && !m.ipEqual(requestedIP, preAllocatedAddress) {
-		conditions := make([]metav1.Condition, 0, 1)
-		conditions = append(conditions, metav1.Condition{
-			Type:               capipamv1.IPAddressClaimReadyCondition,
-			Status:             metav1.ConditionFalse,
-			LastTransitionTime: metav1.Now(),
-			Reason:             capipamv1.IPAddressClaimReadyAllocationFailedReason,
-			Message:            "PreAllocation and requested ip address are conflicting",
-		})
-		addressClaim.SetConditions(conditions)
-		return "", 0, nil, errors.New("PreAllocation and requested ip address are conflicting")
+	selector, err := poolEntrySelector(addressClaim)
+	if err != nil {
+		return "", 0, nil, m.failAllocation(addressClaim, claimKindIPAddressClaim,
+			newAllocationError(ErrorReasonInvalidPoolEntrySelector, "Invalid %s annotation: %v", PoolEntrySelectorAnnotation, err))
 	}
 
-	for _, pool := range m.IPPool.Spec.Pools {
-		if ipAllocated {
-			break
-		}
-
-		// PreAllocations and requestedIP always use sequential scan
-		if ipPreAllocated || requestedIP != "" {
-			index := 0
-			for !ipAllocated {
-				allocatedAddress, err = ipamv1.GetIPAddress(pool, index)
-				if err != nil {
-					break
-				}
-				index++
-				if requestedIP != "" && !m.ipEqual(allocatedAddress, requestedIP) {
-					continue
-				}
-				if requestedIP != "" && m.ipEqual(allocatedAddress, requestedIP) {
-					isRequestedIPAllocated = true
-				}
-				if ipPreAllocated && !m.ipEqual(allocatedAddress, preAllocatedAddress) {
-					continue
-				}
-				if ipPreAllocated {
-					ipAllocated = true
-				}
-				if _, ok := addresses[allocatedAddress]; !ok && allocatedAddress != "" {
-					ipAllocated = true
-				}
-			}
-		} else {
-			allocatedAddress, ipAllocated = m.selectIPFromPool(pool, addresses)
-		}
-
-		if ipAllocated {
-			if pool.Prefix != 0 {
-				prefix = pool.Prefix
-			}
-			if pool.Gateway != nil {
-				gateway = pool.Gateway
-			}
-		}
+	allocatedAddress, pool, err := m.selectAddress(addressClaim, claimKindIPAddressClaim, requestedIP, selector, addresses)
+	if err != nil {
+		return "", 0, nil, err
 	}
-	// We did not get requestedIp as it did not match with any available IP
-	if requestedIP != "" && isRequestedIPAllocated && !ipAllocated {
-		conditions := make([]metav1.Condition, 0, 1)
-		conditions = append(conditions, metav1.Condition{
-			Type:               capipamv1.IPAddressClaimReadyCondition,
-			Status:             metav1.ConditionFalse,
-			LastTransitionTime: metav1.Now(),
-			Reason:             capipamv1.IPAddressClaimReadyAllocationFailedReason,
-			Message:            "Requested IP not available",
-		})
-		addressClaim.SetConditions(conditions)
-		return "", 0, nil, errors.New("requested IP not available")
+	if pool.Prefix != 0 {
+		prefix = pool.Prefix
 	}
-	// We have a preallocated IP but we did not find it in the pools! It means it is
-	// misconfigured
-	if !ipAllocated && ipPreAllocated {
-		conditions := make([]metav1.Condition, 0, 1)
-		conditions = append(conditions, metav1.Condition{
-			Type:               capipamv1.IPAddressClaimReadyCondition,
-			Status:             metav1.ConditionFalse,
-			LastTransitionTime: metav1.Now(),
-			Reason:             capipamv1.IPAddressClaimReadyAllocationFailedReason,
-			Message:            "Pre-allocated IP out of bounds",
-		})
-		addressClaim.SetConditions(conditions)
-		return "", 0, nil, errors.New("pre-allocated IP out of bounds")
-	}
-	if !ipAllocated {
-		conditions := make([]metav1.Condition, 0, 1)
-		conditions = append(conditions, metav1.Condition{
-			Type:               capipamv1.IPAddressClaimReadyCondition,
-			Status:             metav1.ConditionFalse,
-			LastTransitionTime: metav1.Now(),
-			Reason:             capipamv1.IPAddressClaimReadyPoolExhaustedReason,
-			Message:            "Exhausted IP Pools",
-		})
-		addressClaim.SetConditions(conditions)
-		return "", 0, nil, errors.New("exhausted IP pools")
+	if pool.Gateway != nil {
+		gateway = pool.Gateway
 	}
 	if prefix < 0 || prefix > 128 {
-		conditions := make([]metav1.Condition, 0, 1)
-		conditions = append(conditions, metav1.Condition{
-			Type:               capipamv1.IPAddressClaimReadyCondition,
-			Status:             metav1.ConditionFalse,
-			LastTransitionTime: metav1.Now(),
-			Reason:             capipamv1.IPAddressClaimReadyAllocationFailedReason,
-			Message:            "Invalid prefix value, it must be between 0 and 128",
-		})
-		addressClaim.SetConditions(conditions)
-		return "", 0, nil, errors.New("invalid prefix value, it must be between 0 and 128")
+		return "", 0, nil, m.failAllocation(addressClaim, claimKindIPAddressClaim, errInvalidPrefix)
 	}
 	prefixInt32 := int32(prefix)
 	return allocatedAddress, prefixInt32, gateway, nil
@@ -682,51 +855,79 @@ func (m *IPPoolManager) capiAllocateAddress(addressClaim *capipamv1.IPAddressCla
 func (m *IPPoolManager) createAddress(ctx context.Context,
 	addressClaim *ipamv1.IPClaim, addresses map[ipamv1.IPAddressStr]string,
 ) (map[ipamv1.IPAddressStr]string, error) {
-	if !Contains(addressClaim.Finalizers, ipamv1.IPClaimFinalizer) {
+	finalizer := m.claimFinalizer(addressClaim)
+	if !Contains(addressClaim.Finalizers, finalizer) {
 		addressClaim.Finalizers = append(addressClaim.Finalizers,
-			ipamv1.IPClaimFinalizer,
+			finalizer,
 		)
 	}
 
-	if allocatedAddress, ok := m.IPPool.Status.Allocations[addressClaim.Name]; ok {
-		addressClaim.Status.Address = &corev1.ObjectReference{
-			Name:      m.formatAddressName(allocatedAddress),
-			Namespace: m.IPPool.Namespace,
+	allocationKey := m.allocationKey(addressClaim)
+	addressNamespace := m.claimNamespace(addressClaim)
+	if allocatedAddress, ok := m.allocations[allocationKey]; ok {
+		if err := m.rebindAddress(ctx, &ipamv1.IPAddress{}, addressClaim, claimKindIPClaim, allocatedAddress); err != nil {
+			return addresses, err
 		}
+		m.setClaimAddress(addressClaim, &corev1.ObjectReference{
+			Name:      m.formatAddressName(allocatedAddress),
+			Namespace: addressNamespace,
+		})
+		setClaimReady(addressClaim)
 		return addresses, nil
 	}
 
+	if err := m.exceededQuota(addressClaim); err != nil {
+		return addresses, m.failAllocation(addressClaim, claimKindIPClaim, err)
+	}
+
 	// Get a new index for this machine
 	m.Log.Info("Getting address", "Claim", addressClaim.Name)
-	// Get a new IP for this owner
-	allocatedAddress, prefix, gateway, dnsServers, err := m.allocateAddress(addressClaim, addresses)
+	// Get a new IP, or a new subnet, for this owner
+	allocate := m.allocateAddress
+	if addressClaim.Spec.PrefixLength != 0 {
+		allocate = m.allocateSubnet
+	}
+	allocatedAddress, prefix, gateway, dnsServers, err := allocate(addressClaim, addresses)
 	if err != nil {
 		return addresses, err
 	}
+	var subnet *ipamv1.IPSubnetStr
+	if addressClaim.Spec.PrefixLength != 0 {
+		subnet = ptr.To(ipamv1.IPSubnetStr(fmt.Sprintf("%s/%d", allocatedAddress, prefix)))
+	}
+	if err := m.checkDualStackFamily(ctx, addressClaim, allocatedAddress); err != nil {
+		return addresses, err
+	}
 
 	// Set the index and IPAddress names
 	addressName := m.formatAddressName(allocatedAddress)
+	// The selector was already validated when allocating the address.
+	selector, _ := poolEntrySelector(addressClaim)
 
 	m.Log.Info("Address allocated", "Claim", addressClaim.Name, "address", allocatedAddress)
 
-	// Construct ownerRefs for the IPClaim and the IPPool.
-	ownerRefs := []metav1.OwnerReference{
-		{
+	// Construct ownerRefs for the IPClaim and the IPPool. Owner references
+	// cannot cross namespaces, so the IPAddress of an IPClaim of another
+	// namespace is only owned by the IPClaim, and released through the
+	// finalizers.
+	ownerRefs := []metav1.OwnerReference{}
+	if m.canOwnAddress(addressClaim) {
+		ownerRefs = append(ownerRefs, metav1.OwnerReference{
 			APIVersion: m.IPPool.APIVersion,
 			Kind:       m.IPPool.Kind,
 			Name:       m.IPPool.Name,
 			UID:        m.IPPool.UID,
-		},
-		{
-			APIVersion: addressClaim.APIVersion,
-			Kind:       addressClaim.Kind,
-			Name:       addressClaim.Name,
-			UID:        addressClaim.UID,
-		},
+		})
 	}
+	ownerRefs = append(ownerRefs, metav1.OwnerReference{
+		APIVersion: addressClaim.APIVersion,
+		Kind:       addressClaim.Kind,
+		Name:       addressClaim.Name,
+		UID:        addressClaim.UID,
+	})
 
-	// Create the IPAddress object, with an Owner ref to the IPClaim and
-	// the IPPool. Also add a finalizer.
+	// Create the IPAddress object, in the namespace of the IPClaim, with an
+	// Owner ref to the IPClaim and the IPPool. Also add a finalizer.
 	addressObject := &ipamv1.IPAddress{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "IPAddress",
@@ -734,45 +935,52 @@ func (m *IPPoolManager) createAddress(ctx context.Context,
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            addressName,
-			Namespace:       m.IPPool.Namespace,
+			Namespace:       addressNamespace,
 			Finalizers:      []string{ipamv1.IPAddressFinalizer},
 			OwnerReferences: ownerRefs,
 			Labels:          addressClaim.Labels,
 		},
 		Spec: ipamv1.IPAddressSpec{
 			Address: allocatedAddress,
-			Pool: corev1.ObjectReference{
-				Name:      m.IPPool.Name,
-				Namespace: m.IPPool.Namespace,
-			},
+			Pool:    m.poolReference(),
 			Claim: corev1.ObjectReference{
 				Name:      addressClaim.Name,
-				Namespace: m.IPPool.Namespace,
+				Namespace: addressNamespace,
 			},
 			Prefix:     prefix,
 			Gateway:    gateway,
 			DNSServers: dnsServers,
+			PoolEntry:  m.poolEntryName(selector, allocatedAddress),
+			Subnet:     subnet,
 		},
 	}
 
 	// Create the IPAddress object. If we get a conflict (that will set
 	// Transient error), then requeue to retrigger the reconciliation with
 	// the new state
-	if err := createObject(ctx, m.client, addressObject); err != nil {
-		var reconcileError ReconcileError
-		if !errors.As(err, &reconcileError) {
-			addressClaim.Status.ErrorMessage = ptr.To("Failed to create associated IPAddress object")
+	if err := m.createAddressObject(ctx, addressObject); err != nil {
+		var allocationError *AllocationError
+		if errors.As(err, &allocationError) {
+			return addresses, m.failAllocation(addressClaim, claimKindIPClaim, allocationError)
 		}
 		return addresses, err
 	}
 
-	m.IPPool.Status.Allocations[addressClaim.Name] = allocatedAddress
-	addresses[allocatedAddress] = addressClaim.Name
+	m.allocations[allocationKey] = allocatedAddress
+	addresses[allocatedAddress] = allocationKey
+	if subnet != nil {
+		m.addSubnet(allocatedAddress, *subnet)
+	}
+	m.trackCreatedAddress(allocatedAddress, allocationKey, subnet, addressClaim)
+	m.updateQuotaUsage(addressClaim.Namespace, addressClaim.Labels, 1)
+	m.recordAllocation(claimKindIPClaim, addressClaim.CreationTimestamp)
+	m.recordNormal(addressClaim, claimKindIPClaim, AddressAllocatedReason, "Allocated address %s", allocatedAddress)
 
-	addressClaim.Status.Address = &corev1.ObjectReference{
+	m.setClaimAddress(addressClaim, &corev1.ObjectReference{
 		Name:      addressName,
-		Namespace: m.IPPool.Namespace,
-	}
+		Namespace: addressNamespace,
+	})
+	setClaimReady(addressClaim)
 
 	return addresses, nil
 }
@@ -789,13 +997,21 @@ func (m *IPPoolManager) capiCreateAddress(ctx context.Context,
 		)
 	}
 
-	if allocatedAddress, ok := m.IPPool.Status.Allocations[addressClaim.Name]; ok {
+	allocationKey := m.allocationKey(addressClaim)
+	if allocatedAddress, ok := m.allocations[allocationKey]; ok {
+		if err := m.rebindAddress(ctx, &capipamv1.IPAddress{}, addressClaim, claimKindIPAddressClaim, allocatedAddress); err != nil {
+			return addresses, err
+		}
 		addressClaim.Status.AddressRef = capipamv1.IPAddressReference{
 			Name: m.formatAddressName(allocatedAddress),
 		}
 		return addresses, nil
 	}
 
+	if err := m.exceededQuota(addressClaim); err != nil {
+		return addresses, m.failAllocation(addressClaim, claimKindIPAddressClaim, err)
+	}
+
 	// Get a new index for this machine
 	m.Log.Info("Getting address", "Claim", addressClaim.Name)
 	// Get a new IP for this owner
@@ -813,27 +1029,35 @@ func (m *IPPoolManager) capiCreateAddress(ctx context.Context,
 
 	// Set the index and IPAddress names
 	addressName := m.formatAddressName(allocatedAddress)
+	// The selector was already validated when allocating the address.
+	selector, _ := poolEntrySelector(addressClaim)
+	var annotations map[string]string
+	if poolEntry := m.poolEntryName(selector, allocatedAddress); poolEntry != "" {
+		annotations = map[string]string{PoolEntryAnnotation: poolEntry}
+	}
 
 	m.Log.Info("Address allocated", "Claim", addressClaim.Name, "address", allocatedAddress)
 
 	// Construct ownerRefs for the IPAddressClaim and the IPPool.
-	ownerRefs := []metav1.OwnerReference{
-		{
+	ownerRefs := []metav1.OwnerReference{}
+	if m.canOwnAddress(addressClaim) {
+		ownerRefs = append(ownerRefs, metav1.OwnerReference{
 			APIVersion: m.IPPool.APIVersion,
 			Kind:       m.IPPool.Kind,
 			Name:       m.IPPool.Name,
 			UID:        m.IPPool.UID,
-		},
-		{
-			APIVersion: addressClaim.APIVersion,
-			Kind:       addressClaim.Kind,
-			Name:       addressClaim.Name,
-			UID:        addressClaim.UID,
-		},
+		})
 	}
+	ownerRefs = append(ownerRefs, metav1.OwnerReference{
+		APIVersion: addressClaim.APIVersion,
+		Kind:       addressClaim.Kind,
+		Name:       addressClaim.Name,
+		UID:        addressClaim.UID,
+	})
 
-	// Create the IPAddress object, with an Owner ref to the IPAddressClaim and
-	// the IPPool. Also add a finalizer.
+	// Create the IPAddress object, in the namespace of the IPAddressClaim,
+	// with an Owner ref to the IPAddressClaim and the IPPool. Also add a
+	// finalizer.
 	addressObject := &capipamv1.IPAddress{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "IPAddress",
@@ -841,10 +1065,11 @@ func (m *IPPoolManager) capiCreateAddress(ctx context.Context,
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            addressName,
-			Namespace:       m.IPPool.Namespace,
+			Namespace:       m.claimNamespace(addressClaim),
 			Finalizers:      []string{IPAddressFinalizer},
 			OwnerReferences: ownerRefs,
 			Labels:          addressClaim.Labels,
+			Annotations:     annotations,
 		},
 		Spec: capipamv1.IPAddressSpec{
 			Address: string(allocatedAddress),
@@ -864,36 +1089,26 @@ func (m *IPPoolManager) capiCreateAddress(ctx context.Context,
 	// Create the IPAddress object. If we get a conflict (that will set
 	// Transient error), then requeue to retrigger the reconciliation with
 	// the new state
-	if err := createObject(ctx, m.client, addressObject); err != nil {
-		var reconcileError ReconcileError
-		if !errors.As(err, &reconcileError) {
-			conditions := make([]metav1.Condition, 0, 1)
-			conditions = append(conditions, metav1.Condition{
-				Type:               capipamv1.IPAddressClaimReadyCondition,
-				Status:             metav1.ConditionFalse,
-				LastTransitionTime: metav1.Now(),
-				Reason:             capipamv1.IPAddressClaimReadyAllocationFailedReason,
-				Message:            "Failed to create associated IPAddress object",
-			})
-			addressClaim.SetConditions(conditions)
+	if err := m.createAddressObject(ctx, addressObject); err != nil {
+		var allocationError *AllocationError
+		if errors.As(err, &allocationError) {
+			return addresses, m.failAllocation(addressClaim, claimKindIPAddressClaim, allocationError)
 		}
 		return addresses, err
 	}
 
-	m.IPPool.Status.Allocations[addressClaim.Name] = allocatedAddress
-	addresses[allocatedAddress] = addressClaim.Name
+	m.allocations[allocationKey] = allocatedAddress
+	addresses[allocatedAddress] = allocationKey
+	m.trackCreatedAddress(allocatedAddress, allocationKey, nil, addressClaim)
+	m.updateQuotaUsage(addressClaim.Namespace, addressClaim.Labels, 1)
+	m.recordAllocation(claimKindIPAddressClaim, addressClaim.CreationTimestamp)
+	m.recordNormal(addressClaim, claimKindIPAddressClaim, AddressAllocatedReason, "Allocated address %s", allocatedAddress)
 
 	addressClaim.Status.AddressRef = capipamv1.IPAddressReference{
 		Name: addressName,
 	}
 
-	conditions := make([]metav1.Condition, 0, 1)
-	conditions = append(conditions, metav1.Condition{
-		Type:               capipamv1.IPAddressClaimReadyCondition,
-		Status:             metav1.ConditionTrue,
-		LastTransitionTime: metav1.Now(),
-	})
-	addressClaim.SetConditions(conditions)
+	setCapiClaimCondition(addressClaim, metav1.ConditionTrue, ipamv1.IPClaimAddressAllocatedReason, "")
 
 	return addresses, nil
 }
@@ -901,21 +1116,47 @@ func (m *IPPoolManager) capiCreateAddress(ctx context.Context,
 // deleteAddress removes the finalizer from the IPClaim and deletes the associated IPAddress.
 func (m *IPPoolManager) deleteAddress(ctx context.Context,
 	addressClaim *ipamv1.IPClaim, addresses map[ipamv1.IPAddressStr]string,
+) (map[ipamv1.IPAddressStr]string, error) {
+	return m.removeAddress(ctx, addressClaim, addresses, true)
+}
+
+// rollbackAddress removes the finalizer from a dual-stack IPClaim whose other
+// pool failed and deletes the associated IPAddress. The address was not used,
+// so it is freed without being released: it is not quarantined, nor recorded
+// as released.
+func (m *IPPoolManager) rollbackAddress(ctx context.Context,
+	addressClaim *ipamv1.IPClaim, addresses map[ipamv1.IPAddressStr]string,
+) (map[ipamv1.IPAddressStr]string, error) {
+	return m.removeAddress(ctx, addressClaim, addresses, false)
+}
+
+// removeAddress removes the finalizer from the IPClaim and deletes the
+// associated IPAddress, releasing its address if release is set.
+func (m *IPPoolManager) removeAddress(ctx context.Context,
+	addressClaim *ipamv1.IPClaim, addresses map[ipamv1.IPAddressStr]string, release bool,
 ) (map[ipamv1.IPAddressStr]string, error) {
 	m.Log.Info("Deleting IPAddress associated with IPClaim", "IPClaim", addressClaim.Name)
 
-	allocatedAddress, ok := m.IPPool.Status.Allocations[addressClaim.Name]
+	allocationKey := m.allocationKey(addressClaim)
+	allocatedAddress, ok := m.allocations[allocationKey]
+	retained := false
 	if ok {
-		// Try to get the IPAddress. if it succeeds, delete it
+		// Try to get the IPAddress. if it succeeds, retain or delete it
 		ipAddress := &ipamv1.IPAddress{}
 		key := client.ObjectKey{
 			Name:      m.formatAddressName(allocatedAddress),
-			Namespace: m.IPPool.Namespace,
+			Namespace: m.claimNamespace(addressClaim),
 		}
 		err := m.client.Get(ctx, key, ipAddress)
 		if err != nil && !apierrors.IsNotFound(err) {
 			addressClaim.Status.ErrorMessage = ptr.To("Failed to get associated IPAddress object")
 			return addresses, err
+		} else if err == nil && m.mustRetain(addressClaim, allocationKey) {
+			if err := m.retainAddress(ctx, ipAddress, addressClaim, claimKindIPClaim, allocatedAddress); err != nil {
+				m.Log.Info("Unable to retain IPAddress", "IPAddress", ipAddress.Name)
+				return addresses, err
+			}
+			retained = true
 		} else if err == nil {
 			// Remove the finalizer
 			ipAddress.Finalizers = Filter(ipAddress.Finalizers,
@@ -930,14 +1171,16 @@ func (m *IPPoolManager) deleteAddress(ctx context.Context,
 			err = deleteObject(ctx, m.client, ipAddress)
 			if err != nil {
 				addressClaim.Status.ErrorMessage = ptr.To("Failed to delete associated IPAddress object")
+				m.recordWarning(addressClaim, claimKindIPClaim, AddressDeletionFailedReason,
+					"Failed to delete IPAddress %s: %v", ipAddress.Name, err)
 				return addresses, err
 			}
 			m.Log.Info("Deleted IPAddress", "IPAddress", ipAddress.Name)
 		}
 	}
-	addressClaim.Status.Address = nil
+	m.setClaimAddress(addressClaim, nil)
 	addressClaim.Finalizers = Filter(addressClaim.Finalizers,
-		ipamv1.IPClaimFinalizer,
+		m.claimFinalizer(addressClaim),
 	)
 	err := updateObject(ctx, m.client, addressClaim)
 	if err != nil && !apierrors.IsNotFound(err) {
@@ -945,12 +1188,25 @@ func (m *IPPoolManager) deleteAddress(ctx context.Context,
 		return addresses, err
 	}
 
-	if ok {
-		if _, ok := m.IPPool.Spec.PreAllocations[addressClaim.Name]; !ok {
+	if ok && !retained {
+		m.untrackCreatedAddress(allocatedAddress)
+		if _, ok := m.IPPool.Spec.PreAllocations[allocationKey]; !ok {
 			delete(addresses, allocatedAddress)
+			if release {
+				m.recordReleasedAddress(allocatedAddress)
+				m.quarantineAddress(allocatedAddress)
+			}
+			m.triggerRetries()
 		}
-		delete(m.IPPool.Status.Allocations, addressClaim.Name)
+		delete(m.subnets, allocatedAddress)
+		m.addressSpaces = nil
+		delete(m.allocations, allocationKey)
+		m.updateQuotaUsage(addressClaim.Namespace, addressClaim.Labels, -1)
 		m.Log.Info("IPAddressClaim removed from IPPool allocations", "IPAddressClaim", addressClaim.Name)
+		if release {
+			m.recordRelease(claimKindIPClaim)
+			m.recordNormal(addressClaim, claimKindIPClaim, AddressReleasedReason, "Released address %s", allocatedAddress)
+		}
 	}
 	m.updateStatusTimestamp()
 	return addresses, nil
@@ -962,18 +1218,26 @@ func (m *IPPoolManager) capiDeleteAddress(ctx context.Context,
 ) (map[ipamv1.IPAddressStr]string, error) {
 	m.Log.Info("Deleting IPAddress associated with IPAddressClaim", "IPAddressClaim", addressClaim.Name)
 
-	allocatedAddress, ok := m.IPPool.Status.Allocations[addressClaim.Name]
+	allocationKey := m.allocationKey(addressClaim)
+	allocatedAddress, ok := m.allocations[allocationKey]
+	retained := false
 	if ok {
-		// Try to get the IPAddress. if it succeeds, delete it
+		// Try to get the IPAddress. if it succeeds, retain or delete it
 		ipAddress := &capipamv1.IPAddress{}
 		key := client.ObjectKey{
 			Name:      m.formatAddressName(allocatedAddress),
-			Namespace: m.IPPool.Namespace,
+			Namespace: m.claimNamespace(addressClaim),
 		}
 		err := m.client.Get(ctx, key, ipAddress)
 		if err != nil && !apierrors.IsNotFound(err) {
 			m.Log.Error(err, "Failed to get associated IPAddress object", "IPAddress", ipAddress.Name)
 			return addresses, err
+		} else if err == nil && m.mustRetain(addressClaim, allocationKey) {
+			if err := m.retainAddress(ctx, ipAddress, addressClaim, claimKindIPAddressClaim, allocatedAddress); err != nil {
+				m.Log.Error(err, "Unable to retain IPAddress", "IPAddress", ipAddress.Name)
+				return addresses, err
+			}
+			retained = true
 		} else if err == nil {
 			// Remove the finalizer
 			ipAddress.Finalizers = Filter(ipAddress.Finalizers,
@@ -988,6 +1252,8 @@ func (m *IPPoolManager) capiDeleteAddress(ctx context.Context,
 			err = deleteObject(ctx, m.client, ipAddress)
 			if err != nil {
 				m.Log.Error(err, "Failed to delete associated IPAddress object", "IPAddress", ipAddress.Name)
+				m.recordWarning(addressClaim, claimKindIPAddressClaim, AddressDeletionFailedReason,
+					"Failed to delete IPAddress %s: %v", ipAddress.Name, err)
 				return addresses, err
 			}
 			m.Log.Info("Deleted IPAddress", "IPAddress", ipAddress.Name)
@@ -1003,70 +1269,28 @@ func (m *IPPoolManager) capiDeleteAddress(ctx context.Context,
 		return addresses, err
 	}
 
-	if ok {
-		if _, ok := m.IPPool.Spec.PreAllocations[addressClaim.Name]; !ok {
+	if ok && !retained {
+		m.untrackCreatedAddress(allocatedAddress)
+		if _, ok := m.IPPool.Spec.PreAllocations[allocationKey]; !ok {
 			delete(addresses, allocatedAddress)
+			m.recordReleasedAddress(allocatedAddress)
+			m.quarantineAddress(allocatedAddress)
+			m.triggerRetries()
 		}
-		delete(m.IPPool.Status.Allocations, addressClaim.Name)
+		m.addressSpaces = nil
+		delete(m.allocations, allocationKey)
+		m.updateQuotaUsage(addressClaim.Namespace, addressClaim.Labels, -1)
 		m.Log.Info("IPAddressClaim removed from IPPool allocations", "IPAddressClaim", addressClaim.Name)
+		m.recordRelease(claimKindIPAddressClaim)
+		m.recordNormal(addressClaim, claimKindIPAddressClaim, AddressReleasedReason, "Released address %s", allocatedAddress)
 	}
 	m.updateStatusTimestamp()
 	return addresses, nil
 }
 
-// selectIPFromPool picks an available IP from the given pool based on the allocation strategy.
-// Returns the selected IP and true, or empty string and false if no IP is available.
-func (m *IPPoolManager) selectIPFromPool(pool ipamv1.Pool, addresses map[ipamv1.IPAddressStr]string) (ipamv1.IPAddressStr, bool) {
-	if m.IPPool.Spec.AllocationStrategy == ipamv1.AllocationStrategyRandom {
-		// Pick a random offset in the pool and scan forward, wrapping around
-		// at the end. This walks each index at most once and returns the first
-		// free IP encountered, giving O(1) memory and removing the asymmetry
-		// of a fixed-size candidate window.
-		size, err := ipamv1.GetPoolSize(pool)
-		if err != nil || size <= 0 {
-			return "", false
-		}
-		start := rand.IntN(size) //nolint:gosec // cryptographic randomness not needed for IP allocation
-		for i := range size {
-			index := (start + i) % size
-			candidate, err := ipamv1.GetIPAddress(pool, index)
-			if err != nil {
-				continue
-			}
-			if _, ok := addresses[candidate]; !ok && candidate != "" {
-				return candidate, true
-			}
-		}
-		return "", false
-	}
-
-	// Sequential allocation (default)
-	index := 0
-	for {
-		candidate, err := ipamv1.GetIPAddress(pool, index)
-		if err != nil {
-			break
-		}
-		index++
-		if _, ok := addresses[candidate]; !ok && candidate != "" {
-			return candidate, true
-		}
-	}
-	return "", false
-}
-
 // formatAddressName renders the name of the IPAddress objects.
 func (m *IPPoolManager) formatAddressName(address ipamv1.IPAddressStr) string {
 	return strings.TrimRight(m.IPPool.Spec.NamePrefix+"-"+strings.Replace(
 		strings.Replace(string(address), ":", "-", -1), ".", "-", -1,
 	), "-")
 }
-
-// check if IPAddressClaim is stamped with an error.
-// Only Conditions[0] is checked because SetConditions
-// is always called with a single-element slice.
-func anyErrorInExistingClaim(addressClaim capipamv1.IPAddressClaim) bool {
-	return len(addressClaim.Status.Conditions) > 0 &&
-		(addressClaim.Status.Conditions[0].Reason == capipamv1.IPAddressClaimReadyAllocationFailedReason ||
-			addressClaim.Status.Conditions[0].Reason == capipamv1.IPAddressClaimReadyPoolExhaustedReason)
-}
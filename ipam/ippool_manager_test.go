@@ -708,6 +708,8 @@ var _ = Describe("IPPool manager", func() {
 			}
 			Expect(nbAllocations).To(Equal(tc.expectedNbAllocations))
 			Expect(tc.ipPool.Status.LastUpdated).ToNot(BeNil())
+			Expect(tc.ipPool.Status.Capacity).ToNot(BeNil())
+			Expect(tc.ipPool.Status.Pools).To(HaveLen(len(tc.ipPool.Spec.Pools)))
 			if tc.expectedAllocations != nil {
 				Expect(tc.ipPool.Status.Allocations).To(Equal(tc.expectedAllocations))
 			} else {
@@ -2107,6 +2109,56 @@ var _ = Describe("IPPool manager", func() {
 			},
 			expectError: true,
 		}),
+		Entry("Excluded addresses are skipped", testCaseAllocateAddress{
+			ipPool: &ipamv1.IPPool{
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{
+							Start:      (*ipamv1.IPAddressStr)(ptr.To("192.168.0.11")),
+							End:        (*ipamv1.IPAddressStr)(ptr.To("192.168.0.20")),
+							Exclusions: []ipamv1.IPExclusionStr{"192.168.0.12-192.168.0.13"},
+						},
+					},
+					Exclusions: []ipamv1.IPExclusionStr{"192.168.0.14"},
+					Prefix:     24,
+					Gateway:    (*ipamv1.IPAddressStr)(ptr.To("192.168.0.1")),
+				},
+			},
+			ipClaim: &ipamv1.IPClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "abc",
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{
+				"192.168.0.11": "bcd",
+			},
+			expectedAddress: ipamv1.IPAddressStr("192.168.0.15"),
+			expectedGateway: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.1")),
+			expectedPrefix:  24,
+		}),
+		Entry("Requested IP excluded", testCaseAllocateAddress{
+			ipPool: &ipamv1.IPPool{
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{
+							Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.11")),
+							End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.20")),
+						},
+					},
+					Exclusions: []ipamv1.IPExclusionStr{"192.168.0.12/30"},
+				},
+			},
+			ipClaim: &ipamv1.IPClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "abc",
+					Annotations: map[string]string{
+						IPAddressAnnotation: "192.168.0.13",
+					},
+				},
+			},
+			addresses:   map[ipamv1.IPAddressStr]string{},
+			expectError: true,
+		}),
 		Entry("One pool, pre-allocated", testCaseAllocateAddress{
 			ipPool: &ipamv1.IPPool{
 				Spec: ipamv1.IPPoolSpec{
@@ -2300,6 +2352,38 @@ var _ = Describe("IPPool manager", func() {
 			expectedGateway: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.1")),
 			expectedPrefix:  24,
 		}),
+		Entry("One pool, with start and existing address, requestedAddress set over the ipAddress annotation", testCaseAllocateAddress{
+			ipPool: &ipamv1.IPPool{
+				Spec: ipamv1.IPPoolSpec{
+					Pools: []ipamv1.Pool{
+						{
+							Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.11")),
+							End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.20")),
+						},
+					},
+					Prefix:  24,
+					Gateway: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.1")),
+				},
+			},
+			ipClaim: &ipamv1.IPClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "TestRef",
+					Annotations: map[string]string{
+						IPAddressAnnotation: "192.168.0.16",
+					},
+				},
+				Spec: ipamv1.IPClaimSpec{
+					RequestedAddress: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.17")),
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{
+				ipamv1.IPAddressStr("192.168.0.12"): "bcde",
+				ipamv1.IPAddressStr("192.168.0.11"): "abcd",
+			},
+			expectedAddress: ipamv1.IPAddressStr("192.168.0.17"),
+			expectedGateway: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.1")),
+			expectedPrefix:  24,
+		}),
 		Entry("One pool, with start and existing address, ipAddress annotation present but already acquired", testCaseAllocateAddress{
 			ipPool: &ipamv1.IPPool{
 				Spec: ipamv1.IPPoolSpec{
@@ -2577,7 +2661,7 @@ var _ = Describe("IPPool manager", func() {
 			},
 			addresses:            map[ipamv1.IPAddressStr]string{},
 			expectError:          true,
-			expectedErrorMessage: ptr.To(`Invalid ipAddress annotation "not-a-valid-ip": not a valid IP address`),
+			expectedErrorMessage: ptr.To(`Invalid requested address "not-a-valid-ip": not a valid IP address`),
 		}),
 	)
 
@@ -3540,7 +3624,7 @@ var _ = Describe("IPPool manager", func() {
 
 		It("should error with exhausted IP pools when no IP is available", func() {
 			// All IPs in the pool are already allocated; the random branch must
-			// surface "exhausted IP pools" rather than returning a duplicate.
+			// surface errPoolExhausted rather than returning a duplicate.
 			ipPool := &ipamv1.IPPool{
 				Spec: ipamv1.IPPoolSpec{
 					Pools: []ipamv1.Pool{
@@ -3566,7 +3650,7 @@ var _ = Describe("IPPool manager", func() {
 			Expect(err).NotTo(HaveOccurred())
 
 			allocatedAddress, _, _, _, err := ipPoolMgr.allocateAddress(ipClaim, addresses)
-			Expect(err).To(MatchError("exhausted IP pools"))
+			Expect(err).To(MatchError(errPoolExhausted))
 			Expect(allocatedAddress).To(Equal(ipamv1.IPAddressStr("")))
 			Expect(ipClaim.Status.ErrorMessage).To(Equal(ptr.To("Exhausted IP Pools")))
 		})
@@ -3609,4 +3693,39 @@ var _ = Describe("IPPool manager", func() {
 		})
 	})
 
+	DescribeTable("Test poolEntryAddress",
+		func(pool ipamv1.Pool, address ipamv1.IPAddressStr, expected ipamv1.IPAddressStr) {
+			result, ok := poolEntryAddress(pool, address)
+			Expect(ok).To(Equal(expected != ""))
+			Expect(result).To(Equal(expected))
+		},
+		Entry("In range", ipamv1.Pool{
+			Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+			End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.20")),
+		}, ipamv1.IPAddressStr("192.168.0.20"), ipamv1.IPAddressStr("192.168.0.20")),
+		Entry("Out of range", ipamv1.Pool{
+			Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+			End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.20")),
+		}, ipamv1.IPAddressStr("192.168.0.21"), ipamv1.IPAddressStr("")),
+		Entry("Excluded", ipamv1.Pool{
+			Start:      (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+			End:        (*ipamv1.IPAddressStr)(ptr.To("192.168.0.20")),
+			Exclusions: []ipamv1.IPExclusionStr{"192.168.0.12-192.168.0.14"},
+		}, ipamv1.IPAddressStr("192.168.0.13"), ipamv1.IPAddressStr("")),
+		Entry("Network address of a subnet", ipamv1.Pool{
+			Subnet: (*ipamv1.IPSubnetStr)(ptr.To("192.168.0.0/24")),
+		}, ipamv1.IPAddressStr("192.168.0.0"), ipamv1.IPAddressStr("")),
+		Entry("Unbounded range", ipamv1.Pool{
+			Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+		}, ipamv1.IPAddressStr("192.168.200.1"), ipamv1.IPAddressStr("192.168.200.1")),
+		Entry("IPv4-mapped IPv6 address", ipamv1.Pool{
+			Subnet: (*ipamv1.IPSubnetStr)(ptr.To("192.168.0.0/24")),
+		}, ipamv1.IPAddressStr("::ffff:c0a8:1"), ipamv1.IPAddressStr("192.168.0.1")),
+		Entry("Canonical IPv6 form", ipamv1.Pool{
+			Subnet: (*ipamv1.IPSubnetStr)(ptr.To("2001:db8::/64")),
+		}, ipamv1.IPAddressStr("2001:DB8:0::0a"), ipamv1.IPAddressStr("2001:db8::a")),
+		Entry("Invalid address", ipamv1.Pool{
+			Subnet: (*ipamv1.IPSubnetStr)(ptr.To("2001:db8::/64")),
+		}, ipamv1.IPAddressStr("abc"), ipamv1.IPAddressStr("")),
+	)
 })
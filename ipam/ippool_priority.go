@@ -0,0 +1,60 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"cmp"
+	"strconv"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PriorityAnnotation sets the priority of a CAPI IPAddressClaim, as the
+// Priority of an IPClaim.
+const PriorityAnnotation = "ipam.metal3.io/priority"
+
+// claimPriority returns the priority of the claim. The priority of a CAPI
+// IPAddressClaim with an invalid annotation is 0.
+func claimPriority(claim client.Object) int32 {
+	switch claim := claim.(type) {
+	case *ipamv1.IPClaim:
+		return claim.Spec.Priority
+	case *capipamv1.IPAddressClaim:
+		if priority, err := strconv.ParseInt(claim.Annotations[PriorityAnnotation], 10, 32); err == nil {
+			return int32(priority)
+		}
+	}
+	return 0
+}
+
+// compareClaims orders the claims waiting for an address: by decreasing
+// priority, then by creation, the oldest first. The namespace and name break
+// the ties, so that the order does not depend on the listing.
+func compareClaims(a, b client.Object) int {
+	if c := cmp.Compare(claimPriority(b), claimPriority(a)); c != 0 {
+		return c
+	}
+	if c := a.GetCreationTimestamp().Compare(b.GetCreationTimestamp().Time); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.GetNamespace(), b.GetNamespace()); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.GetName(), b.GetName())
+}
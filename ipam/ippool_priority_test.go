@@ -0,0 +1,193 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("IPPool claim priority", func() {
+	now := time.Now().Truncate(time.Second)
+
+	newPriorityIPClaim := func(name string, priority int32, age time.Duration) *ipamv1.IPClaim {
+		claim := newTestIPClaim(name)
+		claim.CreationTimestamp = metav1.NewTime(now.Add(-age))
+		claim.Spec.Priority = priority
+		return claim
+	}
+
+	newPriorityIPAddressClaim := func(name, priority string, age time.Duration) *capipamv1.IPAddressClaim {
+		claim := newTestIPAddressClaim(name)
+		claim.CreationTimestamp = metav1.NewTime(now.Add(-age))
+		if priority != "" {
+			claim.Annotations = map[string]string{PriorityAnnotation: priority}
+		}
+		return claim
+	}
+
+	newSmallIPPool := func() *ipamv1.IPPool {
+		return newTestIPPool(newTestPool("192.168.1.10", "192.168.1.11"))
+	}
+
+	// claimAddress returns the name of the IPAddress of the IPClaim or of the
+	// CAPI IPAddressClaim.
+	claimAddress := func(c client.Client, name string) string {
+		claim := &ipamv1.IPClaim{}
+		if err := c.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "myns"}, claim); err == nil {
+			if claim.Status.Address == nil {
+				return ""
+			}
+			return claim.Status.Address.Name
+		}
+		capiClaim := &capipamv1.IPAddressClaim{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "myns"}, capiClaim)).To(Succeed())
+		return capiClaim.Status.AddressRef.Name
+	}
+
+	type testCaseClaimOrder struct {
+		objects           []client.Object
+		expectedAddresses map[string]string
+	}
+
+	DescribeTable("Serves the claims by priority, then the oldest first",
+		func(tc testCaseClaimOrder) {
+			c := newTestClient(tc.objects...)
+			_, err := updateTestAddresses(c, newSmallIPPool())
+			Expect(err).To(MatchError(errPoolExhausted))
+
+			for name, address := range tc.expectedAddresses {
+				Expect(claimAddress(c, name)).To(Equal(address), name)
+			}
+		},
+		Entry("IPClaims", testCaseClaimOrder{
+			objects: []client.Object{
+				newPriorityIPClaim("a-worker-new", 0, time.Minute),
+				newPriorityIPClaim("b-worker-old", 0, time.Hour),
+				newPriorityIPClaim("c-control-plane", 10, 0),
+			},
+			expectedAddresses: map[string]string{
+				"c-control-plane": "abcpref-192-168-1-10",
+				"b-worker-old":    "abcpref-192-168-1-11",
+				"a-worker-new":    "",
+			},
+		}),
+		Entry("CAPI IPAddressClaims", testCaseClaimOrder{
+			objects: []client.Object{
+				newPriorityIPAddressClaim("a-worker", "", time.Hour),
+				newPriorityIPAddressClaim("b-invalid", "high", time.Hour),
+				newPriorityIPAddressClaim("c-control-plane", "10", 0),
+			},
+			expectedAddresses: map[string]string{
+				"c-control-plane": "abcpref-192-168-1-10",
+				"a-worker":        "abcpref-192-168-1-11",
+				"b-invalid":       "",
+			},
+		}),
+		Entry("IPClaims and CAPI IPAddressClaims", testCaseClaimOrder{
+			objects: []client.Object{
+				newPriorityIPClaim("a-worker-old", 0, time.Hour),
+				newPriorityIPAddressClaim("b-control-plane", "10", 0),
+				newPriorityIPClaim("c-worker-new", 0, time.Minute),
+			},
+			expectedAddresses: map[string]string{
+				"b-control-plane": "abcpref-192-168-1-10",
+				"a-worker-old":    "abcpref-192-168-1-11",
+				"c-worker-new":    "",
+			},
+		}),
+	)
+
+	It("Serves a failed IPClaim ahead of a new IPClaim of lower priority", func() {
+		c := newTestClient(newPriorityIPClaim("holder", 0, time.Hour), newPriorityIPClaim("worker", 0, time.Hour))
+		ipPool := newTestIPPool(newTestPool("192.168.1.10", "192.168.1.10"))
+		_, err := updateTestAddresses(c, ipPool)
+		Expect(err).To(MatchError(errPoolExhausted))
+		Expect(c.Create(context.TODO(), newPriorityIPClaim("control-plane", 10, time.Minute))).To(Succeed())
+		_, err = updateTestAddresses(c, ipPool)
+		Expect(err).To(MatchError(errPoolExhausted))
+
+		// The address of the deleted IPClaim goes to the failed IPClaim of
+		// highest priority, not to the new IPClaim.
+		Expect(c.Create(context.TODO(), newPriorityIPClaim("new", 0, 0))).To(Succeed())
+		Expect(c.Delete(context.TODO(), newTestIPClaim("holder"))).To(Succeed())
+		_, err = updateTestAddresses(c, ipPool)
+		Expect(err).To(MatchError(errPoolExhausted))
+		Expect(getTestIPClaim(c, "control-plane").Status.Address).NotTo(BeNil())
+		for _, name := range []string{"worker", "new"} {
+			claim := getTestIPClaim(c, name)
+			Expect(claim.Status.Address).To(BeNil())
+			Expect(claim.Status.ErrorMessage).NotTo(BeNil())
+		}
+	})
+
+	It("Serves the next IPClaims when one fails", func() {
+		invalid := newTestRequestingIPClaim("control-plane", "192.168.2.10")
+		invalid.Spec.Priority = 10
+		c := newTestClient(invalid, newPriorityIPClaim("worker", 0, 0))
+		_, err := updateTestAddresses(c, newSmallIPPool())
+		Expect(err).To(HaveOccurred())
+
+		Expect(getTestIPClaim(c, "control-plane").Status.ErrorMessage).NotTo(BeNil())
+		Expect(getTestIPClaim(c, "worker").Status.Address).NotTo(BeNil())
+	})
+
+	type testCaseCompareClaims struct {
+		claim    client.Object
+		other    client.Object
+		expected int
+	}
+
+	DescribeTable("Orders the claims",
+		func(tc testCaseCompareClaims) {
+			Expect(cmp.Compare(compareClaims(tc.claim, tc.other), 0)).To(Equal(tc.expected))
+		},
+		Entry("Higher priority first", testCaseCompareClaims{
+			claim:    newPriorityIPClaim("a", 1, 0),
+			other:    newPriorityIPClaim("b", 0, time.Hour),
+			expected: -1,
+		}),
+		Entry("Newer claim last", testCaseCompareClaims{
+			claim:    newPriorityIPClaim("a", 0, 0),
+			other:    newPriorityIPClaim("b", 0, time.Hour),
+			expected: 1,
+		}),
+		Entry("Same age, by name", testCaseCompareClaims{
+			claim:    newPriorityIPClaim("a", 0, time.Hour),
+			other:    newPriorityIPClaim("b", 0, time.Hour),
+			expected: -1,
+		}),
+		Entry("Priority annotation of a CAPI claim", testCaseCompareClaims{
+			claim:    newPriorityIPAddressClaim("b", "-5", time.Hour),
+			other:    newPriorityIPClaim("a", 0, 0),
+			expected: 1,
+		}),
+		Entry("Invalid priority annotation of a CAPI claim", testCaseCompareClaims{
+			claim:    newPriorityIPAddressClaim("a", "high", time.Hour),
+			other:    newPriorityIPClaim("b", 0, 0),
+			expected: -1,
+		}),
+	)
+})
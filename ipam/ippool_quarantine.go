@@ -0,0 +1,91 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"net/netip"
+	"time"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The addresses released by the claims are quarantined for the
+// QuarantineDuration of the IPPool. The end of their quarantine is recorded in
+// the status of the IPPool, they are marked as used in the addressSpaces and
+// counted apart in the capacity until then. The IPPool is reconciled again
+// when the first quarantine ends.
+
+// quarantineAddress quarantines a released address for the QuarantineDuration
+// of the IPPool. The allocated subnets are not quarantined.
+func (m *IPPoolManager) quarantineAddress(address ipamv1.IPAddressStr) {
+	duration := m.IPPool.Spec.QuarantineDuration
+	if duration == nil || duration.Duration <= 0 {
+		return
+	}
+	if _, ok := m.subnets[address]; ok {
+		return
+	}
+	if m.IPPool.Status.QuarantinedAddresses == nil {
+		m.IPPool.Status.QuarantinedAddresses = make(map[ipamv1.IPAddressStr]metav1.Time)
+	}
+	m.IPPool.Status.QuarantinedAddresses[address] = metav1.NewTime(time.Now().Add(duration.Duration))
+}
+
+// pruneQuarantinedAddresses ends the quarantine of the addresses whose
+// quarantine expired or that are in use again, and of all addresses if the
+// IPPool does not quarantine addresses anymore.
+func (m *IPPoolManager) pruneQuarantinedAddresses(addresses map[ipamv1.IPAddressStr]string) {
+	duration := m.IPPool.Spec.QuarantineDuration
+	if duration == nil || duration.Duration <= 0 {
+		m.IPPool.Status.QuarantinedAddresses = nil
+		return
+	}
+	now := time.Now()
+	for address, expiry := range m.IPPool.Status.QuarantinedAddresses {
+		if _, ok := addresses[address]; ok || !now.Before(expiry.Time) {
+			delete(m.IPPool.Status.QuarantinedAddresses, address)
+			m.triggerRetries()
+			m.updateStatusTimestamp()
+		}
+	}
+}
+
+// reserveQuarantined marks the quarantined addresses as used in the
+// addressSpace.
+func (s *addressSpace) reserveQuarantined(quarantined map[ipamv1.IPAddressStr]metav1.Time) {
+	for address := range quarantined {
+		if addr, err := netip.ParseAddr(string(address)); err == nil {
+			s.markUsed(addr.Unmap())
+		}
+	}
+}
+
+// quarantineRequeueAfter returns the time until the first quarantine of the
+// IPPool status ends, or zero if no address is quarantined.
+func quarantineRequeueAfter(status ipamv1.IPPoolStatus) time.Duration {
+	var requeueAfter time.Duration
+	for _, expiry := range status.QuarantinedAddresses {
+		// The quarantine ends at the next reconciliation if it already
+		// expired.
+		until := max(time.Until(expiry.Time), time.Second)
+		if requeueAfter == 0 || until < requeueAfter {
+			requeueAfter = until
+		}
+	}
+	return requeueAfter
+}
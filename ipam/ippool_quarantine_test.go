@@ -0,0 +1,109 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("IPPool quarantine", func() {
+	It("Does not allocate a released address until its quarantine ends", func() {
+		c := newTestClient(newTestIPClaim("first"))
+		ipPool := newTestIPPool(newTestPool("192.168.1.10", "192.168.1.11"))
+		ipPool.Spec.QuarantineDuration = &metav1.Duration{Duration: 30 * time.Minute}
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(1))
+		Expect(getTestIPClaim(c, "first").Status.Address.Name).To(Equal("abcpref-192-168-1-10"))
+
+		// The release of the address starts its quarantine.
+		Expect(c.Delete(context.TODO(), newTestIPClaim("first"))).To(Succeed())
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(0))
+		Expect(ipPool.Status.QuarantinedAddresses).To(HaveKey(ipamv1.IPAddressStr("192.168.1.10")))
+		Expect(ipPool.Status.Capacity.Quarantined).To(Equal(1))
+		Expect(ipPool.Status.Capacity.Free).To(Equal("1"))
+		Expect(RequeueAfter(ipPool.Status)).To(BeNumerically("~", 30*time.Minute, time.Minute))
+
+		Expect(c.Create(context.TODO(), newTestIPClaim("second"))).To(Succeed())
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(1))
+		Expect(getTestIPClaim(c, "second").Status.Address.Name).To(Equal("abcpref-192-168-1-11"))
+		Expect(c.Create(context.TODO(), newTestIPClaim("third"))).To(Succeed())
+		_, err := updateTestAddresses(c, ipPool)
+		Expect(err).To(MatchError(errPoolExhausted))
+		Expect(getTestIPClaim(c, "third").Status.Address).To(BeNil())
+
+		// The address can be allocated again once its quarantine ended, to
+		// the failed claim first.
+		ipPool.Status.QuarantinedAddresses["192.168.1.10"] = metav1.NewTime(time.Now().Add(-time.Second))
+		fourth := newTestIPClaim("fourth")
+		fourth.CreationTimestamp = metav1.Now()
+		Expect(c.Create(context.TODO(), fourth)).To(Succeed())
+		nbAllocations, err := updateTestAddresses(c, ipPool)
+		Expect(err).To(MatchError(errPoolExhausted))
+		Expect(nbAllocations).To(Equal(2))
+		Expect(getTestIPClaim(c, "third").Status.Address.Name).To(Equal("abcpref-192-168-1-10"))
+		Expect(getTestIPClaim(c, "fourth").Status.Address).To(BeNil())
+		Expect(ipPool.Status.QuarantinedAddresses).To(BeEmpty())
+		Expect(ipPool.Status.Capacity.Quarantined).To(Equal(0))
+		// The claim failing for lack of addresses waits for a release.
+		Expect(RequeueAfter(ipPool.Status)).To(BeZero())
+	})
+
+	It("Ends the quarantines ending first beyond the status limit", func() {
+		ipPool := &ipamv1.IPPool{
+			Status: ipamv1.IPPoolStatus{
+				QuarantinedAddresses: map[ipamv1.IPAddressStr]metav1.Time{},
+				ReleasedAddresses:    map[ipamv1.IPAddressStr]metav1.Time{},
+			},
+		}
+		now := time.Now()
+		for i := range ipamv1.MaxStatusEntries + 2 {
+			address := ipamv1.IPAddressStr(fmt.Sprintf("10.0.%d.%d", i/256, i%256))
+			ipPool.Status.QuarantinedAddresses[address] = metav1.NewTime(now.Add(time.Duration(i) * time.Second))
+			ipPool.Status.ReleasedAddresses[address] = metav1.NewTime(now.Add(time.Duration(i) * time.Second))
+		}
+		ipPoolMgr, err := NewIPPoolManager(nil, ipPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		ipPoolMgr.boundAddressTimes()
+		Expect(ipPool.Status.QuarantinedAddresses).To(HaveLen(ipamv1.MaxStatusEntries))
+		Expect(ipPool.Status.QuarantinedAddresses).NotTo(HaveKey(ipamv1.IPAddressStr("10.0.0.1")))
+		Expect(ipPool.Status.QuarantinedAddresses).To(HaveKey(ipamv1.IPAddressStr("10.0.0.2")))
+		Expect(ipPool.Status.ReleasedAddresses).To(HaveLen(ipamv1.MaxStatusEntries))
+		Expect(ipPool.Status.ReleasedAddresses).NotTo(HaveKey(ipamv1.IPAddressStr("10.0.0.0")))
+	})
+
+	It("Ends all quarantines when the quarantine duration is unset", func() {
+		ipPool := &ipamv1.IPPool{
+			Status: ipamv1.IPPoolStatus{
+				QuarantinedAddresses: map[ipamv1.IPAddressStr]metav1.Time{
+					"192.168.1.10": metav1.NewTime(time.Now().Add(time.Hour)),
+				},
+			},
+		}
+		ipPoolMgr, err := NewIPPoolManager(nil, ipPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		ipPoolMgr.quarantineAddress("192.168.1.11")
+		ipPoolMgr.pruneQuarantinedAddresses(map[ipamv1.IPAddressStr]string{})
+		Expect(ipPool.Status.QuarantinedAddresses).To(BeNil())
+	})
+})
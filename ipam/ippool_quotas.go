@@ -0,0 +1,108 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The usage of the quotas is counted from the IPAddress objects, which are in
+// the namespace of their claim and carry the labels of their claim, and kept
+// up to date as the addresses are allocated and released.
+
+// quotaGroup returns the group of the quota that a claim of the namespace and
+// with the labels belongs to. It returns false if the quota does not apply to
+// the claim.
+func quotaGroup(quota ipamv1.AllocationQuota, namespace string, claimLabels map[string]string) (string, bool) {
+	switch quota.Scope {
+	case ipamv1.QuotaScopeNamespace:
+		return namespace, true
+	case ipamv1.QuotaScopeCluster:
+		cluster := claimLabels[clusterv1.ClusterNameLabel]
+		if cluster == "" {
+			return "", false
+		}
+		return namespace + "/" + cluster, true
+	case ipamv1.QuotaScopeSelector:
+		if quota.Selector == nil {
+			return "", false
+		}
+		// The selector is validated on admission.
+		selector, err := metav1.LabelSelectorAsSelector(quota.Selector)
+		if err != nil || !selector.Matches(labels.Set(claimLabels)) {
+			return "", false
+		}
+		return "", true
+	}
+	return "", false
+}
+
+// updateQuotaUsage adds delta to the usage of the quotas that apply to a claim
+// of the namespace and with the labels.
+func (m *IPPoolManager) updateQuotaUsage(namespace string, claimLabels map[string]string, delta int) {
+	quotas := m.IPPool.Spec.Quotas
+	if len(quotas) == 0 {
+		return
+	}
+	if len(m.quotaUsage) != len(quotas) {
+		m.quotaUsage = make([]map[string]int, len(quotas))
+		for i := range m.quotaUsage {
+			m.quotaUsage[i] = make(map[string]int)
+		}
+	}
+	for i, quota := range quotas {
+		if group, ok := quotaGroup(quota, namespace, claimLabels); ok {
+			m.quotaUsage[i][group] = max(m.quotaUsage[i][group]+delta, 0)
+		}
+	}
+}
+
+// exceededQuota returns the error describing the first quota that a new
+// allocation to the claim would exceed, or nil if the claim is within all the
+// quotas.
+func (m *IPPoolManager) exceededQuota(claim client.Object) *AllocationError {
+	for i, quota := range m.IPPool.Spec.Quotas {
+		group, ok := quotaGroup(quota, claim.GetNamespace(), claim.GetLabels())
+		if !ok {
+			continue
+		}
+		usage := 0
+		if i < len(m.quotaUsage) {
+			usage = m.quotaUsage[i][group]
+		}
+		if usage < quota.MaxAllocations {
+			continue
+		}
+		switch quota.Scope {
+		case ipamv1.QuotaScopeNamespace:
+			return newAllocationError(ErrorReasonQuotaExceeded,
+				"Quota of %d allocations exceeded for namespace %s", quota.MaxAllocations, group)
+		case ipamv1.QuotaScopeCluster:
+			return newAllocationError(ErrorReasonQuotaExceeded,
+				"Quota of %d allocations exceeded for cluster %s", quota.MaxAllocations, group)
+		default:
+			return newAllocationError(ErrorReasonQuotaExceeded,
+				"Quota of %d allocations exceeded for the claims matching %s",
+				quota.MaxAllocations, metav1.FormatLabelSelector(quota.Selector))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("IPPool quotas", func() {
+	newClusterIPClaim := func(name, cluster string) *ipamv1.IPClaim {
+		claim := newTestIPClaim(name)
+		if cluster != "" {
+			claim.Labels = map[string]string{clusterv1.ClusterNameLabel: cluster}
+		}
+		return claim
+	}
+
+	newAppIPAddressClaim := func(name, app string) *capipamv1.IPAddressClaim {
+		claim := newTestIPAddressClaim(name)
+		claim.Labels = map[string]string{"app": app}
+		return claim
+	}
+
+	newQuotaIPPool := func(quota ipamv1.AllocationQuota) *ipamv1.IPPool {
+		ipPool := newTestIPPool(newTestPool("192.168.1.10", "192.168.1.20"))
+		ipPool.Spec.Quotas = []ipamv1.AllocationQuota{quota}
+		return ipPool
+	}
+
+	// claimStatus returns the name of the IPAddress and the Ready reason of
+	// the IPClaim or of the CAPI IPAddressClaim.
+	claimStatus := func(c client.Client, name string) (string, string) {
+		claim := &ipamv1.IPClaim{}
+		if err := c.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "myns"}, claim); err == nil {
+			reason := conditions.GetReason(claim, ipamv1.IPClaimReadyCondition)
+			if claim.Status.Address == nil {
+				return "", reason
+			}
+			return claim.Status.Address.Name, reason
+		}
+		capiClaim := &capipamv1.IPAddressClaim{}
+		Expect(c.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "myns"}, capiClaim)).To(Succeed())
+		if len(capiClaim.Status.Conditions) == 0 {
+			return capiClaim.Status.AddressRef.Name, ""
+		}
+		return capiClaim.Status.AddressRef.Name, capiClaim.Status.Conditions[0].Reason
+	}
+
+	type testCaseQuota struct {
+		objects       []client.Object
+		quota         ipamv1.AllocationQuota
+		expectedError string
+		servedClaims  []string
+		refusedClaims []string
+	}
+
+	DescribeTable("Limits the allocations of the quota groups",
+		func(tc testCaseQuota) {
+			c := newTestClient(tc.objects...)
+			_, err := updateTestAddresses(c, newQuotaIPPool(tc.quota))
+			Expect(err).To(MatchError(tc.expectedError))
+
+			for _, name := range tc.servedClaims {
+				address, _ := claimStatus(c, name)
+				Expect(address).NotTo(BeEmpty(), name)
+			}
+			for _, name := range tc.refusedClaims {
+				address, reason := claimStatus(c, name)
+				Expect(address).To(BeEmpty(), name)
+				Expect(reason).To(Equal(ipamv1.IPClaimQuotaExceededReason), name)
+			}
+		},
+		Entry("Cluster scope", testCaseQuota{
+			objects: []client.Object{
+				newClusterIPClaim("c1-0", "c1"),
+				newClusterIPClaim("c1-1", "c1"),
+				newClusterIPClaim("c2-0", "c2"),
+				newClusterIPClaim("other", ""),
+			},
+			quota:         ipamv1.AllocationQuota{Scope: ipamv1.QuotaScopeCluster, MaxAllocations: 1},
+			expectedError: "Quota of 1 allocations exceeded for cluster myns/c1",
+			servedClaims:  []string{"c1-0", "c2-0", "other"},
+			refusedClaims: []string{"c1-1"},
+		}),
+		Entry("Selector scope on CAPI IPAddressClaims", testCaseQuota{
+			objects: []client.Object{
+				newAppIPAddressClaim("capi-claim", "db"),
+				newAppIPAddressClaim("capi-other", "web"),
+			},
+			quota: ipamv1.AllocationQuota{
+				Scope:    ipamv1.QuotaScopeSelector,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+			},
+			expectedError: "Quota of 0 allocations exceeded for the claims matching app=db",
+			servedClaims:  []string{"capi-other"},
+			refusedClaims: []string{"capi-claim"},
+		}),
+	)
+
+	It("Frees the quota when an address is released", func() {
+		first := newTestIPClaim("first")
+		c := newTestClient(first)
+		ipPool := newQuotaIPPool(ipamv1.AllocationQuota{Scope: ipamv1.QuotaScopeNamespace, MaxAllocations: 1})
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(1))
+
+		// The address of the deleted claim is released before the next claim
+		// is served, in the same reconciliation.
+		Expect(c.Delete(context.TODO(), first)).To(Succeed())
+		Expect(c.Create(context.TODO(), newTestIPClaim("second"))).To(Succeed())
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(1))
+		Expect(getTestIPClaim(c, "second").Status.Address).NotTo(BeNil())
+	})
+
+	type testCaseQuotaGroup struct {
+		quota         ipamv1.AllocationQuota
+		labels        map[string]string
+		expectedGroup string
+		expectedOK    bool
+	}
+
+	DescribeTable("Groups the claims of the quotas",
+		func(tc testCaseQuotaGroup) {
+			group, ok := quotaGroup(tc.quota, "myns", tc.labels)
+			Expect(ok).To(Equal(tc.expectedOK))
+			Expect(group).To(Equal(tc.expectedGroup))
+		},
+		Entry("Namespace scope", testCaseQuotaGroup{
+			quota:         ipamv1.AllocationQuota{Scope: ipamv1.QuotaScopeNamespace},
+			expectedGroup: "myns",
+			expectedOK:    true,
+		}),
+		Entry("Cluster scope, claim of no cluster", testCaseQuotaGroup{
+			quota: ipamv1.AllocationQuota{Scope: ipamv1.QuotaScopeCluster},
+		}),
+		Entry("Cluster scope", testCaseQuotaGroup{
+			quota:         ipamv1.AllocationQuota{Scope: ipamv1.QuotaScopeCluster},
+			labels:        map[string]string{clusterv1.ClusterNameLabel: "c1", "app": "db"},
+			expectedGroup: "myns/c1",
+			expectedOK:    true,
+		}),
+		Entry("Selector scope, claim not matching", testCaseQuotaGroup{
+			quota: ipamv1.AllocationQuota{
+				Scope:    ipamv1.QuotaScopeSelector,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			},
+			labels: map[string]string{clusterv1.ClusterNameLabel: "c1", "app": "db"},
+		}),
+	)
+})
@@ -0,0 +1,278 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"cmp"
+	"context"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// With the retain ReclaimPolicy, the IPAddress of a deleted claim is not
+// deleted. Its owner reference to the claim is removed and it is annotated
+// with the end of the reservation, so that it keeps the address allocated to
+// the claim name, in the IPAddress objects like any other allocation. When a
+// claim of the same name is created, it is bound to the IPAddress again. The
+// reservation ends when it expires, when the IPAddress is deleted or when the
+// IPPool is deleted, and the IPAddress is then released on the next
+// reconciliation, before the claims are served.
+
+const (
+	// ReclaimPolicyAnnotation overrides the ReclaimPolicy of the IPPool for
+	// an IPAddressClaim.
+	ReclaimPolicyAnnotation = "ipam.metal3.io/reclaim-policy"
+	// ReservedUntilAnnotation marks an IPAddress reserved for a deleted
+	// claim, with the end of the reservation in RFC 3339 format, or an empty
+	// value when the reservation does not expire.
+	ReservedUntilAnnotation = "ipam.metal3.io/reserved-until"
+)
+
+// reclaimPolicy returns the ReclaimPolicy of the claim, which defaults to the
+// ReclaimPolicy of the IPPool.
+func (m *IPPoolManager) reclaimPolicy(claim client.Object) ipamv1.ReclaimPolicy {
+	switch claim := claim.(type) {
+	case *ipamv1.IPClaim:
+		if claim.Spec.ReclaimPolicy != "" {
+			return claim.Spec.ReclaimPolicy
+		}
+	case *capipamv1.IPAddressClaim:
+		if policy := claim.Annotations[ReclaimPolicyAnnotation]; policy != "" {
+			return ipamv1.ReclaimPolicy(policy)
+		}
+	}
+	if m.IPPool.Spec.ReclaimPolicy != "" {
+		return m.IPPool.Spec.ReclaimPolicy
+	}
+	return ipamv1.ReclaimPolicyDelete
+}
+
+// mustRetain returns true if the address of the claim must be reserved for
+// its name instead of being released. Only the addresses of deleted claims
+// are retained, and not when the IPPool is deleted. The pre-allocated
+// addresses are already reserved for the claim name.
+func (m *IPPoolManager) mustRetain(claim client.Object, allocationKey string) bool {
+	if claim.GetDeletionTimestamp().IsZero() || !m.IPPool.DeletionTimestamp.IsZero() {
+		return false
+	}
+	if _, ok := m.IPPool.Spec.PreAllocations[allocationKey]; ok {
+		return false
+	}
+	return m.reclaimPolicy(claim) == ipamv1.ReclaimPolicyRetain
+}
+
+// retainAddress reserves the IPAddress of a deleted claim for the claim name.
+func (m *IPPoolManager) retainAddress(ctx context.Context, ipAddress, claim client.Object,
+	claimKind string, address ipamv1.IPAddressStr,
+) error {
+	var expiresAt *metav1.Time
+	value := ""
+	if ttl := m.IPPool.Spec.ReservationTTL; ttl != nil {
+		expiresAt = &metav1.Time{Time: time.Now().Add(ttl.Duration).Truncate(time.Second)}
+		value = expiresAt.UTC().Format(time.RFC3339)
+	}
+	annotations := ipAddress.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[ReservedUntilAnnotation] = value
+	ipAddress.SetAnnotations(annotations)
+	ipAddress.SetOwnerReferences(slices.DeleteFunc(ipAddress.GetOwnerReferences(), func(ref metav1.OwnerReference) bool {
+		return ref.UID == claim.GetUID()
+	}))
+	if err := updateObject(ctx, m.client, ipAddress); err != nil {
+		return err
+	}
+
+	m.setReservation(m.allocationKey(claim), ipamv1.AddressReservation{
+		Claim: corev1.ObjectReference{
+			Kind:      claimKind,
+			Name:      claim.GetName(),
+			Namespace: claim.GetNamespace(),
+		},
+		Address:   address,
+		ExpiresAt: expiresAt,
+	})
+	m.Log.Info("IPAddress reserved for the claim name", "IPAddress", ipAddress.GetName())
+	m.recordNormal(claim, claimKind, AddressRetainedReason, "Retained address %s", address)
+	return nil
+}
+
+// rebindAddress binds the IPAddress reserved for the name of the claim to
+// the claim again. It is a no-op if the address of the claim is not reserved.
+func (m *IPPoolManager) rebindAddress(ctx context.Context, ipAddress, claim client.Object,
+	claimKind string, address ipamv1.IPAddressStr,
+) error {
+	allocationKey := m.allocationKey(claim)
+	if _, ok := m.reservations[allocationKey]; !ok {
+		return nil
+	}
+	key := client.ObjectKey{
+		Name:      m.formatAddressName(address),
+		Namespace: m.claimNamespace(claim),
+	}
+	if err := m.client.Get(ctx, key, ipAddress); err != nil {
+		return err
+	}
+	annotations := ipAddress.GetAnnotations()
+	delete(annotations, ReservedUntilAnnotation)
+	ipAddress.SetAnnotations(annotations)
+	apiVersion, kind := claim.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+	ipAddress.SetOwnerReferences(append(ipAddress.GetOwnerReferences(), metav1.OwnerReference{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Name:       claim.GetName(),
+		UID:        claim.GetUID(),
+	}))
+	if err := updateObject(ctx, m.client, ipAddress); err != nil {
+		return err
+	}
+
+	delete(m.reservations, allocationKey)
+	m.Log.Info("Reserved IPAddress bound to the claim again", "IPAddress", ipAddress.GetName())
+	m.recordNormal(claim, claimKind, AddressReboundReason, "Bound reserved address %s again", address)
+	return nil
+}
+
+// expiredReservation is an IPAddress whose reservation ended, released by
+// releaseReservations.
+type expiredReservation struct {
+	ipAddress client.Object
+	finalizer string
+	address   ipamv1.IPAddressStr
+}
+
+// checkReservation tracks the reservation of an IPAddress retained for a
+// deleted claim. It returns false if the reservation expired, the IPAddress
+// was deleted or the IPPool is deleted, the IPAddress is then released by
+// releaseReservations.
+func (m *IPPoolManager) checkReservation(ipAddress client.Object, finalizer string,
+	allocationKey string, claim corev1.ObjectReference, address ipamv1.IPAddressStr,
+) bool {
+	value, ok := ipAddress.GetAnnotations()[ReservedUntilAnnotation]
+	if !ok {
+		return true
+	}
+	var expiresAt *metav1.Time
+	if expiry, err := time.Parse(time.RFC3339, value); err == nil {
+		expiresAt = &metav1.Time{Time: expiry}
+	}
+	if ipAddress.GetDeletionTimestamp().IsZero() && m.IPPool.DeletionTimestamp.IsZero() &&
+		(expiresAt == nil || time.Now().Before(expiresAt.Time)) {
+		m.setReservation(allocationKey, ipamv1.AddressReservation{
+			Claim:     claim,
+			Address:   address,
+			ExpiresAt: expiresAt,
+		})
+		return true
+	}
+	m.expiredReservations = append(m.expiredReservations, expiredReservation{
+		ipAddress: ipAddress,
+		finalizer: finalizer,
+		address:   address,
+	})
+	return false
+}
+
+// releaseReservations releases the IPAddresses whose reservation ended, before
+// their addresses are allocated again.
+func (m *IPPoolManager) releaseReservations(ctx context.Context) error {
+	for _, reservation := range m.expiredReservations {
+		ipAddress := reservation.ipAddress
+		ipAddress.SetFinalizers(Filter(ipAddress.GetFinalizers(), reservation.finalizer))
+		if err := updateObject(ctx, m.client, ipAddress); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		if ipAddress.GetDeletionTimestamp().IsZero() {
+			if err := deleteObject(ctx, m.client, ipAddress); err != nil {
+				return err
+			}
+		}
+		m.Log.Info("Reservation ended, IPAddress released", "IPAddress", ipAddress.GetName())
+		m.untrackCreatedAddress(reservation.address)
+		m.recordReleasedAddress(reservation.address)
+		m.quarantineAddress(reservation.address)
+		m.triggerRetries()
+		m.updateStatusTimestamp()
+	}
+	m.expiredReservations = nil
+	return nil
+}
+
+// setReservation records the reservation of an address for a deleted claim.
+func (m *IPPoolManager) setReservation(allocationKey string, reservation ipamv1.AddressReservation) {
+	if m.reservations == nil {
+		m.reservations = make(map[string]ipamv1.AddressReservation)
+	}
+	m.reservations[allocationKey] = reservation
+}
+
+// updateReservations lists the reservations in the status of the IPPool.
+// Only the ipamv1.MaxStatusEntries reservations expiring first are listed,
+// the others are still recorded on their IPAddress.
+func (m *IPPoolManager) updateReservations() {
+	var reservations []ipamv1.AddressReservation
+	for _, reservation := range m.reservations {
+		reservations = append(reservations, reservation)
+	}
+	if len(reservations) > ipamv1.MaxStatusEntries {
+		slices.SortFunc(reservations, func(a, b ipamv1.AddressReservation) int {
+			switch {
+			case a.ExpiresAt == nil && b.ExpiresAt == nil:
+				return strings.Compare(string(a.Address), string(b.Address))
+			case a.ExpiresAt == nil:
+				return 1
+			case b.ExpiresAt == nil:
+				return -1
+			}
+			return cmp.Or(a.ExpiresAt.Compare(b.ExpiresAt.Time), strings.Compare(string(a.Address), string(b.Address)))
+		})
+		reservations = reservations[:ipamv1.MaxStatusEntries]
+	}
+	slices.SortFunc(reservations, func(a, b ipamv1.AddressReservation) int {
+		return strings.Compare(string(a.Address), string(b.Address))
+	})
+	if !reflect.DeepEqual(reservations, m.IPPool.Status.Reservations) {
+		m.IPPool.Status.Reservations = reservations
+		m.updateStatusTimestamp()
+	}
+}
+
+// reservationRequeueAfter returns the time until the first reservation of the
+// IPPool status expires, or zero if no reservation expires.
+func reservationRequeueAfter(status ipamv1.IPPoolStatus) time.Duration {
+	var requeueAfter time.Duration
+	for _, reservation := range status.Reservations {
+		if reservation.ExpiresAt == nil {
+			continue
+		}
+		until := max(time.Until(reservation.ExpiresAt.Time), time.Second)
+		if requeueAfter == 0 || until < requeueAfter {
+			requeueAfter = until
+		}
+	}
+	return requeueAfter
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("IPPool reservations", func() {
+	newPolicyIPClaim := func(name string, policy ipamv1.ReclaimPolicy) *ipamv1.IPClaim {
+		claim := newTestIPClaim(name)
+		claim.Spec.ReclaimPolicy = policy
+		return claim
+	}
+
+	newRetainingIPPool := func() *ipamv1.IPPool {
+		ipPool := newTestIPPool(newTestPool("192.168.1.10", "192.168.1.20"))
+		ipPool.Spec.ReclaimPolicy = ipamv1.ReclaimPolicyRetain
+		ipPool.Spec.ReservationTTL = &metav1.Duration{Duration: time.Hour}
+		return ipPool
+	}
+
+	claimAddress := func(c client.Client, name string) string {
+		claim := getTestIPClaim(c, name)
+		Expect(claim.Status.Address).NotTo(BeNil())
+		return claim.Status.Address.Name
+	}
+
+	It("Binds the address retained for a deleted IPClaim to the IPClaim of the same name", func() {
+		c := newTestClient(newTestIPClaim("node-0"), newPolicyIPClaim("node-1", ipamv1.ReclaimPolicyDelete))
+		ipPool := newRetainingIPPool()
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(2))
+		Expect(claimAddress(c, "node-0")).To(Equal("abcpref-192-168-1-10"))
+		Expect(claimAddress(c, "node-1")).To(Equal("abcpref-192-168-1-11"))
+
+		// The address of node-0 is retained, the one of node-1 is released.
+		Expect(c.Delete(context.TODO(), newTestIPClaim("node-0"))).To(Succeed())
+		Expect(c.Delete(context.TODO(), newTestIPClaim("node-1"))).To(Succeed())
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(1))
+		ipAddress := getTestIPAddress(c, "abcpref-192-168-1-10")
+		Expect(ipAddress.Annotations).To(HaveKey(ReservedUntilAnnotation))
+		Expect(ipAddress.OwnerReferences).To(BeEmpty())
+		err := c.Get(context.TODO(), client.ObjectKey{Name: "abcpref-192-168-1-11", Namespace: "myns"}, &ipamv1.IPAddress{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(ipPool.Status.Reservations).To(HaveLen(1))
+		Expect(ipPool.Status.Reservations[0].Claim).To(Equal(corev1.ObjectReference{Kind: "IPClaim", Name: "node-0", Namespace: "myns"}))
+		Expect(ipPool.Status.Reservations[0].Address).To(Equal(ipamv1.IPAddressStr("192.168.1.10")))
+		Expect(RequeueAfter(ipPool.Status)).To(BeNumerically("~", time.Hour, time.Minute))
+
+		// The reserved address is not allocated to other claims, and is
+		// bound again to the re-created node-0.
+		Expect(c.Create(context.TODO(), newTestIPClaim("node-2"))).To(Succeed())
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(2))
+		Expect(claimAddress(c, "node-2")).To(Equal("abcpref-192-168-1-11"))
+		Expect(c.Create(context.TODO(), newTestIPClaim("node-0"))).To(Succeed())
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(2))
+		Expect(claimAddress(c, "node-0")).To(Equal("abcpref-192-168-1-10"))
+		ipAddress = getTestIPAddress(c, "abcpref-192-168-1-10")
+		Expect(ipAddress.Annotations).NotTo(HaveKey(ReservedUntilAnnotation))
+		Expect(ipAddress.OwnerReferences).To(HaveLen(1))
+		Expect(ipAddress.OwnerReferences[0].Name).To(Equal("node-0"))
+		Expect(ipPool.Status.Reservations).To(BeEmpty())
+	})
+
+	It("Releases the reserved address once the reservation expired", func() {
+		c := newTestClient(newTestIPClaim("node-0"))
+		ipPool := newRetainingIPPool()
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(1))
+		Expect(c.Delete(context.TODO(), newTestIPClaim("node-0"))).To(Succeed())
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(1))
+
+		ipAddress := getTestIPAddress(c, "abcpref-192-168-1-10")
+		ipAddress.Annotations[ReservedUntilAnnotation] = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+		Expect(c.Update(context.TODO(), ipAddress)).To(Succeed())
+
+		// Listing the addresses does not release the IPAddress, it is
+		// released when the claims are served.
+		ipPoolMgr, err := NewIPPoolManager(c, ipPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		addresses, err := ipPoolMgr.getIndexes(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(addresses).NotTo(HaveKey(ipamv1.IPAddressStr("192.168.1.10")))
+		Expect(getTestIPAddress(c, "abcpref-192-168-1-10").Finalizers).To(ContainElement(ipamv1.IPAddressFinalizer))
+
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(0))
+		err = c.Get(context.TODO(), client.ObjectKey{Name: "abcpref-192-168-1-10", Namespace: "myns"}, &ipamv1.IPAddress{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(ipPool.Status.Reservations).To(BeEmpty())
+	})
+
+	type testCaseReclaimPolicy struct {
+		claim          client.Object
+		expectedPolicy ipamv1.ReclaimPolicy
+	}
+
+	DescribeTable("Follows the reclaim policy of the claims",
+		func(tc testCaseReclaimPolicy) {
+			ipPoolMgr, err := NewIPPoolManager(nil, newRetainingIPPool(), logr.Discard())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ipPoolMgr.reclaimPolicy(tc.claim)).To(Equal(tc.expectedPolicy))
+		},
+		Entry("Policy of the IPPool", testCaseReclaimPolicy{
+			claim:          newTestIPClaim("node-0"),
+			expectedPolicy: ipamv1.ReclaimPolicyRetain,
+		}),
+		Entry("Policy of the IPClaim", testCaseReclaimPolicy{
+			claim:          newPolicyIPClaim("node-0", ipamv1.ReclaimPolicyDelete),
+			expectedPolicy: ipamv1.ReclaimPolicyDelete,
+		}),
+		Entry("Annotation of the CAPI IPAddressClaim", testCaseReclaimPolicy{
+			claim: &capipamv1.IPAddressClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{ReclaimPolicyAnnotation: string(ipamv1.ReclaimPolicyDelete)},
+				},
+			},
+			expectedPolicy: ipamv1.ReclaimPolicyDelete,
+		}),
+	)
+
+	It("Retains the addresses of the deleted claims only", func() {
+		ipPoolMgr, err := NewIPPoolManager(nil, newRetainingIPPool(), logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		claim := newTestIPClaim("node-0")
+		Expect(ipPoolMgr.mustRetain(claim, "node-0")).To(BeFalse())
+		claim.DeletionTimestamp = ptr.To(metav1.Now())
+		Expect(ipPoolMgr.mustRetain(claim, "node-0")).To(BeTrue())
+		ipPoolMgr.IPPool.Spec.PreAllocations = map[string]ipamv1.IPAddressStr{"node-0": "192.168.1.15"}
+		Expect(ipPoolMgr.mustRetain(claim, "node-0")).To(BeFalse())
+	})
+})
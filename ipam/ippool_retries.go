@@ -0,0 +1,198 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"cmp"
+	"errors"
+	"reflect"
+	"slices"
+	"time"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The claims whose allocation failed are not served on every reconciliation,
+// since their allocation would fail the same way. They are tracked in the
+// status of the IPPool, and served again, in priority order with the other
+// waiting claims, as soon as the spec of the IPPool changed or the IPPool
+// released addresses. The claims that failed while the IPPool had free
+// addresses are also served again after a backoff period, which doubles with
+// each failed attempt. A claim that gets an address is cleared of its failure.
+
+const (
+	// retryBaseDelay is the delay between the first failed attempt and the
+	// next one.
+	retryBaseDelay = 10 * time.Second
+	// retryMaxDelay bounds the delay between two attempts.
+	retryMaxDelay = 5 * time.Minute
+)
+
+// retryBackoff returns the minimum delay after the last of the given number
+// of failed attempts.
+func retryBackoff(attempts int32) time.Duration {
+	delay := retryBaseDelay
+	for i := int32(1); i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, retryMaxDelay)
+}
+
+// retryReference returns the reference to a claim in the retries.
+func retryReference(claim client.Object, claimKind string) corev1.ObjectReference {
+	return corev1.ObjectReference{
+		Kind:      claimKind,
+		Name:      claim.GetName(),
+		Namespace: claim.GetNamespace(),
+	}
+}
+
+// loadRetries loads the retries from the status of the IPPool, and schedules
+// the retries of the claims that failed with a previous spec of the IPPool
+// right away.
+func (m *IPPoolManager) loadRetries() {
+	m.retries = make(map[corev1.ObjectReference]*ipamv1.ClaimRetry, len(m.IPPool.Status.Retries))
+	m.failedClaims = make(map[corev1.ObjectReference]bool)
+	now := metav1.Now()
+	for _, retry := range m.IPPool.Status.Retries {
+		retry := retry.DeepCopy()
+		if retry.PoolGeneration != m.IPPool.Generation {
+			retry.RetryAt = &now
+		}
+		m.retries[retry.Claim] = retry
+	}
+}
+
+// triggerRetries schedules the retries of all the failed claims right away,
+// when addresses are released. The claims still waiting are served in the
+// same reconciliation, the others in the next one.
+func (m *IPPoolManager) triggerRetries() {
+	now := metav1.Now()
+	for _, retry := range m.retries {
+		retry.RetryAt = &now
+	}
+}
+
+// mustRetry returns true if the failed claim must be served again. A failed
+// claim without retry, for example that failed before its retries were
+// tracked, is tracked from now on and served again after the backoff.
+func (m *IPPoolManager) mustRetry(claim client.Object, claimKind string) bool {
+	ref := retryReference(claim, claimKind)
+	m.failedClaims[ref] = true
+	retry, ok := m.retries[ref]
+	if !ok {
+		// Only the oldest failures are listed in the status when too many
+		// claims failed, the others are served on every reconciliation.
+		if len(m.IPPool.Status.Retries) >= ipamv1.MaxStatusEntries {
+			return true
+		}
+		now := metav1.Now()
+		m.retries[ref] = &ipamv1.ClaimRetry{
+			Claim:          ref,
+			Attempts:       1,
+			LastAttempt:    now,
+			PoolGeneration: m.IPPool.Generation,
+			RetryAt:        &metav1.Time{Time: now.Add(retryBackoff(1))},
+		}
+		return false
+	}
+	return retry.RetryAt != nil && !time.Now().Before(retry.RetryAt.Time)
+}
+
+// recordFailedAttempt records a failed attempt to serve the claim. A claim
+// that failed for lack of free addresses waits for addresses to be released,
+// any other failure is retried after the backoff.
+func (m *IPPoolManager) recordFailedAttempt(claim client.Object, claimKind string, err error) {
+	ref := retryReference(claim, claimKind)
+	m.failedClaims[ref] = true
+	retry, ok := m.retries[ref]
+	if !ok {
+		retry = &ipamv1.ClaimRetry{Claim: ref}
+		m.retries[ref] = retry
+	}
+	retry.Attempts++
+	retry.LastAttempt = metav1.Now()
+	retry.PoolGeneration = m.IPPool.Generation
+	retry.RetryAt = nil
+	var allocationError *AllocationError
+	if errors.As(err, &allocationError) && !waitsForAddresses(allocationError.Reason) {
+		retry.RetryAt = &metav1.Time{Time: retry.LastAttempt.Add(retryBackoff(retry.Attempts))}
+	}
+}
+
+// waitsForAddresses returns true if the allocations failing with the reason
+// can only succeed once the IPPool released addresses or changed.
+func waitsForAddresses(reason ErrorReason) bool {
+	return reason == ErrorReasonExhausted || reason == ErrorReasonQuotaExceeded
+}
+
+// clearRetry stops tracking a claim that was served.
+func (m *IPPoolManager) clearRetry(claim client.Object, claimKind string) {
+	ref := retryReference(claim, claimKind)
+	delete(m.retries, ref)
+	delete(m.failedClaims, ref)
+}
+
+// updateRetries lists the retries in the status of the IPPool. When prune is
+// set, all the claims were examined, and the retries of the claims that are
+// not failed anymore are dropped. Only the ipamv1.MaxStatusEntries oldest
+// failures are listed.
+func (m *IPPoolManager) updateRetries(prune bool) {
+	var retries []ipamv1.ClaimRetry
+	for ref, retry := range m.retries {
+		if prune && !m.failedClaims[ref] {
+			continue
+		}
+		retries = append(retries, *retry)
+	}
+	if len(retries) > ipamv1.MaxStatusEntries {
+		slices.SortFunc(retries, func(a, b ipamv1.ClaimRetry) int {
+			return a.LastAttempt.Compare(b.LastAttempt.Time)
+		})
+		retries = retries[:ipamv1.MaxStatusEntries]
+	}
+	slices.SortFunc(retries, func(a, b ipamv1.ClaimRetry) int {
+		return cmp.Or(
+			cmp.Compare(a.Claim.Namespace, b.Claim.Namespace),
+			cmp.Compare(a.Claim.Name, b.Claim.Name),
+			cmp.Compare(a.Claim.Kind, b.Claim.Kind),
+		)
+	})
+	if !reflect.DeepEqual(retries, m.IPPool.Status.Retries) {
+		m.IPPool.Status.Retries = retries
+		m.updateStatusTimestamp()
+	}
+}
+
+// retryRequeueAfter returns the time until the first scheduled retry of the
+// IPPool status, or zero if no retry is scheduled.
+func retryRequeueAfter(status ipamv1.IPPoolStatus) time.Duration {
+	var requeueAfter time.Duration
+	for _, retry := range status.Retries {
+		if retry.RetryAt == nil {
+			continue
+		}
+		until := max(time.Until(retry.RetryAt.Time), time.Second)
+		if requeueAfter == 0 || until < requeueAfter {
+			requeueAfter = until
+		}
+	}
+	return requeueAfter
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("IPPool retries", func() {
+	newSingleIPPool := func() *ipamv1.IPPool {
+		ipPool := newTestIPPool(newTestPool("192.168.1.10", "192.168.1.10"))
+		ipPool.Generation = 1
+		return ipPool
+	}
+
+	// skipBackoff moves the scheduled retries back in time, as if their
+	// backoff elapsed.
+	skipBackoff := func(ipPool *ipamv1.IPPool) {
+		for i := range ipPool.Status.Retries {
+			if ipPool.Status.Retries[i].RetryAt != nil {
+				ipPool.Status.Retries[i].RetryAt = &metav1.Time{Time: time.Now().Add(-time.Second)}
+			}
+		}
+	}
+
+	It("Serves a failed IPClaim again once an address is released", func() {
+		c := newTestClient(newTestIPClaim("first"), newTestIPClaim("second"))
+		ipPool := newSingleIPPool()
+		_, err := updateTestAddresses(c, ipPool)
+		Expect(err).To(MatchError(errPoolExhausted))
+		Expect(ipPool.Status.Retries).To(HaveLen(1))
+		Expect(ipPool.Status.Retries[0].Claim).To(Equal(corev1.ObjectReference{Kind: "IPClaim", Name: "second", Namespace: "myns"}))
+		Expect(ipPool.Status.Retries[0].Attempts).To(Equal(int32(1)))
+		Expect(ipPool.Status.Retries[0].RetryAt).To(BeNil())
+
+		// The failed IPClaim is not served while the IPPool is unchanged.
+		_, err = updateTestAddresses(c, ipPool)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ipPool.Status.Retries).To(HaveLen(1))
+		Expect(RequeueAfter(ipPool.Status)).To(BeZero())
+
+		Expect(c.Delete(context.TODO(), newTestIPClaim("first"))).To(Succeed())
+		_, err = updateTestAddresses(c, ipPool)
+		Expect(err).NotTo(HaveOccurred())
+		claim := getTestIPClaim(c, "second")
+		Expect(claim.Status.Address).NotTo(BeNil())
+		Expect(claim.Status.ErrorMessage).To(BeNil())
+		Expect(conditions.IsTrue(claim, ipamv1.IPClaimReadyCondition)).To(BeTrue())
+		Expect(ipPool.Status.Retries).To(BeEmpty())
+	})
+
+	It("Serves a failed CAPI IPAddressClaim again once the IPPool changed", func() {
+		// The CAPI IPAddressClaim is served last, being the newest claim.
+		capiClaim := newTestIPAddressClaim("capi-claim")
+		capiClaim.CreationTimestamp = metav1.Now()
+		c := newTestClient(newTestIPClaim("first"), capiClaim)
+		ipPool := newSingleIPPool()
+		_, err := updateTestAddresses(c, ipPool)
+		Expect(err).To(MatchError(errPoolExhausted))
+		Expect(ipPool.Status.Retries).To(HaveLen(1))
+
+		// The failed claim is served as soon as the IPPool changed.
+		ipPool.Generation = 2
+		ipPool.Spec.Pools[0].End = (*ipamv1.IPAddressStr)(ptr.To("192.168.1.11"))
+		_, err = updateTestAddresses(c, ipPool)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(capiClaim), capiClaim)).To(Succeed())
+		Expect(capiClaim.Status.AddressRef.Name).To(Equal("abcpref-192-168-1-11"))
+		Expect(capiClaim.Status.Conditions[0].Reason).To(Equal(ipamv1.IPClaimAddressAllocatedReason))
+		Expect(ipPool.Status.Retries).To(BeEmpty())
+	})
+
+	It("Serves a claim failing while addresses are free after the backoff", func() {
+		c := newTestClient(newTestIPClaim("first"), newTestRequestingIPClaim("second", "192.168.1.10"))
+		ipPool := newSingleIPPool()
+		ipPool.Spec.Pools[0].End = (*ipamv1.IPAddressStr)(ptr.To("192.168.1.11"))
+		_, err := updateTestAddresses(c, ipPool)
+		Expect(err).To(HaveOccurred())
+		Expect(ipPool.Status.Retries).To(HaveLen(1))
+		Expect(ipPool.Status.Retries[0].RetryAt).NotTo(BeNil())
+		Expect(RequeueAfter(ipPool.Status)).To(BeNumerically("~", retryBaseDelay, time.Second))
+
+		// The claim is not served before the backoff elapsed.
+		_, err = updateTestAddresses(c, ipPool)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ipPool.Status.Retries[0].Attempts).To(Equal(int32(1)))
+
+		skipBackoff(ipPool)
+		_, err = updateTestAddresses(c, ipPool)
+		Expect(err).To(HaveOccurred())
+		Expect(ipPool.Status.Retries[0].Attempts).To(Equal(int32(2)))
+		Expect(RequeueAfter(ipPool.Status)).To(BeNumerically("~", 2*retryBaseDelay, time.Second))
+	})
+
+	It("Tracks a failed claim without retry", func() {
+		failed := newTestIPClaim("first")
+		failed.Status.ErrorMessage = ptr.To("Exhausted IP Pools")
+		c := newTestClient(failed)
+		ipPool := newSingleIPPool()
+		_, err := updateTestAddresses(c, ipPool)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ipPool.Status.Retries).To(HaveLen(1))
+		Expect(RequeueAfter(ipPool.Status)).To(BeNumerically("~", retryBaseDelay, time.Second))
+
+		skipBackoff(ipPool)
+		_, err = updateTestAddresses(c, ipPool)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getTestIPClaim(c, "first").Status.Address).NotTo(BeNil())
+		Expect(ipPool.Status.Retries).To(BeEmpty())
+	})
+
+	It("Lists the oldest failures only", func() {
+		ipPool := newSingleIPPool()
+		ipPoolMgr, err := NewIPPoolManager(nil, ipPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		now := time.Now()
+		for i := range ipamv1.MaxStatusEntries + 1 {
+			ref := corev1.ObjectReference{Kind: "IPClaim", Name: fmt.Sprintf("claim-%d", i), Namespace: "myns"}
+			ipPoolMgr.retries[ref] = &ipamv1.ClaimRetry{
+				Claim:       ref,
+				Attempts:    1,
+				LastAttempt: metav1.NewTime(now.Add(-time.Duration(i) * time.Second)),
+			}
+		}
+		ipPoolMgr.updateRetries(false)
+		Expect(ipPool.Status.Retries).To(HaveLen(ipamv1.MaxStatusEntries))
+		Expect(ipPool.Status.Retries).NotTo(ContainElement(HaveField("Claim.Name", "claim-0")))
+
+		// The failed claims that are not listed are served again.
+		claim := newTestIPClaim("claim-0")
+		ipPoolMgr, err = NewIPPoolManager(nil, ipPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ipPoolMgr.mustRetry(claim, claimKindIPClaim)).To(BeTrue())
+	})
+
+	DescribeTable("Bounds the backoff of the retries",
+		func(attempts int32, expectedBackoff time.Duration) {
+			Expect(retryBackoff(attempts)).To(Equal(expectedBackoff))
+		},
+		Entry("First attempt", int32(1), retryBaseDelay),
+		Entry("Second attempt", int32(2), 2*retryBaseDelay),
+		Entry("Many attempts", int32(100), retryMaxDelay),
+	)
+})
@@ -0,0 +1,57 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"maps"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The IPAddress objects are the source of truth of the allocations: they are
+// listed on every reconciliation to rebuild the allocations of the IPPool.
+// By default the allocations are also recorded in the status of the IPPool.
+// With the ipAddresses AllocationStorage, they are only kept in memory during
+// the reconciliation, so that the size of the IPPool does not grow with the
+// number of allocations, and the status keeps the capacity and conditions.
+
+// addressNamespaces returns the namespaces in which the IPAddresses of the
+// IPPool are listed. Without the allocations recorded in the status, the
+// namespaces that still hold allocations after their grant was revoked are
+// only known once the IPAddresses are listed, so all namespaces are listed.
+func (m *IPPoolManager) addressNamespaces(ctx context.Context) ([]string, error) {
+	if !ipamv1.StoresAllocationsInStatus(m.IPPool.Spec) {
+		return []string{metav1.NamespaceAll}, nil
+	}
+	namespaces, _, err := m.claimNamespaces(ctx)
+	return namespaces, err
+}
+
+// setAllocations sets the allocations of the IPPool, and records them in its
+// status when the IPPool records its allocations in its status.
+func (m *IPPoolManager) setAllocations(allocations map[string]ipamv1.IPAddressStr) {
+	m.allocations = allocations
+	if !ipamv1.StoresAllocationsInStatus(m.IPPool.Spec) {
+		allocations = nil
+	}
+	if !maps.Equal(allocations, m.IPPool.Status.Allocations) {
+		m.updateStatusTimestamp()
+	}
+	m.IPPool.Status.Allocations = allocations
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("IPPool allocation storage", func() {
+	newStoringIPPool := func() *ipamv1.IPPool {
+		ipPool := newTestIPPool(newTestPool("192.168.1.10", "192.168.1.20"))
+		ipPool.Spec.AllocationStorage = ipamv1.AllocationStorageIPAddresses
+		return ipPool
+	}
+
+	It("Records the allocations in the IPAddress objects only", func() {
+		c := newTestClient(newTestIPClaim("first"), newTestIPClaim("second"))
+		ipPool := newStoringIPPool()
+		ipPool.Status.Allocations = map[string]ipamv1.IPAddressStr{
+			"stale": "192.168.1.20",
+		}
+		nbAllocations, err := updateTestAddresses(c, ipPool)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nbAllocations).To(Equal(2))
+		Expect(ipPool.Status.Allocations).To(BeNil())
+		Expect(ipPool.Status.Capacity.Allocated).To(Equal(2))
+		Expect(ipPool.Status.Capacity.Free).To(Equal("9"))
+
+		// The next reconciliation finds the allocations in the IPAddress
+		// objects.
+		Expect(c.Create(context.TODO(), newTestIPClaim("third"))).To(Succeed())
+		ipPool = ipPool.DeepCopy()
+		nbAllocations, err = updateTestAddresses(c, ipPool)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nbAllocations).To(Equal(3))
+		Expect(ipPool.Status.Allocations).To(BeNil())
+
+		claim := getTestIPClaim(c, "third")
+		Expect(claim.Status.Address).To(Equal(&corev1.ObjectReference{Name: "abcpref-192-168-1-12", Namespace: "myns"}))
+	})
+})
@@ -0,0 +1,295 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"errors"
+	"math/big"
+	"net"
+	"slices"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// An IPClaim with a prefix length gets a subnet instead of a single address.
+// The subnet is aligned on its prefix length and carved out of the Subnet of a
+// pool entry, within its Start and End. It never overlaps the excluded
+// addresses, the allocated and pre-allocated addresses, nor the other
+// allocated subnets, and a free subnet never holds a quarantined address. Its
+// network address is recorded in the allocations of the IPPool, and the
+// allocated subnets are excluded from the pool entries when allocating single
+// addresses.
+
+// addSubnet records a subnet allocated to an IPClaim.
+func (m *IPPoolManager) addSubnet(address ipamv1.IPAddressStr, subnet ipamv1.IPSubnetStr) {
+	_, ipNet, err := net.ParseCIDR(string(subnet))
+	if err != nil {
+		m.Log.Info("Failed to parse allocated subnet", "subnet", subnet)
+		return
+	}
+	if m.subnets == nil {
+		m.subnets = make(map[ipamv1.IPAddressStr]*net.IPNet)
+	}
+	m.subnets[address] = ipNet
+	m.addressSpaces = nil
+}
+
+var (
+	// errNoSubnetFits is returned when no pool entry has a subnet that can
+	// hold the requested prefix length.
+	errNoSubnetFits = errors.New("no pool entry has a subnet that can hold the prefix length")
+	// errRequestedSubnetUnavailable is returned when the requested subnet is
+	// not aligned, out of bounds or overlaps addresses in use.
+	errRequestedSubnetUnavailable = errors.New("requested subnet not available")
+	// errSubnetsExhausted is returned when no free subnet of the requested
+	// prefix length is left.
+	errSubnetsExhausted = errors.New("exhausted IP pools")
+	// errNoMatchingPoolEntry is returned when no pool entry matches the pool
+	// entry selector.
+	errNoMatchingPoolEntry = errors.New("no pool entry matches the pool entry selector")
+)
+
+// getPools returns the pool entries of the IPPool, with the allocated subnets
+// merged into their exclusions.
+func (m *IPPoolManager) getPools() []ipamv1.Pool {
+	return m.getPoolsExcept("")
+}
+
+// getPoolsExcept returns the pool entries of the IPPool, with the allocated
+// subnets, except the one of the given network address, merged into their
+// exclusions.
+func (m *IPPoolManager) getPoolsExcept(skip ipamv1.IPAddressStr) []ipamv1.Pool {
+	pools := ipamv1.GetPools(m.poolSpec())
+	subnets := make([]ipamv1.IPExclusionStr, 0, len(m.subnets))
+	for address, ipNet := range m.subnets {
+		if address != skip {
+			subnets = append(subnets, ipamv1.IPExclusionStr(ipNet.String()))
+		}
+	}
+	if len(subnets) == 0 {
+		return pools
+	}
+	slices.Sort(subnets)
+	for i := range pools {
+		pools[i].Exclusions = append(pools[i].Exclusions, subnets...)
+	}
+	return pools
+}
+
+// allocateSubnet gets a subnet of the prefix length of the IPClaim. A
+// pre-allocated address of the IPClaim is the network address of its subnet.
+// Returns the network address, the prefix length, no gateway and a list of
+// DNS servers.
+func (m *IPPoolManager) allocateSubnet(addressClaim *ipamv1.IPClaim,
+	addresses map[ipamv1.IPAddressStr]string,
+) (ipamv1.IPAddressStr, int, *ipamv1.IPAddressStr, []ipamv1.IPAddressStr, error) {
+	prefixLength := addressClaim.Spec.PrefixLength
+	preAllocatedAddress := m.IPPool.Spec.PreAllocations[m.allocationKey(addressClaim)]
+
+	selector, err := poolEntrySelector(addressClaim)
+	if err != nil {
+		return "", 0, nil, []ipamv1.IPAddressStr{}, m.failAllocation(addressClaim, claimKindIPClaim,
+			newAllocationError(ErrorReasonInvalidPoolEntrySelector, "Invalid pool entry selector: %v", err))
+	}
+
+	address, dnsServers, err := m.selectSubnet(prefixLength, preAllocatedAddress, preAllocatedAddress, selector, addresses)
+	switch {
+	case err == nil:
+		return address, prefixLength, nil, dnsServers, nil
+	case errors.Is(err, errNoMatchingPoolEntry):
+		return "", 0, nil, []ipamv1.IPAddressStr{}, m.failAllocation(addressClaim, claimKindIPClaim, errNoPoolEntryMatches)
+	case errors.Is(err, errNoSubnetFits):
+		return "", 0, nil, []ipamv1.IPAddressStr{}, m.failAllocation(addressClaim, claimKindIPClaim,
+			noSubnetFits(prefixLength))
+	case errors.Is(err, errRequestedSubnetUnavailable):
+		return "", 0, nil, []ipamv1.IPAddressStr{}, m.failAllocation(addressClaim, claimKindIPClaim, errPreAllocatedSubnetOutOfBounds)
+	default:
+		return "", 0, nil, []ipamv1.IPAddressStr{}, m.failAllocation(addressClaim, claimKindIPClaim, errPoolExhausted)
+	}
+}
+
+// selectSubnet finds a free subnet of the prefix length and returns its
+// network address and the DNS servers of its pool entry. Only the pool entries
+// matching the selector are considered. If requested is set, only the subnet
+// starting at that address is considered. The address in use and the
+// allocated subnet at the skip address are ignored, so that a pre-allocated or
+// an allocated subnet can be obtained again.
+func (m *IPPoolManager) selectSubnet(prefixLength int, requested, skip ipamv1.IPAddressStr,
+	selector labels.Selector, addresses map[ipamv1.IPAddressStr]string,
+) (ipamv1.IPAddressStr, []ipamv1.IPAddressStr, error) {
+	used := make([][2]*big.Int, 0, len(addresses))
+	for address := range addresses {
+		ip := net.ParseIP(string(address))
+		if ip == nil || (skip != "" && m.ipEqual(address, skip)) {
+			continue
+		}
+		used = append(used, [2]*big.Int{ipToInt(ip), ipToInt(ip)})
+	}
+	if requested == "" {
+		// As for single addresses, only a requested subnet may hold a
+		// quarantined address.
+		for address := range m.IPPool.Status.QuarantinedAddresses {
+			if ip := net.ParseIP(string(address)); ip != nil {
+				used = append(used, [2]*big.Int{ipToInt(ip), ipToInt(ip)})
+			}
+		}
+	}
+
+	matched, fits := false, false
+	for _, pool := range m.getPoolsExcept(skip) {
+		if !selector.Matches(labels.Set(pool.Labels)) {
+			continue
+		}
+		matched = true
+		first, last, size, ok := subnetBounds(pool, prefixLength)
+		if !ok {
+			continue
+		}
+		fits = true
+
+		blocked := slices.Clone(used)
+		for _, exclusion := range pool.Exclusions {
+			if firstIP, lastIP, err := ipamv1.ParseExclusion(exclusion); err == nil {
+				blocked = append(blocked, [2]*big.Int{ipToInt(firstIP), ipToInt(lastIP)})
+			}
+		}
+
+		var start *big.Int
+		if requested != "" {
+			start = requestedSubnet(requested, first, last, size, blocked)
+		} else {
+			start = freeSubnet(first, last, size, blocked)
+		}
+		if start == nil {
+			continue
+		}
+
+		dnsServers := m.IPPool.Spec.DNSServers
+		if len(pool.DNSServers) != 0 {
+			dnsServers = pool.DNSServers
+		}
+		ip := intToIP(start)
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		return ipamv1.IPAddressStr(ip.String()), dnsServers, nil
+	}
+
+	switch {
+	case !matched && !selector.Empty():
+		return "", nil, errNoMatchingPoolEntry
+	case !fits:
+		return "", nil, errNoSubnetFits
+	case requested != "":
+		return "", nil, errRequestedSubnetUnavailable
+	default:
+		return "", nil, errSubnetsExhausted
+	}
+}
+
+// subnetBounds returns the first and the last address of the pool entry
+// that subnets of the prefix length can use, and the size of such subnets.
+// It returns false if the pool entry has no Subnet, or if its Subnet cannot
+// hold a subnet of the prefix length.
+func subnetBounds(pool ipamv1.Pool, prefixLength int) (*big.Int, *big.Int, *big.Int, bool) {
+	if pool.Subnet == nil {
+		return nil, nil, nil, false
+	}
+	_, ipNet, err := net.ParseCIDR(string(*pool.Subnet))
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	ones, bits := ipNet.Mask.Size()
+	if prefixLength < ones || prefixLength > bits {
+		return nil, nil, nil, false
+	}
+	size := new(big.Int).Lsh(big.NewInt(1), uint(bits-prefixLength))
+	first := ipToInt(ipNet.IP)
+	last := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	last.Add(last, first).Sub(last, big.NewInt(1))
+	if pool.Start != nil {
+		if ip := net.ParseIP(string(*pool.Start)); ip != nil && ipToInt(ip).Cmp(first) > 0 {
+			first = ipToInt(ip)
+		}
+	}
+	if pool.End != nil {
+		if ip := net.ParseIP(string(*pool.End)); ip != nil && ipToInt(ip).Cmp(last) < 0 {
+			last = ipToInt(ip)
+		}
+	}
+	return first, last, size, true
+}
+
+// freeSubnet returns the network address of the first subnet of the given
+// size between first and last that does not overlap any blocked range, or nil
+// if there is none.
+func freeSubnet(first, last, size *big.Int, blocked [][2]*big.Int) *big.Int {
+	slices.SortFunc(blocked, func(a, b [2]*big.Int) int { return a[0].Cmp(b[0]) })
+	start := alignUp(first, size)
+	for {
+		end := new(big.Int).Add(start, size)
+		end.Sub(end, big.NewInt(1))
+		if end.Cmp(last) > 0 {
+			return nil
+		}
+		overlap := overlappingRange(start, end, blocked)
+		if overlap == nil {
+			return start
+		}
+		start = alignUp(new(big.Int).Add(overlap[1], big.NewInt(1)), size)
+	}
+}
+
+// requestedSubnet returns the network address of the subnet of the given
+// size starting at the requested address, or nil if that subnet is not
+// aligned, not between first and last, or overlaps a blocked range.
+func requestedSubnet(address ipamv1.IPAddressStr, first, last, size *big.Int, blocked [][2]*big.Int) *big.Int {
+	ip := net.ParseIP(string(address))
+	if ip == nil {
+		return nil
+	}
+	start := ipToInt(ip)
+	end := new(big.Int).Add(start, size)
+	end.Sub(end, big.NewInt(1))
+	if new(big.Int).Mod(start, size).Sign() != 0 || start.Cmp(first) < 0 || end.Cmp(last) > 0 {
+		return nil
+	}
+	if overlappingRange(start, end, blocked) != nil {
+		return nil
+	}
+	return start
+}
+
+// overlappingRange returns the first blocked range that overlaps the range
+// from start to end, or nil if there is none.
+func overlappingRange(start, end *big.Int, blocked [][2]*big.Int) *[2]*big.Int {
+	for i := range blocked {
+		if blocked[i][0].Cmp(end) <= 0 && blocked[i][1].Cmp(start) >= 0 {
+			return &blocked[i]
+		}
+	}
+	return nil
+}
+
+// alignUp rounds the value up to a multiple of size.
+func alignUp(value, size *big.Int) *big.Int {
+	aligned := new(big.Int).Add(value, size)
+	aligned.Sub(aligned, big.NewInt(1))
+	aligned.Div(aligned, size)
+	return aligned.Mul(aligned, size)
+}
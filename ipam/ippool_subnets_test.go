@@ -0,0 +1,163 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"context"
+	"time"
+
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("IPPool subnets", func() {
+	newSubnetIPPool := func(subnet string) *ipamv1.IPPool {
+		return newTestIPPool(ipamv1.Pool{Subnet: (*ipamv1.IPSubnetStr)(ptr.To(subnet))})
+	}
+
+	newSubnetIPClaim := func(name string, prefixLength int) *ipamv1.IPClaim {
+		claim := newTestIPClaim(name)
+		claim.Spec.PrefixLength = prefixLength
+		return claim
+	}
+
+	It("Keeps single addresses out of the allocated subnets", func() {
+		c := newTestClient(newSubnetIPClaim("block", 28))
+		ipPool := newSubnetIPPool("192.168.0.0/24")
+		ipPool.Spec.PreAllocations = map[string]ipamv1.IPAddressStr{"other": "192.168.0.20"}
+
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(2))
+		Expect(ipPool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{"block": "192.168.0.0"}))
+		address := getTestIPAddress(c, "abcpref-192-168-0-0")
+		Expect(address.Spec.Address).To(Equal(ipamv1.IPAddressStr("192.168.0.0")))
+		Expect(address.Spec.Prefix).To(Equal(28))
+		Expect(address.Spec.Subnet).To(Equal((*ipamv1.IPSubnetStr)(ptr.To("192.168.0.0/28"))))
+		Expect(ipPool.Status.Capacity.Allocated).To(Equal(16))
+
+		// A single address is allocated after the subnet, and the next subnet
+		// skips the pre-allocated address.
+		Expect(c.Create(context.TODO(), newSubnetIPClaim("host", 0))).To(Succeed())
+		Expect(c.Create(context.TODO(), newSubnetIPClaim("other-block", 28))).To(Succeed())
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(4))
+		Expect(ipPool.Status.Allocations).To(Equal(map[string]ipamv1.IPAddressStr{
+			"block":       "192.168.0.0",
+			"host":        "192.168.0.16",
+			"other-block": "192.168.0.32",
+		}))
+
+		// The subnets are found again on the next reconciliation.
+		ipPool = newSubnetIPPool("192.168.0.0/24")
+		Expect(c.Create(context.TODO(), newSubnetIPClaim("host2", 0))).To(Succeed())
+		Expect(updateTestAddresses(c, ipPool)).To(Equal(4))
+		Expect(ipPool.Status.Allocations).To(HaveKeyWithValue("host2", ipamv1.IPAddressStr("192.168.0.17")))
+		Expect(ipPool.Status.Capacity.Allocated).To(Equal(34))
+	})
+
+	type testCaseAllocateSubnets struct {
+		subnet              string
+		claims              []client.Object
+		preAllocations      map[string]ipamv1.IPAddressStr
+		quarantined         []ipamv1.IPAddressStr
+		expectedAllocations map[string]ipamv1.IPAddressStr
+		expectedSubnets     map[string]string
+	}
+
+	DescribeTable("Allocates subnets",
+		func(tc testCaseAllocateSubnets) {
+			c := newTestClient(tc.claims...)
+			ipPool := newSubnetIPPool(tc.subnet)
+			ipPool.Spec.PreAllocations = tc.preAllocations
+			ipPool.Spec.QuarantineDuration = &metav1.Duration{Duration: time.Hour}
+			for _, address := range tc.quarantined {
+				if ipPool.Status.QuarantinedAddresses == nil {
+					ipPool.Status.QuarantinedAddresses = map[ipamv1.IPAddressStr]metav1.Time{}
+				}
+				ipPool.Status.QuarantinedAddresses[address] = metav1.NewTime(time.Now().Add(time.Hour))
+			}
+
+			Expect(updateTestAddresses(c, ipPool)).To(Equal(len(tc.claims)))
+			Expect(ipPool.Status.Allocations).To(Equal(tc.expectedAllocations))
+			for name, subnet := range tc.expectedSubnets {
+				Expect(getTestIPAddress(c, name).Spec.Subnet).To(Equal((*ipamv1.IPSubnetStr)(ptr.To(subnet))))
+			}
+		},
+		Entry("IPv6 subnets", testCaseAllocateSubnets{
+			subnet: "2001:db8::/48",
+			claims: []client.Object{newSubnetIPClaim("block1", 64), newSubnetIPClaim("block2", 64)},
+			expectedAllocations: map[string]ipamv1.IPAddressStr{
+				"block1": "2001:db8::",
+				"block2": "2001:db8:0:1::",
+			},
+			expectedSubnets: map[string]string{
+				"abcpref-2001-db8-0-1": "2001:db8:0:1::/64",
+			},
+		}),
+		Entry("Pre-allocated subnet", testCaseAllocateSubnets{
+			subnet:              "192.168.0.0/24",
+			claims:              []client.Object{newSubnetIPClaim("block", 28)},
+			preAllocations:      map[string]ipamv1.IPAddressStr{"block": "192.168.0.64"},
+			expectedAllocations: map[string]ipamv1.IPAddressStr{"block": "192.168.0.64"},
+			expectedSubnets: map[string]string{
+				"abcpref-192-168-0-64": "192.168.0.64/28",
+			},
+		}),
+		Entry("Subnet holding a quarantined address", testCaseAllocateSubnets{
+			subnet:              "192.168.0.0/24",
+			claims:              []client.Object{newSubnetIPClaim("block", 28)},
+			quarantined:         []ipamv1.IPAddressStr{"192.168.0.0", "192.168.0.20"},
+			expectedAllocations: map[string]ipamv1.IPAddressStr{"block": "192.168.0.32"},
+			expectedSubnets: map[string]string{
+				"abcpref-192-168-0-32": "192.168.0.32/28",
+			},
+		}),
+		Entry("Pre-allocated subnet holding a quarantined address", testCaseAllocateSubnets{
+			subnet:              "192.168.0.0/24",
+			claims:              []client.Object{newSubnetIPClaim("block", 28)},
+			preAllocations:      map[string]ipamv1.IPAddressStr{"block": "192.168.0.64"},
+			quarantined:         []ipamv1.IPAddressStr{"192.168.0.70"},
+			expectedAllocations: map[string]ipamv1.IPAddressStr{"block": "192.168.0.64"},
+			expectedSubnets: map[string]string{
+				"abcpref-192-168-0-64": "192.168.0.64/28",
+			},
+		}),
+	)
+
+	DescribeTable("Fails to allocate a subnet",
+		func(prefixLength int, preAllocation string, expectedMessage string) {
+			c := newTestClient(newSubnetIPClaim("block", prefixLength))
+			ipPool := newSubnetIPPool("192.168.0.0/24")
+			ipPool.Spec.Exclusions = []ipamv1.IPExclusionStr{"192.168.0.100"}
+			if preAllocation != "" {
+				ipPool.Spec.PreAllocations = map[string]ipamv1.IPAddressStr{"block": ipamv1.IPAddressStr(preAllocation)}
+			}
+
+			_, err := updateTestAddresses(c, ipPool)
+			Expect(err).To(HaveOccurred())
+			Expect(ipPool.Status.Allocations).To(BeEmpty())
+			Expect(getTestIPClaim(c, "block").Status.ErrorMessage).To(Equal(ptr.To(expectedMessage)))
+		},
+		Entry("Prefix length shorter than the subnet", 20, "", "No pool entry has a subnet that can hold a /20 subnet"),
+		Entry("Prefix length longer than the family", 64, "", "No pool entry has a subnet that can hold a /64 subnet"),
+		Entry("Subnet containing an excluded address", 24, "", "Exhausted IP Pools"),
+		Entry("Pre-allocation not aligned", 28, "192.168.0.8", "Pre-allocated subnet out of bounds"),
+		Entry("Pre-allocation containing an excluded address", 28, "192.168.0.96", "Pre-allocated subnet out of bounds"),
+	)
+})
@@ -19,6 +19,7 @@ package ipam
 import (
 	"github.com/go-logr/logr"
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -26,19 +27,42 @@ type ManagerFactoryInterface interface {
 	NewIPPoolManager(*ipamv1.IPPool, logr.Logger) (
 		IPPoolManagerInterface, error,
 	)
+	NewGlobalIPPoolManager(*ipamv1.GlobalIPPool, logr.Logger) (
+		IPPoolManagerInterface, error,
+	)
 }
 
-// ManagerFactory only contains a client.
+// ManagerFactory contains a client, an event recorder, and the addresses
+// allocated by its managers that the cache may not have observed yet.
 type ManagerFactory struct {
-	client client.Client
+	client   client.Client
+	recorder record.EventRecorder
+	created  *createdAddresses
 }
 
 // NewManagerFactory returns a new factory.
-func NewManagerFactory(client client.Client) ManagerFactory {
-	return ManagerFactory{client: client}
+func NewManagerFactory(client client.Client, recorder record.EventRecorder) ManagerFactory {
+	return ManagerFactory{client: client, recorder: recorder, created: newCreatedAddresses()}
 }
 
 // NewIPPoolManager creates a new IPPoolManager.
 func (f ManagerFactory) NewIPPoolManager(ipPool *ipamv1.IPPool, metadataLog logr.Logger) (IPPoolManagerInterface, error) {
-	return NewIPPoolManager(f.client, ipPool, metadataLog)
+	ipPoolMgr, err := NewIPPoolManager(f.client, ipPool, metadataLog)
+	if err != nil {
+		return nil, err
+	}
+	ipPoolMgr.recorder = f.recorder
+	ipPoolMgr.created = f.created
+	return ipPoolMgr, nil
+}
+
+// NewGlobalIPPoolManager creates a new GlobalIPPoolManager.
+func (f ManagerFactory) NewGlobalIPPoolManager(globalIPPool *ipamv1.GlobalIPPool, metadataLog logr.Logger) (IPPoolManagerInterface, error) {
+	globalIPPoolMgr, err := NewGlobalIPPoolManager(f.client, globalIPPool, metadataLog)
+	if err != nil {
+		return nil, err
+	}
+	globalIPPoolMgr.recorder = f.recorder
+	globalIPPoolMgr.created = f.created
+	return globalIPPoolMgr, nil
 }
@@ -21,27 +21,40 @@ import (
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 var _ = Describe("Manager factory testing", func() {
 	var managerClient client.Client
+	var managerRecorder record.EventRecorder
 	var managerFactory ManagerFactory
 	clusterLog := logr.Discard()
 
 	BeforeEach(func() {
 		managerClient = fakeclient.NewClientBuilder().WithScheme(setupScheme()).Build()
-		managerFactory = NewManagerFactory(managerClient)
+		managerRecorder = record.NewFakeRecorder(10)
+		managerFactory = NewManagerFactory(managerClient, managerRecorder)
 	})
 
 	It("returns a manager factory", func() {
 		Expect(managerFactory.client).To(Equal(managerClient))
+		Expect(managerFactory.recorder).To(Equal(managerRecorder))
 	})
 
 	It("returns an IPPool manager", func() {
-		_, err := managerFactory.NewIPPoolManager(&ipamv1.IPPool{}, clusterLog)
+		ipPoolMgr, err := managerFactory.NewIPPoolManager(&ipamv1.IPPool{}, clusterLog)
 		Expect(err).NotTo(HaveOccurred())
+		Expect(ipPoolMgr.(*IPPoolManager).recorder).To(Equal(managerRecorder))
+		Expect(ipPoolMgr.(*IPPoolManager).created).To(BeIdenticalTo(managerFactory.created))
+	})
+
+	It("returns a GlobalIPPool manager", func() {
+		globalIPPoolMgr, err := managerFactory.NewGlobalIPPoolManager(&ipamv1.GlobalIPPool{}, clusterLog)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(globalIPPoolMgr.(*GlobalIPPoolManager).recorder).To(Equal(managerRecorder))
+		Expect(globalIPPoolMgr.(*GlobalIPPoolManager).created).To(BeIdenticalTo(managerFactory.created))
 	})
 
 })
@@ -0,0 +1,214 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	metricsNamespace = "ipam"
+
+	labelNamespace = "namespace"
+	labelPool      = "pool"
+	labelClaimKind = "claim_kind"
+	labelReason    = "reason"
+
+	// claimKindIPClaim is the claim_kind label of the metal3 IPClaim.
+	claimKindIPClaim = "IPClaim"
+	// claimKindIPAddressClaim is the claim_kind label of the CAPI IPAddressClaim.
+	claimKindIPAddressClaim = "IPAddressClaim"
+	// claimKindIPPool is the claim_kind label of a child IPPool.
+	claimKindIPPool = "IPPool"
+)
+
+// AllocationFailureReason is the reason label of the allocation failures metric.
+type AllocationFailureReason string
+
+const (
+	// AllocationFailureExhausted is used when no address is left in the pools.
+	AllocationFailureExhausted AllocationFailureReason = "exhausted"
+	// AllocationFailurePreAllocationOutOfBounds is used when the pre-allocated
+	// address of the claim is not in any pool.
+	AllocationFailurePreAllocationOutOfBounds AllocationFailureReason = "preallocation_out_of_bounds"
+	// AllocationFailureRequestedIPUnavailable is used when the address
+	// requested by the claim is already in use.
+	AllocationFailureRequestedIPUnavailable AllocationFailureReason = "requested_ip_unavailable"
+	// AllocationFailureConflict is used when the requested address conflicts
+	// with the pre-allocated address of the claim.
+	AllocationFailureConflict AllocationFailureReason = "conflict"
+	// AllocationFailureInvalidRequestedIP is used when the requested address
+	// is not a valid IP address.
+	AllocationFailureInvalidRequestedIP AllocationFailureReason = "invalid_requested_ip"
+	// AllocationFailureInvalidPrefix is used when the prefix of the pool is
+	// not a valid prefix length.
+	AllocationFailureInvalidPrefix AllocationFailureReason = "invalid_prefix"
+	// AllocationFailureIPFamilyConflict is used when both pools of a
+	// dual-stack IPClaim allocate an address of the same IP family.
+	AllocationFailureIPFamilyConflict AllocationFailureReason = "ip_family_conflict"
+	// AllocationFailureInvalidPrefixLength is used when no pool entry has a
+	// subnet that can hold the prefix length requested by the claim.
+	AllocationFailureInvalidPrefixLength AllocationFailureReason = "invalid_prefix_length"
+	// AllocationFailureInvalidPoolEntrySelector is used when the pool entry
+	// selector of the claim cannot be parsed.
+	AllocationFailureInvalidPoolEntrySelector AllocationFailureReason = "invalid_pool_entry_selector"
+	// AllocationFailureNoMatchingPoolEntry is used when no pool entry matches
+	// the pool entry selector of the claim.
+	AllocationFailureNoMatchingPoolEntry AllocationFailureReason = "no_matching_pool_entry"
+	// AllocationFailureQuotaExceeded is used when the allocation would exceed
+	// an allocation quota of the pool.
+	AllocationFailureQuotaExceeded AllocationFailureReason = "quota_exceeded"
+	// AllocationFailureNameCollision is used when the IPAddress of the
+	// allocated address already exists for another pool.
+	AllocationFailureNameCollision AllocationFailureReason = "name_collision"
+	// AllocationFailureAddressCreationFailed is used when the IPAddress of
+	// the claim cannot be created.
+	AllocationFailureAddressCreationFailed AllocationFailureReason = "address_creation_failed"
+)
+
+var (
+	poolSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "ippool_size",
+			Help:      "Number of addresses in the IPPool. Not reported when the size cannot be computed.",
+		},
+		[]string{labelNamespace, labelPool},
+	)
+
+	poolUsedAddresses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "ippool_used_addresses",
+			Help:      "Number of allocated and pre-allocated addresses in the IPPool.",
+		},
+		[]string{labelNamespace, labelPool},
+	)
+
+	poolFreeAddresses = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "ippool_free_addresses",
+			Help:      "Number of free addresses in the IPPool. Not reported when the size cannot be computed.",
+		},
+		[]string{labelNamespace, labelPool},
+	)
+
+	addressAllocations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "address_allocations_total",
+			Help:      "Number of addresses allocated from the IPPool.",
+		},
+		[]string{labelNamespace, labelPool, labelClaimKind},
+	)
+
+	addressReleases = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "address_releases_total",
+			Help:      "Number of addresses released to the IPPool.",
+		},
+		[]string{labelNamespace, labelPool, labelClaimKind},
+	)
+
+	addressAllocationFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "address_allocation_failures_total",
+			Help:      "Number of failed address allocations from the IPPool, by reason.",
+		},
+		[]string{labelNamespace, labelPool, labelClaimKind, labelReason},
+	)
+
+	claimBindingDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "claim_binding_duration_seconds",
+			Help:      "Time from the creation of a claim to the binding of its address.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 14),
+		},
+		[]string{labelClaimKind},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		poolSize,
+		poolUsedAddresses,
+		poolFreeAddresses,
+		addressAllocations,
+		addressReleases,
+		addressAllocationFailures,
+		claimBindingDuration,
+	)
+}
+
+// recordCapacity exports the capacity status of the IPPool.
+func (m *IPPoolManager) recordCapacity() {
+	capacity := m.IPPool.Status.Capacity
+	if capacity == nil {
+		return
+	}
+	labels := prometheus.Labels{labelNamespace: m.IPPool.Namespace, labelPool: m.IPPool.Name}
+	poolUsedAddresses.With(labels).Set(float64(capacity.Allocated + capacity.PreAllocated))
+
+	total, totalErr := strconv.Atoi(capacity.Total)
+	free, freeErr := strconv.Atoi(capacity.Free)
+	if totalErr != nil || freeErr != nil {
+		poolSize.Delete(labels)
+		poolFreeAddresses.Delete(labels)
+		return
+	}
+	poolSize.With(labels).Set(float64(total))
+	poolFreeAddresses.With(labels).Set(float64(free))
+}
+
+// deletePoolMetrics removes the series of the IPPool once it is deleted.
+func (m *IPPoolManager) deletePoolMetrics() {
+	labels := prometheus.Labels{labelNamespace: m.IPPool.Namespace, labelPool: m.IPPool.Name}
+	poolSize.Delete(labels)
+	poolUsedAddresses.Delete(labels)
+	poolFreeAddresses.Delete(labels)
+	addressAllocations.DeletePartialMatch(labels)
+	addressReleases.DeletePartialMatch(labels)
+	addressAllocationFailures.DeletePartialMatch(labels)
+}
+
+// recordAllocation records the allocation of an address to a claim, and the
+// time it took since the creation of the claim.
+func (m *IPPoolManager) recordAllocation(claimKind string, claimCreation metav1.Time) {
+	addressAllocations.WithLabelValues(m.IPPool.Namespace, m.IPPool.Name, claimKind).Inc()
+	if !claimCreation.IsZero() {
+		claimBindingDuration.WithLabelValues(claimKind).Observe(time.Since(claimCreation.Time).Seconds())
+	}
+}
+
+// recordRelease records the release of the address of a claim.
+func (m *IPPoolManager) recordRelease(claimKind string) {
+	addressReleases.WithLabelValues(m.IPPool.Namespace, m.IPPool.Name, claimKind).Inc()
+}
+
+// recordAllocationFailure records a failed allocation for a claim.
+func (m *IPPoolManager) recordAllocationFailure(claimKind string, reason AllocationFailureReason) {
+	addressAllocationFailures.WithLabelValues(m.IPPool.Namespace, m.IPPool.Name, claimKind, string(reason)).Inc()
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2026 The Metal3 Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ipam
+
+import (
+	"github.com/go-logr/logr"
+	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+)
+
+var _ = Describe("IPPool metrics", func() {
+	newPoolManager := func(name string, pools []ipamv1.Pool) *IPPoolManager {
+		ipPool := &ipamv1.IPPool{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "metrics",
+			},
+			Spec: ipamv1.IPPoolSpec{
+				Pools: pools,
+				PreAllocations: map[string]ipamv1.IPAddressStr{
+					"outofbounds": "10.0.0.1",
+				},
+			},
+			Status: ipamv1.IPPoolStatus{
+				Allocations: map[string]ipamv1.IPAddressStr{},
+			},
+		}
+		ipPoolMgr, err := NewIPPoolManager(nil, ipPool, logr.Discard())
+		Expect(err).NotTo(HaveOccurred())
+		return ipPoolMgr
+	}
+
+	type testCaseAllocationFailure struct {
+		claimName string
+		requested string
+		pools     []ipamv1.Pool
+		addresses map[ipamv1.IPAddressStr]string
+		reason    AllocationFailureReason
+	}
+
+	DescribeTable("Test allocation failures are counted by reason",
+		func(tc testCaseAllocationFailure) {
+			annotations := map[string]string{}
+			if tc.requested != "" {
+				annotations[IPAddressAnnotation] = tc.requested
+			}
+			objectMeta := metav1.ObjectMeta{
+				Name:        tc.claimName,
+				Namespace:   "metrics",
+				Annotations: annotations,
+			}
+
+			ipPoolMgr := newPoolManager("m3-"+string(tc.reason), tc.pools)
+			_, _, _, _, err := ipPoolMgr.allocateAddress(&ipamv1.IPClaim{ObjectMeta: objectMeta}, tc.addresses)
+			Expect(err).To(HaveOccurred())
+			Expect(testutil.ToFloat64(addressAllocationFailures.WithLabelValues(
+				"metrics", "m3-"+string(tc.reason), claimKindIPClaim, string(tc.reason),
+			))).To(Equal(1.0))
+
+			ipPoolMgr = newPoolManager("capi-"+string(tc.reason), tc.pools)
+			_, _, _, err = ipPoolMgr.capiAllocateAddress(&capipamv1.IPAddressClaim{ObjectMeta: objectMeta}, tc.addresses)
+			Expect(err).To(HaveOccurred())
+			Expect(testutil.ToFloat64(addressAllocationFailures.WithLabelValues(
+				"metrics", "capi-"+string(tc.reason), claimKindIPAddressClaim, string(tc.reason),
+			))).To(Equal(1.0))
+		},
+		Entry("Exhausted", testCaseAllocationFailure{
+			claimName: "abc",
+			pools: []ipamv1.Pool{
+				{
+					Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+					End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{"192.168.0.10": "bcd"},
+			reason:    AllocationFailureExhausted,
+		}),
+		Entry("Pre-allocation out of bounds", testCaseAllocationFailure{
+			claimName: "outofbounds",
+			pools: []ipamv1.Pool{
+				{
+					Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+					End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.20")),
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{},
+			reason:    AllocationFailurePreAllocationOutOfBounds,
+		}),
+		Entry("Requested IP unavailable", testCaseAllocationFailure{
+			claimName: "abc",
+			requested: "192.168.0.11",
+			pools: []ipamv1.Pool{
+				{
+					Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+					End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.20")),
+				},
+			},
+			addresses: map[ipamv1.IPAddressStr]string{"192.168.0.11": "bcd"},
+			reason:    AllocationFailureRequestedIPUnavailable,
+		}),
+		Entry("Conflict", testCaseAllocationFailure{
+			claimName: "outofbounds",
+			requested: "192.168.0.11",
+			addresses: map[ipamv1.IPAddressStr]string{},
+			reason:    AllocationFailureConflict,
+		}),
+	)
+
+	It("Exports the capacity of the pool and removes it on deletion", func() {
+		ipPoolMgr := newPoolManager("capacity", []ipamv1.Pool{
+			{
+				Start: (*ipamv1.IPAddressStr)(ptr.To("192.168.0.10")),
+				End:   (*ipamv1.IPAddressStr)(ptr.To("192.168.0.19")),
+			},
+		})
+		ipPoolMgr.updateCapacity(map[ipamv1.IPAddressStr]string{
+			"192.168.0.10": "abc",
+			"192.168.0.11": "",
+		})
+		ipPoolMgr.recordCapacity()
+		Expect(testutil.ToFloat64(poolSize.WithLabelValues("metrics", "capacity"))).To(Equal(10.0))
+		Expect(testutil.ToFloat64(poolUsedAddresses.WithLabelValues("metrics", "capacity"))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(poolFreeAddresses.WithLabelValues("metrics", "capacity"))).To(Equal(8.0))
+
+		ipPoolMgr.UnsetFinalizer()
+		Expect(poolSize.DeleteLabelValues("metrics", "capacity")).To(BeFalse())
+		Expect(poolUsedAddresses.DeleteLabelValues("metrics", "capacity")).To(BeFalse())
+		Expect(poolFreeAddresses.DeleteLabelValues("metrics", "capacity")).To(BeFalse())
+	})
+})
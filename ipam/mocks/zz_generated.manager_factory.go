@@ -60,6 +60,21 @@ func (m *MockManagerFactoryInterface) EXPECT() *MockManagerFactoryInterfaceMockR
 	return m.recorder
 }
 
+// NewGlobalIPPoolManager mocks base method.
+func (m *MockManagerFactoryInterface) NewGlobalIPPoolManager(arg0 *v1alpha1.GlobalIPPool, arg1 logr.Logger) (ipam.IPPoolManagerInterface, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NewGlobalIPPoolManager", arg0, arg1)
+	ret0, _ := ret[0].(ipam.IPPoolManagerInterface)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NewGlobalIPPoolManager indicates an expected call of NewGlobalIPPoolManager.
+func (mr *MockManagerFactoryInterfaceMockRecorder) NewGlobalIPPoolManager(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewGlobalIPPoolManager", reflect.TypeOf((*MockManagerFactoryInterface)(nil).NewGlobalIPPoolManager), arg0, arg1)
+}
+
 // NewIPPoolManager mocks base method.
 func (m *MockManagerFactoryInterface) NewIPPoolManager(arg0 *v1alpha1.IPPool, arg1 logr.Logger) (ipam.IPPoolManagerInterface, error) {
 	m.ctrl.T.Helper()
@@ -21,7 +21,7 @@ import (
 	"path/filepath"
 	"testing"
 
-	_ "github.com/go-logr/logr"
+	"github.com/go-logr/logr"
 	ipamv1 "github.com/metal3-io/ip-address-manager/api/v1alpha1"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -33,10 +33,12 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
 	capipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 )
 
@@ -116,5 +118,121 @@ func setupScheme() *runtime.Scheme {
 	if err := clusterv1.AddToScheme(s); err != nil {
 		panic(err)
 	}
+	if err := corev1.AddToScheme(s); err != nil {
+		panic(err)
+	}
 	return s
 }
+
+// newTestIPPool returns the IPPool abc of the namespace myns, with the pool
+// entries.
+func newTestIPPool(pools ...ipamv1.Pool) *ipamv1.IPPool {
+	return &ipamv1.IPPool{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "IPPool",
+			APIVersion: ipamv1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "abc",
+			Namespace: "myns",
+		},
+		Spec: ipamv1.IPPoolSpec{
+			NamePrefix: "abcpref",
+			Pools:      pools,
+		},
+	}
+}
+
+// newTestPool returns a pool entry from start to end, with the exclusions.
+func newTestPool(start, end string, exclusions ...ipamv1.IPExclusionStr) ipamv1.Pool {
+	return ipamv1.Pool{
+		Start:      (*ipamv1.IPAddressStr)(ptr.To(start)),
+		End:        (*ipamv1.IPAddressStr)(ptr.To(end)),
+		Exclusions: exclusions,
+	}
+}
+
+// newTestIPClaim returns an IPClaim of the namespace myns on the IPPool abc.
+func newTestIPClaim(name string) *ipamv1.IPClaim {
+	return &ipamv1.IPClaim{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "IPClaim",
+			APIVersion: ipamv1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "myns",
+		},
+		Spec: ipamv1.IPClaimSpec{
+			Pool: corev1.ObjectReference{
+				Name:      "abc",
+				Namespace: "myns",
+			},
+		},
+	}
+}
+
+// newTestRequestingIPClaim returns an IPClaim of the namespace myns on the
+// IPPool abc, requesting the address.
+func newTestRequestingIPClaim(name, requested string) *ipamv1.IPClaim {
+	claim := newTestIPClaim(name)
+	claim.Spec.RequestedAddress = (*ipamv1.IPAddressStr)(ptr.To(requested))
+	return claim
+}
+
+// newTestIPAddressClaim returns a CAPI IPAddressClaim of the namespace myns on
+// the IPPool abc.
+func newTestIPAddressClaim(name string) *capipamv1.IPAddressClaim {
+	return &capipamv1.IPAddressClaim{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "IPAddressClaim",
+			APIVersion: capipamv1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "myns",
+		},
+		Spec: capipamv1.IPAddressClaimSpec{
+			PoolRef: capipamv1.IPPoolReference{
+				Name:     "abc",
+				Kind:     "IPPool",
+				APIGroup: APIGroup,
+			},
+		},
+	}
+}
+
+// newTestClient returns a fake client holding the objects, as the client of
+// the controllers: the status of the claims and the IPPools is a
+// subresource, and the IPClaims are indexed by pool.
+func newTestClient(objects ...client.Object) client.Client {
+	return fakeclient.NewClientBuilder().WithScheme(setupScheme()).
+		WithStatusSubresource(&ipamv1.IPClaim{}, &capipamv1.IPAddressClaim{}, &ipamv1.IPPool{}).
+		WithObjects(objects...).
+		WithIndex(&ipamv1.IPClaim{}, IPClaimPoolField, IPClaimPools).
+		Build()
+}
+
+// updateTestAddresses reconciles the claims of the IPPool with a new manager,
+// as on each reconciliation of the IPPool.
+func updateTestAddresses(c client.Client, ipPool *ipamv1.IPPool) (int, error) {
+	ipPoolMgr, err := NewIPPoolManager(c, ipPool, logr.Discard())
+	if err != nil {
+		return 0, err
+	}
+	return ipPoolMgr.UpdateAddresses(context.TODO())
+}
+
+// getTestIPClaim returns the IPClaim of the namespace myns.
+func getTestIPClaim(c client.Client, name string) *ipamv1.IPClaim {
+	claim := &ipamv1.IPClaim{}
+	Expect(c.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "myns"}, claim)).To(Succeed())
+	return claim
+}
+
+// getTestIPAddress returns the IPAddress of the namespace myns.
+func getTestIPAddress(c client.Client, name string) *ipamv1.IPAddress {
+	address := &ipamv1.IPAddress{}
+	Expect(c.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "myns"}, address)).To(Succeed())
+	return address
+}
@@ -17,8 +17,11 @@ limitations under the License.
 package ipam
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"maps"
+	"slices"
 	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -30,6 +33,24 @@ import (
 
 var errNotFound *NotFoundError
 
+// dropEarliest removes the entries with the earliest times from the map, so
+// that it holds at most limit entries. It returns the number of entries
+// removed.
+func dropEarliest[K ~string](times map[K]metav1.Time, limit int) int {
+	if len(times) <= limit {
+		return 0
+	}
+	keys := slices.Collect(maps.Keys(times))
+	slices.SortFunc(keys, func(a, b K) int {
+		return cmp.Or(times[a].Compare(times[b].Time), cmp.Compare(a, b))
+	})
+	dropped := keys[:len(keys)-limit]
+	for _, key := range dropped {
+		delete(times, key)
+	}
+	return len(dropped)
+}
+
 // Filter filters a list for a string.
 func Filter(list []string, strToFilter string) (newList []string) {
 	for _, item := range list {
@@ -145,6 +145,7 @@ func main() {
 	ctx := ctrl.SetupSignalHandler()
 
 	setupChecks(mgr)
+	setupIndexes(ctx, mgr)
 	setupReconcilers(ctx, mgr)
 	setupWebhooks(mgr)
 
@@ -206,40 +207,64 @@ func setupChecks(mgr ctrl.Manager) {
 	}
 }
 
+func setupIndexes(ctx context.Context, mgr ctrl.Manager) {
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &ipamv1.IPClaim{}, ipam.IPClaimPoolField, ipam.IPClaimPools); err != nil {
+		setupLog.Error(err, "unable to create index", "index", ipam.IPClaimPoolField)
+		os.Exit(1)
+	}
+	if err := mgr.GetFieldIndexer().IndexField(ctx, &ipamv1.IPAddress{}, webhooks.IPAddressPoolField, webhooks.IPAddressPool); err != nil {
+		setupLog.Error(err, "unable to create index", "index", webhooks.IPAddressPoolField)
+		os.Exit(1)
+	}
+}
+
 func setupReconcilers(ctx context.Context, mgr ctrl.Manager) {
+	recorder := mgr.GetEventRecorderFor("ippool-controller") //nolint:staticcheck // events are emitted on the core/v1 events API
+	managerFactory := ipam.NewManagerFactory(mgr.GetClient(), recorder)
 	if err := (&controllers.IPPoolReconciler{
 		Client:           mgr.GetClient(),
-		ManagerFactory:   ipam.NewManagerFactory(mgr.GetClient()),
-		Log:              ctrl.Log.WithName("controllers").WithName("IPPoolForIPClaim"),
+		ManagerFactory:   managerFactory,
+		Log:              ctrl.Log.WithName("controllers").WithName("IPPool"),
 		WatchFilterValue: watchFilterValue,
-	}).SetupWithManagerForIPClaim(ctx, mgr, concurrency(ippoolConcurrency)); err != nil {
+	}).SetupWithManager(ctx, mgr, concurrency(ippoolConcurrency)); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "IPPoolReconciler")
 		os.Exit(1)
 	}
 
-	if err := (&controllers.IPPoolReconciler{
+	if err := (&controllers.GlobalIPPoolReconciler{
 		Client:           mgr.GetClient(),
-		ManagerFactory:   ipam.NewManagerFactory(mgr.GetClient()),
-		Log:              ctrl.Log.WithName("controllers").WithName("IPPoolForIPAddressClaim"),
+		ManagerFactory:   managerFactory,
+		Log:              ctrl.Log.WithName("controllers").WithName("GlobalIPPool"),
 		WatchFilterValue: watchFilterValue,
-	}).SetupWithManagerForIPAddressClaim(ctx, mgr, concurrency(ippoolConcurrency)); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "IPPoolReconcilerForCAPI")
+	}).SetupWithManager(ctx, mgr, concurrency(ippoolConcurrency)); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GlobalIPPoolReconciler")
 		os.Exit(1)
 	}
 }
 
 func setupWebhooks(mgr ctrl.Manager) {
-	if err := (&webhooks.IPPool{}).SetupWebhookWithManager(mgr); err != nil {
+	if err := (&webhooks.IPPool{
+		Client: mgr.GetClient(),
+	}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "IPPool")
 		os.Exit(1)
 	}
 
+	if err := (&webhooks.GlobalIPPool{
+		IPPool: webhooks.IPPool{Client: mgr.GetClient()},
+	}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "GlobalIPPool")
+		os.Exit(1)
+	}
+
 	if err := (&webhooks.IPAddress{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "IPAddress")
 		os.Exit(1)
 	}
 
-	if err := (&webhooks.IPClaim{}).SetupWebhookWithManager(mgr); err != nil {
+	if err := (&webhooks.IPClaim{
+		Client: mgr.GetClient(),
+	}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "IPClaim")
 		os.Exit(1)
 	}